@@ -53,7 +53,7 @@ func (cache *MongodbCache) Set(ctx context.Context, key string, value any, expir
 	}
 
 	inputCache := &entity.Cache{}
-	inputCache.Expiration = primitive.DateTime(expiration)
+	inputCache.Expiration = primitive.NewDateTimeFromTime(time.Now().Add(expiration))
 	inputCache.Key = []byte(fmt.Sprintf("C:%s", key))
 	inputCache.Type = family
 	valueMarshal, err := json.Marshal(value)
@@ -86,7 +86,7 @@ func (cache *MongodbCache) setByte(ctx context.Context, key string, value []byte
 	}
 
 	inputCache := &entity.Cache{}
-	inputCache.Expiration = primitive.DateTime(expiration)
+	inputCache.Expiration = primitive.NewDateTimeFromTime(time.Now().Add(expiration))
 	inputCache.Key = []byte(fmt.Sprintf("C:%s", key))
 	inputCache.Type = family
 	inputCache.Value = value
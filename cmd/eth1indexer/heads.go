@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/db"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/types"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/sirupsen/logrus"
+)
+
+// headFollower indexes the heads announced by the node as soon as they arrive. A head extending the last indexed block
+// is indexed right away, the chain is only walked back by HandleChainReorgs if the parent hash does not match.
+type headFollower struct {
+	bt     interfaces.Database
+	client rpc.Eth1Client
+	depth  int
+	// index stores the blocks start to end and indexes their data
+	index func(start, end int64) error
+	// transforms are run by index, their cursors bound the last indexed block
+	transforms []*types.Eth1Transform
+	// afterHead is called after every head which has been handled without an error, it must not block the next head
+	afterHead func()
+
+	last     uint64
+	lastHash []byte
+}
+
+// loadTip sets the last indexed block to the highest block of the blocks table whose data has been processed by all
+// transforms with a cursor. Blocks which have been stored without their data are indexed again with the next head.
+func (f *headFollower) loadTip() error {
+	last, err := f.bt.GetLastBlockInBlocksTable()
+	if err != nil {
+		return err
+	}
+	cursors, err := transformCursors(f.bt, f.transforms)
+	if err != nil {
+		return err
+	}
+	for _, cursor := range cursors {
+		if cursor < uint64(last) {
+			last = int(cursor)
+		}
+	}
+
+	block, err := f.bt.GetBlockFromBlocksTable(uint64(last))
+	if errors.Is(err, db.ErrBlockNotFound) {
+		f.last, f.lastHash = 0, nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.last, f.lastHash = block.Number, block.Hash
+	return nil
+}
+
+// handleHead indexes the blocks from the last indexed block up to the head
+func (f *headFollower) handleHead(head *rpc.Head) error {
+	if f.lastHash != nil {
+		var canonical bool
+		if head.Number == f.last+1 {
+			canonical = bytes.Equal(head.ParentHash, f.lastHash)
+		} else {
+			// the head skipped blocks or does not extend the last indexed block, check that it is still canonical
+			hash, err := f.client.GetBlockHash(f.last)
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return err
+			}
+			canonical = err == nil && bytes.Equal(hash, f.lastHash)
+		}
+
+		if !canonical {
+			logrus.Warnf("head %v (0x%x) does not extend the last indexed block %v (0x%x), checking for chain reorgs", head.Number, head.Hash, f.last, f.lastHash)
+			err := HandleChainReorgs(f.bt, f.client, f.depth, f.index)
+			if err != nil {
+				return fmt.Errorf("error handling chain reorgs: %w", err)
+			}
+			return f.loadTip()
+		}
+		if head.Number <= f.last {
+			return nil
+		}
+	}
+
+	start := f.last + 1
+	if f.lastHash == nil {
+		start = head.Number
+	}
+	startTs := time.Now()
+	err := f.index(int64(start), int64(head.Number))
+	if err != nil {
+		return fmt.Errorf("error indexing blocks %v to %v: %w", start, head.Number, err)
+	}
+	err = f.bt.SaveCheckpoint(blocksCheckpoint, head.Number)
+	if err != nil {
+		return fmt.Errorf("error saving checkpoint %v at block %v: %w", blocksCheckpoint, head.Number, err)
+	}
+
+	block, err := f.bt.GetBlockFromBlocksTable(head.Number)
+	if err != nil {
+		return err
+	}
+	f.last, f.lastHash = block.Number, block.Hash
+	logrus.Infof("indexed head %v (0x%x) in %v", block.Number, block.Hash, time.Since(startTs))
+	return nil
+}
+
+// follow handles the heads until the subscription ends
+func (f *headFollower) follow(heads <-chan *rpc.Head, errs <-chan error) error {
+	for {
+		select {
+		case head := <-heads:
+			err := f.handleHead(head)
+			if err != nil {
+				// the blocks are indexed again with the next head
+				logrus.WithError(err).Errorf("error handling head %v", head.Number)
+				if err := f.loadTip(); err != nil {
+					logrus.WithError(err).Errorf("error retrieving the last indexed block")
+				}
+				continue
+			}
+			if f.afterHead != nil {
+				f.afterHead()
+			}
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// followHeads follows the new heads of the websocket endpoint. Blocks produced while the subscription was down are
+// indexed by poll, which also keeps the index up to date every 14 seconds as long as no subscription can be established.
+func followHeads(endpoint string, f *headFollower, poll func() error) {
+	for {
+		heads := make(chan *rpc.Head, 16)
+		sub, err := rpc.SubscribeNewHeads(context.Background(), endpoint, heads)
+		if err != nil {
+			logrus.WithError(err).Errorf("error subscribing to new heads at %v, polling the node", endpoint)
+			if err := poll(); err != nil {
+				logrus.WithError(err).Errorf("error during index run")
+			}
+			time.Sleep(time.Second * 14)
+			continue
+		}
+
+		if err := poll(); err != nil {
+			logrus.WithError(err).Errorf("error during index run")
+		}
+		if err := f.loadTip(); err != nil {
+			logrus.WithError(err).Errorf("error retrieving the last indexed block")
+		}
+		logrus.Infof("following new heads at %v from block %v", endpoint, f.last)
+
+		err = f.follow(heads, sub.Err())
+		sub.Unsubscribe()
+		logrus.WithError(err).Errorf("new heads subscription at %v ended", endpoint)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/types"
+)
+
+func TestHeadFollower(t *testing.T) {
+	bt := testEmbedded(t, "1")
+
+	// block returns the block at number of the branch whose parent is on parentBranch
+	block := func(number uint64, branch, parentBranch string) *types.Eth1Block {
+		b := testutil.FixtureBlock(number, branch)
+		b.ParentHash = testutil.FixtureHash(number-1, parentBranch)
+		return b
+	}
+	head := func(b *types.Eth1Block) *rpc.Head {
+		return &rpc.Head{Number: b.Number, Hash: b.Hash, ParentHash: b.ParentHash}
+	}
+
+	client := rpc.NewFakeEth1Client()
+	for i := uint64(0); i <= 3; i++ {
+		client.SetBlocks(block(i, "a", "a"))
+	}
+	indexed := [2]int64{}
+	index := func(start, end int64) error {
+		indexed = [2]int64{start, end}
+		return IndexFromNode(bt, client, start, end, 1, 10, "")
+	}
+	if err := index(0, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &headFollower{bt: bt, client: client, depth: 10, index: index}
+	if err := f.loadTip(); err != nil {
+		t.Fatal(err)
+	}
+	if f.last != 3 {
+		t.Fatalf("tip = %v, want 3", f.last)
+	}
+
+	tests := []struct {
+		name     string
+		chain    []*types.Eth1Block
+		head     *types.Eth1Block
+		wantTip  uint64
+		wantHash map[uint64]string
+	}{
+		{
+			name:     "head extends the tip",
+			chain:    []*types.Eth1Block{block(4, "a", "a")},
+			head:     block(4, "a", "a"),
+			wantTip:  4,
+			wantHash: map[uint64]string{4: "a"},
+		},
+		{
+			name:     "head skips a block",
+			chain:    []*types.Eth1Block{block(5, "a", "a"), block(6, "a", "a")},
+			head:     block(6, "a", "a"),
+			wantTip:  6,
+			wantHash: map[uint64]string{5: "a", 6: "a"},
+		},
+		{
+			name:     "parent hash mismatch",
+			chain:    []*types.Eth1Block{block(5, "b", "a"), block(6, "b", "b"), block(7, "b", "b")},
+			head:     block(7, "b", "b"),
+			wantTip:  7,
+			wantHash: map[uint64]string{4: "a", 5: "b", 6: "b", 7: "b"},
+		},
+		{
+			name:     "announced twice",
+			head:     block(7, "b", "b"),
+			wantTip:  7,
+			wantHash: map[uint64]string{7: "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.SetBlocks(tt.chain...)
+			if err := f.handleHead(head(tt.head)); err != nil {
+				t.Fatal(err)
+			}
+			if f.last != tt.wantTip {
+				t.Errorf("tip = %v, want %v", f.last, tt.wantTip)
+			}
+			for number, branch := range tt.wantHash {
+				stored, err := bt.GetBlockFromBlocksTable(number)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(stored.Hash, testutil.FixtureHash(number, branch)) {
+					t.Errorf("block %v: got hash %x, want %x", number, stored.Hash, testutil.FixtureHash(number, branch))
+				}
+			}
+		})
+	}
+
+	// the data of blocks 6 and 7 hasn't been processed by a transform, the next head indexes them again
+	tx := &types.Eth1Transform{Name: "tx", Version: 1}
+	if err := bt.SaveTransformCursor(tx.Name, tx.Version, 5); err != nil {
+		t.Fatal(err)
+	}
+	f.transforms = []*types.Eth1Transform{tx}
+	if err := f.loadTip(); err != nil {
+		t.Fatal(err)
+	}
+	if f.last != 5 {
+		t.Fatalf("tip = %v with the tx cursor at 5, want 5", f.last)
+	}
+	client.SetBlocks(block(8, "b", "b"))
+	if err := f.handleHead(head(block(8, "b", "b"))); err != nil {
+		t.Fatal(err)
+	}
+	if f.last != 8 || indexed != [2]int64{6, 8} {
+		t.Errorf("tip = %v after indexing blocks %v, want 8 after indexing blocks 6 to 8", f.last, indexed)
+	}
+}
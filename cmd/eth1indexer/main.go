@@ -3,8 +3,8 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -16,7 +16,11 @@ import (
 	"time"
 
 	"github.com/Prajjawalk/zond-indexer/db"
+	"github.com/Prajjawalk/zond-indexer/entity"
 	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/handlers"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/metrics"
 	"github.com/Prajjawalk/zond-indexer/rpc"
 	"github.com/Prajjawalk/zond-indexer/services"
 	"github.com/Prajjawalk/zond-indexer/types"
@@ -26,6 +30,7 @@ import (
 
 	"github.com/coocood/freecache"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
@@ -35,12 +40,17 @@ import (
 )
 
 func main() {
-	erigonEndpoint := flag.String("erigon", "", "Erigon archive node enpoint")
+	erigonEndpoint := flag.String("erigon", "", "Erigon archive node enpoint, several http endpoints are separated by commas and take the options weight, rps and name, e.g. http://node-a:8545|weight=3|rps=50,http://node-b:8545")
+	rpcRetries := flag.Int("rpc.retries", rpc.DefaultPoolConfig().MaxRetries, "Number of retries of a failed request to the erigon nodes")
+	rpcMaxHeadLag := flag.Uint64("rpc.headlag", rpc.DefaultPoolConfig().MaxHeadLag, "Number of blocks an erigon node may lag behind the others before it is avoided")
 	block := flag.Int64("block", 0, "Index a specific block")
 
+	headsEndpoint := flag.String("erigon.ws", "", "Websocket endpoint of the erigon node, if set new heads are indexed as soon as they are announced instead of polling the node every 14 seconds")
+
 	reorgDepth := flag.Int("reorg.depth", 20, "Lookback to check and handle chain reorgs")
 
 	concurrencyBlocks := flag.Int64("blocks.concurrency", 30, "Concurrency to use when indexing blocks from erigon")
+	batchBlocks := flag.Int("blocks.batch", 100, "Number of blocks to write to the blocks table in a single batch")
 	startBlocks := flag.Int64("blocks.start", 0, "Block to start indexing")
 	endBlocks := flag.Int64("blocks.end", 0, "Block to finish indexing")
 	offsetBlocks := flag.Int64("blocks.offset", 100, "Blocks offset")
@@ -53,10 +63,23 @@ func main() {
 	offsetData := flag.Int64("data.offset", 1000, "Data offset")
 	checkDataGaps := flag.Bool("data.gaps", false, "Check for gaps in the data table")
 	checkDataGapsLookback := flag.Int("data.gaps.lookback", 1000000, "Lookback for gaps check of the blocks table")
+	transformNames := flag.String("transforms", "", "Comma separated names of the transforms to run, e.g. erc1155 to backfill a single transform with -data.start and -data.end, all transforms are run if empty")
 
 	enableBalanceUpdater := flag.Bool("balances.enabled", false, "Enable balance update process")
 	enableFullBalanceUpdater := flag.Bool("balances.full.enabled", false, "Enable full balance update process")
 	balanceUpdaterBatchSize := flag.Int("balances.batch", 1000, "Batch size for balance updates")
+	checkBalances := flag.Bool("balances.check", true, "Compare the balances retrieved from the node with the balances derived from the indexed balance deltas")
+	balanceChecker := flag.String("balances.checker", "", "Z or 0x prefixed address of a deployed balance checker contract, if empty balances are retrieved with eth_getBalance and balanceOf calls")
+
+	enableTokenDiscovery := flag.Bool("tokens.discovery", true, "Retrieve the metadata of newly seen token contracts and add them to the token registry")
+	tokenDiscoveryBatchSize := flag.Int("tokens.batch", 100, "Batch size for the token metadata retrieval")
+	enableNFTMetadata := flag.Bool("nfts.metadata", true, "Retrieve the metadata uris of newly seen nft token ids")
+	nftMetadataBatchSize := flag.Int("nfts.batch", 100, "Batch size for the nft metadata retrieval")
+
+	verifySignatures := flag.Bool("signatures.verify", false, "Verify the dilithium signature of every indexed transaction")
+
+	recordFixtures := flag.String("rpc.record", "", "Record the responses of the erigon node as test fixtures to this directory")
+	replayFixtures := flag.String("rpc.replay", "", "Answer all requests to the erigon node with the test fixtures in this directory")
 
 	tokenPriceExport := flag.Bool("token.price.enabled", false, "Enable token export process")
 	tokenPriceExportList := flag.String("token.price.list", "", "Tokenlist path to use for the token price export")
@@ -65,6 +88,10 @@ func main() {
 	mongodbConnectionString := flag.String("mongodb.connectionstring", "", "Mongodb project")
 	mongodbInstance := flag.String("mongodb.instance", "zondDb", "Mongodb instance")
 
+	storage := flag.String("storage", "mongodb", "Storage backend to use, mongodb or embedded")
+	dataDir := flag.String("datadir", "data", "Data directory of the embedded storage backend")
+	apiAddress := flag.String("api.address", "", "Serve the execution layer api on this address from the indexer process, the data directory of the embedded storage backend can't be opened by the explorer while the indexer runs")
+
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 
 	configPath := flag.String("config", "", "Path to the config file, if empty string defaults will be used")
@@ -92,31 +119,71 @@ func main() {
 		}()
 	}
 
-	db.MustInitDB(&types.DatabaseConfig{
-		Username: cfg.WriterDatabase.Username,
-		Password: cfg.WriterDatabase.Password,
-		Name:     cfg.WriterDatabase.Name,
-		Host:     cfg.WriterDatabase.Host,
-		Port:     cfg.WriterDatabase.Port,
-	}, &types.DatabaseConfig{
-		Username: cfg.ReaderDatabase.Username,
-		Password: cfg.ReaderDatabase.Password,
-		Name:     cfg.ReaderDatabase.Name,
-		Host:     cfg.ReaderDatabase.Host,
-		Port:     cfg.ReaderDatabase.Port,
-	})
-	defer db.ReaderDb.Close()
-	defer db.WriterDb.Close()
+	if *storage == "embedded" {
+		// the embedded backend runs without postgres, which is only needed to report the service status
+		utils.Config.ReportServiceStatus = false
+	} else {
+		db.MustInitDB(&types.DatabaseConfig{
+			Username: cfg.WriterDatabase.Username,
+			Password: cfg.WriterDatabase.Password,
+			Name:     cfg.WriterDatabase.Name,
+			Host:     cfg.WriterDatabase.Host,
+			Port:     cfg.WriterDatabase.Port,
+		}, &types.DatabaseConfig{
+			Username: cfg.ReaderDatabase.Username,
+			Password: cfg.ReaderDatabase.Password,
+			Name:     cfg.ReaderDatabase.Name,
+			Host:     cfg.ReaderDatabase.Host,
+			Port:     cfg.ReaderDatabase.Port,
+		})
+		defer db.ReaderDb.Close()
+		defer db.WriterDb.Close()
+	}
 
-	if erigonEndpoint == nil || *erigonEndpoint == "" {
+	var client *rpc.ErigonClient
+	switch {
+	case *replayFixtures != "":
+		logrus.Infof("replaying erigon node responses from %v", *replayFixtures)
+		client, err = rpc.NewReplayingErigonClient(*replayFixtures)
+	case erigonEndpoint == nil || *erigonEndpoint == "":
 		utils.LogFatal(nil, "no erigon node url provided", 0)
+	case strings.HasPrefix(*erigonEndpoint, "http://") || strings.HasPrefix(*erigonEndpoint, "https://"):
+		var endpoints []rpc.PoolEndpoint
+		endpoints, err = rpc.ParsePoolEndpoints(*erigonEndpoint)
+		if err != nil {
+			break
+		}
+		poolConfig := rpc.DefaultPoolConfig()
+		poolConfig.MaxRetries = *rpcRetries
+		poolConfig.MaxHeadLag = *rpcMaxHeadLag
+
+		var transport http.RoundTripper
+		if *recordFixtures != "" {
+			logrus.Infof("recording erigon node responses to %v", *recordFixtures)
+			transport, err = rpc.NewRecordingTransport(*recordFixtures, nil)
+			if err != nil {
+				break
+			}
+		}
+		client, err = rpc.NewErigonPoolClient(endpoints, poolConfig, transport)
+	default:
+		if *recordFixtures != "" {
+			utils.LogFatal(nil, "recording fixtures requires a http erigon endpoint", 0)
+		}
+		logrus.Infof("using erigon node at %v", *erigonEndpoint)
+		client, err = rpc.NewErigonClient(*erigonEndpoint)
 	}
-
-	logrus.Infof("using erigon node at %v", *erigonEndpoint)
-	client, err := rpc.NewErigonClient(*erigonEndpoint)
 	if err != nil {
 		utils.LogFatal(err, "erigon client creation error", 0)
 	}
+	client.SetVerifySignatures(*verifySignatures)
+	if *balanceChecker != "" {
+		err = client.SetBalanceChecker(*balanceChecker)
+		if err != nil {
+			utils.LogFatal(err, "balance checker contract error", 0)
+		}
+		logrus.Infof("retrieving balances through the balance checker contract at %v", *balanceChecker)
+	}
 
 	chainId := strconv.FormatUint(utils.Config.Chain.Config.DepositChainID, 10)
 
@@ -131,12 +198,33 @@ func main() {
 		logrus.Fatalf("node chain id mismatch, wanted %v got %v", chainId, nodeChainId.String())
 	}
 
-	bt, err := db.InitMongodb(*mongodbConnectionString, *mongodbInstance, chainId)
-	if err != nil {
-		logrus.Fatalf("error connecting to bigtable: %v", err)
+	var bt interfaces.Database
+	switch *storage {
+	case "mongodb":
+		bt, err = db.InitMongodb(*mongodbConnectionString, *mongodbInstance, chainId)
+		if err != nil {
+			logrus.Fatalf("error connecting to bigtable: %v", err)
+		}
+	case "embedded":
+		bt, err = db.InitEmbedded(*dataDir, chainId)
+		if err != nil {
+			logrus.Fatalf("error opening embedded storage: %v", err)
+		}
+		logrus.Infof("using embedded storage in %v", *dataDir)
+	default:
+		logrus.Fatalf("invalid storage backend %v, supported backends are mongodb and embedded", *storage)
 	}
 	defer bt.Close()
 
+	if utils.Config.Metrics.Enabled {
+		go func(addr string) {
+			logrus.Infof("serving metrics on %v", addr)
+			if err := metrics.Serve(addr); err != nil {
+				logrus.WithError(err).Fatal("error serving metrics")
+			}
+		}(utils.Config.Metrics.Address)
+	}
+
 	if *tokenPriceExport {
 		go func() {
 			for {
@@ -155,7 +243,7 @@ func main() {
 	// }
 	// return
 	if *enableFullBalanceUpdater {
-		ProcessMetadataUpdates(bt, client, balanceUpdaterPrefix, *balanceUpdaterBatchSize, -1)
+		ProcessMetadataUpdates(bt, client, balanceUpdaterPrefix, *balanceUpdaterBatchSize, -1, *checkBalances)
 		return
 		// currentKey := balanceUpdaterPrefix // "1:00028ebf7d36c5779c1deddf3ba72761fd46c8aa"
 		// for {
@@ -189,20 +277,47 @@ func main() {
 		// }
 	}
 
-	transforms := make([]func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error), 0)
-	transforms = append(transforms,
-		bt.TransformBlock,
-		bt.TransformTx,
-		bt.TransformItx,
-		bt.TransformERC20,
-		bt.TransformERC721,
-		bt.TransformERC1155,
-		bt.TransformWithdrawals)
+	transforms := bt.DataTransforms()
+
+	contractEvents, err := db.NewContractEventIndexes(utils.Config.Indexer.ContractEvents)
+	if err != nil {
+		utils.LogFatal(err, "contract events config error", 0)
+	}
+	err = bt.ApplyContractEventSchema(contractEvents)
+	if err != nil {
+		utils.LogFatal(err, "contract events schema error", 0)
+	}
+	for _, index := range contractEvents {
+		logrus.Infof("indexing %v events of %v into %v", len(index.Events), index.Name, index.Collection)
+		transforms = append(transforms, &types.Eth1Transform{Name: index.Collection, Version: index.Version, Transform: bt.TransformContractEvents(index)})
+		handlers.ContractEventIndexes[index.Name] = index
+	}
+
+	if *apiAddress != "" {
+		rpc.CurrentErigonClient = client
+		router := mux.NewRouter()
+		handlers.RegisterExecutionRoutes(router.PathPrefix("/api/v1").Subrouter())
+		go func() {
+			logrus.Infof("serving the execution layer api on %v", *apiAddress)
+			if err := http.ListenAndServe(*apiAddress, router); err != nil {
+				logrus.WithError(err).Fatal("error serving the execution layer api")
+			}
+		}()
+	}
+
+	err = seedTransformCursors(bt, transforms)
+	if err != nil {
+		utils.LogFatal(err, "error seeding transform cursors", 0)
+	}
+	transforms, err = selectTransforms(transforms, *transformNames)
+	if err != nil {
+		utils.LogFatal(err, "transforms flag error", 0)
+	}
 
 	cache := freecache.NewCache(100 * 1024 * 1024) // 100 MB limit
 
 	if *block != 0 {
-		err = IndexFromNode(bt, client, *block, *block, *concurrencyBlocks)
+		err = IndexFromNode(bt, client, *block, *block, *concurrencyBlocks, *batchBlocks, "")
 		if err != nil {
 			logrus.WithError(err).Fatalf("error indexing from node, start: %v end: %v concurrency: %v", *block, *block, *concurrencyBlocks)
 		}
@@ -227,15 +342,50 @@ func main() {
 	}
 
 	if *endBlocks != 0 && *startBlocks < *endBlocks {
-		err = IndexFromNode(bt, client, *startBlocks, *endBlocks, *concurrencyBlocks)
+		// resume an interrupted backfill of the same range from its checkpoint
+		checkpoint := fmt.Sprintf("blocks:%d-%d", *startBlocks, *endBlocks)
+		start := *startBlocks
+		lastBlock, err := bt.GetCheckpoint(checkpoint)
+		if err == nil && int64(lastBlock) >= start {
+			logrus.Infof("resuming backfill of blocks %v to %v from checkpoint at block %v", *startBlocks, *endBlocks, lastBlock)
+			start = int64(lastBlock) + 1
+		} else if err != nil && err != db.ErrCheckpointNotFound {
+			logrus.WithError(err).Fatalf("error retrieving checkpoint %v", checkpoint)
+		}
+		if start > *endBlocks {
+			logrus.Infof("blocks %v to %v have already been indexed", *startBlocks, *endBlocks)
+			return
+		}
+
+		err = IndexFromNode(bt, client, start, *endBlocks, *concurrencyBlocks, *batchBlocks, checkpoint)
 		if err != nil {
-			logrus.WithError(err).Fatalf("error indexing from node, start: %v end: %v concurrency: %v", *startBlocks, *endBlocks, *concurrencyBlocks)
+			logrus.WithError(err).Fatalf("error indexing from node, start: %v end: %v concurrency: %v", start, *endBlocks, *concurrencyBlocks)
 		}
 		return
 	}
 
 	if *endData != 0 && *startData < *endData {
-		err = IndexFromBigtable(bt, int64(*startData), int64(*endData), transforms, *concurrencyData, cache)
+		// explicitly selected transforms without a cursor are backfilled from the start of the range
+		if *transformNames != "" {
+			err = startTransformCursors(bt, transforms, *startData)
+			if err != nil {
+				logrus.WithError(err).Fatalf("error starting transform cursors")
+			}
+		}
+		// resume an interrupted backfill of the same range from the transform cursors
+		start, err := resumeTransforms(bt, transforms, *startData, *endData)
+		if err != nil {
+			logrus.WithError(err).Fatalf("error retrieving transform cursors")
+		}
+		if start > *endData {
+			logrus.Infof("blocks %v to %v have already been transformed", *startData, *endData)
+			return
+		}
+		if start > *startData {
+			logrus.Infof("resuming transform of blocks %v to %v from the transform cursors at block %v", *startData, *endData, start-1)
+		}
+
+		err = IndexFromBigtable(bt, start, *endData, transforms, *concurrencyData, cache)
 		if err != nil {
 			logrus.WithError(err).Fatalf("error indexing from mongodb")
 		}
@@ -243,29 +393,36 @@ func main() {
 		return
 	}
 
-	for ; ; time.Sleep(time.Second * 14) {
-		err := HandleChainReorgs(bt, client, *reorgDepth)
+	reindex := func(start, end int64) error {
+		err := IndexFromNode(bt, client, start, end, *concurrencyBlocks, *batchBlocks, "")
 		if err != nil {
-			logrus.Errorf("error handling chain reorgs: %v", err)
-			continue
+			return err
+		}
+		// balance updates of the canonical blocks must not be skipped as already marked
+		cache.Clear()
+		return IndexFromBigtable(bt, start, end, transforms, *concurrencyData, cache)
+	}
+
+	// indexRun checks the last blocks for reorgs and indexes all blocks missing in the blocks and data tables
+	indexRun := func() error {
+		err := HandleChainReorgs(bt, client, *reorgDepth, reindex)
+		if err != nil {
+			return fmt.Errorf("error handling chain reorgs: %w", err)
 		}
 
 		lastBlockFromNode, err := client.GetLatestEth1BlockNumber()
 		if err != nil {
-			logrus.Errorf("error retrieving latest eth block number: %v", err)
-			continue
+			return fmt.Errorf("error retrieving latest eth block number: %w", err)
 		}
 
-		lastBlockFromBlocksTable, err := bt.GetLastBlockInBlocksTable()
+		lastBlockFromBlocksTable, err := getLastContiguousBlock(bt)
 		if err != nil {
-			logrus.Errorf("error retrieving last blocks from blocks table: %v", err)
-			continue
+			return fmt.Errorf("error retrieving last blocks from blocks table: %w", err)
 		}
 
 		lastBlockFromDataTable, err := bt.GetLastBlockInDataTable()
 		if err != nil {
-			logrus.Errorf("error retrieving last blocks from data table: %v", err)
-			continue
+			return fmt.Errorf("error retrieving last blocks from data table: %w", err)
 		}
 
 		logrus.WithFields(
@@ -279,10 +436,9 @@ func main() {
 		if lastBlockFromBlocksTable < int(lastBlockFromNode) {
 			logrus.Infof("missing blocks %v to %v in blocks table, indexing ...", lastBlockFromBlocksTable, lastBlockFromNode)
 
-			err = IndexFromNode(bt, client, int64(lastBlockFromBlocksTable)-*offsetBlocks, int64(lastBlockFromNode), *concurrencyBlocks)
+			err = IndexFromNode(bt, client, int64(lastBlockFromBlocksTable)-*offsetBlocks, int64(lastBlockFromNode), *concurrencyBlocks, *batchBlocks, blocksCheckpoint)
 			if err != nil {
-				logrus.WithError(err).Errorf("error indexing from node, start: %v end: %v concurrency: %v", int64(lastBlockFromBlocksTable)-*offsetBlocks, int64(lastBlockFromNode), *concurrencyBlocks)
-				continue
+				return fmt.Errorf("error indexing from node, start: %v end: %v concurrency: %v: %w", int64(lastBlockFromBlocksTable)-*offsetBlocks, int64(lastBlockFromNode), *concurrencyBlocks, err)
 			}
 		}
 
@@ -291,26 +447,84 @@ func main() {
 
 			logrus.Infof("missing blocks %v to %v in data table, indexing ...", lastBlockFromDataTable, lastBlockFromNode)
 			err = IndexFromBigtable(bt, int64(lastBlockFromDataTable)-*offsetData, int64(lastBlockFromNode), transforms, *concurrencyData, cache)
+			cache.Clear()
 			if err != nil {
-				logrus.WithError(err).Errorf("error indexing from mongodb")
-				cache.Clear()
-				continue
+				return fmt.Errorf("error indexing from mongodb: %w", err)
 			}
-			cache.Clear()
-		}
-
-		if *enableBalanceUpdater {
-			ProcessMetadataUpdates(bt, client, balanceUpdaterPrefix, *balanceUpdaterBatchSize, 10)
 		}
 
 		logrus.Infof("index run completed")
 		services.ReportStatus("eth1indexer", "Running", nil)
+		return nil
+	}
+
+	// the updaters retrieve balances and metadata from the node in their own loop, so neither the heads nor the index
+	// runs wait for them
+	if *enableBalanceUpdater || *enableTokenDiscovery || *enableNFTMetadata {
+		go func() {
+			for ; ; time.Sleep(time.Second * 14) {
+				if *enableBalanceUpdater {
+					ProcessMetadataUpdates(bt, client, balanceUpdaterPrefix, *balanceUpdaterBatchSize, 10, *checkBalances)
+				}
+				if *enableTokenDiscovery {
+					ProcessTokenUpdates(bt, client, *tokenDiscoveryBatchSize, 10)
+				}
+				if *enableNFTMetadata {
+					ProcessNFTMetadataUpdates(bt, client, *nftMetadataBatchSize, 10)
+				}
+			}
+		}()
+	}
+
+	if *headsEndpoint != "" {
+		follower := &headFollower{
+			bt:         bt,
+			client:     client,
+			depth:      *reorgDepth,
+			transforms: transforms,
+			index: func(start, end int64) error {
+				err := IndexFromNode(bt, client, start, end, 1, *batchBlocks, "")
+				if err != nil {
+					return err
+				}
+				err = IndexFromBigtable(bt, start, end, transforms, *concurrencyData, cache)
+				cache.Clear()
+				return err
+			},
+			afterHead: func() {
+				services.ReportStatus("eth1indexer", "Running", nil)
+			},
+		}
+		followHeads(*headsEndpoint, follower, indexRun)
+	}
+
+	for ; ; time.Sleep(time.Second * 14) {
+		err := indexRun()
+		if err != nil {
+			logrus.WithError(err).Errorf("error during index run")
+		}
 	}
 
 	// utils.WaitForCtrlC()
 }
 
-func UpdateTokenPrices(bt *db.Mongo, client *rpc.ErigonClient, tokenListPath string) error {
+// blocksCheckpoint is the checkpoint of the continuous indexing loop of the blocks table
+const blocksCheckpoint = "blocks"
+
+// getLastContiguousBlock returns the highest block up to which the blocks table is complete.
+// Falls back to the last block in the blocks table if no checkpoint has been stored yet.
+func getLastContiguousBlock(bt interfaces.Database) (int, error) {
+	lastBlock, err := bt.GetCheckpoint(blocksCheckpoint)
+	if err == nil {
+		return int(lastBlock), nil
+	}
+	if err != db.ErrCheckpointNotFound {
+		return 0, err
+	}
+	return bt.GetLastBlockInBlocksTable()
+}
+
+func UpdateTokenPrices(bt interfaces.Database, client *rpc.ErigonClient, tokenListPath string) error {
 
 	tokenListContent, err := ioutil.ReadFile(tokenListPath)
 	if err != nil {
@@ -405,18 +619,25 @@ func UpdateTokenPrices(bt *db.Mongo, client *rpc.ErigonClient, tokenListPath str
 	return bt.SaveERC20TokenPrices(tokenPrices)
 }
 
-func HandleChainReorgs(bt *db.Mongo, client *rpc.ErigonClient, depth int) error {
-	ctx := context.Background()
+// HandleChainReorgs compares the hashes of the last depth blocks in the db with the canonical chain of the node. If they
+// differ, every block from the fork block on is removed together with all documents derived from it, the blocks
+// checkpoint is rewound to the block before the fork, the canonical branch is re-indexed using reindex and the reorg is
+// recorded.
+func HandleChainReorgs(bt interfaces.Database, client rpc.Eth1Client, depth int, reindex func(start, end int64) error) error {
 	// get latest block from the node
-	latestNodeBlock, err := client.GetNativeClient().BlockByNumber(ctx, nil)
+	latestNodeBlockNumber, err := client.GetLatestEth1BlockNumber()
 	if err != nil {
 		return err
 	}
-	latestNodeBlockNumber := latestNodeBlock.NumberU64()
+
+	start := uint64(0)
+	if latestNodeBlockNumber > uint64(depth) {
+		start = latestNodeBlockNumber - uint64(depth)
+	}
 
 	// for each block check if block node hash and block db hash match
-	for i := latestNodeBlockNumber - uint64(depth); i <= latestNodeBlockNumber; i++ {
-		nodeBlock, err := client.GetNativeClient().HeaderByNumber(ctx, big.NewInt(int64(i)))
+	for i := start; i <= latestNodeBlockNumber; i++ {
+		nodeBlockHash, err := client.GetBlockHash(i)
 		if err != nil {
 			return err
 		}
@@ -429,33 +650,84 @@ func HandleChainReorgs(bt *db.Mongo, client *rpc.ErigonClient, depth int) error
 			return err
 		}
 
-		if !bytes.Equal(nodeBlock.Hash().Bytes(), dbBlock.Hash) {
-			logrus.Warnf("found incosistency at height %v, node block hash: %x, db block hash: %x", i, nodeBlock.Hash().Bytes(), dbBlock.Hash)
+		if bytes.Equal(nodeBlockHash, dbBlock.Hash) {
+			continue
+		}
+		logrus.Warnf("found inconsistency at height %v, node block hash: %x, db block hash: %x", i, nodeBlockHash, dbBlock.Hash)
 
-			// delete all blocks starting from the fork block up to the latest block in the db
-			for j := i; j <= latestNodeBlockNumber; j++ {
-				dbBlock, err := bt.GetBlockFromBlocksTable(j)
-				if err != nil {
-					if err == db.ErrBlockNotFound { // exit if we hit a block that is not yet in the db
-						return nil
-					}
-					return err
+		reorg := &entity.Reorg{
+			DetectedAt: time.Now(),
+			ForkBlock:  i,
+		}
+
+		// collect all blocks starting from the fork block up to the latest block in the db, the orphaned branch can be
+		// longer than the canonical one
+		orphaned := []*types.Eth1Block{dbBlock}
+		for j := i + 1; ; j++ {
+			dbBlock, err := bt.GetBlockFromBlocksTable(j)
+			if err != nil {
+				if err == db.ErrBlockNotFound {
+					break
 				}
-				logrus.Infof("deleting block at height %v with hash %x", dbBlock.Number, dbBlock.Hash)
-				err = bt.DeleteBlock(dbBlock.Number, dbBlock.Hash)
+				return err
+			}
+			orphaned = append(orphaned, dbBlock)
+		}
+
+		for _, orphan := range orphaned {
+			reorgBlock := entity.ReorgBlock{Number: orphan.Number, OldHash: orphan.Hash}
+			if orphan.Number <= latestNodeBlockNumber {
+				reorgBlock.NewHash, err = client.GetBlockHash(orphan.Number)
 				if err != nil {
 					return err
 				}
 			}
-		} else {
-			logrus.Infof("height %v, node block hash: %x, db block hash: %x", i, nodeBlock.Hash().Bytes(), dbBlock.Hash)
+			reorg.Blocks = append(reorg.Blocks, reorgBlock)
 		}
+		reorg.Depth = uint64(len(orphaned))
+
+		// delete from the tip down, an interrupted rollback leaves a contiguous chain which is picked up by the next run
+		for j := len(orphaned) - 1; j >= 0; j-- {
+			logrus.Infof("deleting block at height %v with hash %x", orphaned[j].Number, orphaned[j].Hash)
+			err = bt.DeleteBlock(orphaned[j].Number, orphaned[j].Hash)
+			if err != nil {
+				return fmt.Errorf("error deleting block %v with hash %x: %w", orphaned[j].Number, orphaned[j].Hash, err)
+			}
+		}
+
+		// the checkpoint never moves backwards on its own, rewind it to the last block both branches share
+		forkParent := uint64(0)
+		if i > 0 {
+			forkParent = i - 1
+		}
+		err = bt.ResetCheckpoint(blocksCheckpoint, forkParent)
+		if err != nil {
+			return fmt.Errorf("error resetting checkpoint %v to block %v: %w", blocksCheckpoint, forkParent, err)
+		}
+
+		logrus.Infof("re-indexing canonical blocks %v to %v", i, latestNodeBlockNumber)
+		err = reindex(int64(i), int64(latestNodeBlockNumber))
+		if err != nil {
+			return fmt.Errorf("error re-indexing canonical blocks %v to %v: %w", i, latestNodeBlockNumber, err)
+		}
+
+		err = bt.SaveReorg(reorg)
+		if err != nil {
+			return fmt.Errorf("error saving reorg at block %v: %w", i, err)
+		}
+		metrics.ChainReorgs.Inc()
+		metrics.ChainReorgDepth.Observe(float64(reorg.Depth))
+		logrus.WithFields(logrus.Fields{"forkBlock": reorg.ForkBlock, "depth": reorg.Depth}).Warnf("handled chain reorg")
+
+		return nil
 	}
 
 	return nil
 }
 
-func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix string, batchSize int, iterations int) {
+// ProcessMetadataUpdates retrieves the balances marked for an update from the node. With check set every retrieved
+// balance is compared with the balance the indexed balance deltas expect before it is saved.
+func ProcessMetadataUpdates(bt interfaces.Database, client *rpc.ErigonClient, prefix string, batchSize int, iterations int, check bool) {
 	lastKey := prefix
 	// for {
 	// 	updates, err := bt.GetMetadataUpdates(lastKey, batchSize)
@@ -549,6 +821,13 @@ func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix strin
 		// 	logrus.Infof("retrieved balance %x for token %x of address %x", b.Balance, b.Token, b.Address)
 		// }
 
+		// all balances of an iteration are retrieved at the last indexed block, so they match the indexed transfers
+		lastBlock, err := bt.GetLastBlockInBlocksTable()
+		if err != nil {
+			logrus.Errorf("error retrieving last block from the blocks table: %v", err)
+			return
+		}
+
 		balances := make([]*types.Eth1AddressBalance, 0, len(pairs))
 		for b := 0; b < len(pairs); b += batchSize {
 			start := b
@@ -559,7 +838,7 @@ func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix strin
 
 			logrus.Infof("processing batch %v with start %v and end %v", b, start, end)
 
-			b, err := client.GetBalances(pairs[start:end], 2, 4)
+			b, err := client.GetBalances(pairs[start:end], uint64(lastBlock))
 
 			if err != nil {
 				logrus.Errorf("error retrieving balances from node: %v", err)
@@ -568,6 +847,10 @@ func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix strin
 			balances = append(balances, b...)
 		}
 
+		if check {
+			compareBalances(bt, balances)
+		}
+
 		err = bt.SaveBalances(balances, keys)
 		if err != nil {
 			logrus.Errorf("error saving balances to bigtable: %v", err)
@@ -581,7 +864,7 @@ func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix strin
 		// }
 
 		lastKey = keys[len(keys)-1]
-		logrus.Infof("retrieved %v balances in %v, currently at %v", len(balances), time.Since(start), lastKey)
+		logrus.Infof("retrieved %v balances at block %v in %v, currently at %v", len(balances), lastBlock, time.Since(start), lastKey)
 
 		its++
 
@@ -643,56 +926,309 @@ func ProcessMetadataUpdates(bt *db.Mongo, client *rpc.ErigonClient, prefix strin
 	// }
 }
 
-func IndexFromNode(bt *db.Mongo, client *rpc.ErigonClient, start, end, concurrency int64) error {
+// ProcessTokenUpdates retrieves the metadata and the ERC-165 interfaces of the token contracts queued by the transforms
+// and adds them to the token registry. Tokens whose registry entry has been refreshed within the last day are not
+// retrieved again. Tokens whose metadata can't be retrieved are skipped and stay queued for the next run.
+func ProcessTokenUpdates(bt interfaces.Database, client *rpc.ErigonClient, batchSize int, iterations int) {
+	// the skipped tokens are returned first by every batch, so each batch is extended by them
+	skipped := make(map[string]bool)
+	for its := 0; iterations == -1 || its <= iterations; its++ {
+		start := time.Now()
+		queuedKeys, queued, err := bt.GetTokenUpdates(batchSize + len(skipped))
+		if err != nil {
+			logrus.Errorf("error retrieving token updates: %v", err)
+			return
+		}
 
-	g := new(errgroup.Group)
-	g.SetLimit(int(concurrency))
+		keys := make([]string, 0, len(queuedKeys))
+		tokens := make([]*types.Eth1Token, 0, len(queued))
+		for i, token := range queued {
+			if skipped[queuedKeys[i]] {
+				continue
+			}
+			stored, err := bt.GetToken(token.Address)
+			if err == nil && time.Since(stored.UpdatedAt) < time.Hour*24 {
+				if token.FirstBlock < stored.FirstBlock {
+					stored.FirstBlock = token.FirstBlock
+				}
+				keys = append(keys, queuedKeys[i])
+				tokens = append(tokens, stored)
+				continue
+			}
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				logrus.Errorf("error retrieving token %x from the registry: %v", token.Address, err)
+				return
+			}
 
-	startTs := time.Now()
-	lastTickTs := time.Now()
+			metadata, err := client.GetERC20TokenMetadata(token.Address)
+			if err != nil {
+				logrus.Errorf("error retrieving metadata of token %x, skipping it: %v", token.Address, err)
+				skipped[queuedKeys[i]] = true
+				continue
+			}
+			supported, err := client.GetTokenInterfaces(token.Address)
+			if err != nil {
+				logrus.Errorf("error retrieving interfaces of token %x, skipping it: %v", token.Address, err)
+				skipped[queuedKeys[i]] = true
+				continue
+			}
 
-	processedBlocks := int64(0)
+			token.Standard = tokenStandard(token.Standard, supported)
+			token.Name = metadata.Name
+			token.Symbol = metadata.Symbol
+			token.Decimals = metadata.Decimals
+			token.TotalSupply = metadata.TotalSupply
+			token.Interfaces = supported
+			token.UpdatedAt = time.Now()
 
-	for i := start; i <= end; i++ {
+			err = bt.SaveERC20Metadata(token.Address, metadata)
+			if err != nil {
+				logrus.Errorf("error saving metadata of token %x: %v", token.Address, err)
+				return
+			}
+			keys = append(keys, queuedKeys[i])
+			tokens = append(tokens, token)
+		}
+		if len(keys) == 0 {
+			return
+		}
 
-		i := i
-		// g.Go(func() error {
-		blockStartTs := time.Now()
-		bc, timings, err := client.GetBlock(i)
+		err = bt.SaveTokens(tokens, keys)
 		if err != nil {
-			return fmt.Errorf("error getting block: %v from ethereum node err: %w", i, err)
+			logrus.Errorf("error saving tokens: %v", err)
+			return
 		}
+		logrus.Infof("retrieved metadata of %v tokens in %v, %v tokens skipped", len(tokens), time.Since(start), len(skipped))
+	}
+}
 
-		dbStart := time.Now()
-		err = bt.SaveBlock(bc)
+// ProcessNFTMetadataUpdates retrieves the metadata uris of the token ids queued by the nft transform and stores them
+// with the JSON metadata they embed. Metadata hosted elsewhere is not retrieved, only its uri is stored. Token ids whose
+// uri can't be retrieved are skipped and stay queued for the next run.
+func ProcessNFTMetadataUpdates(bt interfaces.Database, client *rpc.ErigonClient, batchSize int, iterations int) {
+	// the skipped token ids are returned first by every batch, so each batch is extended by them
+	skipped := make(map[string]bool)
+	for its := 0; iterations == -1 || its <= iterations; its++ {
+		start := time.Now()
+		queuedKeys, queued, err := bt.GetNFTMetadataUpdates(batchSize + len(skipped))
 		if err != nil {
-			return fmt.Errorf("error saving block: %v to bigtable: %w", i, err)
-
+			logrus.Errorf("error retrieving nft metadata updates: %v", err)
+			return
 		}
-		current := atomic.AddInt64(&processedBlocks, 1)
-		if current%100 == 0 {
-			r := end - start
-			if r == 0 {
-				r = 1
+
+		inline := 0
+		keys := make([]string, 0, len(queuedKeys))
+		nfts := make([]*types.Eth1NFTMetadata, 0, len(queued))
+		for i, nft := range queued {
+			if skipped[queuedKeys[i]] {
+				continue
 			}
-			perc := float64(i-start) * 100 / float64(r)
+			nft.URI, err = client.GetNFTTokenURI(nft.Token, nft.TokenId, nft.Standard == db.TOKEN_STANDARD_ERC1155)
+			if err != nil {
+				logrus.Errorf("error retrieving metadata uri of token %x id %x, skipping it: %v", nft.Token, nft.TokenId, err)
+				skipped[queuedKeys[i]] = true
+				continue
+			}
+			if metadata, ok := utils.InlineNFTMetadata(nft.URI); ok {
+				nft.Metadata = metadata
+				inline++
+			}
+			nft.UpdatedAt = time.Now()
+			keys = append(keys, queuedKeys[i])
+			nfts = append(nfts, nft)
+		}
+		if len(keys) == 0 {
+			return
+		}
 
-			logrus.Infof("retrieved & saved block %v (0x%x) in %v (header: %v, receipts: %v, traces: %v, db: %v)", bc.Number, bc.Hash, time.Since(blockStartTs), timings.Headers, timings.Receipts, timings.Traces, time.Since(dbStart))
-			logrus.Infof("processed %v blocks in %v (%.1f blocks / sec); sync is %.1f%% complete", current, time.Since(startTs), float64((current))/time.Since(lastTickTs).Seconds(), perc)
+		err = bt.SaveNFTMetadata(nfts, keys)
+		if err != nil {
+			logrus.Errorf("error saving nft metadata: %v", err)
+			return
+		}
+		logrus.Infof("retrieved metadata uris of %v token ids (%v with inline metadata) in %v, %v token ids skipped", len(nfts), inline, time.Since(start), len(skipped))
+	}
+}
+
+// tokenStandard returns the standard of a token, the interfaces reported via ERC-165 take precedence over the standard
+// derived from the transfer events
+func tokenStandard(standard string, supported []string) string {
+	for _, i := range supported {
+		if i == rpc.InterfaceERC1155 {
+			return db.TOKEN_STANDARD_ERC1155
+		}
+	}
+	for _, i := range supported {
+		if i == rpc.InterfaceERC721 {
+			return db.TOKEN_STANDARD_ERC721
+		}
+	}
+	return standard
+}
 
-			lastTickTs = time.Now()
-			atomic.StoreInt64(&processedBlocks, 0)
+// compareBalances compares the balances retrieved from the node with the balances expected from the previously
+// retrieved balances and the indexed balance deltas since. Mismatches point to transfers missed by the balance deltas.
+func compareBalances(bt interfaces.Database, balances []*types.Eth1AddressBalance) {
+	for _, balance := range balances {
+		expected, err := db.ExpectedBalance(bt, balance)
+		if err != nil {
+			logrus.WithError(err).Errorf("error checking balance of %x for token %x", balance.Address, balance.Token)
+			continue
+		}
+		if expected == nil {
+			continue
+		}
+		if actual := new(big.Int).SetBytes(balance.Balance); actual.Cmp(expected) != 0 {
+			metrics.BalanceChecks.WithLabelValues("mismatch").Inc()
+			logrus.Warnf("balance of %x for token %x at block %v is %v, the indexed balance deltas expect %v", balance.Address, balance.Token, balance.BlockNumber, actual, expected)
+			continue
 		}
-		time.Sleep(120403)
-		// return nil
-		// })
+		metrics.BalanceChecks.WithLabelValues("match").Inc()
+	}
+}
 
+// IndexFromNode retrieves the blocks start to end from the node and stores them in the blocks table.
+// Up to concurrency blocks are fetched in parallel, the results are written in batches of batchSize blocks.
+// If a checkpoint name is provided, the highest block up to which all blocks of the range have been stored is persisted under that name.
+func IndexFromNode(bt interfaces.Database, client rpc.Eth1Client, start, end, concurrency int64, batchSize int, checkpoint string) error {
+	if start < 0 {
+		start = 0
+	}
+	if concurrency < 1 {
+		concurrency = 1
 	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	g, ctx := errgroup.WithContext(context.Background())
+
+	blockNumbers := make(chan int64)
+	blocks := make(chan *types.Eth1Block, batchSize)
+
+	g.Go(func() error {
+		defer close(blockNumbers)
+		for i := start; i <= end; i++ {
+			select {
+			case blockNumbers <- i:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		defer close(blocks)
+
+		workers, workersCtx := errgroup.WithContext(ctx)
+		for w := int64(0); w < concurrency; w++ {
+			workers.Go(func() error {
+				for {
+					var i int64
+					var ok bool
+					select {
+					case i, ok = <-blockNumbers:
+						if !ok {
+							return nil
+						}
+					case <-workersCtx.Done():
+						return workersCtx.Err()
+					}
+
+					blockStartTs := time.Now()
+					bc, timings, err := client.GetBlock(i)
+					if err != nil {
+						return fmt.Errorf("error getting block: %v from ethereum node err: %w", i, err)
+					}
+					logrus.Debugf("retrieved block %v (0x%x) in %v (header: %v, receipts: %v, traces: %v)", bc.Number, bc.Hash, time.Since(blockStartTs), timings.Headers, timings.Receipts, timings.Traces)
+
+					select {
+					case blocks <- bc:
+					case <-workersCtx.Done():
+						return workersCtx.Err()
+					}
+				}
+			})
+		}
+		return workers.Wait()
+	})
+
+	g.Go(func() error {
+		startTs := time.Now()
+		lastTickTs := time.Now()
+		processedBlocks := 0
+		totalBlocks := 0
+
+		// blocks arrive out of order, keep track of the stored blocks above the highest contiguous one
+		stored := make(map[uint64]bool)
+		next := uint64(start)
+
+		batch := make([]*types.Eth1Block, 0, batchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+
+			dbStart := time.Now()
+			err := bt.SaveBlocks(batch)
+			if err != nil {
+				return fmt.Errorf("error saving %v blocks to mongodb: %w", len(batch), err)
+			}
+			for _, bc := range batch {
+				stored[bc.Number] = true
+			}
+			for stored[next] {
+				delete(stored, next)
+				next++
+			}
+			if checkpoint != "" && next > uint64(start) {
+				err = bt.SaveCheckpoint(checkpoint, next-1)
+				if err != nil {
+					return fmt.Errorf("error saving checkpoint %v at block %v: %w", checkpoint, next-1, err)
+				}
+			}
+
+			processedBlocks += len(batch)
+			totalBlocks += len(batch)
+			if processedBlocks >= 100 {
+				r := end - start + 1
+				perc := float64(totalBlocks) * 100 / float64(r)
+
+				logrus.Infof("saved %v blocks in %v, highest contiguous block is %v", len(batch), time.Since(dbStart), int64(next)-1)
+				logrus.Infof("processed %v blocks in %v (%.1f blocks / sec); sync is %.1f%% complete", totalBlocks, time.Since(startTs), float64(processedBlocks)/time.Since(lastTickTs).Seconds(), perc)
+
+				lastTickTs = time.Now()
+				processedBlocks = 0
+			}
+
+			batch = batch[:0]
+			return nil
+		}
+
+		for bc := range blocks {
+			batch = append(batch, bc)
+			if len(batch) >= batchSize {
+				err := flush()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	})
 
 	return g.Wait()
 }
 
-func IndexFromBigtable(bt *db.Mongo, start, end int64, transforms []func(blk *types.Eth1Block, cache *freecache.Cache) (bulkData *types.BulkMutations, bulkMetadataUpdates []mongo.WriteModel, err error), concurrency int64, cache *freecache.Cache) error {
+// IndexFromBigtable runs the transforms over blocks start to end of the blocks table and advances the cursors of the
+// transforms up to the highest contiguous block written
+func IndexFromBigtable(bt interfaces.Database, start, end int64, transforms []*types.Eth1Transform, concurrency int64, cache *freecache.Cache) error {
+	progress, err := newTransformProgress(bt, transforms, start)
+	if err != nil {
+		return err
+	}
+
 	g := new(errgroup.Group)
 	g.SetLimit(int(concurrency))
 
@@ -714,12 +1250,12 @@ func IndexFromBigtable(bt *db.Mongo, start, end int64, transforms []func(blk *ty
 			bulkMutsData := types.BulkMutations{}
 			var bulkMutsMetadataUpdate []mongo.WriteModel
 			for _, transform := range transforms {
-				mutsData, mutsMetadataUpdate, err := transform(block, cache)
+				mutsData, mutsMetadataUpdate, err := transform.Transform(block, cache)
 				if err != nil {
-					logrus.WithError(err).Error("error transforming block")
+					// the block isn't written, the cursors must not move past a block a transform failed on
+					return fmt.Errorf("error transforming block %v with transform %v: %w", block.Number, transform.Name, err)
 				}
-				bulkMutsData.Keys = append(bulkMutsData.Keys, mutsData.Keys...)
-				bulkMutsData.Model = append(bulkMutsData.Model, mutsData.Model...)
+				bulkMutsData.Append(mutsData)
 
 				if mutsMetadataUpdate != nil {
 					// bulkMutsMetadataUpdate.Keys = append(bulkMutsMetadataUpdate.Keys, mutsMetadataUpdate.Keys...)
@@ -727,26 +1263,14 @@ func IndexFromBigtable(bt *db.Mongo, start, end int64, transforms []func(blk *ty
 				}
 			}
 
-			if len(bulkMutsData.Keys) > 0 {
-				metaKeys := strings.Join(bulkMutsData.Keys, ",") // save block keys in order to be able to handle chain reorgs
-				err = bt.SaveBlockKeys(block.Number, block.Hash, metaKeys)
-				if err != nil {
-					return fmt.Errorf("error saving block keys to bigtable metadata updates table: %w", err)
-				}
-
-				// err = bt.WriteBulk(&bulkMutsData, bt.GetDataTable())
-				_, err := db.MongodbClient.Db.Collection(db.DATA).BulkWrite(context.Background(), bulkMutsData.Model)
-				if err != nil {
-					return fmt.Errorf("error writing to mongodb data table: %w", err)
-				}
+			// the written documents are journaled with the block in order to be able to handle chain reorgs
+			err = bt.WriteBlockMutations(block, &bulkMutsData, bulkMutsMetadataUpdate)
+			if err != nil {
+				return fmt.Errorf("error writing mutations of block %v: %w", block.Number, err)
 			}
-
-			if len(bulkMutsMetadataUpdate) > 0 {
-				// err = bt.WriteBulk(&bulkMutsMetadataUpdate, bt.GetMetadataUpdatesTable())
-				_, err := db.MongodbClient.Db.Collection(db.METADATA_UPDATES).BulkWrite(context.Background(), bulkMutsMetadataUpdate)
-				if err != nil {
-					return fmt.Errorf("error writing to bigtable metadata updates table: %w", err)
-				}
+			err = progress.blockDone(block.Number)
+			if err != nil {
+				return err
 			}
 
 			current := atomic.AddInt64(&processedBlocks, 1)
@@ -765,7 +1289,12 @@ func IndexFromBigtable(bt *db.Mongo, start, end int64, transforms []func(blk *ty
 
 	}
 
-	if err := g.Wait(); err == nil {
+	// the blocks written before a failure still advance the cursors
+	err = g.Wait()
+	if flushErr := progress.flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if err == nil {
 		logrus.Info("data table indexing completed")
 	} else {
 		utils.LogError(err, "wait group error", 0)
@@ -775,7 +1304,7 @@ func IndexFromBigtable(bt *db.Mongo, start, end int64, transforms []func(blk *ty
 	return nil
 }
 
-func ImportMainnetERC20TokenMetadataFromTokenDirectory(bt *db.Mongo) {
+func ImportMainnetERC20TokenMetadataFromTokenDirectory(bt interfaces.Database) {
 
 	client := &http.Client{Timeout: time.Second * 10}
 
@@ -825,11 +1354,11 @@ func ImportMainnetERC20TokenMetadataFromTokenDirectory(bt *db.Mongo) {
 
 	for _, token := range td.Tokens {
 
-		address, err := hex.DecodeString(strings.TrimPrefix(token.Address, "0x"))
+		address, err := utils.ParseAddress(token.Address)
 		if err != nil {
-			utils.LogFatal(err, "decoding string to hex error", 0)
+			utils.LogFatal(err, "parsing token address error", 0)
 		}
-		logrus.Infof("processing token %v at address %x", token.Name, address)
+		logrus.Infof("processing token %v at address %v", token.Name, address)
 
 		meta := &types.ERC20Metadata{}
 		meta.Decimals = big.NewInt(token.Decimals).Bytes()
@@ -852,7 +1381,7 @@ func ImportMainnetERC20TokenMetadataFromTokenDirectory(bt *db.Mongo) {
 		meta.OfficialSite = token.Extensions.Link
 		meta.Symbol = token.Symbol
 
-		err = bt.SaveERC20Metadata(address, meta)
+		err = bt.SaveERC20Metadata(address.Bytes(), meta)
 		if err != nil {
 			utils.LogFatal(err, "error while saving ERC20 metadata", 0)
 		}
@@ -861,7 +1390,7 @@ func ImportMainnetERC20TokenMetadataFromTokenDirectory(bt *db.Mongo) {
 
 }
 
-func ImportNameLabels(bt *db.Mongo) {
+func ImportNameLabels(bt interfaces.Database) {
 	type NameEntry struct {
 		Name string
 	}
@@ -886,7 +1415,12 @@ func ImportNameLabels(bt *db.Mongo) {
 		if name.Name == "" {
 			continue
 		}
-		logrus.Infof("%v: %v", address, name.Name)
-		bt.SaveAddressName(common.FromHex(strings.TrimPrefix(address, "0x")), name.Name)
+		parsed, err := utils.ParseAddress(address)
+		if err != nil {
+			logrus.Errorf("skipping name %v for invalid address %v: %v", name.Name, address, err)
+			continue
+		}
+		logrus.Infof("%v: %v", parsed, name.Name)
+		bt.SaveAddressName(parsed.Bytes(), name.Name)
 	}
 }
@@ -1,10 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	_ "net/http/pprof"
+	"path/filepath"
 	"testing"
 
+	"github.com/Prajjawalk/zond-indexer/db"
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func Test_main(t *testing.T) {
@@ -22,3 +40,264 @@ func Test_main(t *testing.T) {
 		})
 	}
 }
+
+// testEmbedded opens an embedded database of the chain in a temporary directory which is closed at the end of the test
+func testEmbedded(t *testing.T, chainId string) *db.Embedded {
+	t.Helper()
+
+	bt, err := db.InitEmbedded(t.TempDir(), chainId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bt.Close() })
+	return bt
+}
+
+func TestHandleChainReorgs(t *testing.T) {
+	bt := testEmbedded(t, "1")
+
+	client := rpc.NewFakeEth1Client()
+	for i := uint64(0); i <= 5; i++ {
+		client.SetBlocks(testutil.FixtureBlock(i, "a"))
+	}
+	reindex := func(start, end int64) error {
+		return IndexFromNode(bt, client, start, end, 2, 2, "")
+	}
+	if err := reindex(0, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		reorg     func()
+		wantReorg *entity.Reorg
+		wantHash  map[uint64]string
+	}{
+		{
+			name:     "no reorg",
+			reorg:    func() {},
+			wantHash: map[uint64]string{2: "a", 5: "a"},
+		},
+		{
+			name: "canonical branch is shorter",
+			reorg: func() {
+				client.RemoveBlocks(3)
+				client.SetBlocks(testutil.FixtureBlock(3, "b"), testutil.FixtureBlock(4, "b"))
+			},
+			wantReorg: &entity.Reorg{ForkBlock: 3, Depth: 3},
+			wantHash:  map[uint64]string{2: "a", 3: "b", 4: "b", 5: ""},
+		},
+		{
+			name: "canonical branch is longer",
+			reorg: func() {
+				client.SetBlocks(testutil.FixtureBlock(4, "c"), testutil.FixtureBlock(5, "c"), testutil.FixtureBlock(6, "c"))
+			},
+			wantReorg: &entity.Reorg{ForkBlock: 4, Depth: 1},
+			wantHash:  map[uint64]string{3: "b", 4: "c", 5: "c", 6: "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := bt.GetReorgs(100)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := bt.SaveCheckpoint(blocksCheckpoint, 5); err != nil {
+				t.Fatal(err)
+			}
+			tt.reorg()
+			if err := HandleChainReorgs(bt, client, 10, reindex); err != nil {
+				t.Fatal(err)
+			}
+
+			checkpoint, err := bt.GetCheckpoint(blocksCheckpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCheckpoint := uint64(5)
+			if tt.wantReorg != nil {
+				wantCheckpoint = tt.wantReorg.ForkBlock - 1
+			}
+			if checkpoint != wantCheckpoint {
+				t.Errorf("got checkpoint %v, want %v", checkpoint, wantCheckpoint)
+			}
+
+			reorgs, err := bt.GetReorgs(100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReorg == nil {
+				if len(reorgs) != len(before) {
+					t.Errorf("got %v new reorgs, want none", len(reorgs)-len(before))
+				}
+			} else {
+				if len(reorgs) != len(before)+1 {
+					t.Fatalf("got %v new reorgs, want 1", len(reorgs)-len(before))
+				}
+				if reorgs[0].ForkBlock != tt.wantReorg.ForkBlock || reorgs[0].Depth != tt.wantReorg.Depth {
+					t.Errorf("got reorg at %v with depth %v, want %v with depth %v", reorgs[0].ForkBlock, reorgs[0].Depth, tt.wantReorg.ForkBlock, tt.wantReorg.Depth)
+				}
+			}
+
+			for number, branch := range tt.wantHash {
+				stored, err := bt.GetBlockFromBlocksTable(number)
+				if branch == "" {
+					if !errors.Is(err, db.ErrBlockNotFound) {
+						t.Errorf("block %v: got error %v, want %v", number, err, db.ErrBlockNotFound)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := testutil.FixtureHash(number, branch); !bytes.Equal(stored.Hash, want) {
+					t.Errorf("block %v: got hash %x, want %x", number, stored.Hash, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexReplayedZondBlocks(t *testing.T) {
+	// the blocks 0 to 3 of the hand-written zond fixtures, see rpc/testdata/zond-devnet-fake
+	fixtures := "../../rpc/testdata/zond-devnet-fake"
+	cfg := &types.Config{}
+	cfg.Chain.ConfigPath = filepath.Join(fixtures, "chain.yml")
+	if err := utils.ReadConfig(cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.Config
+	utils.Config = cfg
+	defer func() { utils.Config = previous }()
+
+	bt := testEmbedded(t, "1337")
+
+	client, err := rpc.NewReplayingErigonClient(filepath.Join(fixtures, "execution"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetVerifySignatures(true)
+
+	if err := IndexFromNode(bt, client, 0, 3, 2, 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexFromBigtable(bt, 0, 3, bt.DataTransforms(), 2, freecache.NewCache(1024*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := bt.GetLastBlockInDataTable(); err != nil || last != 3 {
+		t.Fatalf("got last block %v and error %v in the data table, want 3", last, err)
+	}
+
+	transferBlock, err := bt.GetBlockFromBlocksTable(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transferTx := transferBlock.Transactions[0]
+	sender := transferTx.From
+
+	// the sender derived from the dilithium public keys sent two txs in block 1, one in block 2 and received one in block 3
+	txs, _, err := bt.GetEth1TxForAddress(sender, nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := []uint64{}
+	for _, tx := range txs {
+		blocks = append(blocks, tx.BlockNumber)
+	}
+	if fmt.Sprint(blocks) != "[3 2 1 1]" {
+		t.Errorf("got txs of the sender in blocks %v, want [3 2 1 1]", blocks)
+	}
+
+	erc20, _, _, err := bt.GetTokenTransfersForTransaction(transferTx.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(erc20) != 1 || !bytes.Equal(erc20[0].From, sender) || !bytes.Equal(erc20[0].TokenAddress, transferTx.To) || new(big.Int).SetBytes(erc20[0].Value).Int64() != 1000 {
+		t.Fatalf("got erc20 transfers %v in tx %x, want a transfer of 1000 units of token %x from %x", erc20, transferTx.Hash, transferTx.To, sender)
+	}
+	received, _, err := bt.GetEth1ERC20ForAddress(erc20[0].To, nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].BlockNumber != 2 {
+		t.Errorf("got erc20 transfers %v of the recipient, want the transfer in block 2", received)
+	}
+
+	_, tokens, err := bt.GetTokenUpdates(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || !bytes.Equal(tokens[0].Address, transferTx.To) {
+		t.Errorf("got token updates %v, want the transferred token", tokens)
+	}
+}
+
+func TestProcessTokenUpdatesSkipsFailures(t *testing.T) {
+	bt := testEmbedded(t, "1")
+
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	coin := common.HexToAddress("0xe2")
+	broken := common.HexToAddress("0xe3")
+
+	transfer := func(token common.Address) *types.Eth1Log {
+		return &types.Eth1Log{
+			Address: token.Bytes(),
+			Data:    common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+			Topics:  [][]byte{erc20.TransferTopic, common.LeftPadBytes(alice.Bytes(), 32), common.LeftPadBytes(bob.Bytes(), 32)},
+		}
+	}
+	blocks := []*types.Eth1Block{{Number: 1, Transactions: []*types.Eth1Transaction{{From: alice.Bytes(), Logs: []*types.Eth1Log{transfer(broken), transfer(coin)}}}}}
+	if err := testutil.WriteFixtureBlocks(bt, blocks, bt.TransformERC20); err != nil {
+		t.Fatal(err)
+	}
+
+	// the coin contract reverts every call, the calls to the broken token time out
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		var call struct {
+			To common.Address `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) == 0 || json.Unmarshal(req.Params[0], &call) != nil {
+			t.Errorf("unexpected request %+v: %v", req, err)
+			return
+		}
+		rpcErr := map[string]interface{}{"code": 3, "message": "execution reverted", "data": "0x"}
+		if call.To == broken {
+			rpcErr = map[string]interface{}{"code": -32000, "message": "request timed out"}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": rpcErr})
+	}))
+	defer node.Close()
+	client, err := rpc.NewErigonClient(node.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ProcessTokenUpdates(bt, client, 10, 2)
+
+	token, err := bt.GetToken(coin.Bytes())
+	if err != nil {
+		t.Fatalf("token without metadata methods is not registered: %v", err)
+	}
+	if token.Symbol != "UNKNOWN" || len(token.Interfaces) != 0 {
+		t.Errorf("got token with symbol %q and interfaces %v, want UNKNOWN without interfaces", token.Symbol, token.Interfaces)
+	}
+	if _, err := bt.GetToken(broken.Bytes()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("got error %v for the token whose calls failed, want it not registered", err)
+	}
+	_, queued, err := bt.GetTokenUpdates(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || !bytes.Equal(queued[0].Address, broken.Bytes()) {
+		t.Errorf("got %v queued tokens after the update, want only the token whose calls failed", len(queued))
+	}
+}
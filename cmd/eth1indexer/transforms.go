@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/sirupsen/logrus"
+)
+
+// transformCursorInterval is the number of blocks after which the cursors of a run are saved
+const transformCursorInterval = 100
+
+// selectTransforms returns the transforms with the passed comma separated names, all transforms if names is empty
+func selectTransforms(transforms []*types.Eth1Transform, names string) ([]*types.Eth1Transform, error) {
+	if names == "" {
+		return transforms, nil
+	}
+
+	byName := make(map[string]*types.Eth1Transform, len(transforms))
+	for _, transform := range transforms {
+		byName[transform.Name] = transform
+	}
+
+	selected := []*types.Eth1Transform{}
+	for _, name := range strings.Split(names, ",") {
+		transform, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			known := make([]string, 0, len(transforms))
+			for _, transform := range transforms {
+				known = append(known, transform.Name)
+			}
+			return nil, fmt.Errorf("unknown transform %q, available transforms are %v", name, strings.Join(known, ", "))
+		}
+		selected = append(selected, transform)
+	}
+	return selected, nil
+}
+
+// transformCursors returns the cursors of the passed transforms by name, transforms without a cursor for their current
+// version are missing
+func transformCursors(bt interfaces.Database, transforms []*types.Eth1Transform) (map[string]uint64, error) {
+	stored, err := bt.GetTransformCursors()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]uint64, len(transforms))
+	for _, transform := range transforms {
+		versions[transform.Name] = transform.Version
+	}
+	cursors := make(map[string]uint64, len(transforms))
+	for _, cursor := range stored {
+		if version, ok := versions[cursor.Name]; ok && version == cursor.Version {
+			cursors[cursor.Name] = cursor.Block
+		}
+	}
+	return cursors, nil
+}
+
+// seedTransformCursors initializes the cursors of all transforms with the last block of the data table if no transform
+// has stored a cursor yet. The data table has been written by all transforms before their progress was tracked.
+func seedTransformCursors(bt interfaces.Database, transforms []*types.Eth1Transform) error {
+	stored, err := bt.GetTransformCursors()
+	if err != nil {
+		return err
+	}
+	if len(stored) > 0 {
+		return nil
+	}
+
+	lastBlock, err := bt.GetLastBlockInDataTable()
+	if err != nil || lastBlock == 0 {
+		return err
+	}
+	logrus.Infof("seeding the cursors of %v transforms with the last block %v of the data table", len(transforms), lastBlock)
+	for _, transform := range transforms {
+		err = bt.SaveTransformCursor(transform.Name, transform.Version, uint64(lastBlock))
+		if err != nil {
+			return fmt.Errorf("error seeding cursor of transform %v: %w", transform.Name, err)
+		}
+	}
+	return nil
+}
+
+// startTransformCursors creates the cursors of the passed transforms which have none for their current version at the
+// block before start. It is used when transforms are backfilled explicitly from start, the blocks below start are
+// considered to be covered, otherwise the cursor of a backfill starting above block 0 would never be created.
+func startTransformCursors(bt interfaces.Database, transforms []*types.Eth1Transform, start int64) error {
+	if start <= 0 {
+		return nil
+	}
+	cursors, err := transformCursors(bt, transforms)
+	if err != nil {
+		return err
+	}
+	for _, transform := range transforms {
+		if _, ok := cursors[transform.Name]; ok {
+			continue
+		}
+		logrus.Infof("starting the cursor of transform %v at block %v, blocks below it are not backfilled", transform.Name, start-1)
+		err = bt.SaveTransformCursor(transform.Name, transform.Version, uint64(start-1))
+		if err != nil {
+			return fmt.Errorf("error starting cursor of transform %v: %w", transform.Name, err)
+		}
+	}
+	return nil
+}
+
+// resumeTransforms returns the block from which a run of the transforms over blocks start to end continues. A run is
+// resumed if the cursors of all transforms lie within the range.
+func resumeTransforms(bt interfaces.Database, transforms []*types.Eth1Transform, start, end int64) (int64, error) {
+	cursors, err := transformCursors(bt, transforms)
+	if err != nil {
+		return 0, err
+	}
+
+	resume := end + 1
+	for _, transform := range transforms {
+		cursor, ok := cursors[transform.Name]
+		if !ok || int64(cursor) < start {
+			return start, nil
+		}
+		if int64(cursor)+1 < resume {
+			resume = int64(cursor) + 1
+		}
+	}
+	return resume, nil
+}
+
+// transformProgress tracks the highest contiguous block written by a run of IndexFromBigtable and advances the
+// cursors of the transforms the run continues. The cursor of a transform is left untouched if the run starts above it,
+// the blocks in between haven't been processed by the transform.
+type transformProgress struct {
+	bt         interfaces.Database
+	transforms []*types.Eth1Transform
+
+	mux sync.Mutex
+	// done holds the written blocks above the highest contiguous one
+	done  map[uint64]bool
+	next  uint64
+	saved uint64
+}
+
+func newTransformProgress(bt interfaces.Database, transforms []*types.Eth1Transform, start int64) (*transformProgress, error) {
+	cursors, err := transformCursors(bt, transforms)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving transform cursors: %w", err)
+	}
+
+	progress := &transformProgress{bt: bt, done: make(map[uint64]bool)}
+	if start < 0 {
+		start = 0
+	}
+	progress.next = uint64(start)
+	progress.saved = progress.next
+	for _, transform := range transforms {
+		cursor, ok := cursors[transform.Name]
+		if (ok && cursor+1 >= uint64(start)) || (!ok && start == 0) {
+			progress.transforms = append(progress.transforms, transform)
+		}
+	}
+	return progress, nil
+}
+
+// blockDone records that the block has been written and saves the cursors every transformCursorInterval blocks
+func (p *transformProgress) blockDone(number uint64) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.done[number] = true
+	for p.done[p.next] {
+		delete(p.done, p.next)
+		p.next++
+	}
+	if p.next-p.saved < transformCursorInterval {
+		return nil
+	}
+	return p.save()
+}
+
+// flush saves the cursors up to the highest contiguous block written so far
+func (p *transformProgress) flush() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.next == p.saved {
+		return nil
+	}
+	return p.save()
+}
+
+func (p *transformProgress) save() error {
+	for _, transform := range p.transforms {
+		err := p.bt.SaveTransformCursor(transform.Name, transform.Version, p.next-1)
+		if err != nil {
+			return fmt.Errorf("error saving cursor of transform %v at block %v: %w", transform.Name, p.next-1, err)
+		}
+	}
+	p.saved = p.next
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIndexFromBigtableCursors(t *testing.T) {
+	bt := testEmbedded(t, "1")
+
+	client := rpc.NewFakeEth1Client()
+	for i := uint64(0); i <= 5; i++ {
+		client.SetBlocks(testutil.FixtureBlock(i, "a"))
+	}
+	if err := IndexFromNode(bt, client, 0, 5, 2, 2, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := map[string]*int64{}
+	transform := func(name string, version uint64) *types.Eth1Transform {
+		calls[name] = new(int64)
+		return &types.Eth1Transform{Name: name, Version: version, Transform: func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+			atomic.AddInt64(calls[name], 1)
+			return &types.BulkMutations{}, nil, nil
+		}}
+	}
+	cursors := func(transforms ...*types.Eth1Transform) map[string]uint64 {
+		got, err := transformCursors(bt, transforms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	cache := freecache.NewCache(1024 * 1024)
+
+	tx, erc20 := transform("tx", 1), transform("erc20", 1)
+	if err := IndexFromBigtable(bt, 0, 5, []*types.Eth1Transform{tx, erc20}, 3, cache); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors(tx, erc20); got["tx"] != 5 || got["erc20"] != 5 {
+		t.Fatalf("got cursors %v after indexing blocks 0 to 5, want 5", got)
+	}
+
+	// a new transform which only ran over the last blocks has no cursor until it has been backfilled
+	erc1155 := transform("erc1155", 1)
+	all := []*types.Eth1Transform{tx, erc20, erc1155}
+	if err := IndexFromBigtable(bt, 4, 5, all, 3, cache); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors(all...); len(got) != 2 {
+		t.Fatalf("got cursors %v, want none for erc1155", got)
+	}
+	if start, err := resumeTransforms(bt, []*types.Eth1Transform{erc1155}, 0, 5); err != nil || start != 0 {
+		t.Fatalf("got resume block %v and error %v for erc1155, want 0", start, err)
+	}
+
+	selected, err := selectTransforms(all, "erc1155")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexFromBigtable(bt, 0, 5, selected, 3, cache); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors(all...); got["erc1155"] != 5 || *calls["erc1155"] != 8 || *calls["tx"] != 8 {
+		t.Errorf("got cursors %v after the backfill and %v erc1155 and %v tx calls, want erc1155 at 5 with 8 calls and 8 tx calls", got, *calls["erc1155"], *calls["tx"])
+	}
+	if start, err := resumeTransforms(bt, selected, 0, 5); err != nil || start != 6 {
+		t.Errorf("got resume block %v and error %v for the completed backfill, want 6", start, err)
+	}
+
+	// an explicit backfill of a new transform from a later block starts its cursor below that block
+	nfts := transform("nfts", 1)
+	if err := startTransformCursors(bt, []*types.Eth1Transform{nfts, erc1155}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if start, err := resumeTransforms(bt, []*types.Eth1Transform{nfts}, 3, 5); err != nil || start != 3 {
+		t.Fatalf("got resume block %v and error %v for the nfts backfill, want 3", start, err)
+	}
+	if err := IndexFromBigtable(bt, 3, 5, []*types.Eth1Transform{nfts}, 3, cache); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors(nfts, erc1155); got["nfts"] != 5 || got["erc1155"] != 5 || *calls["nfts"] != 3 {
+		t.Errorf("got cursors %v and %v nfts calls after backfilling nfts from block 3, want both at 5 and 3 calls", got, *calls["nfts"])
+	}
+
+	// a failing transform stops the run below the failed block
+	failing := &types.Eth1Transform{Name: "logs", Version: 1, Transform: func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+		if blk.Number == 2 {
+			return nil, nil, fmt.Errorf("failed")
+		}
+		return &types.BulkMutations{}, nil, nil
+	}}
+	if err := IndexFromBigtable(bt, 0, 5, []*types.Eth1Transform{failing}, 1, cache); err == nil {
+		t.Errorf("got no error for a failing transform")
+	}
+	if got := cursors(failing); got["logs"] != 1 {
+		t.Errorf("got cursors %v after the failure at block 2, want logs at 1", got)
+	}
+
+	// bumping the version restarts the progress of a transform
+	if got := cursors(transform("tx", 2)); len(got) != 0 {
+		t.Errorf("got cursors %v for version 2 of tx, want none", got)
+	}
+	if _, err := selectTransforms(all, "erc1155,erc777"); err == nil {
+		t.Errorf("got no error selecting an unknown transform")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/gob"
+	"errors"
 	"time"
 
 	"github.com/Prajjawalk/zond-indexer/cache"
@@ -56,6 +57,10 @@ func init() {
 
 func main() {
 	configPath := flag.String("config", "", "Path to the config file, if empty string defaults will be used")
+	storage := flag.String("storage", "mongodb", "Storage backend of the execution layer data, mongodb or embedded")
+	dataDir := flag.String("datadir", "data", "Data directory of the embedded storage backend")
+	recordFixtures := flag.String("rpc.record", "", "Record the responses of the beacon node as test fixtures to this directory")
+	replayFixtures := flag.String("rpc.replay", "", "Answer all requests to the beacon node with the test fixtures in this directory")
 
 	flag.Parse()
 
@@ -86,6 +91,31 @@ func main() {
 		}()
 	}
 
+	// the execution client is optional, it retrieves the deployed code of contracts to verify them
+	if utils.Config.Eth1ErigonEndpoint != "" {
+		rpc.CurrentErigonClient, err = rpc.NewErigonClient(utils.Config.Eth1ErigonEndpoint)
+		if err != nil {
+			logrus.Errorf("error initializing erigon client, contract verification is not available: %v", err)
+		}
+	}
+
+	contractEvents, err := db.NewContractEventIndexes(utils.Config.Indexer.ContractEvents)
+	if err != nil {
+		logrus.Fatalf("error reading contract events config: %v", err)
+	}
+	for _, index := range contractEvents {
+		handlers.ContractEventIndexes[index.Name] = index
+	}
+
+	switch *storage {
+	case "mongodb":
+	case "embedded":
+		runEmbeddedAPI(cfg, *dataDir)
+		return
+	default:
+		logrus.Fatalf("invalid storage backend %v, supported backends are mongodb and embedded", *storage)
+	}
+
 	wg := &sync.WaitGroup{}
 
 	wg.Add(1)
@@ -225,7 +255,23 @@ func main() {
 		var rpcClient rpc.Client
 
 		chainID := new(big.Int).SetUint64(utils.Config.Chain.Config.DepositChainID)
-		if utils.Config.Indexer.Node.Type == "lighthouse" {
+		if *replayFixtures != "" {
+			logrus.Infof("replaying beacon node responses from %v", *replayFixtures)
+			rpcClient, err = rpc.NewReplayingLighthouseClient(*replayFixtures, chainID)
+			if err != nil {
+				utils.LogFatal(err, "new explorer replaying lighthouse client error", 0)
+			}
+		} else if utils.Config.Indexer.Node.Type == "lighthouse" && *recordFixtures != "" {
+			logrus.Infof("recording beacon node responses to %v", *recordFixtures)
+			transport, err := rpc.NewRecordingTransport(*recordFixtures, nil)
+			if err != nil {
+				utils.LogFatal(err, "new explorer fixture transport error", 0)
+			}
+			rpcClient, err = rpc.NewLighthouseClientWithTransport("http://"+cfg.Indexer.Node.Host+":"+cfg.Indexer.Node.Port, chainID, transport)
+			if err != nil {
+				utils.LogFatal(err, "new explorer lighthouse client error", 0)
+			}
+		} else if utils.Config.Indexer.Node.Type == "lighthouse" {
 			rpcClient, err = rpc.NewLighthouseClient("http://"+cfg.Indexer.Node.Host+":"+cfg.Indexer.Node.Port, chainID)
 			if err != nil {
 				utils.LogFatal(err, "new explorer lighthouse client error", 0)
@@ -317,18 +363,7 @@ func main() {
 		// 	apiV1Router.HandleFunc("/rocketpool/validator/{indexOrPubkey}", handlers.ApiRocketpoolValidators).Methods("GET", "OPTIONS")
 		// 	apiV1Router.HandleFunc("/ethstore/{day}", handlers.ApiEthStoreDay).Methods("GET", "OPTIONS")
 
-		// 	apiV1Router.HandleFunc("/execution/gasnow", handlers.ApiEth1GasNowData).Methods("GET", "OPTIONS")
-		// 	// query params: token
-		// 	apiV1Router.HandleFunc("/execution/block/{blockNumber}", handlers.ApiETH1ExecBlocks).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/{addressIndexOrPubkey}/produced", handlers.ApiETH1AccountProducedBlocks).Methods("GET", "OPTIONS")
-
-		// 	apiV1Router.HandleFunc("/execution/address/{address}", handlers.ApiEth1Address).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/address/{address}/transactions", handlers.ApiEth1AddressTx).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/address/{address}/internalTx", handlers.ApiEth1AddressItx).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/address/{address}/blocks", handlers.ApiEth1AddressBlocks).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/address/{address}/uncles", handlers.ApiEth1AddressUncles).Methods("GET", "OPTIONS")
-		// 	apiV1Router.HandleFunc("/execution/address/{address}/tokens", handlers.ApiEth1AddressTokens).Methods("GET", "OPTIONS")
-		// 	// // query params: type={erc20,erc721,erc1155}, address
+		handlers.RegisterExecutionRoutes(apiV1Router)
 
 		// 	apiV1Router.HandleFunc("/validator/{indexOrPubkey}/widget", handlers.GetMobileWidgetStatsGet).Methods("GET")
 		// 	apiV1Router.HandleFunc("/dashboard/widget", handlers.GetMobileWidgetStatsPost).Methods("POST")
@@ -639,66 +674,14 @@ func main() {
 		// 		router.Use(metrics.HttpMiddleware)
 		// 	}
 
-		// 	// l := negroni.NewLogger()
-		// 	// l.SetFormat(`{{.Request.Header.Get "X-Forwarded-For"}}, {{.Request.RemoteAddr}} | {{.StartTime}} | {{.Status}} | {{.Duration}} | {{.Hostname}} | {{.Method}} {{.Path}}{{if ne .Request.URL.RawQuery ""}}?{{.Request.URL.RawQuery}}{{end}}`)
-
-		n := negroni.New(negroni.NewRecovery()) //, l
-
-		// 	// Customize the logging middleware to include a proper module entry for the frontend
-		// 	//frontendLogger := negronilogrus.NewMiddleware()
-		// 	//frontendLogger.Before = func(entry *logrus.Entry, request *http.Request, s string) *logrus.Entry {
-		// 	//	entry = negronilogrus.DefaultBefore(entry, request, s)
-		// 	//	return entry.WithField("module", "frontend")
-		// 	//}
-		// 	//frontendLogger.After = func(entry *logrus.Entry, writer negroni.ResponseWriter, duration time.Duration, s string) *logrus.Entry {
-		// 	//	entry = negronilogrus.DefaultAfter(entry, writer, duration, s)
-		// 	//	return entry.WithField("module", "frontend")
-		// 	//}
-		// 	//n.Use(frontendLogger)
-
-		n.Use(gzip.Gzip(gzip.DefaultCompression))
-
-		pa := &proxyaddr.ProxyAddr{}
-		pa.Init(proxyaddr.CIDRLoopback)
-		n.Use(pa)
-
-		n.UseHandler(utils.SessionStore.SCS.LoadAndSave(router))
-
-		if utils.Config.Frontend.HttpWriteTimeout == 0 {
-			utils.Config.Frontend.HttpIdleTimeout = time.Second * 15
-		}
-		if utils.Config.Frontend.HttpReadTimeout == 0 {
-			utils.Config.Frontend.HttpIdleTimeout = time.Second * 15
-		}
-		if utils.Config.Frontend.HttpIdleTimeout == 0 {
-			utils.Config.Frontend.HttpIdleTimeout = time.Second * 60
-		}
-		srv := &http.Server{
-			Addr:         cfg.Frontend.Server.Host + ":" + cfg.Frontend.Server.Port,
-			WriteTimeout: utils.Config.Frontend.HttpWriteTimeout,
-			ReadTimeout:  utils.Config.Frontend.HttpReadTimeout,
-			IdleTimeout:  utils.Config.Frontend.HttpIdleTimeout,
-			Handler:      n,
-		}
-
-		logrus.Printf("http server listening on %v", srv.Addr)
-		go func() {
-			if err := srv.ListenAndServe(); err != nil {
-				logrus.WithError(err).Fatal("Error serving frontend")
-			}
-		}()
+		serveHTTP(cfg, router)
 	}
 	// if utils.Config.Notifications.Enabled {
 	// 	services.InitNotifications(utils.Config.Notifications.PubkeyCachePath)
 	// }
 
 	if utils.Config.Metrics.Enabled {
-		go func(addr string) {
-			logrus.Infof("Serving metrics on %v", addr)
-			if err := metrics.Serve(addr); err != nil {
-				logrus.WithError(err).Fatal("Error serving metrics")
-			}
-		}(utils.Config.Metrics.Address)
+		serveMetrics(utils.Config.Metrics.Address)
 	}
 
 	// if utils.Config.Frontend.ShowDonors.Enabled {
@@ -713,3 +696,97 @@ func main() {
 
 	logrus.Println("exiting...")
 }
+
+// serveHTTP starts the http server of the frontend and the api in the background
+func serveHTTP(cfg *types.Config, router *mux.Router) {
+	// 	// l := negroni.NewLogger()
+	// 	// l.SetFormat(`{{.Request.Header.Get "X-Forwarded-For"}}, {{.Request.RemoteAddr}} | {{.StartTime}} | {{.Status}} | {{.Duration}} | {{.Hostname}} | {{.Method}} {{.Path}}{{if ne .Request.URL.RawQuery ""}}?{{.Request.URL.RawQuery}}{{end}}`)
+
+	n := negroni.New(negroni.NewRecovery()) //, l
+
+	// 	// Customize the logging middleware to include a proper module entry for the frontend
+	// 	//frontendLogger := negronilogrus.NewMiddleware()
+	// 	//frontendLogger.Before = func(entry *logrus.Entry, request *http.Request, s string) *logrus.Entry {
+	// 	//	entry = negronilogrus.DefaultBefore(entry, request, s)
+	// 	//	return entry.WithField("module", "frontend")
+	// 	//}
+	// 	//frontendLogger.After = func(entry *logrus.Entry, writer negroni.ResponseWriter, duration time.Duration, s string) *logrus.Entry {
+	// 	//	entry = negronilogrus.DefaultAfter(entry, writer, duration, s)
+	// 	//	return entry.WithField("module", "frontend")
+	// 	//}
+	// 	//n.Use(frontendLogger)
+
+	n.Use(gzip.Gzip(gzip.DefaultCompression))
+
+	pa := &proxyaddr.ProxyAddr{}
+	pa.Init(proxyaddr.CIDRLoopback)
+	n.Use(pa)
+
+	if utils.SessionStore != nil {
+		n.UseHandler(utils.SessionStore.SCS.LoadAndSave(router))
+	} else {
+		n.UseHandler(router)
+	}
+
+	if utils.Config.Frontend.HttpWriteTimeout == 0 {
+		utils.Config.Frontend.HttpIdleTimeout = time.Second * 15
+	}
+	if utils.Config.Frontend.HttpReadTimeout == 0 {
+		utils.Config.Frontend.HttpIdleTimeout = time.Second * 15
+	}
+	if utils.Config.Frontend.HttpIdleTimeout == 0 {
+		utils.Config.Frontend.HttpIdleTimeout = time.Second * 60
+	}
+	srv := &http.Server{
+		Addr:         cfg.Frontend.Server.Host + ":" + cfg.Frontend.Server.Port,
+		WriteTimeout: utils.Config.Frontend.HttpWriteTimeout,
+		ReadTimeout:  utils.Config.Frontend.HttpReadTimeout,
+		IdleTimeout:  utils.Config.Frontend.HttpIdleTimeout,
+		Handler:      n,
+	}
+
+	logrus.Printf("http server listening on %v", srv.Addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			logrus.WithError(err).Fatal("Error serving frontend")
+		}
+	}()
+}
+
+func serveMetrics(addr string) {
+	go func() {
+		logrus.Infof("Serving metrics on %v", addr)
+		if err := metrics.Serve(addr); err != nil {
+			logrus.WithError(err).Fatal("Error serving metrics")
+		}
+	}()
+}
+
+// runEmbeddedAPI serves the execution layer api from the embedded storage backend in the data directory. It runs
+// without postgres, mongodb and the tiered cache, the consensus layer endpoints and the indexer are not available.
+// The data directory of a running indexer is locked, the indexer serves the api itself with -api.address.
+func runEmbeddedAPI(cfg *types.Config, dataDir string) {
+	bt, err := db.InitEmbedded(dataDir, fmt.Sprintf("%d", utils.Config.Chain.Config.DepositChainID))
+	if errors.Is(err, db.ErrEmbeddedInUse) {
+		logrus.Fatalf("error opening embedded storage: %v, serve the api from the indexer with -api.address instead", err)
+	}
+	if err != nil {
+		logrus.Fatalf("error opening embedded storage: %v", err)
+	}
+	defer bt.Close()
+	logrus.Infof("using embedded storage in %v", dataDir)
+
+	router := mux.NewRouter()
+	apiV1Router := router.PathPrefix("/api/v1").Subrouter()
+	router.PathPrefix("/api/v1/docs/").Handler(httpSwagger.WrapHandler)
+	handlers.RegisterExecutionRoutes(apiV1Router)
+	serveHTTP(cfg, router)
+
+	if utils.Config.Metrics.Enabled {
+		serveMetrics(utils.Config.Metrics.Address)
+	}
+
+	utils.WaitForCtrlC()
+
+	logrus.Println("exiting...")
+}
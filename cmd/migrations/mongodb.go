@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"log"
 	"strconv"
@@ -29,15 +28,11 @@ func main() {
 		log.Fatal(err)
 	}
 
-	collectionList := []string{"beaconchain", "blocks", "cache", "data", "machine_metrics", "metadata", "metadata_updates"}
+	defer mongo.Close()
 
-	for _, i := range collectionList {
-		err = mongo.Db.CreateCollection(context.Background(), i)
-		if err != nil {
-			log.Fatal(err)
-		}
+	err = mongo.ApplyMongoSchema(-2)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	defer mongo.Close()
-
 }
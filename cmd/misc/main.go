@@ -2,6 +2,7 @@ package main
 
 import (
 	"math/big"
+	"os"
 	"strconv"
 
 	"github.com/Prajjawalk/zond-indexer/db"
@@ -24,14 +25,20 @@ var opts = struct {
 	TargetVersion int64
 	StartEpoch    uint64
 	EndEpoch      uint64
+	StartBlock    uint64
+	EndBlock      uint64
+	File          string
 }{}
 
 func main() {
 	configPath := flag.String("config", "config/default.config.yml", "Path to the config file")
-	flag.StringVar(&opts.Command, "command", "", "command to run, available: updateAPIKey, applyDbSchema")
+	flag.StringVar(&opts.Command, "command", "", "command to run, available: updateAPIKey, applyDbSchema, applyMongoSchema, convertAddressIndexes, dedupeData, importSignatures")
 	flag.Uint64Var(&opts.StartEpoch, "start-epoch", 0, "start epoch")
 	flag.Uint64Var(&opts.EndEpoch, "end-epoch", 0, "end epoch")
+	flag.Uint64Var(&opts.StartBlock, "start-block", 0, "start block")
+	flag.Uint64Var(&opts.EndBlock, "end-block", 0, "end block")
 	flag.Uint64Var(&opts.User, "user", 0, "user id")
+	flag.StringVar(&opts.File, "file", "", "Path to the text signature list imported by importSignatures, one signature per line and events prefixed with \"event \"")
 	flag.Int64Var(&opts.TargetVersion, "target-version", -2, "Db migration target version, use -2 to apply up to the latest version, -1 to apply only the next version or the specific versions")
 	flag.Parse()
 
@@ -100,6 +107,49 @@ func main() {
 			logrus.WithError(err).Fatal("error applying db schema")
 		}
 		logrus.Infof("db schema applied successfully")
+	case "applyMongoSchema":
+		logrus.Infof("applying mongodb schema")
+		err := db.MongodbClient.ApplyMongoSchema(opts.TargetVersion)
+		if err != nil {
+			logrus.WithError(err).Fatal("error applying mongodb schema")
+		}
+		logrus.Infof("mongodb schema applied successfully")
+	case "convertAddressIndexes":
+		logrus.Infof("converting address indexes of blocks %v - %v", opts.StartBlock, opts.EndBlock)
+		err := db.MongodbClient.ConvertLegacyAddressIndexes(opts.StartBlock, opts.EndBlock)
+		if err != nil {
+			logrus.WithError(err).Fatal("error converting address indexes")
+		}
+		logrus.Infof("address indexes converted successfully")
+	case "dedupeData":
+		logrus.Infof("deduplicating documents of blocks %v - %v", opts.StartBlock, opts.EndBlock)
+		err := db.MongodbClient.DedupeData(opts.StartBlock, opts.EndBlock)
+		if err != nil {
+			logrus.WithError(err).Fatal("error deduplicating documents")
+		}
+		logrus.Infof("documents deduplicated successfully")
+	case "importSignatures":
+		logrus.Infof("importing signatures from %v", opts.File)
+		file, err := os.Open(opts.File)
+		if err != nil {
+			logrus.WithError(err).Fatal("error opening signature list")
+		}
+		signatures, err := utils.ParseSignatures(file)
+		file.Close()
+		if err != nil {
+			logrus.WithError(err).Fatal("error parsing signature list")
+		}
+		for start := 0; start < len(signatures); start += 10000 {
+			end := start + 10000
+			if end > len(signatures) {
+				end = len(signatures)
+			}
+			err = db.MongodbClient.SaveSignatures(signatures[start:end])
+			if err != nil {
+				logrus.WithError(err).Fatal("error importing signatures")
+			}
+		}
+		logrus.Infof("%v signatures imported successfully", len(signatures))
 	case "epoch-export":
 		logrus.Infof("exporting epochs %v - %v", opts.StartEpoch, opts.EndEpoch)
 
@@ -10,3 +10,6 @@ var MainnetChainYml string
 
 //go:embed prater.chain.yml
 var PraterChainYml string
+
+//go:embed zond-devnet.chain.yml
+var ZondDevnetChainYml string
@@ -152,6 +152,15 @@ func GetEth1Deposits(address string, length, start uint64) ([]*types.EthOneDepos
 
 var searchLikeHash = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{2,96}`) // only search for pubkeys if string consists of 96 hex-chars
 
+// searchQueryHex strips the prefix of a hex search query. Complete addresses are
+// accepted with a Z or 0x prefix, anything else only with the 0x prefix.
+func searchQueryHex(query string) string {
+	if address, err := utils.ParseAddress(query); err == nil {
+		return address.Hex()
+	}
+	return strings.Replace(query, "0x", "", -1)
+}
+
 func GetEth1DepositsJoinEth2Deposits(query string, length, start uint64, orderBy, orderDir string, latestEpoch, validatorOnlineThresholdSlot uint64) ([]*types.EthOneDepositsData, uint64, error) {
 	deposits := []*types.EthOneDepositsData{}
 
@@ -173,7 +182,7 @@ func GetEth1DepositsJoinEth2Deposits(query string, length, start uint64, orderBy
 	var totalCount uint64
 	var err error
 
-	query = strings.Replace(query, "0x", "", -1)
+	query = searchQueryHex(query)
 
 	if searchLikeHash.MatchString(query) {
 		if query != "" {
@@ -544,18 +553,38 @@ func GetCurrentDayClIncome(validator_indices []uint64) (map[uint64]int64, error)
 	return dayIncome, nil
 }
 
-// GetValidatorIndex will return the validator-index for a public key from the database
+// GetValidatorIndex will return the validator-index for a public key (or its hashed short form) from the database
 func GetValidatorIndex(publicKey []byte) (uint64, error) {
 	var index uint64
-	err := ReaderDb.Get(&index, "SELECT validatorindex FROM validators WHERE pubkey = $1", publicKey)
+	err := ReaderDb.Get(&index, "SELECT validatorindex FROM validators WHERE pubkey = $1 OR pubkeyhash = $1", publicKey)
 
 	return index, err
 }
 
-// GetValidatorDeposits will return eth1- and eth2-deposits for a public key from the database
+// GetValidatorPubkey will resolve the hashed short form of a validator public key to the full key.
+// Full keys of the configured signature scheme are returned as is.
+func GetValidatorPubkey(publicKey []byte) ([]byte, error) {
+	if len(publicKey) == utils.PubkeyLength() {
+		return publicKey, nil
+	}
+	if len(publicKey) != utils.PubkeyHashLength {
+		return nil, fmt.Errorf("invalid validator public key length %d", len(publicKey))
+	}
+
+	var pubkey []byte
+	err := ReaderDb.Get(&pubkey, "SELECT pubkey FROM validators WHERE pubkeyhash = $1", publicKey)
+	return pubkey, err
+}
+
+// GetValidatorDeposits will return eth1- and eth2-deposits for a public key (or its hashed short form) from the database
 func GetValidatorDeposits(publicKey []byte) (*types.ValidatorDeposits, error) {
+	publicKey, err := GetValidatorPubkey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
 	deposits := &types.ValidatorDeposits{}
-	err := ReaderDb.Select(&deposits.Eth1Deposits, `
+	err = ReaderDb.Select(&deposits.Eth1Deposits, `
 		SELECT tx_hash, tx_input, tx_index, block_number, EXTRACT(epoch FROM block_ts)::INT as block_ts, from_address, publickey, withdrawal_credentials, amount, signature, merkletree_index, valid_signature
 		FROM eth1_deposits WHERE publickey = $1 ORDER BY block_number ASC`, publicKey)
 	if err != nil {
@@ -2259,7 +2288,7 @@ func GetWithdrawals(query string, length, start uint64, orderBy, orderDir string
 	}
 
 	if query != "" {
-		bquery, _ := hex.DecodeString(strings.TrimPrefix(query, "0x"))
+		bquery, _ := hex.DecodeString(searchQueryHex(query))
 
 		err := ReaderDb.Select(&withdrawals, fmt.Sprintf(`
 			SELECT 
@@ -2847,7 +2876,7 @@ func GetBLSChanges(query string, length, start uint64, orderBy, orderDir string)
 
 	if query != "" {
 
-		bquery, _ := hex.DecodeString(strings.TrimPrefix(query, "0x"))
+		bquery, _ := hex.DecodeString(searchQueryHex(query))
 		err := ReaderDb.Select(&blsChange, fmt.Sprintf(`
 			SELECT 
 				bls.block_slot as slot,
@@ -2859,6 +2888,7 @@ func GetBLSChanges(query string, length, start uint64, orderBy, orderDir string)
 			INNER JOIN blocks b ON bls.block_root = b.blockroot AND b.status = '1'
 			WHERE CAST(bls.validatorindex as varchar) LIKE $3 || '%%'
 				OR pubkey LIKE $4::bytea || '%%'::bytea
+				OR bls.address LIKE $4::bytea || '%%'::bytea
 				OR CAST(block_slot as varchar) LIKE $3 || '%%'
 				OR CAST((block_slot / $5) as varchar) LIKE $3 || '%%'
 			ORDER BY bls.%s %s
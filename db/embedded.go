@@ -0,0 +1,1796 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/proto"
+)
+
+// Key prefixes of the embedded storage backend. Numbers are zero padded so the keys of a prefix sort by them.
+const (
+	embeddedChainIdKey       = "chainid"
+	embeddedBlockPrefix      = "b:"   // b:<number> -> proto encoded block
+	embeddedDocumentPrefix   = "d:"   // d:<_id> -> bson encoded data document
+	embeddedAddressPrefix    = "a:"   // a:<kind>:<address>:<block>:<tx index>:<position>:<direction> -> _id of an address index document
+	embeddedTokenPrefix      = "t:"   // t:<kind>:<token>:<block>:<tx index>:<position>:<direction> -> _id of an address index document
+	embeddedJournalPrefix    = "j:"   // j:<number>:<hash> -> keys written while indexing the block
+	embeddedUpdatePrefix     = "u:"   // u:<marker key>:<token> -> bson encoded balance update marker
+	embeddedHoldingPrefix    = "n:"   // n:<address>:<token>:<token id> -> bson encoded nft holding
+	embeddedHolderPrefix     = "o:"   // o:<token>:<token id>:<address> -> bson encoded nft holding
+	embeddedTokenQueuePrefix = "q:"   // q:<_id> -> bson encoded token or nft metadata update marker
+	embeddedBalancePrefix    = "m:b:" // m:b:<address>:<token> -> bson encoded balance
+	embeddedNamePrefix       = "m:n:" // m:n:<address> -> name
+	embeddedERC20Prefix      = "m:e:" // m:e:<token> -> bson encoded erc20 metadata
+	embeddedContractPrefix   = "m:c:" // m:c:<address> -> bson encoded contract metadata
+	embeddedRegistryPrefix   = "m:t:" // m:t:<token> -> bson encoded token registry entry
+	embeddedNFTPrefix        = "m:f:" // m:f:<token>:<token id> -> bson encoded nft metadata
+	embeddedVerifiedPrefix   = "m:v:" // m:v:<code hash> -> bson encoded metadata of verified code
+	embeddedEventPrefix      = "e:"   // e:<collection>:<block>:<tx index>:<log index> -> bson encoded contract event or log
+	embeddedCheckpointPrefix = "c:"   // c:<name> -> big endian block number
+	embeddedCursorPrefix     = "x:"   // x:<name>:<version> -> bson encoded transform cursor
+	embeddedReorgPrefix      = "r:"   // r:<detected at> -> bson encoded reorg
+	embeddedGasNowPrefix     = "g:"   // g:<unix time> -> bson encoded gas now series
+	embeddedSignaturePrefix  = "s:"   // s:<selector>:<signature> -> bson encoded signature
+)
+
+var _ interfaces.Database = (*Embedded)(nil)
+
+// Embedded is a storage backend keeping the execution layer data of a single chain in an on-disk goleveldb database,
+// for small testnets and local development without a mongodb server. It stores the documents produced by the mongodb
+// transforms as is, lookups of missing documents fail with mongo.ErrNoDocuments like the mongodb backend.
+type Embedded struct {
+	ChainId string
+
+	ldb *leveldb.DB
+	// documents runs the transforms, they don't access the mongodb server
+	documents *Mongo
+	// mux serializes read-modify-write updates like the write journals and checkpoints, it is shared by the handles of
+	// a database
+	mux *sync.Mutex
+	dir string
+}
+
+// ErrEmbeddedInUse is returned by InitEmbedded if another process holds the lock of the directory
+var ErrEmbeddedInUse = errors.New("embedded database is in use by another process")
+
+// embeddedDatabase is an open database and the number of handles using it
+type embeddedDatabase struct {
+	ldb     *leveldb.DB
+	mux     sync.Mutex
+	handles int
+}
+
+// embeddedDatabases are the open databases by directory. goleveldb locks the directory exclusively, so the handles of
+// a process share one database, e.g. to serve the api from the indexer process.
+var embeddedDatabases = struct {
+	sync.Mutex
+	byDir map[string]*embeddedDatabase
+}{byDir: make(map[string]*embeddedDatabase)}
+
+// InitEmbedded opens or creates the embedded database in the passed directory and makes it the storage backend.
+// A directory is bound to the chain it was created for. Handles of the same directory within a process share the
+// database, a directory opened by another process fails with ErrEmbeddedInUse.
+func InitEmbedded(dir, chainId string) (*Embedded, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving embedded database directory %v: %w", dir, err)
+	}
+
+	embeddedDatabases.Lock()
+	defer embeddedDatabases.Unlock()
+
+	database, ok := embeddedDatabases.byDir[abs]
+	if !ok {
+		ldb, err := leveldb.OpenFile(abs, nil)
+		if errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EAGAIN) {
+			return nil, fmt.Errorf("error opening embedded database in %v: %w", dir, ErrEmbeddedInUse)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error opening embedded database in %v: %w", dir, err)
+		}
+		database = &embeddedDatabase{ldb: ldb}
+	}
+	ldb := database.ldb
+
+	stored, err := ldb.Get([]byte(embeddedChainIdKey), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		err = ldb.Put([]byte(embeddedChainIdKey), []byte(chainId), nil)
+	} else if err == nil && string(stored) != chainId {
+		err = fmt.Errorf("embedded database in %v belongs to chain %s, not %s", dir, stored, chainId)
+	}
+	if err == nil && !ok {
+		err = upgradeEmbeddedNFTHoldings(ldb, chainId)
+	}
+	if err != nil {
+		if !ok {
+			ldb.Close()
+		}
+		return nil, err
+	}
+
+	database.handles++
+	embeddedDatabases.byDir[abs] = database
+
+	embedded := &Embedded{
+		ChainId:   chainId,
+		ldb:       ldb,
+		documents: &Mongo{ChainId: chainId},
+		mux:       &database.mux,
+		dir:       abs,
+	}
+	Storage = embedded
+	return embedded, nil
+}
+
+// upgradeEmbeddedNFTHoldings replaces the holder keys of databases written before the nft holdings existed with the
+// holdings summed from the stored nft deltas
+func upgradeEmbeddedNFTHoldings(ldb *leveldb.DB, chainId string) error {
+	batch := new(leveldb.Batch)
+	iter := ldb.NewIterator(util.BytesPrefix([]byte("h:")), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("error retrieving nft holder keys: %w", err)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	embedded := &Embedded{ChainId: chainId, ldb: ldb, documents: &Mongo{ChainId: chainId}}
+	deltas := []*entity.NFTDelta{}
+	iter = ldb.NewIterator(util.BytesPrefix([]byte(embeddedDocumentPrefix+embedded.documents.documentID(NFT_DELTA)+":")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		delta := &entity.NFTDelta{}
+		if err := bson.Unmarshal(iter.Value(), delta); err != nil {
+			return fmt.Errorf("error decoding nft delta %s: %w", iter.Key(), err)
+		}
+		deltas = append(deltas, delta)
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("error retrieving nft deltas: %w", err)
+	}
+
+	changes := embedded.documents.nftHoldingChanges(deltas, nil)
+	if err := embedded.updateNFTHoldings(batch, changes); err != nil {
+		return err
+	}
+	logger.Infof("rebuilt %v nft holdings of the embedded database from its nft deltas", len(changes))
+	return ldb.Write(batch, nil)
+}
+
+// Close releases the handle, the database is closed with its last handle
+func (embedded *Embedded) Close() {
+	embeddedDatabases.Lock()
+	defer embeddedDatabases.Unlock()
+
+	database, ok := embeddedDatabases.byDir[embedded.dir]
+	if !ok || database.ldb != embedded.ldb {
+		return
+	}
+	database.handles--
+	if database.handles > 0 {
+		return
+	}
+	delete(embeddedDatabases.byDir, embedded.dir)
+	if err := embedded.ldb.Close(); err != nil {
+		logger.Errorf("error closing embedded database: %v", err)
+	}
+}
+
+func embeddedBlockKey(number uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", embeddedBlockPrefix, number))
+}
+
+func embeddedJournalKey(number uint64, hash []byte) []byte {
+	return []byte(fmt.Sprintf("%s%020d:%x", embeddedJournalPrefix, number, hash))
+}
+
+// embeddedAddressIndexKeys returns the keys under which an address index document is found by address and by token,
+// the position part of the keys sorts in the (block, tx, position) order of the address indexes
+func embeddedAddressIndexKeys(idx *entity.AddressIndex) [][]byte {
+	position := (&addressIndexCursor{BlockNumber: idx.BlockNumber, TxIndex: idx.TxIndex, Position: idx.Position}).sortKey()
+	keys := [][]byte{[]byte(fmt.Sprintf("%s%s:%x:%s:%s", embeddedAddressPrefix, idx.Kind, idx.Address, position, idx.Direction))}
+	if len(idx.Token) > 0 {
+		keys = append(keys, []byte(fmt.Sprintf("%s%s:%x:%s:%s", embeddedTokenPrefix, idx.Kind, idx.Token, position, idx.Direction)))
+	}
+	return keys
+}
+
+// embeddedLogPosition is the position of a contract event or a log of the logs collection in the chain
+type embeddedLogPosition struct {
+	BlockNumber uint64
+	TxIndex     uint64
+	LogIndex    uint64
+}
+
+// embeddedEventKey returns the key of a contract event or a log, the keys of a collection sort in chain order
+func embeddedEventKey(collection string, position *embeddedLogPosition) string {
+	return fmt.Sprintf("%s%s:%020d:%010d:%010d", embeddedEventPrefix, collection, position.BlockNumber, position.TxIndex, position.LogIndex)
+}
+
+// embeddedNFTHoldingKeys returns the keys of a holding by holder and by token id. Token ids are zero padded to sort by
+// value like the minimal big endian token ids of the mongodb documents.
+func embeddedNFTHoldingKeys(token, tokenId, address []byte) ([]byte, []byte) {
+	id := fmt.Sprintf("%064x", new(big.Int).SetBytes(tokenId))
+	return []byte(fmt.Sprintf("%s%x:%x:%s", embeddedHoldingPrefix, address, token, id)),
+		[]byte(fmt.Sprintf("%s%x:%s:%x", embeddedHolderPrefix, token, id, address))
+}
+
+func (c *addressIndexCursor) sortKey() string {
+	return fmt.Sprintf("%020d:%010d:%010d", c.BlockNumber, c.TxIndex, c.Position)
+}
+
+// getDocument decodes the data document with the passed id into result
+func (embedded *Embedded) getDocument(id string, result interface{}) error {
+	raw, err := embedded.ldb.Get([]byte(embeddedDocumentPrefix+id), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return fmt.Errorf("document %v: %w", id, mongo.ErrNoDocuments)
+	}
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+func (embedded *Embedded) SaveBlocks(blocks []*types.Eth1Block) error {
+	batch := new(leveldb.Batch)
+	for _, block := range blocks {
+		raw, err := proto.Marshal(block)
+		if err != nil {
+			return fmt.Errorf("error encoding block %v: %w", block.Number, err)
+		}
+		batch.Put(embeddedBlockKey(block.Number), raw)
+	}
+	return embedded.ldb.Write(batch, nil)
+}
+
+func (embedded *Embedded) GetBlockFromBlocksTable(number uint64) (*types.Eth1Block, error) {
+	raw, err := embedded.ldb.Get(embeddedBlockKey(number), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, ErrBlockNotFound
+		}
+		return nil, err
+	}
+
+	block := &types.Eth1Block{}
+	if err := proto.Unmarshal(raw, block); err != nil {
+		return nil, fmt.Errorf("error decoding block %v: %w", number, err)
+	}
+	return block, nil
+}
+
+// lastNumbers returns up to limit numbers of the keys with the passed prefix, highest first. The keys have to end
+// with the zero padded number.
+func (embedded *Embedded) lastNumbers(prefix string, limit int) ([]uint64, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	numbers := []uint64{}
+	for ok := iter.Last(); ok && len(numbers) < limit; ok = iter.Prev() {
+		number, err := strconv.ParseUint(strings.TrimPrefix(string(iter.Key()), prefix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", iter.Key(), err)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, iter.Error()
+}
+
+func (embedded *Embedded) GetLastBlockInBlocksTable() (int, error) {
+	numbers, err := embedded.lastNumbers(embeddedBlockPrefix, 1)
+	if err != nil || len(numbers) == 0 {
+		return 0, err
+	}
+	return int(numbers[0]), nil
+}
+
+func (embedded *Embedded) CheckForGapsInBlocksTable(lookback int) (gapFound bool, start int, end int, err error) {
+	numbers, err := embedded.lastNumbers(embeddedBlockPrefix, lookback)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i-1] != numbers[i]+1 {
+			logger.Errorf("found gap between block %v and block %v in blocks table", numbers[i-1], numbers[i])
+			return true, int(numbers[i]), int(numbers[i-1]), nil
+		}
+	}
+	return false, 0, 0, nil
+}
+
+func (embedded *Embedded) GetLastBlockInDataTable() (int, error) {
+	numbers, err := embedded.lastNumbers(embeddedDocumentPrefix+embedded.documents.documentID("blockindex")+":", 1)
+	if err != nil || len(numbers) == 0 {
+		return 0, err
+	}
+	return int(numbers[0]), nil
+}
+
+func (embedded *Embedded) CheckForGapsInDataTable(lookback int) error {
+	numbers, err := embedded.lastNumbers(embeddedDocumentPrefix+embedded.documents.documentID("blockindex")+":", lookback)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i-1] != numbers[i]+1 {
+			logger.Errorf("found gap between block %v and block %v in data table", numbers[i-1], numbers[i])
+			break
+		}
+	}
+	return nil
+}
+
+// SaveCheckpoint persists the highest contiguous block processed by the named job, the stored value never moves backwards
+func (embedded *Embedded) SaveCheckpoint(name string, block uint64) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	current, err := embedded.GetCheckpoint(name)
+	if err != nil && !errors.Is(err, ErrCheckpointNotFound) {
+		return err
+	}
+	if err == nil && current >= block {
+		return nil
+	}
+	return embedded.ResetCheckpoint(name, block)
+}
+
+// ResetCheckpoint sets the checkpoint of the named job to the passed block, even if it is lower than the stored value
+func (embedded *Embedded) ResetCheckpoint(name string, block uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, block)
+	return embedded.ldb.Put([]byte(embeddedCheckpointPrefix+name), value, nil)
+}
+
+func (embedded *Embedded) GetCheckpoint(name string) (uint64, error) {
+	value, err := embedded.ldb.Get([]byte(embeddedCheckpointPrefix+name), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return 0, ErrCheckpointNotFound
+		}
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// SaveTransformCursor persists the highest contiguous block processed by the version of the named transform, the stored
+// value never moves backwards
+func (embedded *Embedded) SaveTransformCursor(name string, version uint64, block uint64) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	key := []byte(fmt.Sprintf("%s%s:%020d", embeddedCursorPrefix, name, version))
+	cursor := &entity.TransformCursor{}
+	value, err := embedded.ldb.Get(key, nil)
+	if err == nil {
+		if err := bson.Unmarshal(value, cursor); err != nil {
+			return fmt.Errorf("error while parsing cursor of transform %v: %w", name, err)
+		}
+		if cursor.Block >= block {
+			return nil
+		}
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+
+	raw, err := bson.Marshal(&entity.TransformCursor{
+		ChainId:   embedded.ChainId,
+		Name:      name,
+		Version:   version,
+		Block:     block,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return embedded.ldb.Put(key, raw, nil)
+}
+
+// GetTransformCursors returns the cursors of all transforms and versions, ordered by name and version
+func (embedded *Embedded) GetTransformCursors() ([]*entity.TransformCursor, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedCursorPrefix)), nil)
+	defer iter.Release()
+
+	cursors := []*entity.TransformCursor{}
+	for iter.Next() {
+		cursor := &entity.TransformCursor{}
+		if err := bson.Unmarshal(iter.Value(), cursor); err != nil {
+			return nil, fmt.Errorf("error while parsing transform cursors: %w", err)
+		}
+		cursors = append(cursors, cursor)
+	}
+	return cursors, iter.Error()
+}
+
+// DataTransforms returns all transforms writing to the data table with their names and versions
+func (embedded *Embedded) DataTransforms() []*types.Eth1Transform {
+	return embedded.documents.DataTransforms()
+}
+
+func (embedded *Embedded) TransformBlock(block *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformBlock(block, cache)
+}
+
+func (embedded *Embedded) TransformTx(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformTx(blk, cache)
+}
+
+func (embedded *Embedded) TransformItx(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformItx(blk, cache)
+}
+
+func (embedded *Embedded) TransformERC20(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformERC20(blk, cache)
+}
+
+func (embedded *Embedded) TransformERC721(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformERC721(blk, cache)
+}
+
+func (embedded *Embedded) TransformERC1155(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformERC1155(blk, cache)
+}
+
+func (embedded *Embedded) TransformWithdrawals(block *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformWithdrawals(block, cache)
+}
+
+func (embedded *Embedded) TransformBalances(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformBalances(blk, cache)
+}
+
+func (embedded *Embedded) TransformNFTs(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformNFTs(blk, cache)
+}
+
+func (embedded *Embedded) TransformLogs(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformLogs(blk, cache)
+}
+
+// modelDocument returns the id and the document written by an upsert or insert model, the id of inserted documents
+// without one is empty
+func modelDocument(model mongo.WriteModel) (string, *bson.D, error) {
+	var id interface{}
+	var doc interface{}
+	switch m := model.(type) {
+	case *mongo.ReplaceOneModel:
+		doc = m.Replacement
+		if filter, ok := m.Filter.(bson.D); ok && len(filter) == 1 && filter[0].Key == "_id" {
+			id = filter[0].Value
+		}
+	case *mongo.InsertOneModel:
+		doc = m.Document
+		id = ""
+	default:
+		return "", nil, fmt.Errorf("unsupported write model %T", model)
+	}
+
+	d, ok := doc.(*bson.D)
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported document type %T", doc)
+	}
+	idString, ok := id.(string)
+	if !ok {
+		return "", nil, fmt.Errorf("write model does not select a string _id")
+	}
+	return idString, d, nil
+}
+
+// WriteBlockMutations writes the documents produced by the transforms of a block in a single batch and records the
+// written keys in the write journal of the block, so DeleteBlock can remove them again if the block gets reorged out
+// of the chain. Balance and token update markers are not journaled, they outlive a removed block to refresh the balances
+// and tokens it touched. The nft deltas of the block are added to the nft holdings in the same batch.
+func (embedded *Embedded) WriteBlockMutations(block *types.Eth1Block, bulkData *types.BulkMutations, metadataUpdates []mongo.WriteModel) error {
+	batch := new(leveldb.Batch)
+	written := []string{}
+	nftDeltaKeys := []string{}
+	nftDeltas := []*entity.NFTDelta{}
+
+	for _, model := range bulkData.Model {
+		id, doc, err := modelDocument(model)
+		if err != nil {
+			return fmt.Errorf("error writing data of block %v: %w", block.Number, err)
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		key := embeddedDocumentPrefix + id
+		batch.Put([]byte(key), raw)
+		written = append(written, key)
+
+		switch docType, _ := bson.Raw(raw).Lookup("type").StringValueOK(); docType {
+		case ADDRESS_INDEX:
+			idx := &entity.AddressIndex{}
+			if err := bson.Unmarshal(raw, idx); err != nil {
+				return fmt.Errorf("error decoding address index %v: %w", id, err)
+			}
+			for _, indexKey := range embeddedAddressIndexKeys(idx) {
+				batch.Put(indexKey, []byte(id))
+				written = append(written, string(indexKey))
+			}
+		case NFT_DELTA:
+			delta := &entity.NFTDelta{}
+			if err := bson.Unmarshal(raw, delta); err != nil {
+				return fmt.Errorf("error decoding nft delta %v: %w", id, err)
+			}
+			nftDeltaKeys = append(nftDeltaKeys, key)
+			nftDeltas = append(nftDeltas, delta)
+		}
+	}
+
+	for collection, models := range bulkData.Collections {
+		for _, model := range models {
+			_, doc, err := modelDocument(model)
+			if err != nil {
+				return fmt.Errorf("error writing %v of block %v: %w", collection, block.Number, err)
+			}
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			position := &embeddedLogPosition{}
+			if err := bson.Unmarshal(raw, position); err != nil {
+				return fmt.Errorf("error decoding %v document of block %v: %w", collection, block.Number, err)
+			}
+			key := embeddedEventKey(collection, position)
+			batch.Put([]byte(key), raw)
+			written = append(written, key)
+		}
+	}
+
+	for _, model := range metadataUpdates {
+		id, doc, err := modelDocument(model)
+		if err != nil {
+			return fmt.Errorf("error writing metadata updates of block %v: %w", block.Number, err)
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if docType, _ := bson.Raw(raw).Lookup("type").StringValueOK(); docType == TOKEN_UPDATE || docType == NFT_METADATA_UPDATE {
+			batch.Put([]byte(embeddedTokenQueuePrefix+id), raw)
+			continue
+		}
+		marker := &entity.BalanceUpdates{}
+		if err := bson.Unmarshal(raw, marker); err != nil {
+			return fmt.Errorf("error decoding balance update of block %v: %w", block.Number, err)
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%s:%x", embeddedUpdatePrefix, marker.Key, marker.Token)), raw)
+	}
+
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	// rewriting a block replaces its stored nft deltas, their changes are taken back from the holdings
+	replaced := []*entity.NFTDelta{}
+	for _, key := range nftDeltaKeys {
+		delta := &entity.NFTDelta{}
+		if err := embedded.getDocument(strings.TrimPrefix(key, embeddedDocumentPrefix), delta); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			return err
+		}
+		replaced = append(replaced, delta)
+	}
+	if err := embedded.updateNFTHoldings(batch, embedded.documents.nftHoldingChanges(nftDeltas, replaced)); err != nil {
+		return fmt.Errorf("error updating nft holdings of block %v: %w", block.Number, err)
+	}
+
+	journal, err := embedded.journal(block.Number, block.Hash)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(journal))
+	for _, key := range journal {
+		seen[key] = true
+	}
+	for _, key := range written {
+		if !seen[key] {
+			seen[key] = true
+			journal = append(journal, key)
+		}
+	}
+	raw, err := bson.Marshal(bson.D{{Key: "keys", Value: journal}})
+	if err != nil {
+		return err
+	}
+	batch.Put(embeddedJournalKey(block.Number, block.Hash), raw)
+
+	return embedded.ldb.Write(batch, nil)
+}
+
+// updateNFTHoldings adds the changes to the stored nft holdings in the batch and removes the holdings whose balance
+// dropped to zero. The caller holds the mux, so the stored balances don't change before the batch is written.
+func (embedded *Embedded) updateNFTHoldings(batch *leveldb.Batch, changes []*nftHoldingChange) error {
+	for _, change := range changes {
+		holding := change.holding
+		byHolder, byToken := embeddedNFTHoldingKeys(holding.Token, holding.TokenId, holding.Address)
+
+		balance := new(big.Int)
+		raw, err := embedded.ldb.Get(byHolder, nil)
+		if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			stored := &entity.NFTHolding{}
+			if err := bson.Unmarshal(raw, stored); err != nil {
+				return fmt.Errorf("error decoding nft holding %v: %w", change.id, err)
+			}
+			if balance, err = decimalToBigInt(stored.Balance); err != nil {
+				return fmt.Errorf("error decoding balance of nft holding %v: %w", change.id, err)
+			}
+		}
+		balance.Add(balance, change.value)
+
+		if balance.Sign() == 0 {
+			batch.Delete(byHolder)
+			batch.Delete(byToken)
+			continue
+		}
+		var ok bool
+		holding.Balance, ok = primitive.ParseDecimal128FromBigInt(balance, 0)
+		if !ok {
+			logger.Warnf("skipping change %v of nft holding %v, its balance does not fit a decimal", change.value, change.id)
+			continue
+		}
+		raw, err = bson.Marshal(holding)
+		if err != nil {
+			return err
+		}
+		batch.Put(byHolder, raw)
+		batch.Put(byToken, raw)
+	}
+	return nil
+}
+
+// journal returns the keys written while indexing the block
+func (embedded *Embedded) journal(number uint64, hash []byte) ([]string, error) {
+	raw, err := embedded.ldb.Get(embeddedJournalKey(number, hash), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	journal := struct{ Keys []string }{}
+	if err := bson.Unmarshal(raw, &journal); err != nil {
+		return nil, fmt.Errorf("error decoding write journal of block %v: %w", number, err)
+	}
+	return journal.Keys, nil
+}
+
+// DeleteBlock removes a block and every key written while indexing it in a single batch. Tokens and token ids touched
+// by the block are queued for a refresh against the canonical chain, tokens first seen in the block are removed from
+// the token registry and its nft deltas are subtracted from the nft holdings.
+func (embedded *Embedded) DeleteBlock(blockNumber uint64, blockHash []byte) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	journal, err := embedded.journal(blockNumber, blockHash)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	docs := []bson.Raw{}
+	for _, key := range journal {
+		if strings.HasPrefix(key, embeddedDocumentPrefix) {
+			raw, err := embedded.ldb.Get([]byte(key), nil)
+			if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+				return err
+			}
+			if err == nil {
+				docs = append(docs, raw)
+			}
+		}
+		batch.Delete([]byte(key))
+	}
+	batch.Delete(embeddedJournalKey(blockNumber, blockHash))
+
+	tokens, markers, err := embedded.documents.tokenMarkersFor(docs)
+	if err != nil {
+		return err
+	}
+	removed := []*entity.NFTDelta{}
+	for _, raw := range docs {
+		if docType, _ := raw.Lookup("type").StringValueOK(); docType != NFT_DELTA {
+			continue
+		}
+		delta := &entity.NFTDelta{}
+		if err := bson.Unmarshal(raw, delta); err != nil {
+			return fmt.Errorf("error decoding nft delta of block %v: %w", blockNumber, err)
+		}
+		removed = append(removed, delta)
+	}
+	if err := embedded.updateNFTHoldings(batch, embedded.documents.nftHoldingChanges(nil, removed)); err != nil {
+		return fmt.Errorf("error reverting nft holdings of block %v: %w", blockNumber, err)
+	}
+	for _, token := range tokens {
+		stored, err := embedded.GetToken(token)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if err == nil && stored.FirstBlock >= blockNumber {
+			batch.Delete([]byte(fmt.Sprintf("%s%x", embeddedRegistryPrefix, token)))
+		}
+	}
+	for _, marker := range markers {
+		key := []byte(embeddedTokenQueuePrefix + marker.id)
+		queued, err := embedded.ldb.Has(key, nil)
+		if err != nil {
+			return err
+		}
+		if queued {
+			continue
+		}
+		raw, err := bson.Marshal(marker.doc)
+		if err != nil {
+			return err
+		}
+		batch.Put(key, raw)
+	}
+
+	block, err := embedded.GetBlockFromBlocksTable(blockNumber)
+	if err != nil && !errors.Is(err, ErrBlockNotFound) {
+		return err
+	}
+	if block != nil && bytes.Equal(block.Hash, blockHash) {
+		batch.Delete(embeddedBlockKey(blockNumber))
+	}
+
+	return embedded.ldb.Write(batch, nil)
+}
+
+// SaveReorg records a chain reorganization handled by the indexer
+func (embedded *Embedded) SaveReorg(reorg *entity.Reorg) error {
+	reorg.ChainId = embedded.ChainId
+	raw, err := bson.Marshal(reorg)
+	if err != nil {
+		return err
+	}
+	return embedded.ldb.Put([]byte(fmt.Sprintf("%s%020d", embeddedReorgPrefix, reorg.DetectedAt.UnixNano())), raw, nil)
+}
+
+// GetReorgs returns the most recent chain reorganizations, newest first
+func (embedded *Embedded) GetReorgs(limit int64) ([]*entity.Reorg, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedReorgPrefix)), nil)
+	defer iter.Release()
+
+	reorgs := []*entity.Reorg{}
+	for ok := iter.Last(); ok && int64(len(reorgs)) < limit; ok = iter.Prev() {
+		reorg := &entity.Reorg{}
+		if err := bson.Unmarshal(iter.Value(), reorg); err != nil {
+			return nil, fmt.Errorf("error while parsing reorgs: %w", err)
+		}
+		reorgs = append(reorgs, reorg)
+	}
+	return reorgs, iter.Error()
+}
+
+func (embedded *Embedded) GetBlocksIndexedMultiple(blockNumbers []uint64, limit uint64) ([]*types.Eth1BlockIndexed, error) {
+	blocks := make([]*types.Eth1BlockIndexed, 0, len(blockNumbers))
+	for _, number := range blockNumbers {
+		if uint64(len(blocks)) >= limit {
+			break
+		}
+		result := &entity.BlockIndex{}
+		err := embedded.getDocument(embedded.documents.documentID("blockindex", fmt.Sprintf("%09d", number)), result)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing block data: %w", err)
+		}
+		blocks = append(blocks, blockIndexed(result))
+	}
+	return blocks, nil
+}
+
+func (embedded *Embedded) GetIndexedEth1Transaction(txHash []byte) (*types.Eth1TransactionIndexed, error) {
+	result := &entity.TransactionIndex{}
+	err := embedded.getDocument(embedded.documents.documentID("transactionindex", txHash), result)
+	if err != nil {
+		return nil, err
+	}
+	return transactionIndexed(result), nil
+}
+
+// GetEth1TransactionWithBlock returns the full transaction with its receipt, logs and internal transactions from the
+// blocks table, together with the block containing it and its position within the block
+func (embedded *Embedded) GetEth1TransactionWithBlock(txHash []byte) (*types.Eth1Transaction, *types.Eth1Block, int, error) {
+	indexed, err := embedded.GetIndexedEth1Transaction(txHash)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	block, err := embedded.GetBlockFromBlocksTable(indexed.BlockNumber)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error retrieving block %v of tx 0x%x: %w", indexed.BlockNumber, txHash, err)
+	}
+
+	for i, tx := range block.Transactions {
+		if bytes.Equal(tx.Hash, txHash) {
+			return tx, block, i, nil
+		}
+	}
+
+	return nil, nil, 0, fmt.Errorf("tx 0x%x not found in block %v: %w", txHash, indexed.BlockNumber, mongo.ErrNoDocuments)
+}
+
+// GetTokenTransfersForTransaction returns the erc20, erc721 and erc1155 transfers emitted by a transaction
+func (embedded *Embedded) GetTokenTransfersForTransaction(txHash []byte) ([]*types.Eth1ERC20Indexed, []*types.Eth1ERC721Indexed, []*types.ETh1ERC1155Indexed, error) {
+	docs := []bson.Raw{}
+	for _, kind := range []string{"erc20index", "erc721index", "erc1155index"} {
+		iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedDocumentPrefix+embedded.documents.documentID(kind, txHash)+":")), nil)
+		for iter.Next() {
+			docs = append(docs, bson.Raw(append([]byte{}, iter.Value()...)))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, nil, nil, fmt.Errorf("error retrieving token transfers of tx 0x%x: %w", txHash, err)
+		}
+	}
+
+	return tokenTransfersFromDocuments(docs)
+}
+
+// GetAddressIndexes returns up to limit address index documents matching the query, most recent first.
+// The returned page token continues after the last returned document and is empty if there are no more documents.
+func (embedded *Embedded) GetAddressIndexes(query *AddressIndexQuery, pageToken string, limit int64) ([]*entity.AddressIndex, string, error) {
+	var prefix string
+	switch {
+	case len(query.Address) > 0:
+		prefix = fmt.Sprintf("%s%s:%x:", embeddedAddressPrefix, query.Kind, query.Address)
+	case len(query.Token) > 0:
+		prefix = fmt.Sprintf("%s%s:%x:", embeddedTokenPrefix, query.Kind, query.Token)
+	default:
+		return nil, "", fmt.Errorf("address index query requires an address or a token")
+	}
+	if len(query.Token) > 0 && query.Filter != nil && len(query.Filter.Token) > 0 {
+		return nil, "", fmt.Errorf("address index query and filter both select a token")
+	}
+
+	keyRange := util.BytesPrefix([]byte(prefix))
+	if pageToken != "" {
+		c, err := decodeAddressIndexCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		keyRange.Limit = []byte(prefix + c.sortKey())
+	}
+
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	indexes := make([]*entity.AddressIndex, 0, limit)
+	for ok := iter.Last(); ok && int64(len(indexes)) < limit; ok = iter.Prev() {
+		idx := &entity.AddressIndex{}
+		if err := embedded.getDocument(string(iter.Value()), idx); err != nil {
+			return nil, "", fmt.Errorf("error decoding %v address indexes: %w", query.Kind, err)
+		}
+		if matchesAddressIndexQuery(idx, query) {
+			indexes = append(indexes, idx)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, "", fmt.Errorf("error retrieving %v address indexes: %w", query.Kind, err)
+	}
+
+	nextPageToken := ""
+	if int64(len(indexes)) == limit && limit > 0 {
+		last := indexes[len(indexes)-1]
+		nextPageToken = (&addressIndexCursor{BlockNumber: last.BlockNumber, TxIndex: last.TxIndex, Position: last.Position}).encode()
+	}
+
+	return indexes, nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1TxForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1TransactionIndexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_TX, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := make([]*types.Eth1TransactionIndexed, 0, len(indexes))
+	for _, idx := range indexes {
+		result := &entity.TransactionIndex{}
+		err := embedded.getDocument(embedded.documents.documentID("transactionindex", idx.Hash), result)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			logger.Errorf("transaction %x referenced by address index %v not found", idx.Hash, idx.Key)
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("error while parsing transaction data: %w", err)
+		}
+		data = append(data, transactionIndexed(result))
+	}
+
+	return data, nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1ItxForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1InternalTransactionIndexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ITX, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return internalTransactionsFromIndexes(indexes), nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1BlocksForAddress(address []byte, pageToken string, limit int64) ([]*types.Eth1BlockIndexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_BLOCK, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := make([]*types.Eth1BlockIndexed, 0, len(indexes))
+	for _, idx := range indexes {
+		result := &entity.BlockIndex{}
+		err := embedded.getDocument(embedded.documents.documentID("blockindex", fmt.Sprintf("%09d", idx.BlockNumber)), result)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			logger.Errorf("block %v referenced by address index %v not found", idx.BlockNumber, idx.Key)
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("error while parsing block data: %w", err)
+		}
+		data = append(data, blockIndexed(result))
+	}
+
+	return data, nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1ERC20ForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC20, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return erc20TransfersFromIndexes(indexes), nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1ERC721ForAddress(address []byte, pageToken string, limit int64) ([]*types.Eth1ERC721Indexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC721, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return erc721TransfersFromIndexes(indexes), nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1ERC1155ForAddress(address []byte, pageToken string, limit int64) ([]*types.ETh1ERC1155Indexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC1155, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return erc1155TransfersFromIndexes(indexes), nextPageToken, nil
+}
+
+func (embedded *Embedded) GetEth1TxForToken(token []byte, address []byte, pageToken string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
+	indexes, nextPageToken, err := embedded.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC20, Address: address, Token: token}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return erc20TransfersFromIndexes(indexes), nextPageToken, nil
+}
+
+// GetBalanceDeltas returns the balance changes of the address for the token ordered by block, the document ids of an
+// address and token sort by block
+func (embedded *Embedded) GetBalanceDeltas(address []byte, token []byte, filter *types.BalanceDeltaFilter) ([]*types.Eth1BalanceDelta, error) {
+	prefix := embeddedDocumentPrefix + embedded.documents.documentID(BALANCE_DELTA, address, token) + ":"
+	keyRange := util.BytesPrefix([]byte(prefix))
+	if filter != nil && filter.FromBlock > 0 {
+		keyRange.Start = []byte(fmt.Sprintf("%s%020d", prefix, filter.FromBlock))
+	}
+	if filter != nil && filter.ToBlock > 0 {
+		keyRange.Limit = []byte(fmt.Sprintf("%s%020d", prefix, filter.ToBlock+1))
+	}
+
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	deltas := []*types.Eth1BalanceDelta{}
+	for iter.Next() {
+		delta := &entity.BalanceDelta{}
+		if err := bson.Unmarshal(iter.Value(), delta); err != nil {
+			return nil, fmt.Errorf("error decoding balance delta %s: %w", iter.Key(), err)
+		}
+		if filter != nil && !filter.FromTime.IsZero() && int64(delta.Time.T) < filter.FromTime.Unix() {
+			continue
+		}
+		deltas = append(deltas, balanceDeltaFromEntity(delta))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("error retrieving balance deltas of %x for token %x: %w", address, token, err)
+	}
+	return deltas, nil
+}
+
+// GetMetadataUpdates returns up to limit pending balance updates, the keys are passed to SaveBalances to remove them
+func (embedded *Embedded) GetMetadataUpdates(prefix string, startToken string, limit int) ([]string, []*types.Eth1AddressBalance, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedUpdatePrefix)), nil)
+	defer iter.Release()
+
+	keys := make([]string, 0, limit)
+	pairs := make([]*types.Eth1AddressBalance, 0, limit)
+	for iter.Next() && len(keys) < limit {
+		marker := &entity.BalanceUpdates{}
+		if err := bson.Unmarshal(iter.Value(), marker); err != nil {
+			return nil, nil, fmt.Errorf("error decoding balance update %s: %w", iter.Key(), err)
+		}
+		keys = append(keys, marker.Key)
+		pairs = append(pairs, &types.Eth1AddressBalance{
+			Address: marker.Address,
+			Token:   marker.Token,
+		})
+	}
+	return keys, pairs, iter.Error()
+}
+
+func (embedded *Embedded) SaveBalances(balances []*types.Eth1AddressBalance, deleteKeys []string) error {
+	batch := new(leveldb.Batch)
+	for _, balance := range balances {
+		raw, err := bson.Marshal(&types.Eth1AddressBalance{Address: balance.Address, Token: balance.Token, Balance: balance.Balance, BlockNumber: balance.BlockNumber})
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%x:%x", embeddedBalancePrefix, balance.Address, balance.Token)), raw)
+	}
+
+	for _, key := range deleteKeys {
+		iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedUpdatePrefix+key+":")), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return embedded.ldb.Write(batch, nil)
+}
+
+func (embedded *Embedded) GetMetadataForAddress(address []byte) (*types.Eth1AddressMetadata, error) {
+	ret := &types.Eth1AddressMetadata{
+		Balances: []*types.Eth1AddressBalance{},
+		ERC20:    &types.ERC20Metadata{},
+		Name:     "",
+		EthBalance: &types.Eth1AddressBalance{
+			Metadata: &types.ERC20Metadata{},
+		},
+	}
+
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(fmt.Sprintf("%s%x:", embeddedBalancePrefix, address))), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		balance := &types.Eth1AddressBalance{}
+		if err := bson.Unmarshal(iter.Value(), balance); err != nil {
+			return nil, fmt.Errorf("error decoding balance %s: %w", iter.Key(), err)
+		}
+		native := bytes.Equal(balance.Token, []byte{0x00})
+		if bytes.Equal(address, ZERO_ADDRESS) && !native { //do not return token balances for the zero address
+			continue
+		}
+		if !native && new(big.Int).SetBytes(balance.Balance).Sign() == 0 {
+			continue
+		}
+
+		metadata, err := embedded.GetERC20MetadataForAddress(balance.Token)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			metadata = &types.ERC20Metadata{}
+		} else if err != nil {
+			return nil, err
+		}
+		balance.Metadata = metadata
+
+		if native {
+			ret.EthBalance = balance
+		} else {
+			ret.Balances = append(ret.Balances, balance)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	name, err := embedded.GetAddressName(address)
+	if err != nil {
+		return nil, err
+	}
+	ret.Name = name
+
+	sortBalancesByValue(ret.Balances)
+
+	return ret, nil
+}
+
+func (embedded *Embedded) GetBalanceForAddress(address []byte, token []byte) (*types.Eth1AddressBalance, error) {
+	raw, err := embedded.ldb.Get([]byte(fmt.Sprintf("%s%x:%x", embeddedBalancePrefix, address, token)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("balance of %x for token %x: %w", address, token, mongo.ErrNoDocuments)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.Eth1AddressBalance{}
+	if err := bson.Unmarshal(raw, ret); err != nil {
+		return nil, err
+	}
+
+	metadata, err := embedded.GetERC20MetadataForAddress(token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the balance of a token is known before its metadata has been retrieved
+		metadata, err = &types.ERC20Metadata{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret.Metadata = metadata
+
+	return ret, nil
+}
+
+func (embedded *Embedded) GetERC20MetadataForAddress(address []byte) (*types.ERC20Metadata, error) {
+	if len(address) == 1 {
+		return &types.ERC20Metadata{
+			Decimals:    big.NewInt(18).Bytes(),
+			Symbol:      "Ether",
+			TotalSupply: []byte{},
+		}, nil
+	}
+
+	raw, err := embedded.ldb.Get([]byte(fmt.Sprintf("%s%x", embeddedERC20Prefix, address)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("erc20 metadata of %x: %w", address, mongo.ErrNoDocuments)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &types.ERC20Metadata{}
+	if err := bson.Unmarshal(raw, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// updateERC20Metadata merges the set fields of the passed metadata into the stored metadata of the token
+func (embedded *Embedded) updateERC20Metadata(address []byte, update *types.ERC20Metadata) error {
+	stored, err := embedded.GetERC20MetadataForAddress(address)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		stored = &types.ERC20Metadata{}
+	} else if err != nil {
+		return err
+	}
+
+	if len(update.Decimals) > 0 {
+		stored.Decimals = update.Decimals
+	}
+	if len(update.TotalSupply) > 0 {
+		stored.TotalSupply = update.TotalSupply
+	}
+	if len(update.Symbol) > 0 {
+		stored.Symbol = update.Symbol
+	}
+	if len(update.Name) > 0 {
+		stored.Name = update.Name
+	}
+	if len(update.Description) > 0 {
+		stored.Description = update.Description
+	}
+	if len(update.OfficialSite) > 0 {
+		stored.OfficialSite = update.OfficialSite
+	}
+	if len(update.Price) > 0 {
+		stored.Price = update.Price
+	}
+	if len(update.Logo) > 0 && len(update.LogoFormat) > 0 {
+		stored.Logo = update.Logo
+		stored.LogoFormat = update.LogoFormat
+	}
+
+	raw, err := bson.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return embedded.ldb.Put([]byte(fmt.Sprintf("%s%x", embeddedERC20Prefix, address)), raw, nil)
+}
+
+func (embedded *Embedded) SaveERC20Metadata(address []byte, metadata *types.ERC20Metadata) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	return embedded.updateERC20Metadata(address, metadata)
+}
+
+func (embedded *Embedded) SaveERC20TokenPrices(prices []*types.ERC20TokenPrice) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	for _, price := range prices {
+		err := embedded.updateERC20Metadata(price.Token, &types.ERC20Metadata{Price: price.Price, TotalSupply: price.TotalSupply})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (embedded *Embedded) GetAddressName(address []byte) (string, error) {
+	name, err := embedded.ldb.Get([]byte(fmt.Sprintf("%s%x", embeddedNamePrefix, address)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return "", nil
+	}
+	return string(name), err
+}
+
+func (embedded *Embedded) SaveAddressName(address []byte, name string) error {
+	return embedded.ldb.Put([]byte(fmt.Sprintf("%s%x", embeddedNamePrefix, address)), []byte(name), nil)
+}
+
+// GetTokenUpdates returns up to limit queued tokens whose metadata has to be retrieved, the keys are passed to
+// SaveTokens to remove them from the queue
+func (embedded *Embedded) GetTokenUpdates(limit int) ([]string, []*types.Eth1Token, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedTokenQueuePrefix+embedded.documents.documentID(TOKEN_UPDATE)+":")), nil)
+	defer iter.Release()
+
+	keys := make([]string, 0, limit)
+	tokens := make([]*types.Eth1Token, 0, limit)
+	for iter.Next() && len(keys) < limit {
+		update := &entity.TokenUpdate{}
+		if err := bson.Unmarshal(iter.Value(), update); err != nil {
+			return nil, nil, fmt.Errorf("error decoding token update %s: %w", iter.Key(), err)
+		}
+		keys = append(keys, strings.TrimPrefix(string(iter.Key()), embeddedTokenQueuePrefix))
+		tokens = append(tokens, &types.Eth1Token{Address: update.Token, Standard: update.Standard, FirstBlock: update.BlockNumber})
+	}
+	return keys, tokens, iter.Error()
+}
+
+// SaveTokens stores the tokens in the token registry and removes the passed keys from the token update queue. The
+// first block of a token already in the registry is only lowered.
+func (embedded *Embedded) SaveTokens(tokens []*types.Eth1Token, deleteKeys []string) error {
+	embedded.mux.Lock()
+	defer embedded.mux.Unlock()
+
+	batch := new(leveldb.Batch)
+	for _, token := range tokens {
+		firstBlock := token.FirstBlock
+		stored, err := embedded.GetToken(token.Address)
+		if err == nil && stored.FirstBlock < firstBlock {
+			firstBlock = stored.FirstBlock
+		} else if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+
+		raw, err := bson.Marshal(&entity.Token{
+			ChainId:     embedded.ChainId,
+			Address:     token.Address,
+			Standard:    token.Standard,
+			Name:        token.Name,
+			Symbol:      token.Symbol,
+			Decimals:    token.Decimals,
+			TotalSupply: token.TotalSupply,
+			Interfaces:  token.Interfaces,
+			FirstBlock:  firstBlock,
+			UpdatedAt:   token.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%x", embeddedRegistryPrefix, token.Address)), raw)
+	}
+	for _, key := range deleteKeys {
+		batch.Delete([]byte(embeddedTokenQueuePrefix + key))
+	}
+	return embedded.ldb.Write(batch, nil)
+}
+
+func (embedded *Embedded) GetToken(address []byte) (*types.Eth1Token, error) {
+	raw, err := embedded.ldb.Get([]byte(fmt.Sprintf("%s%x", embeddedRegistryPrefix, address)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("token %x: %w", address, mongo.ErrNoDocuments)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token := &entity.Token{}
+	if err := bson.Unmarshal(raw, token); err != nil {
+		return nil, err
+	}
+	return tokenFromEntity(token), nil
+}
+
+// GetTokens returns up to limit tokens of the registry ordered by address, starting after startAddress. An empty standard
+// returns the tokens of all standards.
+func (embedded *Embedded) GetTokens(standard string, startAddress []byte, limit int64) ([]*types.Eth1Token, error) {
+	keyRange := util.BytesPrefix([]byte(embeddedRegistryPrefix))
+	if len(startAddress) > 0 {
+		// start right after the registry key of the start address
+		keyRange.Start = []byte(fmt.Sprintf("%s%x\x00", embeddedRegistryPrefix, startAddress))
+	}
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	tokens := []*types.Eth1Token{}
+	for iter.Next() && int64(len(tokens)) < limit {
+		token := &entity.Token{}
+		if err := bson.Unmarshal(iter.Value(), token); err != nil {
+			return nil, fmt.Errorf("error decoding token %s: %w", iter.Key(), err)
+		}
+		if standard != "" && token.Standard != standard {
+			continue
+		}
+		tokens = append(tokens, tokenFromEntity(token))
+	}
+	return tokens, iter.Error()
+}
+
+func (embedded *Embedded) TransformContractEvents(index *types.ContractEventIndex) func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return embedded.documents.TransformContractEvents(index)
+}
+
+// ApplyContractEventSchema is a no-op, the contract events are kept in chain order by their keys
+func (embedded *Embedded) ApplyContractEventSchema(indexes []*types.ContractEventIndex) error {
+	return nil
+}
+
+// GetContractEvents returns up to limit events of the contract event index matching the filter, in chain order, and the
+// token of the next page
+func (embedded *Embedded) GetContractEvents(index *types.ContractEventIndex, filter *types.ContractEventFilter, pageToken string, limit int64) ([]*types.Eth1ContractEvent, string, error) {
+	prefix := fmt.Sprintf("%s%s:", embeddedEventPrefix, index.Collection)
+	keyRange := util.BytesPrefix([]byte(prefix))
+	keyRange.Start = []byte(fmt.Sprintf("%s%020d", prefix, filter.FromBlock))
+	var after []byte
+	if pageToken != "" {
+		c, err := decodeLogCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		after = []byte(embeddedEventKey(index.Collection, &embeddedLogPosition{BlockNumber: c.BlockNumber, TxIndex: c.TxIndex, LogIndex: c.LogIndex}))
+		if bytes.Compare(after, keyRange.Start) >= 0 {
+			keyRange.Start = after
+		}
+	}
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	params := make(map[string]bson.RawValue, len(filter.Params))
+	for name, value := range filter.Params {
+		valueType, data, err := bson.MarshalValue(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("error encoding filter of parameter %v: %w", name, err)
+		}
+		params[name] = bson.RawValue{Type: valueType, Value: data}
+	}
+
+	results := []*entity.ContractEvent{}
+	for iter.Next() && int64(len(results)) < limit {
+		if bytes.Equal(iter.Key(), after) {
+			continue
+		}
+		raw := bson.Raw(iter.Value())
+		event := &entity.ContractEvent{}
+		if err := bson.Unmarshal(raw, event); err != nil {
+			return nil, "", fmt.Errorf("error decoding contract event %s: %w", iter.Key(), err)
+		}
+		if filter.ToBlock > 0 && event.BlockNumber > filter.ToBlock {
+			break
+		}
+		if len(filter.Contract) > 0 && !bytes.Equal(event.Contract, filter.Contract) {
+			continue
+		}
+		if filter.Event != "" && event.Event != filter.Event {
+			continue
+		}
+		matches := true
+		for name, want := range params {
+			got, err := raw.LookupErr("params", name)
+			if err != nil || got.Type != want.Type || !bytes.Equal(got.Value, want.Value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			results = append(results, event)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+
+	events := make([]*types.Eth1ContractEvent, 0, len(results))
+	for _, event := range results {
+		events = append(events, contractEventFromEntity(index, event))
+	}
+	return events, contractEventsPageToken(results, limit), nil
+}
+
+// GetLogs returns up to limit logs matching the filter in chain order. The logs are scanned from the start of the block
+// range, a filter by block hash scans all logs.
+func (embedded *Embedded) GetLogs(filter *types.LogFilter, pageToken string, limit int64) ([]*types.Eth1LogIndexed, string, error) {
+	if len(filter.Topics) > MaxLogTopics {
+		return nil, "", fmt.Errorf("log filter has %v topic positions, logs have at most %v topics", len(filter.Topics), MaxLogTopics)
+	}
+
+	prefix := fmt.Sprintf("%s%s:", embeddedEventPrefix, LOGS)
+	keyRange := util.BytesPrefix([]byte(prefix))
+	if len(filter.BlockHash) == 0 {
+		keyRange.Start = []byte(fmt.Sprintf("%s%020d", prefix, filter.FromBlock))
+	}
+	var after []byte
+	if pageToken != "" {
+		c, err := decodeLogCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		after = []byte(embeddedEventKey(LOGS, &embeddedLogPosition{BlockNumber: c.BlockNumber, TxIndex: c.TxIndex, LogIndex: c.LogIndex}))
+		if bytes.Compare(after, keyRange.Start) >= 0 {
+			keyRange.Start = after
+		}
+	}
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	results := []*entity.Log{}
+	for iter.Next() && int64(len(results)) < limit {
+		if bytes.Equal(iter.Key(), after) {
+			continue
+		}
+		l := &entity.Log{}
+		if err := bson.Unmarshal(iter.Value(), l); err != nil {
+			return nil, "", fmt.Errorf("error decoding log %s: %w", iter.Key(), err)
+		}
+		if len(filter.BlockHash) == 0 && l.BlockNumber > filter.ToBlock {
+			break
+		}
+		if matchesLogFilter(l, filter) {
+			results = append(results, l)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, "", fmt.Errorf("error retrieving logs: %w", err)
+	}
+
+	logs := make([]*types.Eth1LogIndexed, 0, len(results))
+	for _, l := range results {
+		logs = append(logs, logFromEntity(l))
+	}
+
+	nextPageToken := ""
+	if int64(len(results)) == limit && limit > 0 {
+		last := results[len(results)-1]
+		nextPageToken = (&logCursor{BlockNumber: last.BlockNumber, TxIndex: last.TxIndex, LogIndex: last.LogIndex}).encode()
+	}
+	return logs, nextPageToken, nil
+}
+
+// nftHoldings returns up to limit holdings with a positive balance of the keys with the passed prefix after the after
+// key
+func (embedded *Embedded) nftHoldings(prefix string, after []byte, limit int64) ([]*entity.NFTHolding, error) {
+	keyRange := util.BytesPrefix([]byte(prefix))
+	if after != nil {
+		keyRange.Start = after
+	}
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	holdings := make([]*entity.NFTHolding, 0, limit)
+	for iter.Next() && int64(len(holdings)) < limit {
+		if bytes.Equal(iter.Key(), after) {
+			continue
+		}
+		holding := &entity.NFTHolding{}
+		if err := bson.Unmarshal(iter.Value(), holding); err != nil {
+			return nil, fmt.Errorf("error decoding nft holding %s: %w", iter.Key(), err)
+		}
+		balance, err := decimalToBigInt(holding.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding balance of nft holding %s: %w", iter.Key(), err)
+		}
+		if balance.Sign() > 0 {
+			holdings = append(holdings, holding)
+		}
+	}
+	return holdings, iter.Error()
+}
+
+// GetNFTHoldings returns up to limit ERC-721 and ERC-1155 token ids currently held by the address ordered by token and
+// token id. An empty token returns the holdings of all tokens. The returned page token continues after the last
+// returned holding and is empty if there are no more holdings.
+func (embedded *Embedded) GetNFTHoldings(address []byte, token []byte, pageToken string, limit int64) ([]*types.Eth1NFTHolding, string, error) {
+	prefix := fmt.Sprintf("%s%x:", embeddedHoldingPrefix, address)
+	if len(token) > 0 {
+		prefix = fmt.Sprintf("%s%x:", prefix, token)
+	}
+	var after []byte
+	if pageToken != "" {
+		c, err := decodeNFTHoldingCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		after, _ = embeddedNFTHoldingKeys(c.Token, c.TokenId, address)
+	}
+
+	holdings, err := embedded.nftHoldings(prefix, after, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving nft holdings of %x: %w", address, err)
+	}
+	return nftHoldingsPage(holdings, limit)
+}
+
+// GetNFTOwners returns up to limit current holders of a token id ordered by address, a single one for ERC-721 tokens.
+// The returned page token continues after the last returned holder and is empty if there are no more holders.
+func (embedded *Embedded) GetNFTOwners(token []byte, tokenId []byte, pageToken string, limit int64) ([]*types.Eth1NFTHolding, string, error) {
+	_, byToken := embeddedNFTHoldingKeys(token, tokenId, nil)
+	prefix := string(byToken)
+	var after []byte
+	if pageToken != "" {
+		c, err := decodeNFTHoldingCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		_, after = embeddedNFTHoldingKeys(token, tokenId, c.Address)
+	}
+
+	holdings, err := embedded.nftHoldings(prefix, after, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving holders of token %x id %x: %w", token, tokenId, err)
+	}
+	return nftHoldingsPage(holdings, limit)
+}
+
+// GetNFTMetadataUpdates returns up to limit queued token ids whose metadata has to be retrieved, the keys are passed to
+// SaveNFTMetadata to remove them from the queue
+func (embedded *Embedded) GetNFTMetadataUpdates(limit int) ([]string, []*types.Eth1NFTMetadata, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(embeddedTokenQueuePrefix+embedded.documents.documentID(NFT_METADATA_UPDATE)+":")), nil)
+	defer iter.Release()
+
+	keys := make([]string, 0, limit)
+	nfts := make([]*types.Eth1NFTMetadata, 0, limit)
+	for iter.Next() && len(keys) < limit {
+		update := &entity.NFTMetadataUpdate{}
+		if err := bson.Unmarshal(iter.Value(), update); err != nil {
+			return nil, nil, fmt.Errorf("error decoding nft metadata update %s: %w", iter.Key(), err)
+		}
+		keys = append(keys, strings.TrimPrefix(string(iter.Key()), embeddedTokenQueuePrefix))
+		nfts = append(nfts, &types.Eth1NFTMetadata{Token: update.Token, TokenId: update.TokenId, Standard: update.Standard, FirstBlock: update.BlockNumber})
+	}
+	return keys, nfts, iter.Error()
+}
+
+// SaveNFTMetadata stores the metadata of the token ids and removes the passed keys from the nft metadata update queue
+func (embedded *Embedded) SaveNFTMetadata(metadata []*types.Eth1NFTMetadata, deleteKeys []string) error {
+	batch := new(leveldb.Batch)
+	for _, nft := range metadata {
+		raw, err := bson.Marshal(&entity.NFTMetadata{
+			ChainId:   embedded.ChainId,
+			Type:      NFT_METADATA_FAMILY,
+			Token:     nft.Token,
+			TokenId:   nft.TokenId,
+			Standard:  nft.Standard,
+			URI:       nft.URI,
+			Metadata:  nft.Metadata,
+			UpdatedAt: nft.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%x:%x", embeddedNFTPrefix, nft.Token, nft.TokenId)), raw)
+	}
+	for _, key := range deleteKeys {
+		batch.Delete([]byte(embeddedTokenQueuePrefix + key))
+	}
+	return embedded.ldb.Write(batch, nil)
+}
+
+func (embedded *Embedded) GetNFTMetadata(token []byte, tokenId []byte) (*types.Eth1NFTMetadata, error) {
+	raw, err := embedded.ldb.Get([]byte(fmt.Sprintf("%s%x:%x", embeddedNFTPrefix, token, tokenId)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("nft metadata of token %x id %x: %w", token, tokenId, mongo.ErrNoDocuments)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nft := &entity.NFTMetadata{}
+	if err := bson.Unmarshal(raw, nft); err != nil {
+		return nil, err
+	}
+	return nftMetadataFromEntity(nft), nil
+}
+
+// GetContractMetadata returns the stored metadata of a contract, nil if there is none. Unlike the mongodb backend
+// the embedded backend does not fetch the metadata of unknown contracts from an explorer, it only matches their code
+// against verified code.
+func (embedded *Embedded) GetContractMetadata(address []byte) (*types.ContractMetadata, error) {
+	ret, err := embedded.getContractMetadata(fmt.Sprintf("%s%x", embeddedContractPrefix, address))
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret, err = matchVerifiedCode(embedded, address)
+		if err != nil {
+			logger.Warnf("error matching the code of 0x%x against verified code: %v", address, err)
+			return nil, nil
+		}
+		if ret == nil {
+			return nil, nil
+		}
+		if err := embedded.SaveContractMetadata(address, ret); err != nil {
+			return nil, err
+		}
+	}
+
+	val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding abi for address 0x%x: %w", address, err)
+	}
+	ret.ABI = &val
+	return ret, nil
+}
+
+// GetStoredContractMetadata returns the stored metadata of a contract, nil if there is none
+func (embedded *Embedded) GetStoredContractMetadata(address []byte) (*types.ContractMetadata, error) {
+	ret, err := embedded.getContractMetadata(fmt.Sprintf("%s%x", embeddedContractPrefix, address))
+	if err != nil || ret == nil {
+		return nil, err
+	}
+	val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding abi for address 0x%x: %w", address, err)
+	}
+	ret.ABI = &val
+	return ret, nil
+}
+
+func (embedded *Embedded) getContractMetadata(key string) (*types.ContractMetadata, error) {
+	raw, err := embedded.ldb.Get([]byte(key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &entity.ContractMetadataFamily{}
+	if err := bson.Unmarshal(raw, metadata); err != nil {
+		return nil, err
+	}
+	return contractMetadataFromEntity(metadata), nil
+}
+
+func (embedded *Embedded) SaveContractMetadata(address []byte, metadata *types.ContractMetadata) error {
+	raw, err := bson.Marshal(contractMetadataToEntity(metadata))
+	if err != nil {
+		return err
+	}
+	err = embedded.ldb.Put([]byte(fmt.Sprintf("%s%x", embeddedContractPrefix, address)), raw, nil)
+	if err != nil || metadata.ABI == nil {
+		return err
+	}
+	return embedded.SaveSignatures(utils.AbiSignatures(metadata.ABI))
+}
+
+// SaveSignatures adds the signatures to the signature database, signatures which are already known are skipped
+func (embedded *Embedded) SaveSignatures(signatures []*types.Eth1Signature) error {
+	batch := new(leveldb.Batch)
+	for _, signature := range signatures {
+		raw, err := bson.Marshal(&entity.Signature{Selector: signature.Selector, Signature: signature.Signature, Event: signature.Event})
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%x:%s", embeddedSignaturePrefix, signature.Selector, signature.Signature)), raw)
+	}
+	return embedded.ldb.Write(batch, nil)
+}
+
+// GetSignatures returns the signatures of the selector, the signatures of the embedded list come first
+func (embedded *Embedded) GetSignatures(selector []byte) ([]*types.Eth1Signature, error) {
+	iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(fmt.Sprintf("%s%x:", embeddedSignaturePrefix, selector))), nil)
+	defer iter.Release()
+
+	stored := []*entity.Signature{}
+	for iter.Next() {
+		signature := &entity.Signature{}
+		if err := bson.Unmarshal(iter.Value(), signature); err != nil {
+			return nil, fmt.Errorf("error decoding signatures of selector 0x%x: %w", selector, err)
+		}
+		stored = append(stored, signature)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return mergeSignatures(selector, stored), nil
+}
+
+func (embedded *Embedded) SaveVerifiedCode(codeHash []byte, metadata *types.ContractMetadata) error {
+	raw, err := bson.Marshal(contractMetadataToEntity(metadata))
+	if err != nil {
+		return err
+	}
+	return embedded.ldb.Put([]byte(fmt.Sprintf("%s%x", embeddedVerifiedPrefix, codeHash)), raw, nil)
+}
+
+func (embedded *Embedded) GetVerifiedCode(codeHash []byte) (*types.ContractMetadata, error) {
+	return embedded.getContractMetadata(fmt.Sprintf("%s%x", embeddedVerifiedPrefix, codeHash))
+}
+
+func (embedded *Embedded) SaveGasNowHistory(slow, standard, rapid, fast *big.Int) error {
+	ts := time.Now().Truncate(time.Minute)
+	raw, err := bson.Marshal(&entity.Series{
+		ChainID:  embedded.ChainId,
+		Time:     primitive.Timestamp{T: uint32(ts.Unix()), I: 0},
+		Type:     SERIES_FAMILY,
+		Slow:     slow.Bytes(),
+		Standard: standard.Bytes(),
+		Rapid:    rapid.Bytes(),
+		Fast:     fast.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	return embedded.ldb.Put([]byte(fmt.Sprintf("%s%020d", embeddedGasNowPrefix, ts.Unix())), raw, nil)
+}
+
+func (embedded *Embedded) GetGasNowHistory(ts, pastTs time.Time) ([]types.GasNowHistory, error) {
+	keyRange := &util.Range{
+		Start: []byte(fmt.Sprintf("%s%020d", embeddedGasNowPrefix, pastTs.Unix())),
+		Limit: []byte(fmt.Sprintf("%s%020d", embeddedGasNowPrefix, ts.Unix()+1)),
+	}
+	iter := embedded.ldb.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	history := make([]types.GasNowHistory, 0)
+	for iter.Next() {
+		result := &entity.Series{}
+		if err := bson.Unmarshal(iter.Value(), result); err != nil {
+			return nil, fmt.Errorf("error getting gas now history, err: %w", err)
+		}
+		history = append(history, types.GasNowHistory{
+			Ts:       time.Unix(int64(result.Time.T), 0),
+			Fast:     new(big.Int).SetBytes(result.Fast),
+			Rapid:    new(big.Int).SetBytes(result.Rapid),
+			Slow:     new(big.Int).SetBytes(result.Slow),
+			Standard: new(big.Int).SetBytes(result.Standard),
+		})
+	}
+	return history, iter.Error()
+}
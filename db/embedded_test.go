@@ -0,0 +1,328 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testEmbedded opens an embedded database of chain 1 in a temporary directory which is closed at the end of the test
+func testEmbedded(t *testing.T) *Embedded {
+	t.Helper()
+
+	embedded, err := InitEmbedded(t.TempDir(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { embedded.Close() })
+	return embedded
+}
+
+func TestEmbeddedBlockLifecycle(t *testing.T) {
+	testBlockLifecycle(t, testEmbedded(t))
+}
+
+func TestMongoBlockLifecycle(t *testing.T) {
+	testBlockLifecycle(t, testMongo(t))
+}
+
+// testBlockLifecycle writes the mutations of a block, queries them and checks that deleting the block removes them
+func testBlockLifecycle(t *testing.T, storage interfaces.Database) {
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	token := common.HexToAddress("0x03")
+	txHash := common.HexToHash("0xaa").Bytes()
+	block := &types.Eth1Block{
+		Number: 42,
+		Hash:   common.HexToHash("0x42").Bytes(),
+		Time:   timestamppb.New(time.Unix(1700000000, 0)),
+		Transactions: []*types.Eth1Transaction{
+			{
+				Hash:            txHash,
+				From:            from.Bytes(),
+				To:              token.Bytes(),
+				ContractAddress: ZERO_ADDRESS,
+				Logs: []*types.Eth1Log{
+					{
+						Address: token.Bytes(),
+						Data:    common.LeftPadBytes([]byte{0x01}, 32),
+						Topics:  [][]byte{erc20.TransferTopic, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)},
+					},
+				},
+			},
+		},
+	}
+
+	err := storage.SaveBlocks([]*types.Eth1Block{block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := storage.GetBlockFromBlocksTable(block.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Number != block.Number || common.BytesToHash(stored.Hash) != common.BytesToHash(block.Hash) {
+		t.Fatalf("stored block %v/%x, want %v/%x", stored.Number, stored.Hash, block.Number, block.Hash)
+	}
+
+	bulkData := &types.BulkMutations{}
+	for _, transform := range []func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error){
+		storage.TransformBlock, storage.TransformTx, storage.TransformERC20,
+	} {
+		mutations, _, err := transform(block, freecache.NewCache(1024*1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bulkData.Keys = append(bulkData.Keys, mutations.Keys...)
+		bulkData.Model = append(bulkData.Model, mutations.Model...)
+	}
+	if err := storage.WriteBlockMutations(block, bulkData, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want int
+		get  func() (int, error)
+	}{
+		{name: "sender transactions", want: 1, get: func() (int, error) {
+			txs, _, err := storage.GetEth1TxForAddress(from.Bytes(), nil, "", 25)
+			return len(txs), err
+		}},
+		{name: "receiver erc20 transfers", want: 1, get: func() (int, error) {
+			transfers, _, err := storage.GetEth1ERC20ForAddress(to.Bytes(), nil, "", 25)
+			return len(transfers), err
+		}},
+		{name: "incoming only filter", want: 0, get: func() (int, error) {
+			txs, _, err := storage.GetEth1TxForAddress(from.Bytes(), &types.AddressIndexFilter{Direction: "in"}, "", 25)
+			return len(txs), err
+		}},
+		{name: "token transfers of the transaction", want: 1, get: func() (int, error) {
+			erc20Transfers, _, _, err := storage.GetTokenTransfersForTransaction(txHash)
+			return len(erc20Transfers), err
+		}},
+		{name: "indexed blocks", want: 1, get: func() (int, error) {
+			blocks, err := storage.GetBlocksIndexedMultiple([]uint64{block.Number}, 1)
+			return len(blocks), err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v results, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := storage.DeleteBlock(block.Number, block.Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetIndexedEth1Transaction(txHash); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("transaction of a deleted block: got error %v, want %v", err, mongo.ErrNoDocuments)
+	}
+	if _, err := storage.GetBlockFromBlocksTable(block.Number); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("deleted block: got error %v, want %v", err, ErrBlockNotFound)
+	}
+	txs, _, err := storage.GetEth1TxForAddress(from.Bytes(), nil, "", 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 0 {
+		t.Errorf("address index of a deleted block still returns %v transactions", len(txs))
+	}
+}
+
+func TestEmbeddedCheckpoint(t *testing.T) {
+	embedded, err := InitEmbedded(t.TempDir(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer embedded.Close()
+
+	if _, err := embedded.GetCheckpoint("indexer"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrCheckpointNotFound)
+	}
+
+	tests := []struct {
+		name  string
+		block uint64
+		want  uint64
+	}{
+		{name: "first", block: 10, want: 10},
+		{name: "forward", block: 20, want: 20},
+		{name: "backwards is ignored", block: 15, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := embedded.SaveCheckpoint("indexer", tt.block); err != nil {
+				t.Fatal(err)
+			}
+			got, err := embedded.GetCheckpoint("indexer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("GetCheckpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedChainId(t *testing.T) {
+	dir := t.TempDir()
+	embedded, err := InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded.Close()
+
+	if _, err := InitEmbedded(dir, "2"); err == nil {
+		t.Errorf("opening the data directory of chain 1 for chain 2 did not fail")
+	}
+	embedded, err = InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatalf("reopening the data directory of chain 1: %v", err)
+	}
+	embedded.Close()
+}
+
+func TestEmbeddedSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	indexer, err := InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatalf("opening the data directory a second time: %v", err)
+	}
+
+	if err := indexer.SaveCheckpoint("indexer", 10); err != nil {
+		t.Fatal(err)
+	}
+	indexer.Close()
+	got, err := api.GetCheckpoint("indexer")
+	if err != nil {
+		t.Fatalf("reading through the second handle after closing the first: %v", err)
+	}
+	if got != 10 {
+		t.Errorf("GetCheckpoint() = %v, want 10", got)
+	}
+	api.Close()
+
+	// another process holding the directory is simulated by opening it without the shared handles
+	ldb, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatalf("the directory is still locked after closing all handles: %v", err)
+	}
+	defer ldb.Close()
+	if _, err := InitEmbedded(dir, "1"); !errors.Is(err, ErrEmbeddedInUse) {
+		t.Errorf("opening a directory locked by another process: got error %v, want %v", err, ErrEmbeddedInUse)
+	}
+}
+
+func TestEmbeddedVerifiedCode(t *testing.T) {
+	embedded, err := InitEmbedded(t.TempDir(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer embedded.Close()
+
+	codeHash := common.HexToHash("0xc0de").Bytes()
+	contract := common.HexToAddress("0xc1")
+	metadata := &types.ContractMetadata{
+		Name:     "Counter",
+		ABIJson:  []byte(`[{"inputs":[],"name":"count","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`),
+		Compiler: "0.8.19+commit.7dd6d404",
+		Sources:  map[string]string{"contracts/Counter.sol": "contract Counter {}"},
+	}
+
+	verified, err := embedded.GetVerifiedCode(codeHash)
+	if err != nil || verified != nil {
+		t.Fatalf("got verified code %v and error %v before saving it, want none", verified, err)
+	}
+	if err := embedded.SaveVerifiedCode(codeHash, metadata); err != nil {
+		t.Fatal(err)
+	}
+	verified, err = embedded.GetVerifiedCode(codeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified == nil || verified.Name != "Counter" || verified.Sources["contracts/Counter.sol"] != "contract Counter {}" {
+		t.Fatalf("got verified code %+v, want the saved Counter contract", verified)
+	}
+
+	if err := embedded.SaveContractMetadata(contract.Bytes(), verified); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := embedded.GetContractMetadata(contract.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved == nil || saved.Compiler != metadata.Compiler || saved.ABI == nil || saved.ABI.Methods["count"].Name != "count" {
+		t.Errorf("got contract metadata %+v, want the verified Counter contract with its abi", saved)
+	}
+}
+
+func TestEmbeddedNFTHoldingsUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	embedded, err := InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := common.HexToAddress("0xa1")
+	kitties := common.HexToAddress("0xe7")
+	block := &types.Eth1Block{Number: 1, Transactions: []*types.Eth1Transaction{{Logs: []*types.Eth1Log{erc721Transfer(kitties, common.Address{}, alice, 42)}}}}
+	if err := testutil.WriteFixtureBlocks(embedded, []*types.Eth1Block{block}, embedded.TransformNFTs); err != nil {
+		t.Fatal(err)
+	}
+
+	// databases written before the nft holdings only have holder keys next to the nft deltas
+	batch := new(leveldb.Batch)
+	for _, prefix := range []string{embeddedHoldingPrefix, embeddedHolderPrefix} {
+		iter := embedded.ldb.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+	}
+	batch.Put([]byte(fmt.Sprintf("h:%x:%x:%x:%020d", alice, kitties, 42, 1)), []byte(embedded.documents.nftDeltaID(kitties.Bytes(), big.NewInt(42).Bytes(), alice.Bytes(), 1)))
+	if err := embedded.ldb.Write(batch, nil); err != nil {
+		t.Fatal(err)
+	}
+	embedded.Close()
+
+	embedded, err = InitEmbedded(dir, "1")
+	if err != nil {
+		t.Fatalf("reopening the data directory: %v", err)
+	}
+	defer embedded.Close()
+	owners, _, err := embedded.GetNFTOwners(kitties.Bytes(), big.NewInt(42).Bytes(), "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owners) != 1 || !bytes.Equal(owners[0].Address, alice.Bytes()) {
+		t.Errorf("got %v owners of kitty 42 after the upgrade, want alice", len(owners))
+	}
+	if legacy, err := embedded.ldb.Has([]byte(fmt.Sprintf("h:%x:%x:%x:%020d", alice, kitties, 42, 1)), nil); err != nil || legacy {
+		t.Errorf("holder key is still stored after the upgrade")
+	}
+}
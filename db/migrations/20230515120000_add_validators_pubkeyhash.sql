@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE validators ADD COLUMN IF NOT EXISTS pubkeyhash BYTEA GENERATED ALWAYS AS (sha256(pubkey)) STORED;
+CREATE INDEX IF NOT EXISTS idx_validators_pubkeyhash ON validators (pubkeyhash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS idx_validators_pubkeyhash;
+ALTER TABLE validators DROP COLUMN IF EXISTS pubkeyhash;
+-- +goose StatementEnd
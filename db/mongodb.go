@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
 	// "github.com/Prajjawalk/zond-indexer/services"
 	"github.com/Prajjawalk/zond-indexer/types"
 	"github.com/Prajjawalk/zond-indexer/utils"
@@ -32,10 +33,18 @@ var SERIES_FAMILY = "series"
 
 var MongodbClient *Mongo
 
+// Storage is the execution layer storage backend, it is set by InitMongodb and InitEmbedded
+var Storage interfaces.Database
+
+var _ interfaces.Database = (*Mongo)(nil)
+
 type Mongo struct {
 	Client  *mongo.Client
 	Db      *mongo.Database
 	ChainId string
+
+	// transactions is true if the deployment is a replica set or a sharded cluster
+	transactions bool
 }
 
 func InitMongodb(connectionString, instance, chainId string) (*Mongo, error) {
@@ -61,7 +70,9 @@ func InitMongodb(connectionString, instance, chainId string) (*Mongo, error) {
 		Db:      db,
 		ChainId: chainId,
 	}
+	mongodb.transactions = mongodb.supportsTransactions(context.Background())
 	MongodbClient = mongodb
+	Storage = mongodb
 	return mongodb, nil
 }
 
@@ -0,0 +1,505 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ADDRESS_INDEX is the type of the structured address index documents stored in the data collection
+const ADDRESS_INDEX = "addressindex"
+
+// Kinds of entities referenced by address index documents
+const (
+	ADDRESS_INDEX_KIND_TX         = "tx"
+	ADDRESS_INDEX_KIND_ITX        = "itx"
+	ADDRESS_INDEX_KIND_ERC20      = "erc20"
+	ADDRESS_INDEX_KIND_ERC721     = "erc721"
+	ADDRESS_INDEX_KIND_ERC1155    = "erc1155"
+	ADDRESS_INDEX_KIND_BLOCK      = "block"
+	ADDRESS_INDEX_KIND_WITHDRAWAL = "withdrawal"
+)
+
+// Directions of an address index document, seen from the indexed address
+const (
+	DIRECTION_IN   = "in"
+	DIRECTION_OUT  = "out"
+	DIRECTION_SELF = "self"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// AddressIndexQuery selects the address index documents of one kind. Either Address or Token (or both) must be set,
+// a query for a token without an address returns every transfer of the token once.
+type AddressIndexQuery struct {
+	Kind    string
+	Address []byte
+	Token   []byte
+	Filter  *AddressIndexFilter
+}
+
+// AddressIndexFilter narrows down the address index documents of a query, all set conditions have to match
+type AddressIndexFilter = types.AddressIndexFilter
+
+// ParseAddressIndexFilter parses a search string of whitespace separated filter terms into an AddressIndexFilter.
+// The supported terms are named after the index filters:
+//
+//	TO[:<address>]              outgoing, optionally to the address
+//	FROM[:<address>]            incoming, optionally from the address
+//	TOKEN_SENT[:<token>]        outgoing token transfers, optionally of the token
+//	TOKEN_RECEIVED[:<token>]    incoming token transfers, optionally of the token
+//	METHOD:<selector>           transactions calling the 4 byte method selector
+//	ERROR                       failed transactions only
+//	CONTRACT                    contract creations only
+//	BLOCK:<from>-<to>           block range, either bound may be omitted
+//	TIME:<from>-<to>            unix timestamp range, either bound may be omitted
+//
+// An empty search string returns a nil filter.
+func ParseAddressIndexFilter(search string) (*AddressIndexFilter, error) {
+	terms := strings.Fields(search)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	filter := &AddressIndexFilter{}
+	for _, term := range terms {
+		name, value, _ := strings.Cut(term, ":")
+
+		var err error
+		switch IndexFilter(strings.ToUpper(name)) {
+		case FILTER_TO, FILTER_TOKEN_SENT:
+			err = setFilterDirection(filter, DIRECTION_OUT, IndexFilter(strings.ToUpper(name)), value)
+		case FILTER_FROM, FILTER_TOKEN_RECEIVED:
+			err = setFilterDirection(filter, DIRECTION_IN, IndexFilter(strings.ToUpper(name)), value)
+		case FILTER_METHOD:
+			filter.Method, err = hex.DecodeString(strings.TrimPrefix(strings.ToLower(value), "0x"))
+			if err == nil && len(filter.Method) != 4 {
+				err = fmt.Errorf("method selector must be 4 bytes")
+			}
+		case FILTER_ERROR:
+			filter.FailedOnly = true
+		case FILTER_CONTRACT:
+			filter.ContractCreationOnly = true
+		case FILTER_BLOCK:
+			var from, to uint64
+			from, to, err = parseUintRange(value)
+			filter.FromBlock, filter.ToBlock = from, to
+		case FILTER_TIME:
+			var from, to uint64
+			from, to, err = parseUintRange(value)
+			if from > 0 {
+				filter.FromTime = time.Unix(int64(from), 0)
+			}
+			if to > 0 {
+				filter.ToTime = time.Unix(int64(to), 0)
+			}
+		default:
+			err = fmt.Errorf("unknown filter")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter term %q: %w", term, err)
+		}
+	}
+
+	return filter, nil
+}
+
+func setFilterDirection(filter *AddressIndexFilter, direction string, name IndexFilter, value string) error {
+	if filter.Direction != "" && filter.Direction != direction {
+		return fmt.Errorf("conflicting directions")
+	}
+	filter.Direction = direction
+
+	if value == "" {
+		return nil
+	}
+	address, err := utils.ParseAddress(value)
+	if err != nil {
+		return err
+	}
+	if name == FILTER_TOKEN_SENT || name == FILTER_TOKEN_RECEIVED {
+		filter.Token = address.Bytes()
+	} else {
+		filter.Counterparty = address.Bytes()
+	}
+	return nil
+}
+
+// parseUintRange parses a <from>-<to> range where either bound may be omitted
+func parseUintRange(value string) (from uint64, to uint64, err error) {
+	fromString, toString, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("expected a <from>-<to> range")
+	}
+	if fromString != "" {
+		from, err = strconv.ParseUint(fromString, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if toString != "" {
+		to, err = strconv.ParseUint(toString, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if to < from {
+			return 0, 0, fmt.Errorf("range end is lower than its start")
+		}
+	}
+	return from, to, nil
+}
+
+// appendAddressIndexFilter adds the conditions of the filter to a mongodb filter document
+func appendAddressIndexFilter(d bson.D, filter *AddressIndexFilter) bson.D {
+	if filter == nil {
+		return d
+	}
+
+	if filter.Direction != "" {
+		d = append(d, bson.E{Key: "direction", Value: bson.D{{Key: "$in", Value: bson.A{filter.Direction, DIRECTION_SELF}}}})
+	}
+	if len(filter.Counterparty) > 0 {
+		d = append(d, bson.E{Key: "counterparty", Value: filter.Counterparty})
+	}
+	if len(filter.Token) > 0 {
+		d = append(d, bson.E{Key: "token", Value: filter.Token})
+	}
+	if len(filter.Method) > 0 {
+		d = append(d, bson.E{Key: "method", Value: filter.Method})
+	}
+	if filter.FailedOnly {
+		d = append(d, bson.E{Key: "failed", Value: true})
+	}
+	if filter.ContractCreationOnly {
+		d = append(d, bson.E{Key: "contractcreation", Value: true})
+	}
+
+	blockRange := bson.D{}
+	if filter.FromBlock > 0 {
+		blockRange = append(blockRange, bson.E{Key: "$gte", Value: filter.FromBlock})
+	}
+	if filter.ToBlock > 0 {
+		blockRange = append(blockRange, bson.E{Key: "$lte", Value: filter.ToBlock})
+	}
+	if len(blockRange) > 0 {
+		d = append(d, bson.E{Key: "blocknumber", Value: blockRange})
+	}
+
+	timeRange := bson.D{}
+	if !filter.FromTime.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$gte", Value: primitive.Timestamp{T: uint32(filter.FromTime.Unix()), I: 0}})
+	}
+	if !filter.ToTime.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$lte", Value: primitive.Timestamp{T: uint32(filter.ToTime.Unix()), I: 0}})
+	}
+	if len(timeRange) > 0 {
+		d = append(d, bson.E{Key: "time", Value: timeRange})
+	}
+
+	return d
+}
+
+// matchesAddressIndexQuery evaluates the conditions of a query on a decoded address index document, it mirrors the
+// mongodb filter built by GetAddressIndexes for backends without a query language
+func matchesAddressIndexQuery(idx *entity.AddressIndex, query *AddressIndexQuery) bool {
+	if idx.Kind != query.Kind {
+		return false
+	}
+	if len(query.Address) > 0 && !bytes.Equal(idx.Address, query.Address) {
+		return false
+	}
+	if len(query.Token) > 0 {
+		if !bytes.Equal(idx.Token, query.Token) {
+			return false
+		}
+		if len(query.Address) == 0 && (query.Filter == nil || query.Filter.Direction == "") && idx.Direction == DIRECTION_IN {
+			return false
+		}
+	}
+
+	filter := query.Filter
+	if filter == nil {
+		return true
+	}
+	if filter.Direction != "" && idx.Direction != filter.Direction && idx.Direction != DIRECTION_SELF {
+		return false
+	}
+	if len(filter.Counterparty) > 0 && !bytes.Equal(idx.Counterparty, filter.Counterparty) {
+		return false
+	}
+	if len(filter.Token) > 0 && !bytes.Equal(idx.Token, filter.Token) {
+		return false
+	}
+	if len(filter.Method) > 0 && !bytes.Equal(idx.Method, filter.Method) {
+		return false
+	}
+	if (filter.FailedOnly && !idx.Failed) || (filter.ContractCreationOnly && !idx.ContractCreation) {
+		return false
+	}
+	if idx.BlockNumber < filter.FromBlock || (filter.ToBlock > 0 && idx.BlockNumber > filter.ToBlock) {
+		return false
+	}
+	if !filter.FromTime.IsZero() && int64(idx.Time.T) < filter.FromTime.Unix() {
+		return false
+	}
+	if !filter.ToTime.IsZero() && int64(idx.Time.T) > filter.ToTime.Unix() {
+		return false
+	}
+	return true
+}
+
+// addressIndexCursor is the position of an address index document in the (block, tx, position) descending sort order
+type addressIndexCursor struct {
+	BlockNumber uint64
+	TxIndex     uint64
+	Position    uint64
+}
+
+func (c *addressIndexCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%d", c.BlockNumber, c.TxIndex, c.Position)))
+}
+
+func decodeAddressIndexCursor(pageToken string) (*addressIndexCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	c := &addressIndexCursor{}
+	_, err = fmt.Sscanf(string(raw), "%d:%d:%d", &c.BlockNumber, &c.TxIndex, &c.Position)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+// addressIndexKey returns the unique key of an address index document, it is also recorded in the block keys to handle reorgs
+func (mongodb *Mongo) addressIndexKey(idx *entity.AddressIndex) string {
+	return fmt.Sprintf("%s:I:%s:%x:%s:%09d:%d:%d", mongodb.ChainId, idx.Kind, idx.Address, idx.Direction, idx.BlockNumber, idx.TxIndex, idx.Position)
+}
+
+// addressIndexesFor derives the address index documents of a transfer from the sender to the recipient using the
+// passed template. The sender gets an outgoing and the recipient an incoming document, a transfer to self is indexed once.
+func addressIndexesFor(template entity.AddressIndex, from, to []byte) []*entity.AddressIndex {
+	if bytes.Equal(from, to) {
+		self := template
+		self.Address = from
+		self.Direction = DIRECTION_SELF
+		self.Counterparty = to
+		return []*entity.AddressIndex{&self}
+	}
+
+	out := template
+	out.Address = from
+	out.Direction = DIRECTION_OUT
+	out.Counterparty = to
+
+	in := template
+	in.Address = to
+	in.Direction = DIRECTION_IN
+	in.Counterparty = from
+
+	return []*entity.AddressIndex{&out, &in}
+}
+
+// appendAddressIndexes adds the upsert models of the address index documents to the bulk mutations, the key of a
+// document is used as its id
+func (mongodb *Mongo) appendAddressIndexes(bulkData *types.BulkMutations, indexes ...*entity.AddressIndex) error {
+	for _, idx := range indexes {
+		idx.ChainId = mongodb.ChainId
+		idx.Type = ADDRESS_INDEX
+		idx.Key = mongodb.addressIndexKey(idx)
+
+		doc, err := utils.ToDoc(idx)
+		if err != nil {
+			return err
+		}
+		bulkData.Model = append(bulkData.Model, upsertModel(idx.Key, doc))
+		bulkData.Keys = append(bulkData.Keys, idx.Key)
+	}
+	return nil
+}
+
+// GetAddressIndexes returns up to limit address index documents matching the query, most recent first.
+// The returned page token continues after the last returned document and is empty if there are no more documents.
+func (mongodb *Mongo) GetAddressIndexes(query *AddressIndexQuery, pageToken string, limit int64) ([]*entity.AddressIndex, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if len(query.Address) == 0 && len(query.Token) == 0 {
+		return nil, "", fmt.Errorf("address index query requires an address or a token")
+	}
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ADDRESS_INDEX}, {Key: "kind", Value: query.Kind}}
+	if len(query.Address) > 0 {
+		filter = append(filter, bson.E{Key: "address", Value: query.Address})
+	}
+	if len(query.Token) > 0 {
+		if query.Filter != nil && len(query.Filter.Token) > 0 {
+			return nil, "", fmt.Errorf("address index query and filter both select a token")
+		}
+		filter = append(filter, bson.E{Key: "token", Value: query.Token})
+		if len(query.Address) == 0 && (query.Filter == nil || query.Filter.Direction == "") {
+			// every transfer has exactly one outgoing or self document
+			filter = append(filter, bson.E{Key: "direction", Value: bson.D{{Key: "$in", Value: bson.A{DIRECTION_OUT, DIRECTION_SELF}}}})
+		}
+	}
+
+	filter = appendAddressIndexFilter(filter, query.Filter)
+
+	if pageToken != "" {
+		c, err := decodeAddressIndexCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "blocknumber", Value: bson.D{{Key: "$lt", Value: c.BlockNumber}}}},
+			bson.D{{Key: "blocknumber", Value: c.BlockNumber}, {Key: "txindex", Value: bson.D{{Key: "$lt", Value: c.TxIndex}}}},
+			bson.D{{Key: "blocknumber", Value: c.BlockNumber}, {Key: "txindex", Value: c.TxIndex}, {Key: "position", Value: bson.D{{Key: "$lt", Value: c.Position}}}},
+		}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "blocknumber", Value: -1}, {Key: "txindex", Value: -1}, {Key: "position", Value: -1}}).
+		SetLimit(limit)
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving %v address indexes: %w", query.Kind, err)
+	}
+
+	indexes := make([]*entity.AddressIndex, 0, limit)
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, "", fmt.Errorf("error decoding %v address indexes: %w", query.Kind, err)
+	}
+
+	nextPageToken := ""
+	if int64(len(indexes)) == limit && limit > 0 {
+		last := indexes[len(indexes)-1]
+		nextPageToken = (&addressIndexCursor{BlockNumber: last.BlockNumber, TxIndex: last.TxIndex, Position: last.Position}).encode()
+	}
+
+	return indexes, nextPageToken, nil
+}
+
+// transferParticipants returns the sender and the recipient of the entity referenced by an address index document
+func transferParticipants(idx *entity.AddressIndex) (from []byte, to []byte) {
+	switch idx.Direction {
+	case DIRECTION_IN:
+		return idx.Counterparty, idx.Address
+	case DIRECTION_OUT:
+		return idx.Address, idx.Counterparty
+	default:
+		return idx.Address, idx.Address
+	}
+}
+
+func addressIndexTime(idx *entity.AddressIndex) *timestamppb.Timestamp {
+	return timestamppb.New(time.Unix(int64(idx.Time.T), 0))
+}
+
+// ConvertLegacyAddressIndexes rebuilds the structured address index documents of the blocks in the given range from
+// the blocks collection and removes the legacy string keyed index documents of these blocks, which are no longer read.
+// The legacy documents of a block are found through the keys journaled for it when it was indexed.
+func (mongodb *Mongo) ConvertLegacyAddressIndexes(start, end uint64) error {
+	if end == 0 || end < start {
+		return fmt.Errorf("invalid block range %v - %v, the end block has to be set and must not be below the start block", start, end)
+	}
+
+	transforms := mongodb.DataTransforms()
+	cache := freecache.NewCache(100 * 1024 * 1024)
+
+	legacyPrefix := mongodb.ChainId + ":I:"
+	deleted := int64(0)
+	for number := start; number <= end; number++ {
+		block, err := mongodb.GetBlockFromBlocksTable(number)
+		if err != nil {
+			if errors.Is(err, ErrBlockNotFound) {
+				logrus.Warnf("block %v not found in blocks collection, skipping address index conversion", number)
+				continue
+			}
+			return fmt.Errorf("error retrieving block %v: %w", number, err)
+		}
+
+		models := []mongo.WriteModel{}
+		for _, transform := range transforms {
+			bulkData, _, err := transform.Transform(block, cache)
+			if err != nil {
+				return fmt.Errorf("error transforming block %v: %w", number, err)
+			}
+			for _, model := range bulkData.Model {
+				if isAddressIndexModel(model) {
+					models = append(models, model)
+				}
+			}
+		}
+
+		keys, err := mongodb.GetBlockKeys(number, block.GetHash())
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("error retrieving legacy keys of block %v: %w", number, err)
+		}
+		legacyKeys := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if strings.HasPrefix(key, legacyPrefix) {
+				legacyKeys = append(legacyKeys, key)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+		_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ADDRESS_INDEX}, {Key: "blocknumber", Value: number}})
+		if err == nil && len(models) > 0 {
+			_, err = mongodb.Db.Collection(DATA).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		}
+		if err == nil && len(legacyKeys) > 0 {
+			var res *mongo.DeleteResult
+			res, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$in", Value: legacyKeys}}}})
+			if err == nil {
+				deleted += res.DeletedCount
+			}
+		}
+		cancel()
+		if err != nil {
+			return fmt.Errorf("error writing address indexes of block %v: %w", number, err)
+		}
+
+		if number%1000 == 0 {
+			logrus.Infof("converted address indexes up to block %v", number)
+		}
+	}
+	logrus.Infof("deleted %v legacy index documents of blocks %v - %v", deleted, start, end)
+
+	return nil
+}
+
+func isAddressIndexModel(model mongo.WriteModel) bool {
+	upsert, ok := model.(*mongo.ReplaceOneModel)
+	if !ok {
+		return false
+	}
+	doc, ok := upsert.Replacement.(*bson.D)
+	if !ok {
+		return false
+	}
+	for _, e := range *doc {
+		if e.Key == "type" {
+			return e.Value == ADDRESS_INDEX
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+)
+
+func TestAddressIndexCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    addressIndexCursor
+		wantErr bool
+	}{
+		{name: "round trip", token: (&addressIndexCursor{BlockNumber: 17000000, TxIndex: 12, Position: 3}).encode(), want: addressIndexCursor{BlockNumber: 17000000, TxIndex: 12, Position: 3}},
+		{name: "legacy key", token: "1:I:TX:abcd:TIME:", wantErr: true},
+		{name: "garbage", token: "bm90LWEtY3Vyc29y", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAddressIndexCursor(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeAddressIndexCursor(%q) error = %v, wantErr %v", tt.token, err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != tt.want {
+				t.Errorf("decodeAddressIndexCursor(%q) = %+v, want %+v", tt.token, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressIndexesFor(t *testing.T) {
+	a := []byte{0x01}
+	b := []byte{0x02}
+
+	tests := []struct {
+		name       string
+		from       []byte
+		to         []byte
+		directions []string
+	}{
+		{name: "transfer", from: a, to: b, directions: []string{DIRECTION_OUT, DIRECTION_IN}},
+		{name: "self transfer", from: a, to: a, directions: []string{DIRECTION_SELF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexes := addressIndexesFor(entity.AddressIndex{Kind: ADDRESS_INDEX_KIND_TX}, tt.from, tt.to)
+			if len(indexes) != len(tt.directions) {
+				t.Fatalf("got %v indexes, want %v", len(indexes), len(tt.directions))
+			}
+			for i, idx := range indexes {
+				if idx.Direction != tt.directions[i] {
+					t.Errorf("index %v direction = %v, want %v", i, idx.Direction, tt.directions[i])
+				}
+				from, to := transferParticipants(idx)
+				if !bytes.Equal(from, tt.from) || !bytes.Equal(to, tt.to) {
+					t.Errorf("index %v participants = %x -> %x, want %x -> %x", i, from, to, tt.from, tt.to)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAddressIndexFilter(t *testing.T) {
+	counterparty := []byte{0x5a, 0xae, 0xb6, 0x05, 0x3f, 0x3e, 0x94, 0xc9, 0xb9, 0xa0, 0x9f, 0x33, 0x66, 0x94, 0x35, 0xe7, 0xef, 0x1b, 0xea, 0xed}
+
+	tests := []struct {
+		name    string
+		search  string
+		want    *AddressIndexFilter
+		wantErr bool
+	}{
+		{name: "empty", search: "  ", want: nil},
+		{name: "outgoing to counterparty", search: "TO:Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", want: &AddressIndexFilter{Direction: DIRECTION_OUT, Counterparty: counterparty}},
+		{name: "incoming", search: "from", want: &AddressIndexFilter{Direction: DIRECTION_IN}},
+		{name: "combined", search: "METHOD:0xa9059cbb ERROR CONTRACT BLOCK:100-200 TIME:-1700000000", want: &AddressIndexFilter{Method: []byte{0xa9, 0x05, 0x9c, 0xbb}, FailedOnly: true, ContractCreationOnly: true, FromBlock: 100, ToBlock: 200, ToTime: time.Unix(1700000000, 0)}},
+		{name: "token received", search: "TOKEN_RECEIVED:0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", want: &AddressIndexFilter{Direction: DIRECTION_IN, Token: counterparty}},
+		{name: "conflicting directions", search: "TO FROM", wantErr: true},
+		{name: "short method", search: "METHOD:0xa905", wantErr: true},
+		{name: "reversed range", search: "BLOCK:200-100", wantErr: true},
+		{name: "unknown term", search: "VALUE:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddressIndexFilter(tt.search)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAddressIndexFilter(%q) error = %v, wantErr %v", tt.search, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAddressIndexFilter(%q) = %+v, want %+v", tt.search, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertLegacyAddressIndexesRange(t *testing.T) {
+	mongodb := &Mongo{ChainId: "1"}
+	for _, r := range [][2]uint64{{0, 0}, {10, 5}} {
+		if err := mongodb.ConvertLegacyAddressIndexes(r[0], r[1]); err == nil {
+			t.Errorf("got no error converting blocks %v - %v", r[0], r[1])
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BALANCE_DELTA is the type of the balance delta documents stored in the data collection
+const BALANCE_DELTA = "balancedelta"
+
+// balanceDeltaID returns the id of the balance delta document of an address and token in a block, the zero padded
+// block number makes the ids of an address and token sort by block
+func (mongodb *Mongo) balanceDeltaID(address, token []byte, blockNumber uint64) string {
+	return mongodb.documentID(BALANCE_DELTA, address, token, fmt.Sprintf("%020d", blockNumber))
+}
+
+// blockBalanceDeltas returns the net balance changes caused by a block ordered by address and token. Native balances
+// change by the fees paid by the senders, the priority fees received by the fee recipient, the values moved by
+// successful txs and their calls and by withdrawals. Token balances change by the Transfer logs of ERC20 tokens. Calls
+// reverted with a failed caller are skipped, they carry the error of the caller.
+func blockBalanceDeltas(blk *types.Eth1Block) []*types.Eth1BalanceDelta {
+	native := []byte{0x0}
+	deltas := make(map[string]*types.Eth1BalanceDelta)
+
+	add := func(address, token []byte, value *big.Int, negative bool) {
+		if len(address) == 0 || value.Sign() == 0 {
+			return
+		}
+		key := string(address) + ":" + string(token)
+		delta, ok := deltas[key]
+		if !ok {
+			delta = &types.Eth1BalanceDelta{
+				Address:     address,
+				Token:       token,
+				BlockNumber: blk.GetNumber(),
+				Time:        blk.GetTime().AsTime(),
+				Delta:       new(big.Int),
+			}
+			deltas[key] = delta
+		}
+		if negative {
+			delta.Delta.Sub(delta.Delta, value)
+		} else {
+			delta.Delta.Add(delta.Delta, value)
+		}
+	}
+	transfer := func(from, to, token []byte, value *big.Int) {
+		add(from, token, value, true)
+		add(to, token, value, false)
+	}
+
+	baseFee := new(big.Int).SetBytes(blk.GetBaseFee())
+	for _, tx := range blk.GetTransactions() {
+		gasUsed := new(big.Int).SetUint64(tx.GetGasUsed())
+		gasPrice := CalculateEffectiveGasPrice(tx, baseFee)
+		add(tx.GetFrom(), native, new(big.Int).Mul(gasUsed, gasPrice), true)
+		if tip := new(big.Int).Sub(gasPrice, baseFee); tip.Sign() > 0 {
+			add(blk.GetCoinbase(), native, tip.Mul(tip, gasUsed), false)
+		}
+
+		if tx.GetStatus() != 1 {
+			continue
+		}
+
+		// the traces contain the top level call, without them only the value of the tx is known
+		if len(tx.GetItx()) == 0 {
+			to := tx.GetTo()
+			if len(to) == 0 {
+				to = tx.GetContractAddress()
+			}
+			transfer(tx.GetFrom(), to, native, new(big.Int).SetBytes(tx.GetValue()))
+		}
+		for _, itx := range tx.GetItx() {
+			switch itx.GetType() {
+			case "delegatecall", "staticcall", "callcode":
+				// run in the context of the caller, no value is moved
+				continue
+			}
+			if itx.GetErrorMsg() != "" {
+				continue
+			}
+			transfer(itx.GetFrom(), itx.GetTo(), native, new(big.Int).SetBytes(itx.GetValue()))
+		}
+
+		for _, log := range tx.GetLogs() {
+			topics := log.GetTopics()
+			if len(topics) != 3 || !bytes.Equal(topics[0], erc20.TransferTopic) || len(log.GetData()) != 32 {
+				continue
+			}
+			value := new(big.Int).SetBytes(log.GetData())
+			// mints and burns only change the balance of the other side
+			if from := common.BytesToAddress(topics[1]).Bytes(); !bytes.Equal(from, ZERO_ADDRESS) {
+				add(from, log.GetAddress(), value, true)
+			}
+			if to := common.BytesToAddress(topics[2]).Bytes(); !bytes.Equal(to, ZERO_ADDRESS) {
+				add(to, log.GetAddress(), value, false)
+			}
+		}
+	}
+
+	gwei := big.NewInt(1e9)
+	for _, withdrawal := range blk.GetWithdrawals() {
+		add(withdrawal.GetAddress(), native, new(big.Int).Mul(new(big.Int).SetBytes(withdrawal.GetAmount()), gwei), false)
+	}
+
+	result := make([]*types.Eth1BalanceDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		if delta.Delta.Sign() != 0 {
+			result = append(result, delta)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := bytes.Compare(result[i].Address, result[j].Address); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(result[i].Token, result[j].Token) < 0
+	})
+	return result
+}
+
+// TransformBalances stores the net balance changes of every address and token touched by the block
+func (mongodb *Mongo) TransformBalances(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	bulkData := &types.BulkMutations{}
+	var bulkMetadataUpdates []mongo.WriteModel
+
+	for _, delta := range blockBalanceDeltas(blk) {
+		doc, err := utils.ToDoc(&entity.BalanceDelta{
+			ChainId:     mongodb.ChainId,
+			Type:        BALANCE_DELTA,
+			Address:     delta.Address,
+			Token:       delta.Token,
+			BlockNumber: delta.BlockNumber,
+			Time:        primitive.Timestamp{T: uint32(delta.Time.Unix()), I: 0},
+			Value:       new(big.Int).Abs(delta.Delta).Bytes(),
+			Negative:    delta.Delta.Sign() < 0,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		bulkData.Model = append(bulkData.Model, upsertModel(mongodb.balanceDeltaID(delta.Address, delta.Token, delta.BlockNumber), doc))
+	}
+
+	return bulkData, bulkMetadataUpdates, nil
+}
+
+func balanceDeltaFromEntity(delta *entity.BalanceDelta) *types.Eth1BalanceDelta {
+	value := new(big.Int).SetBytes(delta.Value)
+	if delta.Negative {
+		value.Neg(value)
+	}
+	return &types.Eth1BalanceDelta{
+		Address:     delta.Address,
+		Token:       delta.Token,
+		BlockNumber: delta.BlockNumber,
+		Time:        time.Unix(int64(delta.Time.T), 0).UTC(),
+		Delta:       value,
+	}
+}
+
+// GetBalanceDeltas returns the balance changes of the address for the token ordered by block
+func (mongodb *Mongo) GetBalanceDeltas(address []byte, token []byte, filter *types.BalanceDeltaFilter) ([]*types.Eth1BalanceDelta, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	query := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: BALANCE_DELTA}, {Key: "address", Value: address}, {Key: "token", Value: token}}
+	if filter != nil {
+		blocks := bson.D{}
+		if filter.FromBlock > 0 {
+			blocks = append(blocks, bson.E{Key: "$gte", Value: filter.FromBlock})
+		}
+		if filter.ToBlock > 0 {
+			blocks = append(blocks, bson.E{Key: "$lte", Value: filter.ToBlock})
+		}
+		if len(blocks) > 0 {
+			query = append(query, bson.E{Key: "blocknumber", Value: blocks})
+		}
+		if !filter.FromTime.IsZero() {
+			query = append(query, bson.E{Key: "time", Value: bson.D{{Key: "$gte", Value: primitive.Timestamp{T: uint32(filter.FromTime.Unix()), I: 0}}}})
+		}
+	}
+
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, query, options.Find().SetSort(bson.D{{Key: "blocknumber", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving balance deltas of %x for token %x: %w", address, token, err)
+	}
+
+	var results []*entity.BalanceDelta
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding balance deltas of %x for token %x: %w", address, token, err)
+	}
+
+	deltas := make([]*types.Eth1BalanceDelta, 0, len(results))
+	for _, result := range results {
+		deltas = append(deltas, balanceDeltaFromEntity(result))
+	}
+	return deltas, nil
+}
+
+// balanceAnchor returns the last balance of the address for the token retrieved from the node and the block it was
+// retrieved at, found is false if the balance has never been retrieved
+func balanceAnchor(storage interfaces.Database, address, token []byte) (balance *big.Int, blockNumber uint64, found bool, err error) {
+	refreshed, err := storage.GetBalanceForAddress(address, token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return new(big.Int), 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return new(big.Int).SetBytes(refreshed.Balance), refreshed.BlockNumber, true, nil
+}
+
+// balanceAt returns the balance at the end of the block, derived from a balance at the anchor block and the deltas
+// between the two blocks
+func balanceAt(storage interfaces.Database, address, token []byte, anchor *big.Int, anchorBlock, blockNumber uint64) (*big.Int, error) {
+	balance := new(big.Int).Set(anchor)
+	if blockNumber == anchorBlock {
+		return balance, nil
+	}
+
+	filter := &types.BalanceDeltaFilter{FromBlock: anchorBlock + 1, ToBlock: blockNumber}
+	if blockNumber < anchorBlock {
+		filter = &types.BalanceDeltaFilter{FromBlock: blockNumber + 1, ToBlock: anchorBlock}
+	}
+	deltas, err := storage.GetBalanceDeltas(address, token, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, delta := range deltas {
+		if blockNumber < anchorBlock {
+			balance.Sub(balance, delta.Delta)
+		} else {
+			balance.Add(balance, delta.Delta)
+		}
+	}
+	return balance, nil
+}
+
+// GetBalanceAtBlock returns the balance of the address for the token at the end of the block. It is derived from the
+// last balance retrieved from the node and the balance deltas between the two blocks, addresses whose balance has never
+// been retrieved start with a zero balance at block 0.
+func GetBalanceAtBlock(storage interfaces.Database, address, token []byte, blockNumber uint64) (*big.Int, error) {
+	anchor, anchorBlock, _, err := balanceAnchor(storage, address, token)
+	if err != nil {
+		return nil, err
+	}
+	return balanceAt(storage, address, token, anchor, anchorBlock, blockNumber)
+}
+
+// GetDailyBalances returns the balances of the address for the token at the end of every day (UTC) from start to end
+func GetDailyBalances(storage interfaces.Database, address, token []byte, start, end time.Time) ([]*types.Eth1DailyBalance, error) {
+	start = start.UTC().Truncate(time.Hour * 24)
+	end = end.UTC().Truncate(time.Hour * 24)
+
+	anchor, anchorBlock, _, err := balanceAnchor(storage, address, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the deltas since the start and the deltas after the anchor cover every change between the two
+	deltas, err := storage.GetBalanceDeltas(address, token, &types.BalanceDeltaFilter{FromTime: start})
+	if err != nil {
+		return nil, err
+	}
+	afterAnchor, err := storage.GetBalanceDeltas(address, token, &types.BalanceDeltaFilter{FromBlock: anchorBlock + 1})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint64]bool, len(deltas))
+	for _, delta := range deltas {
+		seen[delta.BlockNumber] = true
+	}
+	for _, delta := range afterAnchor {
+		if !seen[delta.BlockNumber] {
+			deltas = append(deltas, delta)
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].BlockNumber < deltas[j].BlockNumber })
+
+	// walk back from the anchor to the balance before the first delta
+	balance := new(big.Int).Set(anchor)
+	for _, delta := range deltas {
+		if delta.BlockNumber <= anchorBlock {
+			balance.Sub(balance, delta.Delta)
+		}
+	}
+
+	balances := make([]*types.Eth1DailyBalance, 0, int(end.Sub(start).Hours()/24)+1)
+	i := 0
+	for day := start; !day.After(end); day = day.Add(time.Hour * 24) {
+		next := day.Add(time.Hour * 24)
+		for ; i < len(deltas) && deltas[i].Time.Before(next); i++ {
+			balance.Add(balance, deltas[i].Delta)
+		}
+		balances = append(balances, &types.Eth1DailyBalance{Day: day, Balance: new(big.Int).Set(balance)})
+	}
+	return balances, nil
+}
+
+// ExpectedBalance returns the balance expected for the address and token of a balance retrieved from the node at its
+// block, derived from the previously retrieved balance and the balance deltas since. It returns nil if the balance has
+// not been retrieved before.
+func ExpectedBalance(storage interfaces.Database, balance *types.Eth1AddressBalance) (*big.Int, error) {
+	anchor, anchorBlock, found, err := balanceAnchor(storage, balance.Address, balance.Token)
+	if err != nil || !found {
+		return nil, err
+	}
+	return balanceAt(storage, balance.Address, balance.Token, anchor, anchorBlock, balance.BlockNumber)
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBlockBalanceDeltas(t *testing.T) {
+	native := []byte{0x0}
+	coinbase := common.HexToAddress("0xc0")
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	contract := common.HexToAddress("0xcc")
+	token := common.HexToAddress("0xe2")
+
+	block := &types.Eth1Block{
+		Number:   7,
+		Coinbase: coinbase.Bytes(),
+		BaseFee:  big.NewInt(10).Bytes(),
+		Time:     timestamppb.New(time.Unix(1700000000, 0)),
+		Transactions: []*types.Eth1Transaction{
+			{
+				// alice calls the contract with 100, which forwards 40 to bob, 5 to alice in a reverted call and
+				// mints tokens for bob; the fee is 10 gas at 12 with a tip of 2
+				Type:                 2,
+				From:                 alice.Bytes(),
+				To:                   contract.Bytes(),
+				Value:                big.NewInt(100).Bytes(),
+				GasUsed:              10,
+				MaxFeePerGas:         big.NewInt(20).Bytes(),
+				MaxPriorityFeePerGas: big.NewInt(2).Bytes(),
+				Status:               1,
+				Itx: []*types.Eth1InternalTransaction{
+					{Type: "call", Path: "[]", From: alice.Bytes(), To: contract.Bytes(), Value: big.NewInt(100).Bytes()},
+					{Type: "call", Path: "[0]", From: contract.Bytes(), To: bob.Bytes(), Value: big.NewInt(40).Bytes()},
+					{Type: "call", Path: "[1]", From: contract.Bytes(), To: alice.Bytes(), Value: big.NewInt(5).Bytes(), ErrorMsg: "execution reverted"},
+					{Type: "delegatecall", Path: "[2]", From: contract.Bytes(), To: bob.Bytes(), Value: big.NewInt(100).Bytes()},
+				},
+				Logs: []*types.Eth1Log{
+					{
+						Address: token.Bytes(),
+						Data:    common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+						Topics:  [][]byte{erc20.TransferTopic, common.LeftPadBytes(ZERO_ADDRESS, 32), common.LeftPadBytes(bob.Bytes(), 32)},
+					},
+				},
+			},
+			{
+				// a failed legacy transfer from bob only pays the fee of 5 gas at 10, without a tip
+				Type:     0,
+				From:     bob.Bytes(),
+				To:       alice.Bytes(),
+				Value:    big.NewInt(1000).Bytes(),
+				GasUsed:  5,
+				GasPrice: big.NewInt(10).Bytes(),
+				Status:   0,
+			},
+		},
+		Withdrawals: []*types.Eth1Withdrawal{
+			{Address: bob.Bytes(), Amount: big.NewInt(3).Bytes()},
+		},
+	}
+
+	want := []struct {
+		address common.Address
+		token   []byte
+		delta   int64
+	}{
+		{address: alice, token: native, delta: -100 - 120},
+		{address: bob, token: native, delta: 40 - 50 + 3e9},
+		{address: bob, token: token.Bytes(), delta: 1000},
+		{address: coinbase, token: native, delta: 20},
+		{address: contract, token: native, delta: 60},
+	}
+
+	got := blockBalanceDeltas(block)
+	if len(got) != len(want) {
+		for _, delta := range got {
+			t.Logf("%x / %x: %v", delta.Address, delta.Token, delta.Delta)
+		}
+		t.Fatalf("got %v balance deltas, want %v", len(got), len(want))
+	}
+	for i, delta := range got {
+		if !bytes.Equal(delta.Address, want[i].address.Bytes()) || !bytes.Equal(delta.Token, want[i].token) {
+			t.Errorf("delta %v is for %x / %x, want %x / %x", i, delta.Address, delta.Token, want[i].address, want[i].token)
+			continue
+		}
+		if delta.Delta.Cmp(big.NewInt(want[i].delta)) != 0 {
+			t.Errorf("delta of %x / %x = %v, want %v", delta.Address, delta.Token, delta.Delta, want[i].delta)
+		}
+		if delta.BlockNumber != block.Number {
+			t.Errorf("delta of %x / %x is at block %v, want %v", delta.Address, delta.Token, delta.BlockNumber, block.Number)
+		}
+	}
+}
+
+func TestEmbeddedBalanceHistory(t *testing.T) {
+	embedded := testEmbedded(t)
+	testBalanceHistory(t, embedded, embedded.TransformBalances)
+}
+
+func TestMongoBalanceHistory(t *testing.T) {
+	mongodb := testMongo(t)
+	testBalanceHistory(t, mongodb, mongodb.TransformBalances)
+}
+
+// testBalanceHistory writes the balance deltas of a few blocks with transform and checks the balance history queries
+// of storage
+func testBalanceHistory(t *testing.T, storage interfaces.Database, transform func(*types.Eth1Block, *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error)) {
+	native := []byte{0x0}
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	day := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// alice receives a withdrawal of 10 gwei on the first day and sends 1 gwei to bob on the second and the third day
+	send := func() []*types.Eth1Transaction {
+		return []*types.Eth1Transaction{{From: alice.Bytes(), To: bob.Bytes(), Value: big.NewInt(1e9).Bytes(), Status: 1}}
+	}
+	blocks := []*types.Eth1Block{
+		{Number: 1, Time: timestamppb.New(day.Add(time.Hour)), Withdrawals: []*types.Eth1Withdrawal{{Address: alice.Bytes(), Amount: big.NewInt(10).Bytes()}}},
+		{Number: 2, Time: timestamppb.New(day.Add(time.Hour * 25))},
+		{Number: 3, Time: timestamppb.New(day.Add(time.Hour * 26)), Transactions: send()},
+		{Number: 4, Time: timestamppb.New(day.Add(time.Hour * 49)), Transactions: send()},
+	}
+	if err := testutil.WriteFixtureBlocks(storage, blocks, transform); err != nil {
+		t.Fatal(err)
+	}
+
+	// the balance of alice has been retrieved from the node at block 3
+	err := storage.SaveBalances([]*types.Eth1AddressBalance{{Address: alice.Bytes(), Token: native, Balance: big.NewInt(9e9).Bytes(), BlockNumber: 3}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+	for block, want := range map[uint64]*big.Int{0: gwei(0), 1: gwei(10), 2: gwei(10), 3: gwei(9), 4: gwei(8), 5: gwei(8)} {
+		got, err := GetBalanceAtBlock(storage, alice.Bytes(), native, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("balance at block %v = %v, want %v", block, got, want)
+		}
+	}
+
+	daily, err := GetDailyBalances(storage, alice.Bytes(), native, day.Add(time.Hour*24), day.Add(time.Hour*24*3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDaily := []*big.Int{gwei(9), gwei(8), gwei(8)}
+	if len(daily) != len(wantDaily) {
+		t.Fatalf("got %v daily balances, want %v", len(daily), len(wantDaily))
+	}
+	for i, balance := range daily {
+		if wantDay := day.Add(time.Hour * 24 * time.Duration(i+1)); !balance.Day.Equal(wantDay) {
+			t.Errorf("daily balance %v is for %v, want %v", i, balance.Day, wantDay)
+		}
+		if balance.Balance.Cmp(wantDaily[i]) != 0 {
+			t.Errorf("balance at the end of %v = %v, want %v", balance.Day, balance.Balance, wantDaily[i])
+		}
+	}
+
+	// the balance of bob has never been retrieved, the balance of alice is expected to be 8 gwei at block 4
+	expected, err := ExpectedBalance(storage, &types.Eth1AddressBalance{Address: bob.Bytes(), Token: native, Balance: gwei(2).Bytes(), BlockNumber: 4})
+	if err != nil || expected != nil {
+		t.Errorf("balance without a previous balance: got expected balance %v and error %v, want none", expected, err)
+	}
+	expected, err = ExpectedBalance(storage, &types.Eth1AddressBalance{Address: alice.Bytes(), Token: native, Balance: gwei(8).Bytes(), BlockNumber: 4})
+	if err != nil || expected == nil || expected.Cmp(gwei(8)) != 0 {
+		t.Errorf("got expected balance %v and error %v, want %v", expected, err, gwei(8))
+	}
+
+	if err := storage.DeleteBlock(4, blocks[3].Hash); err != nil {
+		t.Fatal(err)
+	}
+	deltas, err := storage.GetBalanceDeltas(alice.Bytes(), native, &types.BalanceDeltaFilter{FromBlock: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 1 || deltas[0].BlockNumber != 3 {
+		t.Errorf("got %v balance deltas after deleting block 4, want only block 3", len(deltas))
+	}
+}
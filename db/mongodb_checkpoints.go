@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CHECKPOINTS = "checkpoints"
+const TRANSFORM_CURSORS = "transform_cursors"
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// SaveCheckpoint persists the highest contiguous block processed by the named job. The stored value never moves backwards,
+// use ResetCheckpoint to rewind a checkpoint.
+func (mongodb *Mongo) SaveCheckpoint(name string, block uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "name", Value: name}}
+	update := bson.D{
+		{Key: "$max", Value: bson.D{{Key: "block", Value: block}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+	}
+
+	_, err := mongodb.Db.Collection(CHECKPOINTS).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// ResetCheckpoint sets the checkpoint of the named job to the passed block, even if it is lower than the stored value
+func (mongodb *Mongo) ResetCheckpoint(name string, block uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "name", Value: name}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "block", Value: block}, {Key: "updatedat", Value: time.Now()}}}}
+
+	_, err := mongodb.Db.Collection(CHECKPOINTS).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetCheckpoint returns the checkpoint of the named job, ErrCheckpointNotFound is returned if the job never stored one
+func (mongodb *Mongo) GetCheckpoint(name string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var result entity.Checkpoint
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "name", Value: name}}
+	err := mongodb.Db.Collection(CHECKPOINTS).FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, ErrCheckpointNotFound
+		}
+		return 0, err
+	}
+
+	return result.Block, nil
+}
+
+// SaveTransformCursor persists the highest contiguous block processed by the version of the named transform. The stored
+// value never moves backwards.
+func (mongodb *Mongo) SaveTransformCursor(name string, version uint64, block uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "name", Value: name}, {Key: "version", Value: version}}
+	update := bson.D{
+		{Key: "$max", Value: bson.D{{Key: "block", Value: block}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+	}
+
+	_, err := mongodb.Db.Collection(TRANSFORM_CURSORS).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetTransformCursors returns the cursors of all transforms and versions, ordered by name and version
+func (mongodb *Mongo) GetTransformCursors() ([]*entity.TransformCursor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	cursor, err := mongodb.Db.Collection(TRANSFORM_CURSORS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving transform cursors: %w", err)
+	}
+
+	cursors := []*entity.TransformCursor{}
+	if err = cursor.All(ctx, &cursors); err != nil {
+		return nil, fmt.Errorf("error while parsing transform cursors: %w", err)
+	}
+	return cursors, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VERIFIED_CODE_FAMILY is the type of the metadata of verified runtime bytecode, keyed by the hash of the code so
+// that contracts deployed with identical bytecode are matched to it
+const VERIFIED_CODE_FAMILY = "verified_code"
+
+func contractMetadataToEntity(metadata *types.ContractMetadata) *entity.ContractMetadataFamily {
+	ret := &entity.ContractMetadataFamily{
+		Name:             metadata.Name,
+		Abi:              metadata.ABIJson,
+		Compiler:         metadata.Compiler,
+		CompilerMetadata: metadata.CompilerMetadata,
+	}
+	for path, content := range metadata.Sources {
+		ret.Sources = append(ret.Sources, entity.ContractSource{Path: path, Content: content})
+	}
+	sort.Slice(ret.Sources, func(i, j int) bool {
+		return ret.Sources[i].Path < ret.Sources[j].Path
+	})
+	return ret
+}
+
+// contractMetadataFromEntity converts the stored metadata, the abi is not decoded
+func contractMetadataFromEntity(metadata *entity.ContractMetadataFamily) *types.ContractMetadata {
+	ret := &types.ContractMetadata{
+		Name:             metadata.Name,
+		ABIJson:          metadata.Abi,
+		Compiler:         metadata.Compiler,
+		CompilerMetadata: metadata.CompilerMetadata,
+	}
+	if len(metadata.Sources) > 0 {
+		ret.Sources = make(map[string]string, len(metadata.Sources))
+		for _, source := range metadata.Sources {
+			ret.Sources[source.Path] = source.Content
+		}
+	}
+	return ret
+}
+
+// SaveVerifiedCode stores the metadata of a contract verified against its runtime bytecode under the hash of the code
+func (mongodb *Mongo) SaveVerifiedCode(codeHash []byte, metadata *types.ContractMetadata) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	verified := contractMetadataToEntity(metadata)
+	verified.ChainId = mongodb.ChainId
+	verified.Type = VERIFIED_CODE_FAMILY
+	verified.CodeHash = fmt.Sprintf("%x", codeHash)
+
+	doc, err := utils.ToDoc(verified)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: VERIFIED_CODE_FAMILY}, {Key: "codehash", Value: verified.CodeHash}}
+	_, err = mongodb.Db.Collection(METADATA).ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving verified code %x: %w", codeHash, err)
+	}
+	if err := mongodb.dropContractMissCache(); err != nil {
+		logger.Errorf("error dropping cached contract metadata misses: %v", err)
+	}
+	return nil
+}
+
+// GetVerifiedCode returns the metadata of the verified runtime bytecode with the passed hash, nil if there is none
+func (mongodb *Mongo) GetVerifiedCode(codeHash []byte) (*types.ContractMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: VERIFIED_CODE_FAMILY}, {Key: "codehash", Value: fmt.Sprintf("%x", codeHash)}}
+	verified := &entity.ContractMetadataFamily{}
+	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(verified)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving verified code %x: %w", codeHash, err)
+	}
+	return contractMetadataFromEntity(verified), nil
+}
+
+// matchVerifiedCode returns the metadata of verified code identical to the deployed code of the address, nil if there
+// is no such code or no execution client to retrieve the deployed code from
+func matchVerifiedCode(storage interface {
+	GetVerifiedCode(codeHash []byte) (*types.ContractMetadata, error)
+}, address []byte) (*types.ContractMetadata, error) {
+	if rpc.CurrentErigonClient == nil {
+		return nil, nil
+	}
+
+	code, err := rpc.CurrentErigonClient.GetCode(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, nil
+	}
+	return storage.GetVerifiedCode(crypto.Keccak256(code))
+}
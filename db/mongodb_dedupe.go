@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// documentID returns the deterministic id of a document derived from a block. It consists of the chain id, the kind of
+// the document and the parts identifying it within the chain, e.g. the tx hash and the log index.
+func (mongodb *Mongo) documentID(kind string, parts ...interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(mongodb.ChainId)
+	sb.WriteString(":")
+	sb.WriteString(kind)
+	for _, part := range parts {
+		if b, ok := part.([]byte); ok {
+			fmt.Fprintf(&sb, ":%x", b)
+			continue
+		}
+		fmt.Fprintf(&sb, ":%v", part)
+	}
+	return sb.String()
+}
+
+// upsertModel returns a model which inserts the document with the passed id or replaces it if it already exists,
+// writing the same document twice leaves a single copy
+func upsertModel(id string, doc interface{}) mongo.WriteModel {
+	return mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).SetReplacement(doc).SetUpsert(true)
+}
+
+// DataTransforms returns all transforms writing to the data collection with their names and versions
+func (mongodb *Mongo) DataTransforms() []*types.Eth1Transform {
+	return []*types.Eth1Transform{
+		{Name: "block", Version: 1, Transform: mongodb.TransformBlock},
+		{Name: "tx", Version: 1, Transform: mongodb.TransformTx},
+		{Name: "itx", Version: 1, Transform: mongodb.TransformItx},
+		{Name: "erc20", Version: 1, Transform: mongodb.TransformERC20},
+		{Name: "erc721", Version: 1, Transform: mongodb.TransformERC721},
+		{Name: "erc1155", Version: 1, Transform: mongodb.TransformERC1155},
+		{Name: "withdrawals", Version: 1, Transform: mongodb.TransformWithdrawals},
+		{Name: "balances", Version: 1, Transform: mongodb.TransformBalances},
+		{Name: "nfts", Version: 1, Transform: mongodb.TransformNFTs},
+		{Name: "logs", Version: 1, Transform: mongodb.TransformLogs},
+	}
+}
+
+// DedupeData replaces the documents of blocks start to end which have been written with random ids by their
+// deterministic counterparts. Every block is transformed again from the blocks collection, all copies of a document
+// collapse into a single upserted one. Duplicate documents of a block in the blocks collection and balance update
+// markers with random ids are removed as well. The removal and the rewrite of a block are not atomic, a failed run can
+// be repeated for the same range.
+func (mongodb *Mongo) DedupeData(start, end uint64) error {
+	transforms := mongodb.DataTransforms()
+	cache := freecache.NewCache(100 * 1024 * 1024)
+
+	deleted := int64(0)
+	for number := start; number <= end; number++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+		block, blocksDeleted, err := mongodb.dedupeBlock(ctx, number)
+		cancel()
+		if err != nil {
+			if errors.Is(err, ErrBlockNotFound) {
+				logrus.Warnf("block %v not found in blocks collection, skipping deduplication", number)
+				continue
+			}
+			return err
+		}
+		deleted += blocksDeleted
+
+		bulkData := &types.BulkMutations{Collections: map[string][]mongo.WriteModel{}}
+		var metadataUpdates []mongo.WriteModel
+		for _, transform := range transforms {
+			mutations, updates, err := transform.Transform(block, cache)
+			if err != nil {
+				return fmt.Errorf("error transforming block %v: %w", number, err)
+			}
+			bulkData.Keys = append(bulkData.Keys, mutations.Keys...)
+			bulkData.Model = append(bulkData.Model, mutations.Model...)
+			for collection, models := range mutations.Collections {
+				bulkData.Collections[collection] = append(bulkData.Collections[collection], models...)
+			}
+			metadataUpdates = append(metadataUpdates, updates...)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second*120)
+		filter := bson.D{
+			{Key: "chainid", Value: mongodb.ChainId},
+			{Key: "_id", Value: bson.D{{Key: "$type", Value: "objectId"}}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "blocknumber", Value: number}},
+				bson.D{{Key: "type", Value: "blockindex"}, {Key: "number", Value: number}},
+			}},
+		}
+		res, err := mongodb.Db.Collection(DATA).DeleteMany(ctx, filter)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("error deleting documents of block %v: %w", number, err)
+		}
+		deleted += res.DeletedCount
+
+		markersDeleted, err := mongodb.dedupeBalanceUpdates(ctx, metadataUpdates)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("error deleting balance update markers of block %v: %w", number, err)
+		}
+		deleted += markersDeleted
+
+		err = mongodb.WriteBlockMutations(block, bulkData, metadataUpdates)
+		if err != nil {
+			return fmt.Errorf("error writing documents of block %v: %w", number, err)
+		}
+
+		if number%1000 == 0 {
+			logrus.Infof("deduplicated documents up to block %v, deleted %v documents", number, deleted)
+		}
+	}
+	logrus.Infof("deduplicated documents of blocks %v - %v, deleted %v documents", start, end, deleted)
+
+	return nil
+}
+
+// dedupeBlock removes all but the first stored copy of a block from the blocks collection and returns the kept block
+// with the number of removed copies. ErrBlockNotFound is returned if the block isn't stored.
+func (mongodb *Mongo) dedupeBlock(ctx context.Context, number uint64) (*types.Eth1Block, int64, error) {
+	var kept struct {
+		ID        interface{} `bson:"_id"`
+		Eth1Block types.Eth1Block
+	}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: number}}
+	err := mongodb.Db.Collection(BLOCKS).FindOne(ctx, filter).Decode(&kept)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, 0, ErrBlockNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error retrieving block %v: %w", number, err)
+	}
+
+	res, err := mongodb.Db.Collection(BLOCKS).DeleteMany(ctx, append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$ne", Value: kept.ID}}}))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error deleting duplicates of block %v: %w", number, err)
+	}
+	return &kept.Eth1Block, res.DeletedCount, nil
+}
+
+// dedupeBalanceUpdates removes the balance update markers with random ids of the address and token pairs marked by
+// the passed metadata updates, the updates replace them with markers with deterministic ids
+func (mongodb *Mongo) dedupeBalanceUpdates(ctx context.Context, metadataUpdates []mongo.WriteModel) (int64, error) {
+	pairs := bson.A{}
+	for _, model := range metadataUpdates {
+		_, doc, err := modelDocument(model)
+		if err != nil {
+			return 0, err
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return 0, err
+		}
+		if docType, _ := bson.Raw(raw).Lookup("type").StringValueOK(); docType != "metadata_balances_updates" {
+			continue
+		}
+		marker := &entity.BalanceUpdates{}
+		if err := bson.Unmarshal(raw, marker); err != nil {
+			return 0, fmt.Errorf("error decoding balance update: %w", err)
+		}
+		pairs = append(pairs, bson.D{{Key: "key", Value: marker.Key}, {Key: "token", Value: marker.Token}})
+	}
+	if len(pairs) == 0 {
+		return 0, nil
+	}
+
+	filter := bson.D{
+		{Key: "chainid", Value: mongodb.ChainId},
+		{Key: "type", Value: "metadata_balances_updates"},
+		{Key: "_id", Value: bson.D{{Key: "$type", Value: "objectId"}}},
+		{Key: "$or", Value: pairs},
+	}
+	res, err := mongodb.Db.Collection(METADATA_UPDATES).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
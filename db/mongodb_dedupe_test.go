@@ -0,0 +1,106 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDocumentID(t *testing.T) {
+	mongodb := &Mongo{ChainId: "1"}
+
+	tests := []struct {
+		name  string
+		kind  string
+		parts []interface{}
+		want  string
+	}{
+		{name: "block", kind: "blockindex", parts: []interface{}{"000000042"}, want: "1:blockindex:000000042"},
+		{name: "tx", kind: "transactionindex", parts: []interface{}{[]byte{0xab, 0xcd}}, want: "1:transactionindex:abcd"},
+		{name: "log", kind: "erc20index", parts: []interface{}{[]byte{0xab, 0xcd}, 3}, want: "1:erc20index:abcd:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mongodb.documentID(tt.kind, tt.parts...); got != tt.want {
+				t.Errorf("documentID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformIDsAreDeterministic(t *testing.T) {
+	mongodb := &Mongo{ChainId: "1"}
+
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	token := common.HexToAddress("0x03")
+	block := &types.Eth1Block{
+		Number: 42,
+		Hash:   common.HexToHash("0x42").Bytes(),
+		Time:   timestamppb.New(time.Unix(1700000000, 0)),
+		Transactions: []*types.Eth1Transaction{
+			{
+				Hash:            common.HexToHash("0xaa").Bytes(),
+				From:            from.Bytes(),
+				To:              token.Bytes(),
+				ContractAddress: ZERO_ADDRESS,
+				Logs: []*types.Eth1Log{
+					{
+						Address: token.Bytes(),
+						Data:    common.LeftPadBytes([]byte{0x01}, 32),
+						Topics:  [][]byte{erc20.TransferTopic, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)},
+					},
+				},
+			},
+		},
+	}
+
+	transforms := map[string]func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error){
+		"tx":    mongodb.TransformTx,
+		"erc20": mongodb.TransformERC20,
+	}
+	for name, transform := range transforms {
+		t.Run(name, func(t *testing.T) {
+			ids := func() []interface{} {
+				bulkData, metadataUpdates, err := transform(block, freecache.NewCache(1024*1024))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the balance and token update markers are written with deterministic ids as well
+				ids := make([]interface{}, 0, len(bulkData.Model)+len(metadataUpdates))
+				for _, model := range append(bulkData.Model, metadataUpdates...) {
+					id, err := writeModelID(model)
+					if err != nil {
+						t.Fatal(err)
+					}
+					ids = append(ids, id)
+				}
+				return ids
+			}
+
+			first := ids()
+			second := ids()
+			if len(first) == 0 {
+				t.Fatalf("transform produced no documents")
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Errorf("ids of the same block differ between runs: %v, %v", first, second)
+			}
+
+			seen := make(map[interface{}]bool)
+			for _, id := range first {
+				if seen[id] {
+					t.Errorf("duplicate id %v", id)
+				}
+				seen[id] = true
+			}
+		})
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"log"
 	"math/big"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -50,6 +49,7 @@ const (
 	FILTER_METHOD         IndexFilter = "METHOD"
 	FILTER_CONTRACT       IndexFilter = "CONTRACT"
 	FILTER_ERROR          IndexFilter = "ERROR"
+	FILTER_BLOCK          IndexFilter = "BLOCK"
 )
 
 const (
@@ -132,6 +132,27 @@ func (mongodb *Mongo) SaveBlock(block *types.Eth1Block) error {
 	return nil
 }
 
+// SaveBlocks stores a batch of blocks with a single bulk write. Blocks are upserted by number,
+// so indexing a range a second time does not create duplicates.
+func (mongodb *Mongo) SaveBlocks(blocks []*types.Eth1Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(blocks))
+	for _, block := range blocks {
+		filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: block.Number}}
+		doc := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block", Value: block}}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true))
+	}
+
+	_, err := mongodb.Db.Collection(BLOCKS).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
 func (mongodb *Mongo) GetBlockFromBlocksTable(number uint64) (*types.Eth1Block, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
 	defer cancel()
@@ -140,6 +161,9 @@ func (mongodb *Mongo) GetBlockFromBlocksTable(number uint64) (*types.Eth1Block,
 	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: number}}
 	err := mongodb.Db.Collection(BLOCKS).FindOne(ctx, filter).Decode(&results)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBlockNotFound
+		}
 		return nil, err
 	}
 
@@ -150,8 +174,8 @@ func (mongodb *Mongo) CheckForGapsInBlocksTable(lookback int) (gapFound bool, st
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}}
-	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(int64(lookback)))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "eth1block.number", Value: -1}}).SetLimit(int64(lookback)))
 	if err != nil {
 		return false, 0, 0, err
 	}
@@ -181,8 +205,8 @@ func (mongodb *Mongo) GetLastBlockInBlocksTable() (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}}
-	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(int64(1)))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "eth1block.number", Value: -1}}).SetLimit(int64(1)))
 	if err != nil {
 		return 0, err
 	}
@@ -193,6 +217,10 @@ func (mongodb *Mongo) GetLastBlockInBlocksTable() (int, error) {
 		logger.Errorf("error while parsing blocks: %v", err)
 	}
 
+	if len(results) == 0 {
+		return 0, nil
+	}
+
 	return int(results[0].Eth1Block.Number), nil
 }
 
@@ -200,9 +228,9 @@ func (mongodb *Mongo) CheckForGapsInDataTable(lookback int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
 	var results []*entity.BlockIndex
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(int64(lookback)))
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(lookback)))
 	if err != nil {
 		return err
 	}
@@ -226,14 +254,17 @@ func (mongodb *Mongo) GetLastBlockInDataTable() (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
 	var results []*entity.BlockIndex
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(int64(1)))
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(1)))
 	if err != nil {
 		return 0, err
 	}
 	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing block data: %v", err)
+		return 0, fmt.Errorf("error while parsing block data: %w", err)
+	}
+	if len(results) == 0 {
+		return 0, nil
 	}
 
 	return int(results[0].Number), nil
@@ -243,9 +274,9 @@ func (mongodb *Mongo) GetMostRecentBlockFromDataTable() (*types.Eth1BlockIndexed
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
 	defer cancel()
 
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}}
 	var results []*entity.BlockIndex
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(int64(1)))
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(1)))
 	if err != nil {
 		return nil, err
 	}
@@ -283,16 +314,20 @@ func (mongodb *Mongo) GetFullBlockDescending(start, limit uint64) ([]*types.Eth1
 		return nil, fmt.Errorf("invalid block range provided (start: %v, limit: %v)", start, limit)
 	}
 
-	blocks := make([]*types.Eth1Block, 0, limit)
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "number", Value: bson.D{{Key: "lte", Value: start}}}}
-	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(limit)))
+	var results []*entity.BlockData
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: bson.D{{Key: "$lte", Value: start}}}}
+	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "eth1block.number", Value: -1}}).SetLimit(int64(limit)))
 	if err != nil {
 		return nil, err
 	}
-	if err = cursor.All(ctx, &blocks); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		logger.Errorf("error while parsing block data: %v", err)
 	}
 
+	blocks := make([]*types.Eth1Block, 0, len(results))
+	for _, result := range results {
+		blocks = append(blocks, &result.Eth1Block)
+	}
 	return blocks, nil
 }
 
@@ -305,18 +340,18 @@ func (mongodb *Mongo) GetFullBlocksDescending(stream chan<- *types.Eth1Block, hi
 		return fmt.Errorf("invalid block range provided (start: %v, limit: %v)", high, low)
 	}
 	limit := high - low
-	blocks := make([]*types.Eth1Block, 0, limit)
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "number", Value: bson.D{{Key: "lte", Value: high}}}}
-	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(limit)))
+	var results []*entity.BlockData
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: bson.D{{Key: "$lte", Value: high}}}}
+	cursor, err := mongodb.Db.Collection(BLOCKS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "eth1block.number", Value: -1}}).SetLimit(int64(limit)))
 	if err != nil {
 		return err
 	}
-	if err = cursor.All(ctx, &blocks); err != nil {
+	if err = cursor.All(ctx, &results); err != nil {
 		logger.Errorf("error while parsing block data: %v", err)
 	}
 
-	for _, block := range blocks {
-		stream <- block
+	for _, result := range results {
+		stream <- &result.Eth1Block
 	}
 	return nil
 }
@@ -328,36 +363,17 @@ func (mongodb *Mongo) GetBlocksIndexedMultiple(blockNumbers []uint64, limit uint
 	blocks := make([]*types.Eth1BlockIndexed, 0, 100)
 
 	var results []*entity.BlockIndex
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "number", Value: bson.D{{Key: "$in", Value: blockNumbers}}}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "$in", Value: blockNumbers}}}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(limit)))
 	if err != nil {
 		return nil, err
 	}
 	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing block data: %v", err)
+		return nil, fmt.Errorf("error while parsing block data: %w", err)
 	}
 
-	for i, result := range results {
-		blocks[i] = &types.Eth1BlockIndexed{
-			Hash:                     result.Hash,
-			ParentHash:               result.ParentHash,
-			UncleHash:                result.UncleHash,
-			Coinbase:                 result.Coinbase,
-			Difficulty:               result.Difficulty,
-			Number:                   result.Number,
-			GasLimit:                 result.GasLimit,
-			GasUsed:                  result.GasUsed,
-			Time:                     timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			BaseFee:                  result.BaseFee,
-			UncleCount:               result.UncleCount,
-			TransactionCount:         result.TransactionCount,
-			Mev:                      result.Mev,
-			LowestGasPrice:           result.LowestGasPrice,
-			HighestGasPrice:          result.HighestGasPrice,
-			TxReward:                 result.TxReward,
-			UncleReward:              result.UncleReward,
-			InternalTransactionCount: result.InternalTransactionCount,
-		}
+	for _, result := range results {
+		blocks = append(blocks, blockIndexed(result))
 	}
 	return blocks, nil
 }
@@ -371,8 +387,8 @@ func (mongodb *Mongo) GetBlocksDescending(start, limit uint64) ([]*types.Eth1Blo
 	defer cancel()
 
 	var results []*entity.BlockIndex
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "lte", Value: start}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "$lte", Value: start}}}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(int64(limit)))
 	if err != nil {
 		return nil, err
 	}
@@ -380,28 +396,9 @@ func (mongodb *Mongo) GetBlocksDescending(start, limit uint64) ([]*types.Eth1Blo
 		logger.Errorf("error while parsing block data: %v", err)
 	}
 
-	blocks := make([]*types.Eth1BlockIndexed, 0, 100)
-	for i, result := range results {
-		blocks[i] = &types.Eth1BlockIndexed{
-			Hash:                     result.Hash,
-			ParentHash:               result.ParentHash,
-			UncleHash:                result.UncleHash,
-			Coinbase:                 result.Coinbase,
-			Difficulty:               result.Difficulty,
-			Number:                   result.Number,
-			GasLimit:                 result.GasLimit,
-			GasUsed:                  result.GasUsed,
-			Time:                     timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			BaseFee:                  result.BaseFee,
-			UncleCount:               result.UncleCount,
-			TransactionCount:         result.TransactionCount,
-			Mev:                      result.Mev,
-			LowestGasPrice:           result.LowestGasPrice,
-			HighestGasPrice:          result.HighestGasPrice,
-			TxReward:                 result.TxReward,
-			UncleReward:              result.UncleReward,
-			InternalTransactionCount: result.InternalTransactionCount,
-		}
+	blocks := make([]*types.Eth1BlockIndexed, 0, len(results))
+	for _, result := range results {
+		blocks = append(blocks, blockIndexed(result))
 	}
 	return blocks, nil
 }
@@ -518,29 +515,19 @@ func (mongodb *Mongo) TransformBlock(block *types.Eth1Block, cache *freecache.Ca
 	if err != nil {
 		return bulkData, nil, err
 	}
-	insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-	bulkData.Model = append(bulkData.Model, insertBlock)
+	bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("blockindex", fmt.Sprintf("%09d", block.GetNumber())), doc))
 
-	indexes := []string{
-		// Index blocks by the miners address
-		fmt.Sprintf("%s:I:B:%x:TIME:%s", mongodb.ChainId, block.GetCoinbase(), block.Time),
-	}
-
-	blockIdentifier := fmt.Sprintf("%s:B:%09d", mongodb.ChainId, block.GetNumber())
-
-	for _, idx := range indexes {
-		mut := &entity.Indexes{
-			Type:  "index",
-			Key:   idx,
-			Value: blockIdentifier,
-		}
-		doc, err := utils.ToDoc(mut)
-		if err != nil {
-			return bulkData, nil, err
-		}
-		insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-		bulkData.Model = append(bulkData.Model, insertBlock)
-		bulkData.Keys = append(bulkData.Keys, idx)
+	// Index blocks by the miners address
+	err = mongodb.appendAddressIndexes(bulkData, &entity.AddressIndex{
+		Kind:        ADDRESS_INDEX_KIND_BLOCK,
+		Address:     block.GetCoinbase(),
+		Direction:   DIRECTION_IN,
+		BlockNumber: block.GetNumber(),
+		Time:        idx.Time,
+		Hash:        block.GetHash(),
+	})
+	if err != nil {
+		return bulkData, nil, err
 	}
 
 	return bulkData, bulkMetadataUpdates, nil
@@ -570,19 +557,21 @@ func CalculateTxFeesFromBlock(block *types.Eth1Block) *big.Int {
 }
 
 func CalculateTxFeeFromTransaction(tx *types.Eth1Transaction, blockBaseFee *big.Int) *big.Int {
-	// calculate tx fee depending on tx type
-	txFee := new(big.Int).SetUint64(tx.GasUsed)
+	return new(big.Int).Mul(new(big.Int).SetUint64(tx.GasUsed), CalculateEffectiveGasPrice(tx, blockBaseFee))
+}
+
+// CalculateEffectiveGasPrice returns the gas price paid by a transaction depending on its type
+func CalculateEffectiveGasPrice(tx *types.Eth1Transaction, blockBaseFee *big.Int) *big.Int {
 	if tx.Type == uint32(2) {
-		// multiply gasused with min(baseFee + maxpriorityfee, maxfee)
-		if normalGasPrice, maxGasPrice := new(big.Int).Add(blockBaseFee, new(big.Int).SetBytes(tx.MaxPriorityFeePerGas)), new(big.Int).SetBytes(tx.MaxFeePerGas); normalGasPrice.Cmp(maxGasPrice) <= 0 {
-			txFee.Mul(txFee, normalGasPrice)
-		} else {
-			txFee.Mul(txFee, maxGasPrice)
+		// min(baseFee + maxpriorityfee, maxfee)
+		normalGasPrice := new(big.Int).Add(blockBaseFee, new(big.Int).SetBytes(tx.MaxPriorityFeePerGas))
+		maxGasPrice := new(big.Int).SetBytes(tx.MaxFeePerGas)
+		if normalGasPrice.Cmp(maxGasPrice) <= 0 {
+			return normalGasPrice
 		}
-	} else {
-		txFee.Mul(txFee, new(big.Int).SetBytes(tx.GasPrice))
+		return maxGasPrice
 	}
-	return txFee
+	return new(big.Int).SetBytes(tx.GasPrice)
 }
 
 func (mongodb *Mongo) TransformTx(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
@@ -630,8 +619,8 @@ func (mongodb *Mongo) TransformTx(blk *types.Eth1Block, cache *freecache.Cache)
 		}
 
 		// Mark Sender and Recipient for balance update
-		mongodb.markBalanceUpdate(indexedTx.From, []byte{0x0}, bulkMetadataUpdates, cache)
-		mongodb.markBalanceUpdate(indexedTx.To, []byte{0x0}, bulkMetadataUpdates, cache)
+		mongodb.markBalanceUpdate(indexedTx.From, []byte{0x0}, &bulkMetadataUpdates, cache)
+		mongodb.markBalanceUpdate(indexedTx.To, []byte{0x0}, &bulkMetadataUpdates, cache)
 
 		if len(indexedTx.Hash) != 32 {
 			logger.Fatalf("retrieved hash of length %v for a tx in block %v", len(indexedTx.Hash), blk.GetNumber())
@@ -641,45 +630,21 @@ func (mongodb *Mongo) TransformTx(blk *types.Eth1Block, cache *freecache.Cache)
 		if err != nil {
 			return nil, nil, err
 		}
-		insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-		bulkData.Model = append(bulkData.Model, insertBlock)
-		// indexes := []mongo.IndexModel{{Keys: bson.D{{Key: "to", Value: -1}, {Key: "time", Value: -1}, {Key: "block", Value: -1}, {Key: "method", Value: -1}, {Key: "from", Value: -1}, {Key: "iscontractcreation", Value: -1}, {Key: "errormsg", Value: -1}}}}
-		indexes := []string{
-			fmt.Sprintf("%s:I:TX:%x:TO:%x:%s:%019d", mongodb.ChainId, tx.GetFrom(), to, blk.GetTime(), i),
-			fmt.Sprintf("%s:I:TX:%x:TIME:%s:%019d", mongodb.ChainId, tx.GetFrom(), blk.GetTime(), i),
-			fmt.Sprintf("%s:I:TX:%x:BLOCK:%09d:%d", mongodb.ChainId, tx.GetFrom(), blk.GetNumber(), i),
-			fmt.Sprintf("%s:I:TX:%x:METHOD:%x:%019d:%d", mongodb.ChainId, tx.GetFrom(), method, blk.GetTime(), i),
-			fmt.Sprintf("%s:I:TX:%x:FROM:%x:%019d:%d", mongodb.ChainId, to, tx.GetFrom(), blk.GetTime(), i),
-			fmt.Sprintf("%s:I:TX:%x:TIME:%019d:%d", mongodb.ChainId, to, blk.GetTime(), i),
-			fmt.Sprintf("%s:I:TX:%x:BLOCK:%09d:%d", mongodb.ChainId, to, blk.GetNumber(), i),
-			fmt.Sprintf("%s:I:TX:%x:METHOD:%x:%019d:%d", mongodb.ChainId, to, method, blk.GetTime(), i),
-		}
-
-		if indexedTx.ErrorMsg != "" {
-			indexes = append(indexes, fmt.Sprintf("%s:I:TX:%x:ERROR:%019d:%d", mongodb.ChainId, tx.GetFrom(), blk.GetTime(), i))
-			indexes = append(indexes, fmt.Sprintf("%s:I:TX:%x:ERROR:%019d:%d", mongodb.ChainId, to, blk.GetTime(), i))
-		}
-
-		if indexedTx.IsContractCreation {
-			indexes = append(indexes, fmt.Sprintf("%s:I:TX:%x:CONTRACT:%019d:%d", mongodb.ChainId, tx.GetFrom(), blk.GetTime(), i))
-			indexes = append(indexes, fmt.Sprintf("%s:I:TX:%x:CONTRACT:%019d:%d", mongodb.ChainId, to, blk.GetTime(), i))
-		}
-
-		txIdentifier := fmt.Sprintf("%s:TX:%x", mongodb.ChainId, tx.GetHash())
-
-		for _, idx := range indexes {
-			mut := &entity.Indexes{
-				Type:  "index",
-				Key:   idx,
-				Value: txIdentifier,
-			}
-			doc, err := utils.ToDoc(mut)
-			if err != nil {
-				return nil, nil, err
-			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
-			bulkData.Keys = append(bulkData.Keys, idx)
+		bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("transactionindex", tx.GetHash()), doc))
+
+		err = mongodb.appendAddressIndexes(bulkData, addressIndexesFor(entity.AddressIndex{
+			Kind:             ADDRESS_INDEX_KIND_TX,
+			Method:           method,
+			BlockNumber:      blk.GetNumber(),
+			TxIndex:          uint64(i),
+			Time:             indexedTx.Time,
+			Hash:             tx.GetHash(),
+			Value:            tx.GetValue(),
+			Failed:           indexedTx.ErrorMsg != "",
+			ContractCreation: indexedTx.IsContractCreation,
+		}, tx.GetFrom(), to)...)
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -713,39 +678,29 @@ func (mongodb *Mongo) TransformItx(blk *types.Eth1Block, cache *freecache.Cache)
 				To:              idx.GetTo(),
 				Value:           idx.GetValue(),
 			}
-			mongodb.markBalanceUpdate(indexedItx.To, []byte{0x0}, bulkMetadataUpdates, cache)
-			mongodb.markBalanceUpdate(indexedItx.From, []byte{0x0}, bulkMetadataUpdates, cache)
+			mongodb.markBalanceUpdate(indexedItx.To, []byte{0x0}, &bulkMetadataUpdates, cache)
+			mongodb.markBalanceUpdate(indexedItx.From, []byte{0x0}, &bulkMetadataUpdates, cache)
 
 			doc, err := utils.ToDoc(indexedItx)
 			if err != nil {
 				return nil, nil, err
 			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
-
-			indexes := []string{
-				fmt.Sprintf("%s:I:ITX:%x:TO:%x:%019d:%d:%d", mongodb.ChainId, idx.GetFrom(), idx.GetTo(), blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ITX:%x:FROM:%x:%019d:%d:%d", mongodb.ChainId, idx.GetTo(), idx.GetFrom(), blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ITX:%x:TIME:%019d:%d:%d", mongodb.ChainId, idx.GetFrom(), blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ITX:%x:TIME:%019d:%d:%d", mongodb.ChainId, idx.GetTo(), blk.GetTime(), i, j),
-			}
+			// geth style traces all share the same path, the position of the trace identifies it
+			bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("internaltransactionindex", tx.GetHash(), j), doc))
 
-			itxIdentifier := fmt.Sprintf("%s:ITX:%x:%d", mongodb.ChainId, tx.GetHash(), j)
-			for _, idx := range indexes {
-				mut := &entity.Indexes{
-					Type:  "index",
-					Key:   idx,
-					Value: itxIdentifier,
-				}
-				doc, err := utils.ToDoc(mut)
-				if err != nil {
-					return nil, nil, err
-				}
-				insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-				bulkData.Model = append(bulkData.Model, insertBlock)
-				bulkData.Keys = append(bulkData.Keys, idx)
+			err = mongodb.appendAddressIndexes(bulkData, addressIndexesFor(entity.AddressIndex{
+				Kind:        ADDRESS_INDEX_KIND_ITX,
+				BlockNumber: blk.GetNumber(),
+				TxIndex:     uint64(i),
+				Position:    uint64(j),
+				Time:        indexedItx.Time,
+				Hash:        tx.GetHash(),
+				Value:       idx.GetValue(),
+				CallType:    idx.GetType(),
+			}, idx.GetFrom(), idx.GetTo())...)
+			if err != nil {
+				return nil, nil, err
 			}
-
 		}
 	}
 
@@ -812,44 +767,27 @@ func (mongodb *Mongo) TransformERC20(blk *types.Eth1Block, cache *freecache.Cach
 				To:           transfer.To.Bytes(),
 				Value:        value,
 			}
-			mongodb.markBalanceUpdate(indexedLog.From, indexedLog.TokenAddress, bulkMetadataUpdates, cache)
-			mongodb.markBalanceUpdate(indexedLog.To, indexedLog.TokenAddress, bulkMetadataUpdates, cache)
+			mongodb.markBalanceUpdate(indexedLog.From, indexedLog.TokenAddress, &bulkMetadataUpdates, cache)
+			mongodb.markBalanceUpdate(indexedLog.To, indexedLog.TokenAddress, &bulkMetadataUpdates, cache)
+			mongodb.markTokenUpdate(indexedLog.TokenAddress, TOKEN_STANDARD_ERC20, blk.GetNumber(), &bulkMetadataUpdates, cache)
 			doc, err := utils.ToDoc(indexedLog)
 			if err != nil {
 				return nil, nil, err
 			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
-
-			indexes := []string{
-				fmt.Sprintf("%s:I:ERC20:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC20:%x:ALL:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC20:%x:TO:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.To, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:FROM:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:TOKEN_SENT:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC20:%x:TOKEN_RECEIVED:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.TokenAddress, blk.GetTime(), i, j),
-			}
+			bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("erc20index", tx.GetHash(), j), doc))
 
-			erc20Identifier := fmt.Sprintf("%s:ERC20:%x:%d", mongodb.ChainId, tx.GetHash(), j)
-
-			for _, idx := range indexes {
-				mut := &entity.Indexes{
-					Type:  "index",
-					Key:   idx,
-					Value: erc20Identifier,
-				}
-				doc, err := utils.ToDoc(mut)
-				if err != nil {
-					return nil, nil, err
-				}
-				insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-				bulkData.Model = append(bulkData.Model, insertBlock)
-				bulkData.Keys = append(bulkData.Keys, idx)
+			err = mongodb.appendAddressIndexes(bulkData, addressIndexesFor(entity.AddressIndex{
+				Kind:        ADDRESS_INDEX_KIND_ERC20,
+				Token:       indexedLog.TokenAddress,
+				BlockNumber: blk.GetNumber(),
+				TxIndex:     uint64(i),
+				Position:    uint64(j),
+				Time:        indexedLog.Time,
+				Hash:        tx.GetHash(),
+				Value:       indexedLog.Value,
+			}, indexedLog.From, indexedLog.To)...)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 	}
@@ -917,42 +855,25 @@ func (mongodb *Mongo) TransformERC721(blk *types.Eth1Block, cache *freecache.Cac
 				To:           transfer.To.Bytes(),
 				TokenId:      tokenId.Bytes(),
 			}
+			mongodb.markTokenUpdate(indexedLog.TokenAddress, TOKEN_STANDARD_ERC721, blk.GetNumber(), &bulkMetadataUpdates, cache)
 			doc, err := utils.ToDoc(indexedLog)
 			if err != nil {
 				return nil, nil, err
 			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
+			bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("erc721index", tx.GetHash(), j), doc))
 
-			indexes := []string{
-				fmt.Sprintf("%s:I:ERC721:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC721:%x:ALL:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC721:%x:TO:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.To, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:FROM:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:TOKEN_SENT:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC721:%x:TOKEN_RECEIVED:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.TokenAddress, blk.GetTime(), i, j),
-			}
-
-			erc721Identifier := fmt.Sprintf("%s:ERC721:%x:%d", mongodb.ChainId, tx.GetHash(), j)
-
-			for _, idx := range indexes {
-				mut := &entity.Indexes{
-					Type:  "index",
-					Key:   idx,
-					Value: erc721Identifier,
-				}
-				doc, err := utils.ToDoc(mut)
-				if err != nil {
-					return nil, nil, err
-				}
-				insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-				bulkData.Model = append(bulkData.Model, insertBlock)
-				bulkData.Keys = append(bulkData.Keys, idx)
+			err = mongodb.appendAddressIndexes(bulkData, addressIndexesFor(entity.AddressIndex{
+				Kind:        ADDRESS_INDEX_KIND_ERC721,
+				Token:       indexedLog.TokenAddress,
+				BlockNumber: blk.GetNumber(),
+				TxIndex:     uint64(i),
+				Position:    uint64(j),
+				Time:        indexedLog.Time,
+				Hash:        tx.GetHash(),
+				TokenId:     indexedLog.TokenId,
+			}, indexedLog.From, indexedLog.To)...)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 	}
@@ -1046,43 +967,27 @@ func (mongodb *Mongo) TransformERC1155(blk *types.Eth1Block, cache *freecache.Ca
 				indexedLog.Value = transferSingle.Value.Bytes()
 				indexedLog.TokenAddress = log.GetAddress()
 			}
+			mongodb.markTokenUpdate(indexedLog.TokenAddress, TOKEN_STANDARD_ERC1155, blk.GetNumber(), &bulkMetadataUpdates, cache)
 
 			doc, err := utils.ToDoc(indexedLog)
 			if err != nil {
 				return nil, nil, err
 			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
-
-			indexes := []string{
-				fmt.Sprintf("%s:I:ERC1155:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC1155:%x:ALL:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:%x:TIME:%019d:%d:%d", mongodb.ChainId, indexedLog.TokenAddress, indexedLog.To, blk.GetTime(), i, j),
-
-				fmt.Sprintf("%s:I:ERC1155:%x:TO:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.To, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:FROM:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.From, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:TOKEN_SENT:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.From, indexedLog.TokenAddress, blk.GetTime(), i, j),
-				fmt.Sprintf("%s:I:ERC1155:%x:TOKEN_RECEIVED:%x:%019d:%d:%d", mongodb.ChainId, indexedLog.To, indexedLog.TokenAddress, blk.GetTime(), i, j),
-			}
+			bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("erc1155index", tx.GetHash(), j), doc))
 
-			erc1155Identifier := fmt.Sprintf("%s:ERC1155:%x:%d", mongodb.ChainId, tx.GetHash(), j)
-
-			for _, idx := range indexes {
-				mut := &entity.Indexes{
-					Type:  "index",
-					Key:   idx,
-					Value: erc1155Identifier,
-				}
-				doc, err := utils.ToDoc(mut)
-				if err != nil {
-					return nil, nil, err
-				}
-				insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-				bulkData.Model = append(bulkData.Model, insertBlock)
-				bulkData.Keys = append(bulkData.Keys, idx)
+			err = mongodb.appendAddressIndexes(bulkData, addressIndexesFor(entity.AddressIndex{
+				Kind:        ADDRESS_INDEX_KIND_ERC1155,
+				Token:       indexedLog.TokenAddress,
+				BlockNumber: blk.GetNumber(),
+				TxIndex:     uint64(i),
+				Position:    uint64(j),
+				Time:        indexedLog.Time,
+				Hash:        tx.GetHash(),
+				TokenId:     indexedLog.TokenId,
+				Value:       indexedLog.Value,
+			}, indexedLog.From, indexedLog.To)...)
+			if err != nil {
+				return nil, nil, err
 			}
 		}
 	}
@@ -1121,7 +1026,7 @@ func (mongodb *Mongo) TransformERC1155(blk *types.Eth1Block, cache *freecache.Ca
 // 			Reward:      r.Bytes(),
 // 		}
 
-// 		mongodb.markBalanceUpdate(uncle.Coinbase, []byte{0x0}, bulkMetadataUpdates, cache)
+// 		mongodb.markBalanceUpdate(uncle.Coinbase, []byte{0x0}, &bulkMetadataUpdates, cache)
 
 // 		doc, err := utils.ToDoc(uncleIndexed)
 // 		if err != nil {
@@ -1164,6 +1069,8 @@ func (mongodb *Mongo) TransformWithdrawals(block *types.Eth1Block, cache *freeca
 
 	for _, withdrawal := range block.Withdrawals {
 		withdrawalIndexed := entity.WithdrawalIndex{
+			ChainId:        mongodb.ChainId,
+			Type:           "withdrawalindex",
 			BlockNumber:    block.Number,
 			Index:          withdrawal.Index,
 			ValidatorIndex: withdrawal.ValidatorIndex,
@@ -1172,94 +1079,75 @@ func (mongodb *Mongo) TransformWithdrawals(block *types.Eth1Block, cache *freeca
 			Time:           primitive.Timestamp{T: uint32(block.Time.AsTime().Unix()), I: 0},
 		}
 
-		mongodb.markBalanceUpdate(withdrawal.Address, []byte{0x0}, bulkMetadataUpdates, cache)
+		mongodb.markBalanceUpdate(withdrawal.Address, []byte{0x0}, &bulkMetadataUpdates, cache)
 
 		doc, err := utils.ToDoc(withdrawalIndexed)
 		if err != nil {
 			return nil, nil, err
 		}
-		insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-		bulkData.Model = append(bulkData.Model, insertBlock)
-
-		indexes := []string{
-			// Index withdrawal by address
-			fmt.Sprintf("%s:I:W:%x:TIME:%019d:%d", mongodb.ChainId, withdrawal.Address, block.Time, int(withdrawal.Index)),
-		}
-
-		withdrawalIndexIdentifier := fmt.Sprintf("%s:W:%09d:%d", mongodb.ChainId, block.GetNumber(), int(withdrawal.Index))
-		for _, idx := range indexes {
-			mut := &entity.Indexes{
-				Type:  "index",
-				Key:   idx,
-				Value: withdrawalIndexIdentifier,
-			}
-			doc, err := utils.ToDoc(mut)
-			if err != nil {
-				return nil, nil, err
-			}
-			insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-			bulkData.Model = append(bulkData.Model, insertBlock)
-			bulkData.Keys = append(bulkData.Keys, idx)
+		bulkData.Model = append(bulkData.Model, upsertModel(mongodb.documentID("withdrawalindex", withdrawal.Index), doc))
+
+		// Index withdrawal by address
+		err = mongodb.appendAddressIndexes(bulkData, &entity.AddressIndex{
+			Kind:        ADDRESS_INDEX_KIND_WITHDRAWAL,
+			Address:     withdrawal.Address,
+			Direction:   DIRECTION_IN,
+			BlockNumber: block.GetNumber(),
+			Position:    withdrawal.Index,
+			Time:        withdrawalIndexed.Time,
+			Value:       withdrawal.Amount,
+		})
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
 	return bulkData, bulkMetadataUpdates, nil
 }
 
-func (mongodb *Mongo) GetEth1TxForAddress(prefix string, limit int64) ([]*types.Eth1TransactionIndexed, string, error) {
+func (mongodb *Mongo) GetEth1TxForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1TransactionIndexed, string, error) {
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
 	defer cancel()
 
-	data := make([]*types.Eth1TransactionIndexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, _ := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err := idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_TX, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
+	if len(indexes) == 0 {
+		return []*types.Eth1TransactionIndexed{}, "", nil
 	}
 
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
+	txHashes := make([][]byte, 0, len(indexes))
+	for _, idx := range indexes {
+		txHashes = append(txHashes, idx.Hash)
 	}
 
 	var results []*entity.TransactionIndex
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "transactionindex"}, {Key: "hash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, _ := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
+	txFilter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "transactionindex"}, {Key: "hash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, txFilter)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving transactions: %w", err)
+	}
 	if err := cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
+		return nil, "", fmt.Errorf("error while parsing transaction data: %w", err)
 	}
 
+	txs := make(map[string]*entity.TransactionIndex, len(results))
 	for _, result := range results {
-		data = append(data, &types.Eth1TransactionIndexed{
-			Hash:               result.Hash,
-			BlockNumber:        result.BlockNumber,
-			Time:               timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			MethodId:           result.MethodId,
-			From:               result.From,
-			To:                 result.To,
-			Value:              result.Value,
-			TxFee:              result.TxFee,
-			GasPrice:           result.GasPrice,
-			IsContractCreation: result.IsContractCreation,
-			InvokesContract:    result.InvokesContract,
-			ErrorMsg:           result.ErrorMsg,
-		})
+		txs[string(result.Hash)] = result
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	data := make([]*types.Eth1TransactionIndexed, 0, len(indexes))
+	for _, idx := range indexes {
+		result, ok := txs[string(idx.Hash)]
+		if !ok {
+			logger.Errorf("transaction %x referenced by address index %v not found", idx.Hash, idx.Key)
+			continue
+		}
+		data = append(data, transactionIndexed(result))
+	}
+
+	return data, nextPageToken, nil
 }
 
 func (mongodb *Mongo) GetAddressesNamesArMetadata(names *map[string]string, inputMetadata *map[string]*types.ERC20Metadata) (map[string]string, map[string]*types.ERC20Metadata, error) {
@@ -1308,34 +1196,121 @@ func (mongodb *Mongo) GetIndexedEth1Transaction(txHash []byte) (*types.Eth1Trans
 	defer cancel()
 
 	var result *entity.TransactionIndex
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "transactionindex"}, {Key: "hash", Value: txHash}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "transactionindex"}, {Key: "hash", Value: txHash}}
 	err := mongodb.Db.Collection(DATA).FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
 
-	return &types.Eth1TransactionIndexed{
-		Hash:               result.Hash,
-		BlockNumber:        result.BlockNumber,
-		Time:               timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-		MethodId:           result.MethodId,
-		From:               result.From,
-		To:                 result.To,
-		Value:              result.Value,
-		TxFee:              result.TxFee,
-		GasPrice:           result.GasPrice,
-		IsContractCreation: result.IsContractCreation,
-		InvokesContract:    result.InvokesContract,
-		ErrorMsg:           result.ErrorMsg,
-	}, nil
+	return transactionIndexed(result), nil
+}
+
+// GetEth1TransactionWithBlock returns the full transaction with its receipt, logs and internal transactions from the
+// blocks collection, together with the block containing it and its position within the block
+func (mongodb *Mongo) GetEth1TransactionWithBlock(txHash []byte) (*types.Eth1Transaction, *types.Eth1Block, int, error) {
+	indexed, err := mongodb.GetIndexedEth1Transaction(txHash)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	block, err := mongodb.GetBlockFromBlocksTable(indexed.BlockNumber)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error retrieving block %v of tx 0x%x: %w", indexed.BlockNumber, txHash, err)
+	}
+
+	for i, tx := range block.Transactions {
+		if bytes.Equal(tx.Hash, txHash) {
+			return tx, block, i, nil
+		}
+	}
+
+	return nil, nil, 0, fmt.Errorf("tx 0x%x not found in block %v: %w", txHash, indexed.BlockNumber, mongo.ErrNoDocuments)
+}
+
+// GetTokenTransfersForTransaction returns the erc20, erc721 and erc1155 transfers emitted by a transaction
+func (mongodb *Mongo) GetTokenTransfersForTransaction(txHash []byte) ([]*types.Eth1ERC20Indexed, []*types.Eth1ERC721Indexed, []*types.ETh1ERC1155Indexed, error) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: bson.D{{Key: "$in", Value: bson.A{"erc20index", "erc721index", "erc1155index"}}}}, {Key: "parenthash", Value: txHash}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error retrieving token transfers of tx 0x%x: %w", txHash, err)
+	}
+
+	var results []bson.Raw
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, nil, nil, fmt.Errorf("error while parsing token transfers of tx 0x%x: %w", txHash, err)
+	}
+
+	return tokenTransfersFromDocuments(results)
+}
+
+// tokenTransfersFromDocuments decodes erc20, erc721 and erc1155 index documents, documents of other types are skipped
+func tokenTransfersFromDocuments(docs []bson.Raw) ([]*types.Eth1ERC20Indexed, []*types.Eth1ERC721Indexed, []*types.ETh1ERC1155Indexed, error) {
+	erc20 := []*types.Eth1ERC20Indexed{}
+	erc721 := []*types.Eth1ERC721Indexed{}
+	erc1155 := []*types.ETh1ERC1155Indexed{}
+
+	for _, raw := range docs {
+		switch raw.Lookup("type").StringValue() {
+		case "erc20index":
+			result := &entity.ERC20Index{}
+			if err := bson.Unmarshal(raw, result); err != nil {
+				return nil, nil, nil, err
+			}
+			erc20 = append(erc20, &types.Eth1ERC20Indexed{
+				ParentHash:   result.ParentHash,
+				BlockNumber:  result.BlockNumber,
+				TokenAddress: result.TokenAddress,
+				Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
+				From:         result.From,
+				To:           result.To,
+				Value:        result.Value,
+			})
+		case "erc721index":
+			result := &entity.ERC721Index{}
+			if err := bson.Unmarshal(raw, result); err != nil {
+				return nil, nil, nil, err
+			}
+			erc721 = append(erc721, &types.Eth1ERC721Indexed{
+				ParentHash:   result.ParentHash,
+				BlockNumber:  result.BlockNumber,
+				TokenAddress: result.TokenAddress,
+				Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
+				From:         result.From,
+				To:           result.To,
+				TokenId:      result.TokenId,
+			})
+		case "erc1155index":
+			result := &entity.ERC1155Index{}
+			if err := bson.Unmarshal(raw, result); err != nil {
+				return nil, nil, nil, err
+			}
+			erc1155 = append(erc1155, &types.ETh1ERC1155Indexed{
+				ParentHash:   result.ParentHash,
+				BlockNumber:  result.BlockNumber,
+				TokenAddress: result.TokenAddress,
+				Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
+				From:         result.From,
+				To:           result.To,
+				TokenId:      result.TokenId,
+				Value:        result.Value,
+				Operator:     result.Operator,
+			})
+		}
+	}
+
+	return erc20, erc721, erc1155, nil
 }
 
 func (mongodb *Mongo) GetAddressTransactionsTableData(address []byte, search string, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:TX:%x:%s:", mongodb.ChainId, address, FILTER_TIME)
+	filter, err := ParseAddressIndexFilter(search)
+	if err != nil {
+		return nil, err
 	}
 
-	transactions, lastKey, err := MongodbClient.GetEth1TxForAddress(pageToken, 25)
+	transactions, lastKey, err := MongodbClient.GetEth1TxForAddress(address, filter, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -1355,6 +1330,8 @@ func (mongodb *Mongo) GetAddressTransactionsTableData(address []byte, search str
 		return nil, err
 	}
 
+	// method names are looked up in the signature database once per selector, ambiguous names are marked with a ?
+	methodNames := make(map[string]string)
 	tableData := make([][]interface{}, len(transactions))
 	for i, t := range transactions {
 
@@ -1372,7 +1349,21 @@ func (mongodb *Mongo) GetAddressTransactionsTableData(address []byte, search str
 		if len(t.MethodId) > 0 {
 
 			if t.InvokesContract {
-				method = fmt.Sprintf("0x%x", t.MethodId)
+				name, ok := methodNames[string(t.MethodId)]
+				if !ok {
+					name = fmt.Sprintf("0x%x", t.MethodId)
+					signatures, err := mongodb.GetSignatures(t.MethodId)
+					if err != nil {
+						logger.Warnf("error retrieving signatures of selector 0x%x: %v", t.MethodId, err)
+					} else if n, ambiguous := utils.SignatureName(signatures); n != "" {
+						name = n
+						if ambiguous {
+							name += "?"
+						}
+					}
+					methodNames[string(t.MethodId)] = name
+				}
+				method = name
 			} else {
 				method = "Transfer*"
 			}
@@ -1402,130 +1393,67 @@ func (mongodb *Mongo) GetAddressTransactionsTableData(address []byte, search str
 	return data, nil
 }
 
-func (mongodb *Mongo) GetEth1BlocksForAddress(prefix string, limit int64) ([]*types.Eth1BlockIndexed, string, error) {
+func (mongodb *Mongo) GetEth1BlocksForAddress(address []byte, pageToken string, limit int64) ([]*types.Eth1BlockIndexed, string, error) {
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
 	defer cancel()
 
-	data := make([]*types.Eth1BlockIndexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_BLOCK, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
+	if len(indexes) == 0 {
+		return []*types.Eth1BlockIndexed{}, "", nil
 	}
 
-	chainId := ""
-	var blocknumbers []uint64
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		blocknumber, _ := strconv.ParseUint(keysplit[2], 10, 64)
-		blocknumbers = append(blocknumbers, blocknumber)
+	blocknumbers := make([]uint64, 0, len(indexes))
+	for _, idx := range indexes {
+		blocknumbers = append(blocknumbers, idx.BlockNumber)
 	}
 
 	var results []*entity.BlockIndex
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "$in", Value: blocknumbers}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "blockindex"}, {Key: "number", Value: bson.D{{Key: "$in", Value: blocknumbers}}}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving blocks: %w", err)
+	}
 	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
+		return nil, "", fmt.Errorf("error while parsing block data: %w", err)
 	}
 
+	blocks := make(map[uint64]*entity.BlockIndex, len(results))
 	for _, result := range results {
-		data = append(data, &types.Eth1BlockIndexed{
-			Hash:                     result.Hash,
-			ParentHash:               result.ParentHash,
-			UncleHash:                result.UncleHash,
-			Coinbase:                 result.Coinbase,
-			Difficulty:               result.Difficulty,
-			Number:                   result.Number,
-			GasLimit:                 result.GasLimit,
-			GasUsed:                  result.GasUsed,
-			Time:                     timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			BaseFee:                  result.BaseFee,
-			UncleCount:               result.UncleCount,
-			TransactionCount:         result.TransactionCount,
-			Mev:                      result.Mev,
-			LowestGasPrice:           result.LowestGasPrice,
-			HighestGasPrice:          result.HighestGasPrice,
-			TxReward:                 result.TxReward,
-			UncleReward:              result.UncleReward,
-			InternalTransactionCount: result.InternalTransactionCount,
-		})
-	}
-
-	return data, indexes[len(indexes)-1], nil
-}
-
-func (mongodb *Mongo) GetEth1ItxForAddress(prefix string, limit int64) ([]*types.Eth1InternalTransactionIndexed, string, error) {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	data := make([]*types.Eth1InternalTransactionIndexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
-	}
-
-	if len(keys) == 0 {
-		return data, "", nil
+		blocks[result.Number] = result
 	}
 
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
+	data := make([]*types.Eth1BlockIndexed, 0, len(indexes))
+	for _, idx := range indexes {
+		result, ok := blocks[idx.BlockNumber]
+		if !ok {
+			logger.Errorf("block %v referenced by address index %v not found", idx.BlockNumber, idx.Key)
+			continue
+		}
+		data = append(data, blockIndexed(result))
 	}
 
-	var results []*entity.InternalTransactionIndex
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "internaltransactionindex"}, {Key: "hash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
-	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
+	return data, nextPageToken, nil
+}
 
-	for _, result := range results {
-		data = append(data, &types.Eth1InternalTransactionIndexed{
-			ParentHash:  result.ParentHash,
-			BlockNumber: result.BlockNumber,
-			Type:        result.Type,
-			Time:        timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			From:        result.From,
-			To:          result.To,
-			Value:       result.Value,
-		})
+func (mongodb *Mongo) GetEth1ItxForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1InternalTransactionIndexed, string, error) {
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ITX, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	return internalTransactionsFromIndexes(indexes), nextPageToken, nil
 }
 
 func (mongodb *Mongo) GetAddressInternalTableData(address []byte, search string, pageToken string) (*types.DataTableResponse, error) {
-	// defaults to most recent
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:ITX:%x:%s:", mongodb.ChainId, address, FILTER_TIME)
+	filter, err := ParseAddressIndexFilter(search)
+	if err != nil {
+		return nil, err
 	}
 
-	transactions, lastKey, err := mongodb.GetEth1ItxForAddress(pageToken, 25)
+	transactions, lastKey, err := mongodb.GetEth1ItxForAddress(address, filter, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -1576,8 +1504,11 @@ func (mongodb *Mongo) GetInternalTransfersForTransaction(transaction []byte, fro
 	mux := sync.Mutex{}
 
 	var results []*entity.InternalTransactionIndex
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: "internaltransactionindex"}, {Key: "hash", Value: transaction}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "internaltransactionindex"}, {Key: "parenthash", Value: transaction}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
 	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
@@ -1640,14 +1571,16 @@ func (mongodb *Mongo) GetArbitraryTokenTransfersForTransaction(transaction []byt
 	mux := sync.Mutex{}
 
 	var results []*entity.ERC20Index
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "hash", Value: transaction}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "erc20index"}, {Key: "parenthash", Value: transaction}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
 	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
 	for _, result := range results {
-		mux.Lock()
 		transfers = append(transfers, &types.Eth1ERC20Indexed{
 			ParentHash:   result.ParentHash,
 			BlockNumber:  result.BlockNumber,
@@ -1727,66 +1660,22 @@ func (mongodb *Mongo) GetArbitraryTokenTransfersForTransaction(transaction []byt
 	return data, nil
 }
 
-func (mongodb *Mongo) GetEth1ERC20ForAddress(prefix string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	data := make([]*types.Eth1ERC20Indexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
-	}
-	if len(keys) == 0 {
-		return data, "", nil
-	}
-
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
-	}
-
-	var results []*entity.ERC20Index
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "erc20index"}, {Key: "parenthash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
-	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, result := range results {
-		data = append(data, &types.Eth1ERC20Indexed{
-			ParentHash:   result.ParentHash,
-			BlockNumber:  result.BlockNumber,
-			TokenAddress: result.TokenAddress,
-			Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			From:         result.From,
-			To:           result.To,
-			Value:        result.Value,
-		})
+func (mongodb *Mongo) GetEth1ERC20ForAddress(address []byte, filter *AddressIndexFilter, pageToken string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC20, Address: address, Filter: filter}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	return erc20TransfersFromIndexes(indexes), nextPageToken, nil
 }
 
 func (mongodb *Mongo) GetAddressErc20TableData(address []byte, search string, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:ERC20:%x:%s:", mongodb.ChainId, address, FILTER_TIME)
+	filter, err := ParseAddressIndexFilter(search)
+	if err != nil {
+		return nil, err
 	}
 
-	transactions, lastKey, err := mongodb.GetEth1ERC20ForAddress(pageToken, 25)
+	transactions, lastKey, err := mongodb.GetEth1ERC20ForAddress(address, filter, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -1842,67 +1731,23 @@ func (mongodb *Mongo) GetAddressErc20TableData(address []byte, search string, pa
 	return data, nil
 }
 
-func (mongodb *Mongo) GetEth1ERC721ForAddress(prefix string, limit int64) ([]*types.Eth1ERC721Indexed, string, error) {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	data := make([]*types.Eth1ERC721Indexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
-	}
-	if len(keys) == 0 {
-		return data, "", nil
-	}
-
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
-	}
-
-	var results []*entity.ERC721Index
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "erc721index"}, {Key: "parenthash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
-	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, result := range results {
-		data = append(data, &types.Eth1ERC721Indexed{
-			ParentHash:   result.ParentHash,
-			BlockNumber:  result.BlockNumber,
-			TokenAddress: result.TokenAddress,
-			Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			From:         result.From,
-			To:           result.To,
-			TokenId:      result.TokenId,
-		})
+func (mongodb *Mongo) GetEth1ERC721ForAddress(address []byte, pageToken string, limit int64) ([]*types.Eth1ERC721Indexed, string, error) {
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC721, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	return erc721TransfersFromIndexes(indexes), nextPageToken, nil
 }
 
 func (mongodb *Mongo) GetAddressErc721TableData(address string, search string, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:ERC721:%s:%s:", mongodb.ChainId, address, FILTER_TIME)
-		// pageToken = fmt.Sprintf("%s:I:ERC721:%s:%s:9999999999999999999:9999:99999", bigtable.chainId, address, FILTER_TIME)
+	address = addressKey(address)
+	addressBytes, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %v: %w", address, err)
 	}
 
-	transactions, lastKey, err := mongodb.GetEth1ERC721ForAddress(pageToken, 25)
+	transactions, lastKey, err := mongodb.GetEth1ERC721ForAddress(addressBytes, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -1935,68 +1780,23 @@ func (mongodb *Mongo) GetAddressErc721TableData(address string, search string, p
 	return data, nil
 }
 
-func (mongodb *Mongo) GetEth1ERC1155ForAddress(prefix string, limit int64) ([]*types.ETh1ERC1155Indexed, string, error) {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	data := make([]*types.ETh1ERC1155Indexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
-
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
-	}
-	if len(keys) == 0 {
-		return data, "", nil
-	}
-
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
-	}
-
-	var results []*entity.ERC1155Index
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "erc1155index"}, {Key: "parenthash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
-	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing erc1155 data: %v", err)
-	}
-
-	for _, result := range results {
-		data = append(data, &types.ETh1ERC1155Indexed{
-			ParentHash:   result.ParentHash,
-			BlockNumber:  result.BlockNumber,
-			TokenAddress: result.TokenAddress,
-			Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			From:         result.From,
-			To:           result.To,
-			Value:        result.Value,
-			TokenId:      result.TokenId,
-			Operator:     result.Operator,
-		})
+func (mongodb *Mongo) GetEth1ERC1155ForAddress(address []byte, pageToken string, limit int64) ([]*types.ETh1ERC1155Indexed, string, error) {
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC1155, Address: address}, pageToken, limit)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	return erc1155TransfersFromIndexes(indexes), nextPageToken, nil
 }
 
 func (mongodb *Mongo) GetAddressErc1155TableData(address string, search string, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:ERC1155:%s:%s:", mongodb.ChainId, address, FILTER_TIME)
+	address = addressKey(address)
+	addressBytes, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %v: %w", address, err)
 	}
 
-	transactions, lastKey, err := mongodb.GetEth1ERC1155ForAddress(pageToken, 25)
+	transactions, lastKey, err := mongodb.GetEth1ERC1155ForAddress(addressBytes, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -2079,32 +1879,40 @@ func (mongodb *Mongo) GetMetadataForAddress(address []byte) (*types.Eth1AddressM
 	mux := sync.Mutex{}
 
 	var results []*entity.AccountMetadataFamily
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
 	cursor, err := mongodb.Db.Collection(METADATA).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving account metadata: %w", err)
+	}
 	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing account metadata: %v", err)
+		return nil, fmt.Errorf("error while parsing account metadata: %w", err)
 	}
 
 	for _, result := range results {
 		resl := result
-		if bytes.Equal(address, ZERO_ADDRESS) && result.Token != "0x00" { //do not return token balances for the zero address
+		if bytes.Equal(address, ZERO_ADDRESS) && result.Token != "00" { //do not return token balances for the zero address
 			continue
 		}
 
 		g.Go(func() error {
 			token := common.FromHex(resl.Token)
 
-			if resl.Balance.Cmp(big.NewInt(0)) == 0 && len(token) > 1 {
+			if new(big.Int).SetBytes(resl.Balance).Sign() == 0 && len(token) > 1 {
 				return nil
 			}
 
 			balance := &types.Eth1AddressBalance{
-				Address: address,
-				Token:   token,
-				Balance: common.FromHex(fmt.Sprintf("%x", resl.Balance)),
+				Address:     address,
+				Token:       token,
+				Balance:     resl.Balance,
+				BlockNumber: resl.BlockNumber,
 			}
 
 			metadata, err := mongodb.GetERC20MetadataForAddress(token)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				// the balance of a token is known before its metadata has been retrieved
+				metadata, err = &types.ERC20Metadata{}, nil
+			}
 			if err != nil {
 				return err
 			}
@@ -2129,34 +1937,39 @@ func (mongodb *Mongo) GetMetadataForAddress(address []byte) (*types.Eth1AddressM
 		return nil, err
 	}
 
-	sort.Slice(ret.Balances, func(i, j int) bool {
+	sortBalancesByValue(ret.Balances)
+
+	return ret, nil
+}
+
+// sortBalancesByValue sorts token balances by their value in the price currency, highest first
+func sortBalancesByValue(balances []*types.Eth1AddressBalance) {
+	sort.Slice(balances, func(i, j int) bool {
 		priceI := decimal.New(0, 0)
 		priceJ := decimal.New(0, 0)
 		var err error
 
-		if string(ret.Balances[i].Metadata.Price) != "" {
-			priceI, err = decimal.NewFromString(string(ret.Balances[i].Metadata.Price))
+		if string(balances[i].Metadata.Price) != "" {
+			priceI, err = decimal.NewFromString(string(balances[i].Metadata.Price))
 			if err != nil {
-				logger.WithError(err).Errorf("error parsing string price value, price: %s", ret.Balances[i].Metadata.Price)
+				logger.WithError(err).Errorf("error parsing string price value, price: %s", balances[i].Metadata.Price)
 			}
 		}
 
-		if string(ret.Balances[j].Metadata.Price) != "" {
-			priceJ, err = decimal.NewFromString(string(ret.Balances[j].Metadata.Price))
+		if string(balances[j].Metadata.Price) != "" {
+			priceJ, err = decimal.NewFromString(string(balances[j].Metadata.Price))
 			if err != nil {
-				logger.WithError(err).Errorf("error parsing string price value, price: %s", ret.Balances[j].Metadata.Price)
+				logger.WithError(err).Errorf("error parsing string price value, price: %s", balances[j].Metadata.Price)
 			}
 		}
 
-		mulI := decimal.NewFromFloat(float64(10)).Pow(decimal.NewFromBigInt(new(big.Int).SetBytes(ret.Balances[i].Metadata.Decimals), 0))
-		mulJ := decimal.NewFromFloat(float64(10)).Pow(decimal.NewFromBigInt(new(big.Int).SetBytes(ret.Balances[j].Metadata.Decimals), 0))
-		mkI := priceI.Mul(decimal.NewFromBigInt(new(big.Int).SetBytes(ret.Balances[i].Balance), 0).Div(mulI))
-		mkJ := priceJ.Mul(decimal.NewFromBigInt(new(big.Int).SetBytes(ret.Balances[j].Balance), 0).Div(mulJ))
+		mulI := decimal.NewFromFloat(float64(10)).Pow(decimal.NewFromBigInt(new(big.Int).SetBytes(balances[i].Metadata.Decimals), 0))
+		mulJ := decimal.NewFromFloat(float64(10)).Pow(decimal.NewFromBigInt(new(big.Int).SetBytes(balances[j].Metadata.Decimals), 0))
+		mkI := priceI.Mul(decimal.NewFromBigInt(new(big.Int).SetBytes(balances[i].Balance), 0).Div(mulI))
+		mkJ := priceJ.Mul(decimal.NewFromBigInt(new(big.Int).SetBytes(balances[j].Balance), 0).Div(mulJ))
 
 		return mkI.Cmp(mkJ) >= 0
 	})
-
-	return ret, nil
 }
 
 func (mongodb *Mongo) GetBalanceForAddress(address []byte, token []byte) (*types.Eth1AddressBalance, error) {
@@ -2167,19 +1980,24 @@ func (mongodb *Mongo) GetBalanceForAddress(address []byte, token []byte) (*types
 	tokenHex := hex.EncodeToString(token)
 
 	var result *entity.AccountMetadataFamily
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}, {Key: "token", Value: tokenHex}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}, {Key: "token", Value: tokenHex}}
 	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
 
 	ret := &types.Eth1AddressBalance{
-		Address: address,
-		Token:   token,
-		Balance: common.FromHex(fmt.Sprintf("%x", result.Balance)),
+		Address:     address,
+		Token:       token,
+		Balance:     result.Balance,
+		BlockNumber: result.BlockNumber,
 	}
 
 	metadata, err := mongodb.GetERC20MetadataForAddress(token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the balance of a token is known before its metadata has been retrieved
+		metadata, err = &types.ERC20Metadata{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -2193,31 +2011,39 @@ func (mongodb *Mongo) GetAddressNames(addresses map[string]string) error {
 	defer cancel()
 
 	keys := make([]string, 0, len(addresses))
+	// addresses are stored as lower case hex, keep track of the format the caller used
+	requested := make(map[string]string, len(addresses))
 
 	for address := range addresses {
-		keys = append(keys, address)
+		key := addressKey(address)
+		keys = append(keys, key)
+		requested[key] = address
 	}
 
 	var results []*entity.AccountMetadataFamily
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: bson.D{{Key: "$in", Value: keys}}}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: bson.D{{Key: "$in", Value: keys}}}}
 	cursor, err := mongodb.Db.Collection(METADATA).Find(ctx, filter)
 	if err = cursor.All(ctx, &results); err != nil {
 		return err
 	}
 
 	for _, result := range results {
-		addresses[result.Address] = result.Name
+		if address, ok := requested[result.Address]; ok {
+			addresses[address] = result.Name
+		}
 	}
 
 	return nil
 }
 
 func (mongodb *Mongo) GetAddressBlocksMinedTableData(address string, search string, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		pageToken = fmt.Sprintf("%s:I:B:%s:", mongodb.ChainId, address)
+	address = addressKey(address)
+	addressBytes, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %v: %w", address, err)
 	}
 
-	blocks, lastKey, err := MongodbClient.GetEth1BlocksForAddress(pageToken, 25)
+	blocks, lastKey, err := MongodbClient.GetEth1BlocksForAddress(addressBytes, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -2265,7 +2091,7 @@ func (mongodb *Mongo) GetERC20MetadataForAddress(address []byte) (*types.ERC20Me
 	}
 
 	var result *entity.ERC20MetadataFamily
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ERC20_METADATA_FAMILY}, {Key: "address", Value: fmt.Sprintf("%x", address)}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ERC20_METADATA_FAMILY}, {Key: "address", Value: fmt.Sprintf("%x", address)}}
 	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		return nil, err
@@ -2284,34 +2110,42 @@ func (mongodb *Mongo) GetERC20MetadataForAddress(address []byte) (*types.ERC20Me
 	}, nil
 }
 
+// SaveERC20Metadata merges the set fields of the metadata into the stored metadata of the token
 func (mongodb *Mongo) SaveERC20Metadata(address []byte, metadata *types.ERC20Metadata) error {
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
 	defer cancel()
 
-	ercMetadataInput := &entity.ERC20MetadataFamily{}
+	fields := bson.D{}
 	if len(metadata.Decimals) > 0 {
-		ercMetadataInput.Decimals = metadata.Decimals
+		fields = append(fields, bson.E{Key: "decimals", Value: metadata.Decimals})
 	}
 	if len(metadata.TotalSupply) > 0 {
-		ercMetadataInput.TotalSupply = metadata.TotalSupply
+		fields = append(fields, bson.E{Key: "totalsupply", Value: metadata.TotalSupply})
+	}
+	if len(metadata.Symbol) > 0 {
+		fields = append(fields, bson.E{Key: "symbol", Value: metadata.Symbol})
 	}
 	if len(metadata.Name) > 0 {
-		ercMetadataInput.Name = metadata.Name
+		fields = append(fields, bson.E{Key: "name", Value: metadata.Name})
+	}
+	if len(metadata.Description) > 0 {
+		fields = append(fields, bson.E{Key: "description", Value: metadata.Description})
+	}
+	if len(metadata.OfficialSite) > 0 {
+		fields = append(fields, bson.E{Key: "officialsite", Value: metadata.OfficialSite})
 	}
 	if len(metadata.Price) > 0 {
-		ercMetadataInput.Price = metadata.Price
+		fields = append(fields, bson.E{Key: "price", Value: metadata.Price})
 	}
 	if len(metadata.Logo) > 0 && len(metadata.LogoFormat) > 0 {
-		ercMetadataInput.Logo = metadata.Logo
-		ercMetadataInput.Logoformat = metadata.LogoFormat
+		fields = append(fields, bson.E{Key: "logo", Value: metadata.Logo}, bson.E{Key: "logoformat", Value: metadata.LogoFormat})
 	}
-
-	doc, err := utils.ToDoc(ercMetadataInput)
-	if err != nil {
-		return err
+	if len(fields) == 0 {
+		return nil
 	}
 
-	_, err = mongodb.Db.Collection(METADATA).InsertOne(ctx, doc)
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ERC20_METADATA_FAMILY}, {Key: "address", Value: fmt.Sprintf("%x", address)}}
+	_, err := mongodb.Db.Collection(METADATA).UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: fields}}, options.Update().SetUpsert(true))
 	if err != nil {
 		return err
 	}
@@ -2334,7 +2168,7 @@ func (mongodb *Mongo) GetAddressName(address []byte) (string, error) {
 	addressHex := hex.EncodeToString(address)
 
 	var result *entity.AccountMetadataFamily
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
 	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		return "", err
@@ -2356,7 +2190,7 @@ func (mongodb *Mongo) SaveAddressName(address []byte, name string) error {
 	defer cancel()
 
 	addressHex := hex.EncodeToString(address)
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: addressHex}}
 	update := bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: name}}}}
 	opts := options.Update().SetUpsert(true)
 
@@ -2376,17 +2210,41 @@ func (mongodb *Mongo) GetContractMetadata(address []byte) (*types.ContractMetada
 	cacheKey := mongodb.ChainId + ":CONTRACT:" + rowKey
 	if cached, err := cache.TieredCache.GetWithLocalTimeout(cacheKey, time.Hour*24, new(types.ContractMetadata)); err == nil {
 		ret := cached.(*types.ContractMetadata)
-		val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
-		ret.ABI = &val
-		return ret, err
+		// empty entries are cached misses of earlier versions, misses are cached under missKey now
+		if len(ret.ABIJson) > 0 {
+			val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
+			ret.ABI = &val
+			return ret, err
+		}
+	}
+	missKey := mongodb.contractMissCacheKey(rowKey)
+	if _, err := cache.TieredCache.GetStringWithLocalTimeout(missKey, time.Hour*24); err == nil {
+		// cached lookup of an address without a known contract
+		return nil, nil
 	}
 
 	addressHex := hex.EncodeToString(address)
 	var result *entity.ContractMetadataFamily
-	ret := &types.ContractMetadata{}
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "address", Value: addressHex}, {Key: "type", Value: CONTRACT_METADATA_FAMILY}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "address", Value: addressHex}, {Key: "type", Value: CONTRACT_METADATA_FAMILY}}
 	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(&result)
 	if err != nil || result == nil {
+		// contracts deployed with the bytecode of an already verified contract share its metadata
+		verified, err := matchVerifiedCode(mongodb, address)
+		if err != nil {
+			logger.Warnf("error matching the code of 0x%x against verified code: %v", address, err)
+		}
+		if verified != nil {
+			val, err := abi.JSON(bytes.NewReader(verified.ABIJson))
+			if err != nil {
+				return nil, fmt.Errorf("error decoding abi of verified code for address 0x%x: %w", address, err)
+			}
+			verified.ABI = &val
+			if err := mongodb.SaveContractMetadata(address, verified); err != nil {
+				logger.Errorf("error saving matched contract metadata of 0x%x: %v", address, err)
+			}
+			return verified, nil
+		}
+
 		ret, err := utils.TryFetchContractMetadata(address)
 
 		if err != nil {
@@ -2394,7 +2252,7 @@ func (mongodb *Mongo) GetContractMetadata(address []byte) (*types.ContractMetada
 				logrus.Warnf("Hit rate limit when fetching contract metadata for address %x", address)
 			} else {
 				utils.LogError(err, "Fetching contract metadata", 0, fmt.Sprintf("%x", address))
-				err := cache.TieredCache.Set(cacheKey, &types.ContractMetadata{}, time.Hour*24)
+				err := cache.TieredCache.SetString(missKey, "", time.Hour*24)
 				if err != nil {
 					utils.LogError(err, "Caching contract metadata", 0, fmt.Sprintf("%x", address))
 				}
@@ -2404,7 +2262,7 @@ func (mongodb *Mongo) GetContractMetadata(address []byte) (*types.ContractMetada
 
 		// No contract found, caching empty
 		if ret == nil {
-			err = cache.TieredCache.Set(cacheKey, &types.ContractMetadata{}, time.Hour*24)
+			err = cache.TieredCache.SetString(missKey, "", time.Hour*24)
 			if err != nil {
 				utils.LogError(err, "Caching contract metadata", 0, fmt.Sprintf("%x", address))
 			}
@@ -2423,12 +2281,10 @@ func (mongodb *Mongo) GetContractMetadata(address []byte) (*types.ContractMetada
 		return ret, nil
 	}
 
-	ret.Name = result.Name
-	ret.ABIJson = result.Abi
+	ret := contractMetadataFromEntity(result)
 	val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
-
 	if err != nil {
-		logrus.Fatalf("error decoding abi for address 0x%x: %v", address, err)
+		return nil, fmt.Errorf("error decoding abi for address 0x%x: %w", address, err)
 	}
 	ret.ABI = &val
 
@@ -2436,13 +2292,70 @@ func (mongodb *Mongo) GetContractMetadata(address []byte) (*types.ContractMetada
 	return ret, err
 }
 
+// contractMissCacheKey returns the cache key of a failed contract metadata lookup. The key includes a generation that
+// SaveVerifiedCode increments, saving verified code drops all cached misses as any address may hold the verified code.
+func (mongodb *Mongo) contractMissCacheKey(rowKey string) string {
+	generation, err := cache.TieredCache.GetUint64WithLocalTimeout(mongodb.ChainId+":CONTRACT_MISS_GENERATION", time.Minute)
+	if err != nil {
+		generation = 0
+	}
+	return fmt.Sprintf("%s:CONTRACT_MISS:%d:%s", mongodb.ChainId, generation, rowKey)
+}
+
+// dropContractMissCache increments the generation of the cache keys of failed contract metadata lookups
+func (mongodb *Mongo) dropContractMissCache() error {
+	key := mongodb.ChainId + ":CONTRACT_MISS_GENERATION"
+	generation, err := cache.TieredCache.GetUint64WithLocalTimeout(key, time.Minute)
+	if err != nil {
+		generation = 0
+	}
+	// misses are cached for a day, the generation outlives them
+	return cache.TieredCache.SetUint64(key, generation+1, time.Hour*48)
+}
+
+// GetStoredContractMetadata returns the stored metadata of a contract, nil if there is none. Unlike GetContractMetadata
+// it never retrieves the metadata of an unknown contract from the node or etherscan.
+func (mongodb *Mongo) GetStoredContractMetadata(address []byte) (*types.ContractMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	rowKey := fmt.Sprintf("%s:%x", mongodb.ChainId, address)
+	cacheKey := mongodb.ChainId + ":CONTRACT:" + rowKey
+	if cached, err := cache.TieredCache.GetWithLocalTimeout(cacheKey, time.Hour*24, new(types.ContractMetadata)); err == nil {
+		ret := cached.(*types.ContractMetadata)
+		if len(ret.ABIJson) > 0 {
+			val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
+			ret.ABI = &val
+			return ret, err
+		}
+	}
+
+	result := &entity.ContractMetadataFamily{}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "address", Value: hex.EncodeToString(address)}, {Key: "type", Value: CONTRACT_METADATA_FAMILY}}
+	err := mongodb.Db.Collection(METADATA).FindOne(ctx, filter).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving contract metadata of 0x%x: %w", address, err)
+	}
+
+	ret := contractMetadataFromEntity(result)
+	val, err := abi.JSON(bytes.NewReader(ret.ABIJson))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding abi for address 0x%x: %w", address, err)
+	}
+	ret.ABI = &val
+	return ret, nil
+}
+
 func (mongodb *Mongo) SaveContractMetadata(address []byte, metadata *types.ContractMetadata) error {
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
 	defer cancel()
 
-	inputContractMetadata := &entity.ContractMetadataFamily{}
-	inputContractMetadata.Name = metadata.Name
-	inputContractMetadata.Abi = metadata.ABIJson
+	inputContractMetadata := contractMetadataToEntity(metadata)
+	inputContractMetadata.ChainId = mongodb.ChainId
+	inputContractMetadata.Type = CONTRACT_METADATA_FAMILY
 	inputContractMetadata.Address = hex.EncodeToString(address)
 
 	doc, err := utils.ToDoc(inputContractMetadata)
@@ -2450,11 +2363,26 @@ func (mongodb *Mongo) SaveContractMetadata(address []byte, metadata *types.Contr
 		return err
 	}
 
-	_, err = mongodb.Db.Collection(METADATA).InsertOne(ctx, doc)
+	// verifying a contract replaces the metadata previously fetched for it
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: CONTRACT_METADATA_FAMILY}, {Key: "address", Value: inputContractMetadata.Address}}
+	_, err = mongodb.Db.Collection(METADATA).ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
 	if err != nil {
 		return err
 	}
 
+	if metadata.ABI != nil {
+		err = mongodb.SaveSignatures(utils.AbiSignatures(metadata.ABI))
+		if err != nil {
+			return err
+		}
+	}
+
+	rowKey := fmt.Sprintf("%s:%x", mongodb.ChainId, address)
+	err = cache.TieredCache.Set(mongodb.ChainId+":CONTRACT:"+rowKey, metadata, time.Hour*24)
+	if err != nil {
+		utils.LogError(err, "Caching contract metadata", 0, fmt.Sprintf("%x", address))
+	}
+
 	return nil
 }
 
@@ -2467,21 +2395,9 @@ func (mongodb *Mongo) SaveBalances(balances []*types.Eth1AddressBalance, deleteK
 	var bulkMetadataUpdates []mongo.WriteModel
 
 	for _, balance := range balances {
-		balanceMetadata := &entity.AccountMetadataFamily{}
-		balanceMetadata.ChainId = mongodb.ChainId
-		balanceMetadata.Type = ACCOUNT_METADATA_FAMILY
-		balanceMetadata.Name = balance.Metadata.Name
-		balanceMetadata.Balance = *new(big.Int).SetBytes(balance.Balance)
-		balanceMetadata.Token = hex.EncodeToString(balance.Token)
-		balanceMetadata.Address = hex.EncodeToString(balance.Address)
-
-		doc, err := utils.ToDoc(balanceMetadata)
-		if err != nil {
-			return err
-		}
-
-		filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "address", Value: hex.EncodeToString(balance.Address)}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}}
-		insertBlock := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(doc).SetUpsert(true)
+		filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ACCOUNT_METADATA_FAMILY}, {Key: "address", Value: hex.EncodeToString(balance.Address)}, {Key: "token", Value: hex.EncodeToString(balance.Token)}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "balance", Value: balance.Balance}, {Key: "blocknumber", Value: balance.BlockNumber}}}}
+		insertBlock := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
 		bulkData = append(bulkData, insertBlock)
 	}
 
@@ -2515,20 +2431,9 @@ func (mongodb *Mongo) SaveERC20TokenPrices(prices []*types.ERC20TokenPrice) erro
 	var bulkData []mongo.WriteModel
 
 	for _, price := range prices {
-		erc20Metadata := &entity.ERC20MetadataFamily{}
-		erc20Metadata.ChainId = mongodb.ChainId
-		erc20Metadata.Type = ERC20_METADATA_FAMILY
-		erc20Metadata.Address = hex.EncodeToString(price.Token)
-		erc20Metadata.Price = price.Price
-		erc20Metadata.TotalSupply = price.TotalSupply
-
-		doc, err := utils.ToDoc(erc20Metadata)
-		if err != nil {
-			return err
-		}
-
-		filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: ERC20_METADATA_FAMILY}, {Key: "address", Value: hex.EncodeToString(price.Token)}}
-		insertBlock := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(doc).SetUpsert(true)
+		filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: ERC20_METADATA_FAMILY}, {Key: "address", Value: hex.EncodeToString(price.Token)}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "price", Value: price.Price}, {Key: "totalsupply", Value: price.TotalSupply}}}}
+		insertBlock := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
 		bulkData = append(bulkData, insertBlock)
 	}
 
@@ -2556,7 +2461,7 @@ func (mongodb *Mongo) SaveBlockKeys(blockNumber uint64, blockHash []byte, keys s
 		return err
 	}
 
-	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "blocknumber", Value: blockNumber}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "metadata_block_keys"}, {Key: "blocknumber", Value: blockNumber}, {Key: "blockhash", Value: inputKeys.BlockHash}}
 	_, err = mongodb.Db.Collection(METADATA_UPDATES).UpdateOne(ctx, filter, bson.M{"$set": doc}, options.Update().SetUpsert(true))
 
 	return err
@@ -2567,117 +2472,141 @@ func (mongodb *Mongo) GetBlockKeys(blockNumber uint64, blockHash []byte) ([]stri
 	defer cancel()
 
 	var result *entity.BlockMetadataUpdates
-	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "blocknumber", Value: blockNumber}, {Key: "blockhash", Value: hex.EncodeToString(blockHash)}}
-	err := mongodb.Db.Collection(METADATA_UPDATES).FindOne(ctx, filter).Decode(result)
-
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "metadata_block_keys"}, {Key: "blocknumber", Value: blockNumber}, {Key: "blockhash", Value: hex.EncodeToString(blockHash)}}
+	err := mongodb.Db.Collection(METADATA_UPDATES).FindOne(ctx, filter).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
 
-	if result == nil {
-		return nil, fmt.Errorf("keys for block %v not found", blockNumber)
+	if result.Keys == "" {
+		return []string{}, nil
 	}
-
-	return strings.Split(string(result.Keys), ","), nil
+	return strings.Split(result.Keys, ","), nil
 }
 
-func (mongodb *Mongo) DeleteBlock(blockNumber uint64, blockHash []byte) error {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	// First receive all keys that were written by this block (entities & indices)
-	keys, err := mongodb.GetBlockKeys(blockNumber, blockHash)
+func (mongodb *Mongo) GetEth1TxForToken(token []byte, address []byte, pageToken string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
+	indexes, nextPageToken, err := mongodb.GetAddressIndexes(&AddressIndexQuery{Kind: ADDRESS_INDEX_KIND_ERC20, Address: address, Token: token}, pageToken, limit)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	// Delete all of those keys from index documents
-	idxFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$in", Value: keys}}}}
-	_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, idxFilter)
-	if err != nil {
-		return err
-	}
+	return erc20TransfersFromIndexes(indexes), nextPageToken, nil
+}
 
-	dataFilter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "$or", Value: bson.D{{Key: "blocknumber", Value: blockNumber}, {Key: "number", Value: blockNumber}}}}
-	_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, dataFilter)
-	if err != nil {
-		return err
+func blockIndexed(result *entity.BlockIndex) *types.Eth1BlockIndexed {
+	return &types.Eth1BlockIndexed{
+		Hash:                     result.Hash,
+		ParentHash:               result.ParentHash,
+		UncleHash:                result.UncleHash,
+		Coinbase:                 result.Coinbase,
+		Difficulty:               result.Difficulty,
+		Number:                   result.Number,
+		GasLimit:                 result.GasLimit,
+		GasUsed:                  result.GasUsed,
+		Time:                     timestamppb.New(time.Unix(int64(result.Time.T), 0)),
+		BaseFee:                  result.BaseFee,
+		UncleCount:               result.UncleCount,
+		TransactionCount:         result.TransactionCount,
+		Mev:                      result.Mev,
+		LowestGasPrice:           result.LowestGasPrice,
+		HighestGasPrice:          result.HighestGasPrice,
+		TxReward:                 result.TxReward,
+		UncleReward:              result.UncleReward,
+		InternalTransactionCount: result.InternalTransactionCount,
 	}
+}
 
-	blockFilter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "hash", Value: blockHash}}
-	_, err = mongodb.Db.Collection(BLOCKS).DeleteOne(ctx, blockFilter)
-	if err != nil {
-		return err
+func transactionIndexed(result *entity.TransactionIndex) *types.Eth1TransactionIndexed {
+	return &types.Eth1TransactionIndexed{
+		Hash:               result.Hash,
+		BlockNumber:        result.BlockNumber,
+		Time:               timestamppb.New(time.Unix(int64(result.Time.T), 0)),
+		MethodId:           result.MethodId,
+		From:               result.From,
+		To:                 result.To,
+		Value:              result.Value,
+		TxFee:              result.TxFee,
+		GasPrice:           result.GasPrice,
+		IsContractCreation: result.IsContractCreation,
+		InvokesContract:    result.InvokesContract,
+		ErrorMsg:           result.ErrorMsg,
 	}
-
-	return nil
 }
 
-func (mongodb *Mongo) GetEth1TxForToken(prefix string, limit int64) ([]*types.Eth1ERC20Indexed, string, error) {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*30))
-	defer cancel()
-
-	data := make([]*types.Eth1ERC20Indexed, 0, limit)
-	keys := make([]string, 0, limit)
-	indexes := make([]string, 0, limit)
-
-	indexFilter := bson.D{{Key: "type", Value: "index"}, {Key: "key", Value: bson.D{{Key: "$regex", Value: prefix}}}}
-	idxcursor, err := mongodb.Db.Collection(DATA).Find(ctx, indexFilter, options.Find().SetLimit(limit))
-	var indexResults []*entity.Indexes
-	if err = idxcursor.All(ctx, &indexResults); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
+func erc20TransfersFromIndexes(indexes []*entity.AddressIndex) []*types.Eth1ERC20Indexed {
+	data := make([]*types.Eth1ERC20Indexed, 0, len(indexes))
+	for _, idx := range indexes {
+		from, to := transferParticipants(idx)
+		data = append(data, &types.Eth1ERC20Indexed{
+			ParentHash:   idx.Hash,
+			BlockNumber:  idx.BlockNumber,
+			TokenAddress: idx.Token,
+			Time:         addressIndexTime(idx),
+			From:         from,
+			To:           to,
+			Value:        idx.Value,
+		})
 	}
+	return data
+}
 
-	for _, cur := range indexResults {
-		keys = append(keys, cur.Value)
-		indexes = append(indexes, cur.Key)
+func internalTransactionsFromIndexes(indexes []*entity.AddressIndex) []*types.Eth1InternalTransactionIndexed {
+	data := make([]*types.Eth1InternalTransactionIndexed, 0, len(indexes))
+	for _, idx := range indexes {
+		from, to := transferParticipants(idx)
+		data = append(data, &types.Eth1InternalTransactionIndexed{
+			ParentHash:  idx.Hash,
+			BlockNumber: idx.BlockNumber,
+			Type:        idx.CallType,
+			Time:        addressIndexTime(idx),
+			From:        from,
+			To:          to,
+			Value:       idx.Value,
+		})
 	}
 
-	if len(keys) == 0 {
-		return data, "", nil
-	}
+	return data
+}
 
-	chainId := ""
-	var txHashes [][]byte
-	for _, key := range keys {
-		keysplit := strings.Split(key, ":")
-		chainId = keysplit[0]
-		txHash := keysplit[2]
-		txHashes = append(txHashes, []byte(txHash))
+func erc721TransfersFromIndexes(indexes []*entity.AddressIndex) []*types.Eth1ERC721Indexed {
+	data := make([]*types.Eth1ERC721Indexed, 0, len(indexes))
+	for _, idx := range indexes {
+		from, to := transferParticipants(idx)
+		data = append(data, &types.Eth1ERC721Indexed{
+			ParentHash:   idx.Hash,
+			BlockNumber:  idx.BlockNumber,
+			TokenAddress: idx.Token,
+			Time:         addressIndexTime(idx),
+			From:         from,
+			To:           to,
+			TokenId:      idx.TokenId,
+		})
 	}
 
-	var results []*entity.ERC20Index
-	filter := bson.D{{Key: "chainId", Value: chainId}, {Key: "type", Value: "erc20index"}, {Key: "parenthash", Value: bson.D{{Key: "$in", Value: txHashes}}}}
-	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetLimit(limit))
-	if err = cursor.All(ctx, &results); err != nil {
-		logger.Errorf("error while parsing transaction data: %v", err)
-	}
+	return data
+}
 
-	for _, result := range results {
-		data = append(data, &types.Eth1ERC20Indexed{
-			ParentHash:   result.ParentHash,
-			BlockNumber:  result.BlockNumber,
-			TokenAddress: result.TokenAddress,
-			Time:         timestamppb.New(time.Unix(int64(result.Time.T), 0)),
-			From:         result.From,
-			To:           result.To,
-			Value:        result.Value,
+func erc1155TransfersFromIndexes(indexes []*entity.AddressIndex) []*types.ETh1ERC1155Indexed {
+	data := make([]*types.ETh1ERC1155Indexed, 0, len(indexes))
+	for _, idx := range indexes {
+		from, to := transferParticipants(idx)
+		data = append(data, &types.ETh1ERC1155Indexed{
+			ParentHash:   idx.Hash,
+			BlockNumber:  idx.BlockNumber,
+			TokenAddress: idx.Token,
+			Time:         addressIndexTime(idx),
+			From:         from,
+			To:           to,
+			Value:        idx.Value,
+			TokenId:      idx.TokenId,
 		})
 	}
 
-	return data, indexes[len(indexes)-1], nil
+	return data
 }
 
 func (mongodb *Mongo) GetTokenTransactionsTableData(token []byte, address []byte, pageToken string) (*types.DataTableResponse, error) {
-	if pageToken == "" {
-		if len(address) == 0 {
-			pageToken = fmt.Sprintf("%s:I:ERC20:%x:ALL:%s", mongodb.ChainId, token, FILTER_TIME)
-		} else {
-			pageToken = fmt.Sprintf("%s:I:ERC20:%x:%x:%s", mongodb.ChainId, token, address, FILTER_TIME)
-		}
-	}
-
-	transactions, lastKey, err := MongodbClient.GetEth1TxForToken(pageToken, 25)
+	transactions, lastKey, err := MongodbClient.GetEth1TxForToken(token, address, pageToken, 25)
 	if err != nil {
 		return nil, err
 	}
@@ -2737,7 +2666,7 @@ func (mongodb *Mongo) SearchForAddress(addressPrefix []byte, limit int) ([]*type
 
 	addressHex := hex.EncodeToString(addressPrefix)
 	var results []bson.M
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "address", Value: addressHex}}
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "address", Value: addressHex}}
 	cursor, err := mongodb.Db.Collection(METADATA).Find(ctx, filter)
 	if err = cursor.All(ctx, &results); err != nil {
 		logger.Errorf("error while parsing transaction data: %v", err)
@@ -2767,24 +2696,29 @@ func (mongodb *Mongo) SearchForAddress(addressPrefix []byte, limit int) ([]*type
 	return data, nil
 }
 
-func (mongodb *Mongo) markBalanceUpdate(address []byte, token []byte, mutations interface{}, cache *freecache.Cache) {
+func (mongodb *Mongo) markBalanceUpdate(address []byte, token []byte, mutations *[]mongo.WriteModel, cache *freecache.Cache) {
 	balanceUpdateKey := fmt.Sprintf("%s:B:%x", mongodb.ChainId, address)                        // format is B: for balance update as chainid:prefix:address (token id will be encoded as column name)
 	balanceUpdateCacheKey := []byte(fmt.Sprintf("%s:B:%x:%x", mongodb.ChainId, address, token)) // format is B: for balance update as chainid:prefix:address (token id will be encoded as column name)
 	if _, err := cache.Get(balanceUpdateCacheKey); err != nil {
-		update := entity.BalanceUpdates{
-			Key:     balanceUpdateKey,
-			Type:    "metadata_balances_updates",
-			ChainId: mongodb.ChainId,
-			Token:   token,
-			Address: address,
-		}
-		doc, _ := utils.ToDoc(update)
-		insertBlock := mongo.NewInsertOneModel().SetDocument(doc)
-		mutations = append([]interface{}{mutations}, insertBlock)
+		marker := mongodb.balanceUpdateMarker(balanceUpdateKey, address, token)
+		*mutations = append(*mutations, upsertModel(marker.id, marker.doc))
 		cache.Set(balanceUpdateCacheKey, []byte{0x1}, int((time.Hour * 48).Seconds()))
 	}
 }
 
+// balanceUpdateMarker returns the balance update marker of an address and token, marking a pair twice leaves a single
+// marker
+func (mongodb *Mongo) balanceUpdateMarker(key string, address []byte, token []byte) metadataMarker {
+	doc, _ := utils.ToDoc(entity.BalanceUpdates{
+		Key:     key,
+		Type:    "metadata_balances_updates",
+		ChainId: mongodb.ChainId,
+		Token:   token,
+		Address: address,
+	})
+	return metadataMarker{id: mongodb.documentID("metadata_balances_updates", address, token), doc: doc}
+}
+
 func (mongodb *Mongo) SaveGasNowHistory(slow, standard, rapid, fast *big.Int) error {
 	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
 	defer done()
@@ -2797,7 +2731,7 @@ func (mongodb *Mongo) SaveGasNowHistory(slow, standard, rapid, fast *big.Int) er
 	inputData.Slow = slow.Bytes()
 	inputData.Standard = standard.Bytes()
 	inputData.Rapid = rapid.Bytes()
-	inputData.Fast = rapid.Bytes()
+	inputData.Fast = fast.Bytes()
 
 	doc, err := utils.ToDoc(inputData)
 	if err != nil {
@@ -2817,8 +2751,11 @@ func (mongodb *Mongo) GetGasNowHistory(ts, pastTs time.Time) ([]types.GasNowHist
 
 	history := make([]types.GasNowHistory, 0)
 	var results []*entity.Series
-	filter := bson.D{{Key: "chainId", Value: mongodb.ChainId}, {Key: "type", Value: SERIES_FAMILY}, {Key: "time", Value: bson.D{{Key: "$gte", Value: uint64(ts.Unix())}, {Key: "$lte", Value: uint64(pastTs.Unix())}}}}
-	cursor, err := mongodb.Db.Collection(METADATA).Find(ctx, filter)
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: SERIES_FAMILY}, {Key: "time", Value: bson.D{{Key: "$gte", Value: primitive.Timestamp{T: uint32(pastTs.Unix())}}, {Key: "$lte", Value: primitive.Timestamp{T: uint32(ts.Unix())}}}}}
+	cursor, err := mongodb.Db.Collection(METADATA).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "time", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error getting gas now history, err: %w", err)
+	}
 	if err = cursor.All(ctx, &results); err != nil {
 		return nil, fmt.Errorf("error getting gas now history, err: %w", err)
 	}
@@ -2836,3 +2773,16 @@ func (mongodb *Mongo) GetGasNowHistory(ts, pastTs time.Time) ([]types.GasNowHist
 
 	return history, nil
 }
+
+// addressKey normalizes a Z or 0x prefixed address string or the raw bytes of an address into the lower case hex form
+// used for storage
+func addressKey(address string) string {
+	if len(address) == common.AddressLength {
+		return hex.EncodeToString([]byte(address))
+	}
+	a, err := utils.ParseAddress(address)
+	if err != nil {
+		return strings.ToLower(address)
+	}
+	return a.Hex()
+}
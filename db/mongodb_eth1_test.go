@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMongoBlockQueries(t *testing.T) {
+	mongodb := testMongo(t)
+
+	blocks := []*types.Eth1Block{}
+	for n := uint64(1); n <= 3; n++ {
+		blocks = append(blocks, &types.Eth1Block{
+			Number:     n,
+			Hash:       common.BigToHash(new(big.Int).SetUint64(n)).Bytes(),
+			ParentHash: common.BigToHash(new(big.Int).SetUint64(n - 1)).Bytes(),
+			Time:       timestamppb.New(time.Unix(1700000000+int64(n)*12, 0)),
+		})
+	}
+	if err := mongodb.SaveBlocks(blocks); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		bulkData, _, err := mongodb.TransformBlock(block, freecache.NewCache(1024*1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mongodb.WriteBlockMutations(block, bulkData, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if gap, _, _, err := mongodb.CheckForGapsInBlocksTable(10); err != nil || gap {
+		t.Errorf("gap check of the blocks table: got gap %v and error %v", gap, err)
+	}
+	if err := mongodb.CheckForGapsInDataTable(10); err != nil {
+		t.Errorf("gap check of the data table: %v", err)
+	}
+	if last, err := mongodb.GetLastBlockInBlocksTable(); err != nil || last != 3 {
+		t.Errorf("last block in the blocks table: got %v and error %v, want 3", last, err)
+	}
+	if last, err := mongodb.GetLastBlockInDataTable(); err != nil || last != 3 {
+		t.Errorf("last block in the data table: got %v and error %v, want 3", last, err)
+	}
+	recent, err := mongodb.GetMostRecentBlockFromDataTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent.Number != 3 {
+		t.Errorf("most recent block in the data table: got %v, want 3", recent.Number)
+	}
+
+	full, err := mongodb.GetFullBlockDescending(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) != 2 || full[0].Number != 2 || full[1].Number != 1 {
+		t.Errorf("full blocks descending from 2: got %v", full)
+	}
+	indexed, err := mongodb.GetBlocksDescending(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != 2 || indexed[0].Number != 3 || indexed[1].Number != 2 {
+		t.Errorf("indexed blocks descending from 3: got %v", indexed)
+	}
+}
+
+func TestMongoAddressNames(t *testing.T) {
+	mongodb := testMongo(t)
+
+	address := common.HexToAddress("0x01")
+	if err := mongodb.SaveAddressName(address.Bytes(), "deposit contract"); err != nil {
+		t.Fatal(err)
+	}
+	name, err := mongodb.GetAddressName(address.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "deposit contract" {
+		t.Errorf("got name %q, want %q", name, "deposit contract")
+	}
+
+	names := map[string]string{string(address.Bytes()): ""}
+	if err := mongodb.GetAddressNames(names); err != nil {
+		t.Fatal(err)
+	}
+	if names[string(address.Bytes())] != "deposit contract" {
+		t.Errorf("got names %v, want %q", names, "deposit contract")
+	}
+}
+
+func TestMongoMetadataForUndiscoveredToken(t *testing.T) {
+	mongodb := testMongo(t)
+
+	address := common.HexToAddress("0x01")
+	token := common.HexToAddress("0x03")
+	docs := []interface{}{
+		&entity.AccountMetadataFamily{ChainId: mongodb.ChainId, Type: ACCOUNT_METADATA_FAMILY, Address: common.Bytes2Hex(address.Bytes()), Token: "00", Balance: big.NewInt(5).Bytes()},
+		&entity.AccountMetadataFamily{ChainId: mongodb.ChainId, Type: ACCOUNT_METADATA_FAMILY, Address: common.Bytes2Hex(address.Bytes()), Token: common.Bytes2Hex(token.Bytes()), Balance: big.NewInt(7).Bytes()},
+	}
+	if _, err := mongodb.Db.Collection(METADATA).InsertMany(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := mongodb.GetMetadataForAddress(address.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).SetBytes(metadata.EthBalance.Balance).Int64() != 5 {
+		t.Errorf("got native balance %x, want 5", metadata.EthBalance.Balance)
+	}
+	if len(metadata.Balances) != 1 || common.BytesToAddress(metadata.Balances[0].Token) != token || new(big.Int).SetBytes(metadata.Balances[0].Balance).Int64() != 7 {
+		t.Fatalf("got token balances %+v, want 7 of %v", metadata.Balances, token)
+	}
+	if metadata.Balances[0].Metadata == nil {
+		t.Errorf("got no metadata for the undiscovered token")
+	}
+}
@@ -0,0 +1,426 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CONTRACT_EVENT is the type of the documents of the contract event indexes
+const CONTRACT_EVENT = "contract_event"
+
+var contractEventNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// ContractEventCollection returns the collection of the contract event index with the passed name
+func ContractEventCollection(name string) string {
+	return "events_" + name
+}
+
+// NewContractEventIndexes parses the contract event configs and reads their abi files
+func NewContractEventIndexes(configs []types.ContractEventsConfig) ([]*types.ContractEventIndex, error) {
+	indexes := make([]*types.ContractEventIndex, 0, len(configs))
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if !contractEventNamePattern.MatchString(config.Name) {
+			return nil, fmt.Errorf("invalid contract events name %q, only lower case letters, digits and underscores are allowed", config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate contract events name %q", config.Name)
+		}
+		names[config.Name] = true
+
+		contractAbi, err := readEventsABI(config.ABIFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading abi of contract events %v: %w", config.Name, err)
+		}
+
+		index := &types.ContractEventIndex{
+			Name:       config.Name,
+			Collection: ContractEventCollection(config.Name),
+			Version:    config.Version,
+			Events:     make(map[common.Hash]abi.Event),
+		}
+		if index.Version == 0 {
+			index.Version = 1
+		}
+		for _, address := range config.Addresses {
+			parsed, err := utils.ParseAddress(address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address of contract events %v: %w", config.Name, err)
+			}
+			if index.Addresses == nil {
+				index.Addresses = make(map[common.Address]bool)
+			}
+			index.Addresses[common.Address(parsed)] = true
+		}
+
+		eventNames := config.Events
+		if len(eventNames) == 0 {
+			for name := range contractAbi.Events {
+				eventNames = append(eventNames, name)
+			}
+		}
+		for _, name := range eventNames {
+			event, ok := contractAbi.Events[name]
+			if !ok {
+				return nil, fmt.Errorf("event %v of contract events %v is not part of the abi", name, config.Name)
+			}
+			if event.Anonymous {
+				return nil, fmt.Errorf("event %v of contract events %v is anonymous and can't be identified by its topic", name, config.Name)
+			}
+			index.Events[event.ID] = event
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// readEventsABI reads a json abi, or the abi field of a compiler artifact
+func readEventsABI(path string) (*abi.ABI, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		artifact := struct {
+			ABI json.RawMessage `json:"abi"`
+		}{}
+		if err := json.Unmarshal(raw, &artifact); err != nil {
+			return nil, err
+		}
+		raw = artifact.ABI
+	}
+	contractAbi, err := abi.JSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &contractAbi, nil
+}
+
+// TransformContractEvents returns a transform decoding the logs of the contract event index into its collection. The
+// documents are journaled like those of the data transforms and are removed with their block in a reorg.
+func (mongodb *Mongo) TransformContractEvents(index *types.ContractEventIndex) func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	return func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+		bulkData := &types.BulkMutations{}
+		models := []mongo.WriteModel{}
+
+		// the position of the log in the block, counted like the log index of the logs transform
+		logIndex := uint64(0)
+		for i, tx := range blk.GetTransactions() {
+			for j, log := range tx.GetLogs() {
+				if log.GetRemoved() {
+					continue
+				}
+				logIndex++
+				if len(log.GetTopics()) == 0 {
+					continue
+				}
+				if index.Addresses != nil && !index.Addresses[common.BytesToAddress(log.GetAddress())] {
+					continue
+				}
+				event, ok := index.Events[common.BytesToHash(log.GetTopics()[0])]
+				if !ok {
+					continue
+				}
+
+				inputs, values, err := utils.UnpackLog(&event, log.GetTopics(), log.GetData())
+				if err != nil {
+					// e.g. a contract emitting an event with the same signature but other indexed parameters
+					logger.Warnf("error decoding %v event %v of tx 0x%x: %v", index.Name, event.Name, tx.GetHash(), err)
+					continue
+				}
+				// unnamed inputs are named arg0 to argN by their position, like in the filters and the formatted events
+				params := make(bson.D, 0, len(inputs))
+				for _, input := range inputs {
+					params = append(params, bson.E{Key: input.Name, Value: eventParamToBSON(input.Type, values[input.Name])})
+				}
+
+				doc, err := utils.ToDoc(&entity.ContractEvent{
+					ChainId:     mongodb.ChainId,
+					Type:        CONTRACT_EVENT,
+					Contract:    log.GetAddress(),
+					Event:       event.Name,
+					BlockNumber: blk.GetNumber(),
+					Time:        primitive.Timestamp{T: uint32(blk.GetTime().AsTime().Unix()), I: 0},
+					TxHash:      tx.GetHash(),
+					TxIndex:     uint64(i),
+					LogIndex:    logIndex - 1,
+					Params:      params,
+				})
+				if err != nil {
+					return nil, nil, err
+				}
+				models = append(models, upsertModel(mongodb.documentID(CONTRACT_EVENT, index.Name, tx.GetHash(), j), doc))
+			}
+		}
+
+		if len(models) > 0 {
+			bulkData.Collections = map[string][]mongo.WriteModel{index.Collection: models}
+		}
+		return bulkData, nil, nil
+	}
+}
+
+// eventParamToBSON converts an unpacked event parameter into its stored representation. Integers of all sizes are
+// stored as decimal strings, as 256 bit values exceed the 34 digits of decimal128. Addresses, byte values and the topic
+// hashes of indexed parameters of dynamic types are stored as binary.
+func eventParamToBSON(typ abi.Type, value interface{}) interface{} {
+	if hash, ok := value.(common.Hash); ok {
+		return hash.Bytes()
+	}
+
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		n, ok := value.(*big.Int)
+		if !ok {
+			rv := reflect.ValueOf(value)
+			if typ.T == abi.IntTy {
+				n = big.NewInt(rv.Int())
+			} else {
+				n = new(big.Int).SetUint64(rv.Uint())
+			}
+		}
+		return n.String()
+	case abi.AddressTy:
+		return value.(common.Address).Bytes()
+	case abi.FixedBytesTy, abi.FunctionTy:
+		rv := reflect.ValueOf(value)
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b
+	case abi.SliceTy, abi.ArrayTy:
+		rv := reflect.ValueOf(value)
+		values := make(bson.A, rv.Len())
+		for i := range values {
+			values[i] = eventParamToBSON(*typ.Elem, rv.Index(i).Interface())
+		}
+		return values
+	case abi.TupleTy:
+		rv := reflect.ValueOf(value)
+		values := make(bson.D, len(typ.TupleElems))
+		for i, elem := range typ.TupleElems {
+			values[i] = bson.E{Key: typ.TupleRawNames[i], Value: eventParamToBSON(*elem, rv.Field(i).Interface())}
+		}
+		return values
+	}
+	return value
+}
+
+// formatEventParam converts a stored event parameter into its json representation, in the format of the decoded
+// events of the transaction endpoint
+func formatEventParam(typ abi.Type, value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.Binary:
+		if typ.T == abi.AddressTy {
+			return utils.FormatAddressString(v.Data)
+		}
+		return hexutil.Encode(v.Data)
+	case bson.A:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			if typ.Elem != nil {
+				values[i] = formatEventParam(*typ.Elem, elem)
+			} else {
+				values[i] = formatEventParam(abi.Type{}, elem)
+			}
+		}
+		return values
+	case bson.D:
+		values := make(map[string]interface{}, len(v))
+		for i, elem := range v {
+			elemType := abi.Type{}
+			if i < len(typ.TupleElems) {
+				elemType = *typ.TupleElems[i]
+			}
+			values[elem.Key] = formatEventParam(elemType, elem.Value)
+		}
+		return values
+	}
+	return value
+}
+
+// ContractEventParamFilter converts the value of a top level parameter of an event of the index into its stored
+// representation. The values of indexed strings and bytes are hashed like their topics.
+func ContractEventParamFilter(index *types.ContractEventIndex, eventName, param, value string) (interface{}, error) {
+	for _, event := range index.Events {
+		if event.Name != eventName {
+			continue
+		}
+		for i, input := range event.Inputs {
+			if input.Name != param && !(input.Name == "" && param == fmt.Sprintf("arg%d", i)) {
+				continue
+			}
+			return parseEventParam(input, value)
+		}
+		return nil, fmt.Errorf("event %v has no parameter %v", eventName, param)
+	}
+	return nil, fmt.Errorf("unknown event %v", eventName)
+}
+
+func parseEventParam(input abi.Argument, value string) (interface{}, error) {
+	switch input.Type.T {
+	case abi.AddressTy:
+		address, err := utils.ParseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		return address.Bytes(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", value)
+		}
+		return eventParamToBSON(input.Type, n), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		if input.Indexed {
+			return crypto.Keccak256([]byte(value)), nil
+		}
+		return value, nil
+	case abi.BytesTy, abi.FixedBytesTy, abi.HashTy:
+		b, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if input.Indexed && input.Type.T == abi.BytesTy {
+			return crypto.Keccak256(b), nil
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("filtering by parameters of type %v is not supported", input.Type)
+}
+
+// contractEventFromEntity converts a stored contract event, the parameters are formatted with the abi of the event
+func contractEventFromEntity(index *types.ContractEventIndex, event *entity.ContractEvent) *types.Eth1ContractEvent {
+	inputs := map[string]abi.Type{}
+	for _, candidate := range index.Events {
+		if candidate.Name != event.Event {
+			continue
+		}
+		for i, input := range candidate.Inputs {
+			name := input.Name
+			if name == "" {
+				name = fmt.Sprintf("arg%d", i)
+			}
+			inputs[name] = input.Type
+		}
+	}
+
+	ret := &types.Eth1ContractEvent{
+		Contract:    event.Contract,
+		Event:       event.Event,
+		BlockNumber: event.BlockNumber,
+		Time:        time.Unix(int64(event.Time.T), 0),
+		TxHash:      event.TxHash,
+		TxIndex:     event.TxIndex,
+		LogIndex:    event.LogIndex,
+		Params:      make(map[string]interface{}, len(event.Params)),
+	}
+	for _, param := range event.Params {
+		ret.Params[param.Key] = formatEventParam(inputs[param.Key], param.Value)
+	}
+	return ret
+}
+
+// ApplyContractEventSchema creates the collections of the contract event indexes and their indexes, they depend on the
+// config and are not part of the schema migrations
+func (mongodb *Mongo) ApplyContractEventSchema(indexes []*types.ContractEventIndex) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+	defer cancel()
+
+	position := bson.D{{Key: "blocknumber", Value: 1}, {Key: "txindex", Value: 1}, {Key: "logindex", Value: 1}}
+	schemas := make([]collectionSchema, 0, len(indexes))
+	for _, index := range indexes {
+		schemas = append(schemas, collectionSchema{
+			Name: index.Collection,
+			Indexes: []mongo.IndexModel{
+				{Keys: append(bson.D{{Key: "chainid", Value: 1}}, position...), Options: options.Index().SetName("chainid_position")},
+				{Keys: append(bson.D{{Key: "chainid", Value: 1}, {Key: "event", Value: 1}}, position...), Options: options.Index().SetName("chainid_event_position")},
+				{Keys: append(bson.D{{Key: "chainid", Value: 1}, {Key: "contract", Value: 1}, {Key: "event", Value: 1}}, position...), Options: options.Index().SetName("chainid_contract_event_position")},
+			},
+		})
+	}
+	return mongodb.applyCollectionSchemas(ctx, schemas)
+}
+
+// GetContractEvents returns up to limit events of the contract event index matching the filter, in chain order, and the
+// token of the next page. The page token is the position of the last returned event.
+func (mongodb *Mongo) GetContractEvents(index *types.ContractEventIndex, filter *types.ContractEventFilter, pageToken string, limit int64) ([]*types.Eth1ContractEvent, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	query := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	if len(filter.Contract) > 0 {
+		query = append(query, bson.E{Key: "contract", Value: filter.Contract})
+	}
+	if filter.Event != "" {
+		query = append(query, bson.E{Key: "event", Value: filter.Event})
+	}
+	blocks := bson.D{{Key: "$gte", Value: filter.FromBlock}}
+	if filter.ToBlock > 0 {
+		blocks = append(blocks, bson.E{Key: "$lte", Value: filter.ToBlock})
+	}
+	query = append(query, bson.E{Key: "blocknumber", Value: blocks})
+	for name, value := range filter.Params {
+		query = append(query, bson.E{Key: "params." + name, Value: value})
+	}
+	if pageToken != "" {
+		c, err := decodeLogCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "blocknumber", Value: bson.D{{Key: "$gt", Value: c.BlockNumber}}}},
+			bson.D{{Key: "blocknumber", Value: c.BlockNumber}, {Key: "txindex", Value: bson.D{{Key: "$gt", Value: c.TxIndex}}}},
+			bson.D{{Key: "blocknumber", Value: c.BlockNumber}, {Key: "txindex", Value: c.TxIndex}, {Key: "logindex", Value: bson.D{{Key: "$gt", Value: c.LogIndex}}}},
+		}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "blocknumber", Value: 1}, {Key: "txindex", Value: 1}, {Key: "logindex", Value: 1}}).SetLimit(limit)
+	cursor, err := mongodb.Db.Collection(index.Collection).Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving %v events: %w", index.Name, err)
+	}
+
+	var events []*entity.ContractEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, "", fmt.Errorf("error decoding %v events: %w", index.Name, err)
+	}
+
+	ret := make([]*types.Eth1ContractEvent, 0, len(events))
+	for _, event := range events {
+		ret = append(ret, contractEventFromEntity(index, event))
+	}
+	return ret, contractEventsPageToken(events, limit), nil
+}
+
+// contractEventsPageToken returns the token of the page following the events, an empty token if the page isn't full
+func contractEventsPageToken(events []*entity.ContractEvent, limit int64) string {
+	if int64(len(events)) < limit || limit == 0 {
+		return ""
+	}
+	last := events[len(events)-1]
+	return (&logCursor{BlockNumber: last.BlockNumber, TxIndex: last.TxIndex, LogIndex: last.LogIndex}).encode()
+}
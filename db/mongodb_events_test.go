@@ -0,0 +1,189 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const stakingABI = `[
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"user","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"lockDays","type":"uint32"},{"indexed":false,"name":"note","type":"string"}],"name":"Staked","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"user","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"","type":"uint64"}],"name":"Unstaked","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"rate","type":"uint256"}],"name":"RateChanged","type":"event"}
+]`
+
+func TestNewContractEventIndexes(t *testing.T) {
+	abiFile := filepath.Join(t.TempDir(), "staking.json")
+	if err := os.WriteFile(abiFile, []byte(`{"contractName":"Staking","abi":`+stakingABI+`}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := NewContractEventIndexes([]types.ContractEventsConfig{{Name: "staking", ABIFile: abiFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 || len(indexes[0].Events) != 3 || indexes[0].Addresses != nil || indexes[0].Collection != "events_staking" {
+		t.Errorf("got %v indexes, want the staking index with all 3 events of all contracts", len(indexes))
+	}
+
+	invalid := [][]types.ContractEventsConfig{
+		{{Name: "Staking", ABIFile: abiFile}},
+		{{Name: "staking", ABIFile: abiFile}, {Name: "staking", ABIFile: abiFile}},
+		{{Name: "staking", ABIFile: abiFile, Events: []string{"Slashed"}}},
+		{{Name: "staking", ABIFile: abiFile, Addresses: []string{"0x01"}}},
+		{{Name: "staking", ABIFile: filepath.Join(t.TempDir(), "missing.json")}},
+	}
+	for _, configs := range invalid {
+		if _, err := NewContractEventIndexes(configs); err == nil {
+			t.Errorf("got no error for config %+v", configs)
+		}
+	}
+}
+
+func TestEmbeddedContractEvents(t *testing.T) {
+	testContractEvents(t, testEmbedded(t))
+}
+
+func TestMongoContractEvents(t *testing.T) {
+	testContractEvents(t, testMongo(t))
+}
+
+// testContractEvents writes the events of a contract event index and checks the event queries of storage
+func testContractEvents(t *testing.T, storage interfaces.Database) {
+	staking := common.HexToAddress("0x5a")
+	other := common.HexToAddress("0x5b")
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+
+	abiFile := filepath.Join(t.TempDir(), "staking.json")
+	if err := os.WriteFile(abiFile, []byte(stakingABI), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	indexes, err := NewContractEventIndexes([]types.ContractEventsConfig{{Name: "staking", ABIFile: abiFile, Addresses: []string{utils.FormatAddressString(staking.Bytes())}, Events: []string{"Staked", "Unstaked"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := indexes[0]
+	if err := storage.ApplyContractEventSchema(indexes); err != nil {
+		t.Fatal(err)
+	}
+	contractAbi, err := readEventsABI(abiFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventLog := func(contract common.Address, name string, user common.Address, values ...interface{}) *types.Eth1Log {
+		event := contractAbi.Events[name]
+		data, err := event.Inputs.NonIndexed().Pack(values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &types.Eth1Log{Address: contract.Bytes(), Data: data, Topics: [][]byte{event.ID.Bytes(), common.LeftPadBytes(user.Bytes(), 32)}}
+	}
+
+	large, _ := new(big.Int).SetString("1000000000000000000000000000000000000", 10)
+	blocks := []*types.Eth1Block{
+		{Number: 1, Transactions: []*types.Eth1Transaction{{Hash: common.HexToHash("0x01").Bytes(), Logs: []*types.Eth1Log{
+			eventLog(staking, "Staked", alice, big.NewInt(100), uint32(30), "first"),
+			// the same event of another contract and an event which isn't indexed are skipped
+			eventLog(other, "Staked", alice, big.NewInt(1), uint32(1), ""),
+			{Address: staking.Bytes(), Data: common.LeftPadBytes([]byte{5}, 32), Topics: [][]byte{contractAbi.Events["RateChanged"].ID.Bytes()}},
+		}}}},
+		{Number: 2, Transactions: []*types.Eth1Transaction{
+			{Hash: common.HexToHash("0x02").Bytes(), Logs: []*types.Eth1Log{eventLog(staking, "Staked", bob, large, uint32(365), "second")}},
+			{Hash: common.HexToHash("0x03").Bytes(), Logs: []*types.Eth1Log{eventLog(staking, "Unstaked", alice, big.NewInt(100), uint64(7))}},
+		}},
+	}
+	if err := testutil.WriteFixtureBlocks(storage, blocks, storage.TransformContractEvents(index)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err := storage.GetContractEvents(index, &types.ContractEventFilter{}, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %v events, want 3", len(events))
+	}
+	first := events[0]
+	if first.Event != "Staked" || !bytes.Equal(first.Contract, staking.Bytes()) || first.BlockNumber != 1 || first.Params["user"] != utils.FormatAddressString(alice.Bytes()) ||
+		first.Params["amount"] != "100" || first.Params["lockDays"] != "30" || first.Params["note"] != "first" {
+		t.Errorf("got first event %v %+v, want Staked by alice", first.Event, first.Params)
+	}
+	if events[1].Params["amount"] != large.String() || events[2].Event != "Unstaked" {
+		t.Errorf("got events %v with amount %v and %v, want Staked with amount %v and Unstaked", events[1].Event, events[1].Params["amount"], events[2].Event, large)
+	}
+	// unnamed parameters are named by their position
+	if events[2].Params["arg2"] != "7" {
+		t.Errorf("got Unstaked parameters %+v, want arg2 7", events[2].Params)
+	}
+	// the log index is the position of the log in the block, not in its transaction
+	if first.LogIndex != 0 || events[2].TxIndex != 1 || events[2].LogIndex != 1 {
+		t.Errorf("got log indexes %v and %v in tx %v, want 0 and 1 in tx 1", first.LogIndex, events[2].LogIndex, events[2].TxIndex)
+	}
+
+	page, token, err := storage.GetContractEvents(index, &types.ContractEventFilter{}, "", 2)
+	if err != nil || len(page) != 2 || token == "" {
+		t.Fatalf("got %v events, page token %q and error %v, want 2 events and a page token", len(page), token, err)
+	}
+	page, token, err = storage.GetContractEvents(index, &types.ContractEventFilter{}, token, 2)
+	if err != nil || len(page) != 1 || page[0].Event != "Unstaked" || token != "" {
+		t.Errorf("got %v events and page token %q and error %v on the second page, want the Unstaked event", len(page), token, err)
+	}
+	if _, _, err := storage.GetContractEvents(index, &types.ContractEventFilter{}, "invalid", 2); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("got error %v for an invalid page token, want %v", err, ErrInvalidPageToken)
+	}
+
+	filters := []struct {
+		name   string
+		filter *types.ContractEventFilter
+		params map[string]string
+		want   int
+	}{
+		{name: "event", filter: &types.ContractEventFilter{Event: "Staked"}, want: 2},
+		{name: "block range", filter: &types.ContractEventFilter{FromBlock: 2, ToBlock: 2}, want: 2},
+		{name: "contract", filter: &types.ContractEventFilter{Contract: other.Bytes()}, want: 0},
+		{name: "indexed address", filter: &types.ContractEventFilter{Event: "Staked"}, params: map[string]string{"user": utils.FormatAddressString(bob.Bytes())}, want: 1},
+		{name: "small integer", filter: &types.ContractEventFilter{Event: "Staked"}, params: map[string]string{"lockDays": "30"}, want: 1},
+		{name: "large integer", filter: &types.ContractEventFilter{Event: "Staked"}, params: map[string]string{"amount": large.String()}, want: 1},
+		{name: "unnamed", filter: &types.ContractEventFilter{Event: "Unstaked"}, params: map[string]string{"arg2": "0x7"}, want: 1},
+		{name: "string", filter: &types.ContractEventFilter{Event: "Staked"}, params: map[string]string{"note": "third"}, want: 0},
+	}
+	for _, tt := range filters {
+		tt.filter.Params = map[string]interface{}{}
+		for name, value := range tt.params {
+			tt.filter.Params[name], err = ContractEventParamFilter(index, tt.filter.Event, name, value)
+			if err != nil {
+				t.Fatalf("%v: %v", tt.name, err)
+			}
+		}
+		events, _, err := storage.GetContractEvents(index, tt.filter, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != tt.want {
+			t.Errorf("%v: got %v events, want %v", tt.name, len(events), tt.want)
+		}
+	}
+	if _, err := ContractEventParamFilter(index, "RateChanged", "rate", "1"); err == nil {
+		t.Errorf("got no error filtering by an event which isn't indexed")
+	}
+
+	// removing block 2 in a reorg removes its events
+	if err := storage.DeleteBlock(2, blocks[1].Hash); err != nil {
+		t.Fatal(err)
+	}
+	events, _, err = storage.GetContractEvents(index, &types.ContractEventFilter{}, "", 10)
+	if err != nil || len(events) != 1 {
+		t.Errorf("got %v events and error %v after removing block 2, want 1", len(events), err)
+	}
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LOGS is the collection of the generic log index
+const LOGS = "logs"
+
+// LOG is the type of the documents of the generic log index
+const LOG = "log"
+
+// MaxLogTopics is the number of topics a log can have
+const MaxLogTopics = 4
+
+// TransformLogs indexes every receipt log of the block by its address and topics into the logs collection
+func (mongodb *Mongo) TransformLogs(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	bulkData := &types.BulkMutations{}
+	models := []mongo.WriteModel{}
+
+	logIndex := uint64(0)
+	for i, tx := range blk.GetTransactions() {
+		for j, log := range tx.GetLogs() {
+			if log.GetRemoved() {
+				continue
+			}
+			if len(log.GetTopics()) > MaxLogTopics {
+				return nil, nil, fmt.Errorf("unexpected number of topics in log %v of tx 0x%x: %v", j, tx.GetHash(), len(log.GetTopics()))
+			}
+
+			l := &entity.Log{
+				ChainId:     mongodb.ChainId,
+				Type:        LOG,
+				Address:     log.GetAddress(),
+				TopicCount:  uint64(len(log.GetTopics())),
+				Data:        log.GetData(),
+				BlockNumber: blk.GetNumber(),
+				BlockHash:   blk.GetHash(),
+				TxHash:      tx.GetHash(),
+				TxIndex:     uint64(i),
+				LogIndex:    logIndex,
+			}
+			topics := []*[]byte{&l.Topic0, &l.Topic1, &l.Topic2, &l.Topic3}
+			for position, topic := range log.GetTopics() {
+				*topics[position] = topic
+			}
+			logIndex++
+
+			doc, err := utils.ToDoc(l)
+			if err != nil {
+				return nil, nil, err
+			}
+			models = append(models, upsertModel(mongodb.documentID(LOG, tx.GetHash(), j), doc))
+		}
+	}
+
+	if len(models) > 0 {
+		bulkData.Collections = map[string][]mongo.WriteModel{LOGS: models}
+	}
+	return bulkData, nil, nil
+}
+
+// logCursor is the position of a log in the (block, log index) ascending sort order of the log index
+type logCursor struct {
+	BlockNumber uint64
+	TxIndex     uint64
+	LogIndex    uint64
+}
+
+func (c *logCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%d", c.BlockNumber, c.TxIndex, c.LogIndex)))
+}
+
+func decodeLogCursor(pageToken string) (*logCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	c := &logCursor{}
+	_, err = fmt.Sscanf(string(raw), "%d:%d:%d", &c.BlockNumber, &c.TxIndex, &c.LogIndex)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return c, nil
+}
+
+// logTopics returns the topics of an indexed log in their order
+func logTopics(l *entity.Log) [][]byte {
+	topics := [][]byte{l.Topic0, l.Topic1, l.Topic2, l.Topic3}
+	if l.TopicCount < MaxLogTopics {
+		topics = topics[:l.TopicCount]
+	}
+	return topics
+}
+
+func logFromEntity(l *entity.Log) *types.Eth1LogIndexed {
+	return &types.Eth1LogIndexed{
+		Address:     l.Address,
+		Topics:      logTopics(l),
+		Data:        l.Data,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash,
+		TxHash:      l.TxHash,
+		TxIndex:     l.TxIndex,
+		LogIndex:    l.LogIndex,
+	}
+}
+
+// matchesLogFilter reports whether the log matches the filter like eth_getLogs does, a log has to have at least as
+// many topics as the filter has topic positions
+func matchesLogFilter(l *entity.Log, filter *types.LogFilter) bool {
+	if len(filter.BlockHash) > 0 {
+		if !bytes.Equal(l.BlockHash, filter.BlockHash) {
+			return false
+		}
+	} else if l.BlockNumber < filter.FromBlock || l.BlockNumber > filter.ToBlock {
+		return false
+	}
+	if len(filter.Addresses) > 0 && !containsBytes(filter.Addresses, l.Address) {
+		return false
+	}
+	if uint64(len(filter.Topics)) > l.TopicCount {
+		return false
+	}
+	topics := logTopics(l)
+	for position, set := range filter.Topics {
+		if len(set) > 0 && !containsBytes(set, topics[position]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsBytes(set [][]byte, value []byte) bool {
+	for _, b := range set {
+		if bytes.Equal(b, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLogs returns up to limit logs matching the filter in chain order. The returned page token continues after the
+// last returned log and is empty if there are no more logs.
+func (mongodb *Mongo) GetLogs(filter *types.LogFilter, pageToken string, limit int64) ([]*types.Eth1LogIndexed, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if len(filter.Topics) > MaxLogTopics {
+		return nil, "", fmt.Errorf("log filter has %v topic positions, logs have at most %v topics", len(filter.Topics), MaxLogTopics)
+	}
+
+	query := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	if len(filter.BlockHash) > 0 {
+		query = append(query, bson.E{Key: "blockhash", Value: filter.BlockHash})
+	} else {
+		query = append(query, bson.E{Key: "blocknumber", Value: bson.D{{Key: "$gte", Value: filter.FromBlock}, {Key: "$lte", Value: filter.ToBlock}}})
+	}
+	if len(filter.Addresses) > 0 {
+		query = append(query, bson.E{Key: "address", Value: bson.D{{Key: "$in", Value: filter.Addresses}}})
+	}
+	if len(filter.Topics) > 0 {
+		query = append(query, bson.E{Key: "topiccount", Value: bson.D{{Key: "$gte", Value: len(filter.Topics)}}})
+	}
+	for position, set := range filter.Topics {
+		if len(set) > 0 {
+			query = append(query, bson.E{Key: fmt.Sprintf("topic%d", position), Value: bson.D{{Key: "$in", Value: set}}})
+		}
+	}
+
+	if pageToken != "" {
+		c, err := decodeLogCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "blocknumber", Value: bson.D{{Key: "$gt", Value: c.BlockNumber}}}},
+			bson.D{{Key: "blocknumber", Value: c.BlockNumber}, {Key: "logindex", Value: bson.D{{Key: "$gt", Value: c.LogIndex}}}},
+		}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "blocknumber", Value: 1}, {Key: "logindex", Value: 1}}).SetLimit(limit)
+	cursor, err := mongodb.Db.Collection(LOGS).Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving logs: %w", err)
+	}
+
+	results := make([]*entity.Log, 0, limit)
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, "", fmt.Errorf("error decoding logs: %w", err)
+	}
+
+	logs := make([]*types.Eth1LogIndexed, 0, len(results))
+	for _, l := range results {
+		logs = append(logs, logFromEntity(l))
+	}
+
+	nextPageToken := ""
+	if int64(len(results)) == limit && limit > 0 {
+		last := results[len(results)-1]
+		nextPageToken = (&logCursor{BlockNumber: last.BlockNumber, TxIndex: last.TxIndex, LogIndex: last.LogIndex}).encode()
+	}
+	return logs, nextPageToken, nil
+}
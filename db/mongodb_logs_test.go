@@ -0,0 +1,126 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestEmbeddedLogs(t *testing.T) {
+	testLogs(t, testEmbedded(t))
+}
+
+func TestMongoLogs(t *testing.T) {
+	testLogs(t, testMongo(t))
+}
+
+// testLogs writes the logs of a few blocks and checks the log filters and the paging of storage
+func testLogs(t *testing.T, storage interfaces.Database) {
+	tokenA := common.HexToAddress("0xaa").Bytes()
+	tokenB := common.HexToAddress("0xbb").Bytes()
+	transfer := common.HexToHash("0x01").Bytes()
+	approval := common.HexToHash("0x02").Bytes()
+	alice := common.HexToHash("0xa1").Bytes()
+	bob := common.HexToHash("0xb0").Bytes()
+
+	blocks := []*types.Eth1Block{
+		{Number: 1, Transactions: []*types.Eth1Transaction{
+			{Hash: common.HexToHash("0x11").Bytes(), Logs: []*types.Eth1Log{
+				{Address: tokenA, Topics: [][]byte{transfer, alice, bob}, Data: []byte{1}},
+				{Address: tokenA, Topics: [][]byte{approval, alice}},
+			}},
+			{Hash: common.HexToHash("0x12").Bytes(), Logs: []*types.Eth1Log{
+				{Address: tokenB, Topics: [][]byte{transfer, bob, alice}},
+				{Address: tokenB, Removed: true, Topics: [][]byte{transfer, bob, bob}},
+			}},
+		}},
+		{Number: 2, Transactions: []*types.Eth1Transaction{
+			{Hash: common.HexToHash("0x21").Bytes(), Logs: []*types.Eth1Log{
+				{Address: tokenB, Topics: [][]byte{transfer, alice, alice}},
+				{Address: tokenA},
+			}},
+		}},
+	}
+	if err := testutil.WriteFixtureBlocks(storage, blocks, storage.TransformLogs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *types.LogFilter
+		// want holds the block and log index of the expected logs
+		want [][2]uint64
+	}{
+		{name: "range", filter: &types.LogFilter{FromBlock: 0, ToBlock: 10}, want: [][2]uint64{{1, 0}, {1, 1}, {1, 2}, {2, 0}, {2, 1}}},
+		{name: "single block", filter: &types.LogFilter{FromBlock: 2, ToBlock: 2}, want: [][2]uint64{{2, 0}, {2, 1}}},
+		{name: "block hash", filter: &types.LogFilter{BlockHash: blocks[0].Hash}, want: [][2]uint64{{1, 0}, {1, 1}, {1, 2}}},
+		{name: "addresses", filter: &types.LogFilter{ToBlock: 10, Addresses: [][]byte{tokenB}}, want: [][2]uint64{{1, 2}, {2, 0}}},
+		{name: "topic0", filter: &types.LogFilter{ToBlock: 10, Topics: [][][]byte{{transfer}}}, want: [][2]uint64{{1, 0}, {1, 2}, {2, 0}}},
+		{name: "topic alternatives", filter: &types.LogFilter{ToBlock: 10, Topics: [][][]byte{{transfer, approval}, {alice}}}, want: [][2]uint64{{1, 0}, {1, 1}, {2, 0}}},
+		{name: "wildcard position", filter: &types.LogFilter{ToBlock: 10, Topics: [][][]byte{nil, nil, {alice}}}, want: [][2]uint64{{1, 2}, {2, 0}}},
+		{name: "trailing wildcard requires the topic", filter: &types.LogFilter{ToBlock: 10, Topics: [][][]byte{{approval}, nil}}, want: [][2]uint64{{1, 1}}},
+		{name: "address and topic", filter: &types.LogFilter{ToBlock: 10, Addresses: [][]byte{tokenA}, Topics: [][][]byte{{transfer}}}, want: [][2]uint64{{1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, _, err := storage.GetLogs(tt.filter, "", 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([][2]uint64, 0, len(logs))
+			for _, l := range logs {
+				got = append(got, [2]uint64{l.BlockNumber, l.LogIndex})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got logs %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got logs %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	logs, _, err := storage.GetLogs(&types.LogFilter{FromBlock: 1, ToBlock: 1}, "", 1)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("got %v logs and error %v, want 1", len(logs), err)
+	}
+	if l := logs[0]; !bytes.Equal(l.Address, tokenA) || len(l.Topics) != 3 || !bytes.Equal(l.Topics[2], bob) || !bytes.Equal(l.Data, []byte{1}) || !bytes.Equal(l.TxHash, common.HexToHash("0x11").Bytes()) {
+		t.Errorf("got log %+v, want the transfer of token a", l)
+	}
+
+	// paging through all logs returns every log once
+	pageToken, pages, total := "", 0, 0
+	for {
+		logs, next, err := storage.GetLogs(&types.LogFilter{ToBlock: 10}, pageToken, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		total += len(logs)
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	if total != 5 || pages != 3 {
+		t.Errorf("got %v logs in %v pages, want 5 in 3", total, pages)
+	}
+	if _, _, err := storage.GetLogs(&types.LogFilter{ToBlock: 10}, "invalid", 2); err != ErrInvalidPageToken {
+		t.Errorf("got error %v for an invalid page token, want %v", err, ErrInvalidPageToken)
+	}
+
+	// removing block 2 in a reorg removes its logs
+	if err := storage.DeleteBlock(2, blocks[1].Hash); err != nil {
+		t.Fatal(err)
+	}
+	logs, _, err = storage.GetLogs(&types.LogFilter{ToBlock: 10}, "", 100)
+	if err != nil || len(logs) != 3 {
+		t.Errorf("got %v logs and error %v after removing block 2, want 3", len(logs), err)
+	}
+}
@@ -0,0 +1,631 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/cache"
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const SCHEMA_MIGRATIONS = "schema_migrations"
+
+// mongoMigration is a single versioned change of the mongodb schema. Migrations are applied in ascending
+// version order and every applied version is recorded in the schema_migrations collection.
+type mongoMigration struct {
+	Version     int64
+	Description string
+	Up          func(ctx context.Context, mongodb *Mongo) error
+}
+
+// collectionSchema declares the indexes and the validator of a collection
+type collectionSchema struct {
+	// Database defaults to the database of the mongodb client if empty
+	Database  string
+	Name      string
+	Indexes   []mongo.IndexModel
+	Validator bson.M
+}
+
+// mongoMigrations lists all schema migrations, new migrations must be appended with a higher version
+var mongoMigrations = []mongoMigration{
+	{
+		Version:     20230601120000,
+		Description: "create collections and compound indexes",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			// blocks used to be inserted without a key, the unique index can't be built while duplicates exist
+			if err := mongodb.dedupeBlocks(ctx); err != nil {
+				return err
+			}
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: BLOCKS,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "eth1block.number", Value: -1}}, Options: options.Index().SetName("chainid_number").SetUnique(true)},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "eth1block.hash", Value: 1}}, Options: options.Index().SetName("chainid_hash")},
+					},
+				},
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "type", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetName("type_key")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "hash", Value: 1}}, Options: options.Index().SetName("chainid_type_hash")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "number", Value: -1}}, Options: options.Index().SetName("chainid_type_number")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "blocknumber", Value: -1}}, Options: options.Index().SetName("chainid_type_blocknumber")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "parenthash", Value: 1}}, Options: options.Index().SetName("chainid_type_parenthash")},
+					},
+				},
+				{
+					Name: METADATA,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "address", Value: 1}, {Key: "token", Value: 1}}, Options: options.Index().SetName("chainid_type_address_token")},
+					},
+				},
+				{
+					Name: METADATA_UPDATES,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetName("chainid_type_key")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "blocknumber", Value: -1}, {Key: "blockhash", Value: 1}}, Options: options.Index().SetName("chainid_blocknumber_blockhash")},
+					},
+				},
+				{
+					Name: CHECKPOINTS,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetName("chainid_name").SetUnique(true)},
+					},
+				},
+				{
+					Name: BEACON_CHAIN,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "validatorid", Value: 1}, {Key: "epoch", Value: -1}}, Options: options.Index().SetName("chainid_type_validatorid_epoch")},
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "type", Value: 1}, {Key: "epoch", Value: -1}}, Options: options.Index().SetName("chainid_type_epoch")},
+					},
+				},
+				{
+					Name: MACHINE_METRICS,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "process", Value: 1}, {Key: "machine", Value: 1}, {Key: "timestamp", Value: -1}}, Options: options.Index().SetName("userid_process_machine_timestamp")},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230601120100,
+		Description: "add validators to the blocks, checkpoints and metadata_updates collections",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: BLOCKS,
+					Validator: bson.M{"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"chainid", "eth1block"},
+						"properties": bson.M{
+							"chainid":   bson.M{"bsonType": "string"},
+							"eth1block": bson.M{"bsonType": "object", "required": bson.A{"number", "hash"}},
+						},
+					}},
+				},
+				{
+					Name: CHECKPOINTS,
+					Validator: bson.M{"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"chainid", "name", "block"},
+						"properties": bson.M{
+							"chainid": bson.M{"bsonType": "string"},
+							"name":    bson.M{"bsonType": "string"},
+							"block":   bson.M{"bsonType": "long"},
+						},
+					}},
+				},
+				{
+					Name: METADATA_UPDATES,
+					Validator: bson.M{"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"chainid", "type"},
+						"properties": bson.M{
+							"chainid": bson.M{"bsonType": "string"},
+							"type":    bson.M{"bsonType": "string"},
+						},
+					}},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230601120200,
+		Description: "expire cache entries with a ttl index",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Database: cache.DATABASE,
+					Name:     cache.TABLE_CACHE,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetName("key")},
+						{Keys: bson.D{{Key: "expiration", Value: 1}}, Options: options.Index().SetName("expiration_ttl").SetExpireAfterSeconds(0)},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230608120000,
+		Description: "add compound indexes for the structured address index documents",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			onlyAddressIndexes := bson.D{{Key: "type", Value: ADDRESS_INDEX}}
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "kind", Value: 1}, {Key: "blocknumber", Value: -1}, {Key: "txindex", Value: -1}, {Key: "position", Value: -1}},
+							Options: options.Index().SetName("addressindex_address_kind").SetPartialFilterExpression(onlyAddressIndexes),
+						},
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "kind", Value: 1}, {Key: "token", Value: 1}, {Key: "blocknumber", Value: -1}, {Key: "txindex", Value: -1}, {Key: "position", Value: -1}},
+							Options: options.Index().SetName("addressindex_address_kind_token").SetPartialFilterExpression(onlyAddressIndexes),
+						},
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "token", Value: 1}, {Key: "kind", Value: 1}, {Key: "direction", Value: 1}, {Key: "blocknumber", Value: -1}, {Key: "txindex", Value: -1}, {Key: "position", Value: -1}},
+							Options: options.Index().SetName("addressindex_token_kind_direction").SetPartialFilterExpression(onlyAddressIndexes),
+						},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230612120000,
+		Description: "add filter indexes for the structured address index documents",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			sortKeys := bson.D{{Key: "blocknumber", Value: -1}, {Key: "txindex", Value: -1}, {Key: "position", Value: -1}}
+			filterKeys := func(field string) bson.D {
+				keys := bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "kind", Value: 1}}
+				if field != "" {
+					keys = append(keys, bson.E{Key: field, Value: 1})
+				}
+				return append(keys, sortKeys...)
+			}
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{Keys: filterKeys("direction"), Options: options.Index().SetName("addressindex_address_kind_direction").SetPartialFilterExpression(bson.D{{Key: "type", Value: ADDRESS_INDEX}})},
+						{Keys: filterKeys("counterparty"), Options: options.Index().SetName("addressindex_address_kind_counterparty").SetPartialFilterExpression(bson.D{{Key: "type", Value: ADDRESS_INDEX}})},
+						{Keys: filterKeys("method"), Options: options.Index().SetName("addressindex_address_kind_method").SetPartialFilterExpression(bson.D{{Key: "type", Value: ADDRESS_INDEX}})},
+						{Keys: filterKeys(""), Options: options.Index().SetName("addressindex_address_kind_failed").SetPartialFilterExpression(bson.D{{Key: "type", Value: ADDRESS_INDEX}, {Key: "failed", Value: true}})},
+						{Keys: filterKeys(""), Options: options.Index().SetName("addressindex_address_kind_contractcreation").SetPartialFilterExpression(bson.D{{Key: "type", Value: ADDRESS_INDEX}, {Key: "contractcreation", Value: true}})},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230615120000,
+		Description: "create the block write journal and reorg collections",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: BLOCK_JOURNAL,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "blocknumber", Value: -1}, {Key: "blockhash", Value: 1}}, Options: options.Index().SetName("chainid_blocknumber_blockhash").SetUnique(true)},
+					},
+				},
+				{
+					Name: REORGS,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "detectedat", Value: -1}}, Options: options.Index().SetName("chainid_detectedat")},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230620120000,
+		Description: "add an index for the balance delta documents",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "token", Value: 1}, {Key: "blocknumber", Value: 1}},
+							Options: options.Index().SetName("balancedelta_address_token").SetPartialFilterExpression(bson.D{{Key: "type", Value: BALANCE_DELTA}}),
+						},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230622120000,
+		Description: "add the token registry",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: TOKENS,
+					Indexes: []mongo.IndexModel{
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}},
+							Options: options.Index().SetName("tokens_address").SetUnique(true),
+						},
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "standard", Value: 1}, {Key: "address", Value: 1}},
+							Options: options.Index().SetName("tokens_standard_address"),
+						},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230624120000,
+		Description: "add indexes for the nft delta documents",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "token", Value: 1}},
+							Options: options.Index().SetName("nftdelta_address_token").SetPartialFilterExpression(bson.D{{Key: "type", Value: NFT_DELTA}}),
+						},
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "token", Value: 1}, {Key: "tokenid", Value: 1}},
+							Options: options.Index().SetName("nftdelta_token_id").SetPartialFilterExpression(bson.D{{Key: "type", Value: NFT_DELTA}}),
+						},
+					},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230701120000,
+		Description: "create the transform_cursors collection",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: TRANSFORM_CURSORS,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "name", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetName("chainid_name_version").SetUnique(true)},
+					},
+					Validator: bson.M{"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"chainid", "name", "version", "block"},
+						"properties": bson.M{
+							"chainid": bson.M{"bsonType": "string"},
+							"name":    bson.M{"bsonType": "string"},
+							"version": bson.M{"bsonType": "long"},
+							"block":   bson.M{"bsonType": "long"},
+						},
+					}},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230708120000,
+		Description: "create the logs collection of the generic log index",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			indexes := []mongo.IndexModel{
+				{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "blocknumber", Value: 1}, {Key: "logindex", Value: 1}}, Options: options.Index().SetName("chainid_blocknumber_logindex")},
+				{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "blockhash", Value: 1}}, Options: options.Index().SetName("chainid_blockhash")},
+				{Keys: bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "blocknumber", Value: 1}, {Key: "logindex", Value: 1}}, Options: options.Index().SetName("chainid_address_blocknumber_logindex")},
+			}
+			for position := 0; position < MaxLogTopics; position++ {
+				topic := fmt.Sprintf("topic%d", position)
+				indexes = append(indexes, mongo.IndexModel{
+					Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: topic, Value: 1}, {Key: "blocknumber", Value: 1}, {Key: "logindex", Value: 1}},
+					Options: options.Index().SetName("chainid_" + topic + "_blocknumber_logindex"),
+				})
+			}
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{{Name: LOGS, Indexes: indexes}})
+		},
+	},
+	{
+		Version:     20230715120000,
+		Description: "create the signatures collection of the selector signature database",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			return mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: SIGNATURES,
+					Indexes: []mongo.IndexModel{
+						{Keys: bson.D{{Key: "selector", Value: 1}, {Key: "signature", Value: 1}}, Options: options.Index().SetName("selector_signature").SetUnique(true)},
+					},
+					Validator: bson.M{"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"selector", "signature", "event"},
+						"properties": bson.M{
+							"selector":  bson.M{"bsonType": "binData"},
+							"signature": bson.M{"bsonType": "string"},
+							"event":     bson.M{"bsonType": "bool"},
+						},
+					}},
+				},
+			})
+		},
+	},
+	{
+		Version:     20230722120000,
+		Description: "add the nft holding documents and their indexes and backfill them from the nft deltas",
+		Up: func(ctx context.Context, mongodb *Mongo) error {
+			err := mongodb.applyCollectionSchemas(ctx, []collectionSchema{
+				{
+					Name: DATA,
+					Indexes: []mongo.IndexModel{
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "address", Value: 1}, {Key: "token", Value: 1}, {Key: "tokenid", Value: 1}},
+							Options: options.Index().SetName("nftholding_address_token_id").SetPartialFilterExpression(bson.D{{Key: "type", Value: NFT_HOLDING}}),
+						},
+						{
+							Keys:    bson.D{{Key: "chainid", Value: 1}, {Key: "token", Value: 1}, {Key: "tokenid", Value: 1}, {Key: "address", Value: 1}},
+							Options: options.Index().SetName("nftholding_token_id_address").SetPartialFilterExpression(bson.D{{Key: "type", Value: NFT_HOLDING}}),
+						},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if err := mongodb.backfillNFTHoldings(ctx); err != nil {
+				return err
+			}
+			// the holdings of an address are no longer summed from its deltas
+			_, err = mongodb.Db.Collection(DATA).Indexes().DropOne(ctx, "nftdelta_address_token")
+			if err != nil && !isIndexNotFoundError(err) {
+				return fmt.Errorf("error dropping index nftdelta_address_token: %w", err)
+			}
+			return nil
+		},
+	},
+}
+
+// ApplyMongoSchema applies the mongodb schema migrations. Use -2 to apply all pending migrations, -1 to apply only the next
+// pending migration or a specific version to apply all pending migrations up to and including that version.
+// Migrations which have already been applied are skipped, so the schema can be applied repeatedly.
+func (mongodb *Mongo) ApplyMongoSchema(version int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+	defer cancel()
+
+	applied, err := mongodb.getAppliedMongoMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving applied mongodb migrations: %w", err)
+	}
+
+	migrations := make([]mongoMigration, len(mongoMigrations))
+	copy(migrations, mongoMigrations)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	if version >= 0 {
+		found := false
+		for _, m := range migrations {
+			if m.Version == version {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown mongodb migration version %v", version)
+		}
+	}
+
+	for _, m := range migrations {
+		if version >= 0 && m.Version > version {
+			break
+		}
+		if applied[m.Version] {
+			continue
+		}
+
+		logrus.Infof("applying mongodb migration %v: %v", m.Version, m.Description)
+		err := m.Up(ctx, mongodb)
+		if err != nil {
+			return fmt.Errorf("error applying mongodb migration %v: %w", m.Version, err)
+		}
+
+		_, err = mongodb.Db.Collection(SCHEMA_MIGRATIONS).UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: m.Version}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "description", Value: m.Description}, {Key: "appliedat", Value: time.Now()}}}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("error recording mongodb migration %v: %w", m.Version, err)
+		}
+
+		if version == -1 {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (mongodb *Mongo) getAppliedMongoMigrations(ctx context.Context) (map[int64]bool, error) {
+	cursor, err := mongodb.Db.Collection(SCHEMA_MIGRATIONS).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int64]bool)
+	for cursor.Next(ctx) {
+		var row struct {
+			Version int64 `bson:"_id"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = true
+	}
+
+	return applied, cursor.Err()
+}
+
+// dedupeBlocks removes all but the first stored copy of every block number of every chain from the blocks collection
+func (mongodb *Mongo) dedupeBlocks(ctx context.Context) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "chainid", Value: "$chainid"}, {Key: "number", Value: "$eth1block.number"}}},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}
+	cursor, err := mongodb.Db.Collection(BLOCKS).Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("error retrieving duplicate blocks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	deleted := int64(0)
+	for cursor.Next(ctx) {
+		var duplicates struct {
+			IDs bson.A `bson:"ids"`
+		}
+		if err := cursor.Decode(&duplicates); err != nil {
+			return fmt.Errorf("error decoding duplicate blocks: %w", err)
+		}
+		res, err := mongodb.Db.Collection(BLOCKS).DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: duplicates.IDs[1:]}}}})
+		if err != nil {
+			return fmt.Errorf("error deleting duplicate blocks: %w", err)
+		}
+		deleted += res.DeletedCount
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error retrieving duplicate blocks: %w", err)
+	}
+	if deleted > 0 {
+		logrus.Infof("deleted %v duplicate blocks", deleted)
+	}
+	return nil
+}
+
+// backfillNFTHoldings stores the nft holding documents of the nft deltas written before the holdings existed. The
+// holdings are replaced with the sums of the deltas of their token id, so a retried migration does not count a delta
+// twice.
+func (mongodb *Mongo) backfillNFTHoldings(ctx context.Context) error {
+	opts := options.Find().SetSort(bson.D{{Key: "chainid", Value: 1}, {Key: "token", Value: 1}, {Key: "tokenid", Value: 1}})
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, bson.D{{Key: "type", Value: NFT_DELTA}}, opts)
+	if err != nil {
+		return fmt.Errorf("error retrieving nft deltas: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	written := 0
+	group := []*entity.NFTDelta{}
+	flush := func() error {
+		if len(group) == 0 {
+			return nil
+		}
+		chain := &Mongo{ChainId: group[0].ChainId}
+		models := []mongo.WriteModel{}
+		for _, change := range chain.nftHoldingChanges(group, nil) {
+			balance, ok := primitive.ParseDecimal128FromBigInt(change.value, 0)
+			if !ok {
+				logrus.Warnf("skipping nft holding %v, its balance %v does not fit a decimal", change.id, change.value)
+				continue
+			}
+			change.holding.Balance = balance
+			doc, err := utils.ToDoc(change.holding)
+			if err != nil {
+				return err
+			}
+			models = append(models, upsertModel(change.id, doc))
+		}
+		group = group[:0]
+		if len(models) == 0 {
+			return nil
+		}
+		if _, err := mongodb.Db.Collection(DATA).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("error saving nft holdings: %w", err)
+		}
+		written += len(models)
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		delta := &entity.NFTDelta{}
+		if err := cursor.Decode(delta); err != nil {
+			return fmt.Errorf("error decoding nft delta: %w", err)
+		}
+		if len(group) > 0 && (group[0].ChainId != delta.ChainId || !bytes.Equal(group[0].Token, delta.Token) || !bytes.Equal(group[0].TokenId, delta.TokenId)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		group = append(group, delta)
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error retrieving nft deltas: %w", err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if written > 0 {
+		logrus.Infof("saved %v nft holdings", written)
+	}
+	return nil
+}
+
+// applyCollectionSchemas creates the collections if they do not exist yet, creates their indexes and sets their validators.
+// Creating an index which already exists with the same options is a no-op, which keeps the migrations idempotent.
+func (mongodb *Mongo) applyCollectionSchemas(ctx context.Context, schemas []collectionSchema) error {
+	for _, schema := range schemas {
+		database := mongodb.Db
+		if schema.Database != "" {
+			database = mongodb.Client.Database(schema.Database)
+		}
+
+		err := database.CreateCollection(ctx, schema.Name)
+		if err != nil && !isNamespaceExistsError(err) {
+			return fmt.Errorf("error creating collection %v: %w", schema.Name, err)
+		}
+
+		if len(schema.Indexes) > 0 {
+			_, err = database.Collection(schema.Name).Indexes().CreateMany(ctx, schema.Indexes)
+			if err != nil {
+				return fmt.Errorf("error creating indexes of collection %v: %w", schema.Name, err)
+			}
+		}
+
+		if schema.Validator != nil {
+			err = database.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: schema.Name},
+				{Key: "validator", Value: schema.Validator},
+				{Key: "validationLevel", Value: "moderate"},
+			}).Err()
+			if err != nil {
+				return fmt.Errorf("error setting validator of collection %v: %w", schema.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isNamespaceExistsError(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 48
+	}
+	return false
+}
+
+func isIndexNotFoundError(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 27
+	}
+	return false
+}
@@ -0,0 +1,579 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/erc1155"
+	"github.com/Prajjawalk/zond-indexer/erc721"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	eth_types "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NFT_DELTA is the type of the nft delta documents stored in the data collection
+const NFT_DELTA = "nftdelta"
+
+// NFT_HOLDING is the type of the nft holding documents stored in the data collection, the current balance of a token
+// id held by an address
+const NFT_HOLDING = "nftholding"
+
+// NFT_METADATA_FAMILY is the type of the nft metadata documents stored in the metadata collection
+const NFT_METADATA_FAMILY = "nft_metadata"
+
+// NFT_METADATA_UPDATE is the type of the metadata update markers of token ids whose metadata has to be retrieved
+const NFT_METADATA_UPDATE = "metadata_nft_updates"
+
+// nftDeltaID returns the id of the nft delta document of a token id and holder in a block, the ids of a token id sort
+// by holder and block
+func (mongodb *Mongo) nftDeltaID(token, tokenId, address []byte, blockNumber uint64) string {
+	return mongodb.documentID(NFT_DELTA, token, tokenId, address, fmt.Sprintf("%020d", blockNumber))
+}
+
+// nftHoldingID returns the id of the holding document of a token id and holder
+func (mongodb *Mongo) nftHoldingID(token, tokenId, address []byte) string {
+	return mongodb.documentID(NFT_HOLDING, token, tokenId, address)
+}
+
+// blockNFTDeltas returns the net changes of the token ids held by the addresses caused by the ERC-721 and ERC-1155
+// transfers of a block, ordered by token, token id and address. Every entry of a batch transfer counts.
+func blockNFTDeltas(blk *types.Eth1Block) []*types.Eth1NFTDelta {
+	deltas := make(map[string]*types.Eth1NFTDelta)
+
+	add := func(address, token, tokenId []byte, standard string, value *big.Int, negative bool) {
+		// mints and burns only change the holdings of the other side
+		if bytes.Equal(address, ZERO_ADDRESS) || value.Sign() == 0 {
+			return
+		}
+		key := string(token) + ":" + string(tokenId) + ":" + string(address)
+		delta, ok := deltas[key]
+		if !ok {
+			delta = &types.Eth1NFTDelta{
+				Address:     address,
+				Token:       token,
+				TokenId:     tokenId,
+				Standard:    standard,
+				BlockNumber: blk.GetNumber(),
+				Delta:       new(big.Int),
+			}
+			deltas[key] = delta
+		}
+		if negative {
+			delta.Delta.Sub(delta.Delta, value)
+		} else {
+			delta.Delta.Add(delta.Delta, value)
+		}
+	}
+	transfer := func(from, to, token []byte, tokenId *big.Int, standard string, value *big.Int) {
+		add(from, token, tokenId.Bytes(), standard, value, true)
+		add(to, token, tokenId.Bytes(), standard, value, false)
+	}
+
+	filterer, err := erc1155.NewErc1155Filterer(common.Address{}, nil)
+	if err != nil {
+		logrus.Errorf("error creating filterer: %v", err)
+	}
+
+	one := big.NewInt(1)
+	for _, tx := range blk.GetTransactions() {
+		for _, log := range tx.GetLogs() {
+			topics := log.GetTopics()
+			if len(topics) != 4 {
+				continue
+			}
+
+			if bytes.Equal(topics[0], erc721.TransferTopic) {
+				transfer(common.BytesToAddress(topics[1]).Bytes(), common.BytesToAddress(topics[2]).Bytes(), log.GetAddress(), new(big.Int).SetBytes(topics[3]), TOKEN_STANDARD_ERC721, one)
+				continue
+			}
+
+			if filterer == nil || (!bytes.Equal(topics[0], erc1155.TransferSingleTopic) && !bytes.Equal(topics[0], erc1155.TransferBulkTopic)) {
+				continue
+			}
+			ethLog := eth_types.Log{Address: common.BytesToAddress(log.GetAddress()), Data: log.GetData()}
+			for _, topic := range topics {
+				ethLog.Topics = append(ethLog.Topics, common.BytesToHash(topic))
+			}
+			if single, err := filterer.ParseTransferSingle(ethLog); err == nil {
+				transfer(single.From.Bytes(), single.To.Bytes(), log.GetAddress(), single.Id, TOKEN_STANDARD_ERC1155, single.Value)
+			} else if batch, err := filterer.ParseTransferBatch(ethLog); err == nil && len(batch.Ids) == len(batch.Values) {
+				for i := range batch.Ids {
+					transfer(batch.From.Bytes(), batch.To.Bytes(), log.GetAddress(), batch.Ids[i], TOKEN_STANDARD_ERC1155, batch.Values[i])
+				}
+			}
+		}
+	}
+
+	result := make([]*types.Eth1NFTDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		if delta.Delta.Sign() != 0 {
+			result = append(result, delta)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := bytes.Compare(result[i].Token, result[j].Token); c != 0 {
+			return c < 0
+		}
+		if c := bytes.Compare(result[i].TokenId, result[j].TokenId); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(result[i].Address, result[j].Address) < 0
+	})
+	return result
+}
+
+// TransformNFTs stores the net changes of the token ids held by the addresses touched by the ERC-721 and ERC-1155
+// transfers of the block and queues the token ids seen for the first time for the retrieval of their metadata. Writing
+// the block adds the changes to the nft holding documents, removing a reorged block subtracts them again.
+func (mongodb *Mongo) TransformNFTs(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error) {
+	bulkData := &types.BulkMutations{}
+	var bulkMetadataUpdates []mongo.WriteModel
+
+	for _, delta := range blockNFTDeltas(blk) {
+		doc, err := utils.ToDoc(&entity.NFTDelta{
+			ChainId:     mongodb.ChainId,
+			Type:        NFT_DELTA,
+			Address:     delta.Address,
+			Token:       delta.Token,
+			TokenId:     delta.TokenId,
+			Standard:    delta.Standard,
+			BlockNumber: delta.BlockNumber,
+			Value:       new(big.Int).Abs(delta.Delta).Bytes(),
+			Negative:    delta.Delta.Sign() < 0,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		bulkData.Model = append(bulkData.Model, upsertModel(mongodb.nftDeltaID(delta.Token, delta.TokenId, delta.Address, delta.BlockNumber), doc))
+		mongodb.markNFTMetadataUpdate(delta, &bulkMetadataUpdates, cache)
+	}
+
+	return bulkData, bulkMetadataUpdates, nil
+}
+
+// markNFTMetadataUpdate queues the token id for the retrieval of its metadata the first time it is seen by the transform
+func (mongodb *Mongo) markNFTMetadataUpdate(delta *types.Eth1NFTDelta, mutations *[]mongo.WriteModel, cache *freecache.Cache) {
+	nftUpdateCacheKey := []byte(fmt.Sprintf("%s:N:%x:%x", mongodb.ChainId, delta.Token, delta.TokenId))
+	if _, err := cache.Get(nftUpdateCacheKey); err == nil {
+		return
+	}
+
+	marker := mongodb.nftMetadataUpdateMarker(delta)
+	*mutations = append(*mutations, upsertModel(marker.id, marker.doc))
+	cache.Set(nftUpdateCacheKey, []byte{0x1}, int((time.Hour * 48).Seconds()))
+}
+
+// nftMetadataUpdateMarker returns the metadata update marker of a token id first seen in the block of the delta
+func (mongodb *Mongo) nftMetadataUpdateMarker(delta *types.Eth1NFTDelta) metadataMarker {
+	doc, _ := utils.ToDoc(entity.NFTMetadataUpdate{
+		ChainId:     mongodb.ChainId,
+		Type:        NFT_METADATA_UPDATE,
+		Token:       delta.Token,
+		TokenId:     delta.TokenId,
+		Standard:    delta.Standard,
+		BlockNumber: delta.BlockNumber,
+	})
+	return metadataMarker{id: mongodb.documentID(NFT_METADATA_UPDATE, delta.Token, delta.TokenId), doc: doc}
+}
+
+// nftDeltaValue returns the signed change of the number of units held by the delta
+func nftDeltaValue(delta *entity.NFTDelta) *big.Int {
+	value := new(big.Int).SetBytes(delta.Value)
+	if delta.Negative {
+		value.Neg(value)
+	}
+	return value
+}
+
+// nftDeltasOf returns the ids and documents of the nft deltas written by the models, other models are skipped
+func nftDeltasOf(models []mongo.WriteModel) ([]interface{}, []*entity.NFTDelta, error) {
+	ids := []interface{}{}
+	deltas := []*entity.NFTDelta{}
+	for _, model := range models {
+		m, ok := model.(*mongo.ReplaceOneModel)
+		if !ok {
+			continue
+		}
+		doc, ok := m.Replacement.(*bson.D)
+		if !ok {
+			continue
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if docType, _ := bson.Raw(raw).Lookup("type").StringValueOK(); docType != NFT_DELTA {
+			continue
+		}
+		id, err := writeModelID(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		delta := &entity.NFTDelta{}
+		if err := bson.Unmarshal(raw, delta); err != nil {
+			return nil, nil, fmt.Errorf("error decoding nft delta %v: %w", id, err)
+		}
+		ids = append(ids, id)
+		deltas = append(deltas, delta)
+	}
+	return ids, deltas, nil
+}
+
+// nftHoldingChange is the change of the balance of an nft holding document caused by writing and removing nft deltas
+type nftHoldingChange struct {
+	id      string
+	holding *entity.NFTHolding
+	value   *big.Int
+}
+
+// nftHoldingChanges returns the changes of the holdings caused by writing the written deltas in place of the stored
+// ones with the same ids and removing the removed ones, ordered by id. Changes that cancel out are left out.
+func (mongodb *Mongo) nftHoldingChanges(written, removed []*entity.NFTDelta) []*nftHoldingChange {
+	changes := make(map[string]*nftHoldingChange)
+	add := func(delta *entity.NFTDelta, value *big.Int) {
+		id := mongodb.nftHoldingID(delta.Token, delta.TokenId, delta.Address)
+		change, ok := changes[id]
+		if !ok {
+			change = &nftHoldingChange{
+				id: id,
+				holding: &entity.NFTHolding{
+					ChainId:  mongodb.ChainId,
+					Type:     NFT_HOLDING,
+					Address:  delta.Address,
+					Token:    delta.Token,
+					TokenId:  delta.TokenId,
+					Standard: delta.Standard,
+				},
+				value: new(big.Int),
+			}
+			changes[id] = change
+		}
+		change.value.Add(change.value, value)
+	}
+	for _, delta := range written {
+		add(delta, nftDeltaValue(delta))
+	}
+	for _, delta := range removed {
+		add(delta, new(big.Int).Neg(nftDeltaValue(delta)))
+	}
+
+	result := make([]*nftHoldingChange, 0, len(changes))
+	for _, change := range changes {
+		if change.value.Sign() != 0 {
+			result = append(result, change)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// decimalToBigInt returns the integer value of a decimal balance
+func decimalToBigInt(d primitive.Decimal128) (*big.Int, error) {
+	value, exp, err := d.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	if value.Sign() == 0 || exp == 0 {
+		return value, nil
+	}
+	if exp > 0 {
+		return value.Mul(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)), nil
+	}
+	return value.Quo(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil)), nil
+}
+
+func nftHoldingFromEntity(holding *entity.NFTHolding) (*types.Eth1NFTHolding, error) {
+	balance, err := decimalToBigInt(holding.Balance)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding balance of nft holding of %x: %w", holding.Address, err)
+	}
+	return &types.Eth1NFTHolding{
+		Address:  holding.Address,
+		Token:    holding.Token,
+		TokenId:  holding.TokenId,
+		Standard: holding.Standard,
+		Balance:  balance,
+	}, nil
+}
+
+// findNFTDeltas returns the stored nft delta documents with the passed ids
+func (mongodb *Mongo) findNFTDeltas(ctx context.Context, ids []interface{}) ([]*entity.NFTDelta, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}, {Key: "type", Value: NFT_DELTA}}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving nft deltas: %w", err)
+	}
+	var deltas []*entity.NFTDelta
+	if err := cursor.All(ctx, &deltas); err != nil {
+		return nil, fmt.Errorf("error decoding nft deltas: %w", err)
+	}
+	return deltas, nil
+}
+
+// updateNFTHoldings adds the changes to the balances of the nft holding documents and removes the holdings whose
+// balance dropped to zero. A change beyond the 34 digits of a decimal is skipped, it is skipped again when the block
+// is removed.
+func (mongodb *Mongo) updateNFTHoldings(ctx context.Context, changes []*nftHoldingChange) error {
+	models := make([]mongo.WriteModel, 0, len(changes))
+	ids := make(bson.A, 0, len(changes))
+	for _, change := range changes {
+		inc, ok := primitive.ParseDecimal128FromBigInt(change.value, 0)
+		if !ok {
+			logger.Warnf("skipping change %v of nft holding %v, it does not fit a decimal", change.value, change.id)
+			continue
+		}
+		holding := change.holding
+		update := bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "balance", Value: inc}}},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "chainid", Value: holding.ChainId},
+				{Key: "type", Value: holding.Type},
+				{Key: "address", Value: holding.Address},
+				{Key: "token", Value: holding.Token},
+				{Key: "tokenid", Value: holding.TokenId},
+				{Key: "standard", Value: holding.Standard},
+			}},
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.D{{Key: "_id", Value: change.id}}).SetUpdate(update).SetUpsert(true))
+		ids = append(ids, change.id)
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err := mongodb.Db.Collection(DATA).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("error updating nft holdings: %w", err)
+	}
+	_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}, {Key: "balance", Value: 0}})
+	if err != nil {
+		return fmt.Errorf("error removing empty nft holdings: %w", err)
+	}
+	return nil
+}
+
+// nftHoldingCursor is the position of the last holding of a page. Holdings of an address continue after its token
+// and token id, holders of a token id after its address.
+type nftHoldingCursor struct {
+	Token   []byte
+	TokenId []byte
+	Address []byte
+}
+
+func (c *nftHoldingCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%x:%x:%x", c.Token, c.TokenId, c.Address)))
+}
+
+func decodeNFTHoldingCursor(pageToken string) (*nftHoldingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	// token id 0 is encoded as an empty field
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidPageToken
+	}
+	fields := make([][]byte, len(parts))
+	for i, part := range parts {
+		fields[i], err = hex.DecodeString(part)
+		if err != nil {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	return &nftHoldingCursor{Token: fields[0], TokenId: fields[1], Address: fields[2]}, nil
+}
+
+// nftHoldingsPage returns the holdings of a page and the page token continuing after it, empty if the page is the last
+func nftHoldingsPage(holdings []*entity.NFTHolding, limit int64) ([]*types.Eth1NFTHolding, string, error) {
+	result := make([]*types.Eth1NFTHolding, 0, len(holdings))
+	for _, holding := range holdings {
+		h, err := nftHoldingFromEntity(holding)
+		if err != nil {
+			return nil, "", err
+		}
+		result = append(result, h)
+	}
+
+	nextPageToken := ""
+	if int64(len(holdings)) == limit && limit > 0 {
+		last := holdings[len(holdings)-1]
+		nextPageToken = (&nftHoldingCursor{Token: last.Token, TokenId: last.TokenId, Address: last.Address}).encode()
+	}
+	return result, nextPageToken, nil
+}
+
+// findNFTHoldings returns up to limit holding documents with a positive balance matching the query in the passed order
+func (mongodb *Mongo) findNFTHoldings(query bson.D, order bson.D, limit int64) ([]*entity.NFTHolding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := append(bson.D{
+		{Key: "chainid", Value: mongodb.ChainId},
+		{Key: "type", Value: NFT_HOLDING},
+		{Key: "balance", Value: bson.D{{Key: "$gt", Value: 0}}},
+	}, query...)
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter, options.Find().SetSort(order).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	var holdings []*entity.NFTHolding
+	if err := cursor.All(ctx, &holdings); err != nil {
+		return nil, err
+	}
+	return holdings, nil
+}
+
+// GetNFTHoldings returns up to limit ERC-721 and ERC-1155 token ids currently held by the address ordered by token and
+// token id. An empty token returns the holdings of all tokens. The returned page token continues after the last
+// returned holding and is empty if there are no more holdings.
+func (mongodb *Mongo) GetNFTHoldings(address []byte, token []byte, pageToken string, limit int64) ([]*types.Eth1NFTHolding, string, error) {
+	query := bson.D{{Key: "address", Value: address}}
+	if len(token) > 0 {
+		query = append(query, bson.E{Key: "token", Value: token})
+	}
+	if pageToken != "" {
+		c, err := decodeNFTHoldingCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "token", Value: bson.D{{Key: "$gt", Value: c.Token}}}},
+			bson.D{{Key: "token", Value: c.Token}, {Key: "tokenid", Value: bson.D{{Key: "$gt", Value: c.TokenId}}}},
+		}})
+	}
+
+	holdings, err := mongodb.findNFTHoldings(query, bson.D{{Key: "token", Value: 1}, {Key: "tokenid", Value: 1}}, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving nft holdings of %x: %w", address, err)
+	}
+	return nftHoldingsPage(holdings, limit)
+}
+
+// GetNFTOwners returns up to limit current holders of a token id ordered by address, a single one for ERC-721 tokens.
+// The returned page token continues after the last returned holder and is empty if there are no more holders.
+func (mongodb *Mongo) GetNFTOwners(token []byte, tokenId []byte, pageToken string, limit int64) ([]*types.Eth1NFTHolding, string, error) {
+	query := bson.D{{Key: "token", Value: token}, {Key: "tokenid", Value: tokenId}}
+	if pageToken != "" {
+		c, err := decodeNFTHoldingCursor(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query = append(query, bson.E{Key: "address", Value: bson.D{{Key: "$gt", Value: c.Address}}})
+	}
+
+	holdings, err := mongodb.findNFTHoldings(query, bson.D{{Key: "address", Value: 1}}, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("error retrieving holders of token %x id %x: %w", token, tokenId, err)
+	}
+	return nftHoldingsPage(holdings, limit)
+}
+
+// GetNFTMetadataUpdates returns up to limit queued token ids whose metadata has to be retrieved, the keys are passed to
+// SaveNFTMetadata to remove them from the queue
+func (mongodb *Mongo) GetNFTMetadataUpdates(limit int) ([]string, []*types.Eth1NFTMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: NFT_METADATA_UPDATE}}
+	cursor, err := mongodb.Db.Collection(METADATA_UPDATES).Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving nft metadata updates: %w", err)
+	}
+
+	var updates []*entity.NFTMetadataUpdate
+	if err := cursor.All(ctx, &updates); err != nil {
+		return nil, nil, fmt.Errorf("error decoding nft metadata updates: %w", err)
+	}
+
+	keys := make([]string, 0, len(updates))
+	nfts := make([]*types.Eth1NFTMetadata, 0, len(updates))
+	for _, update := range updates {
+		keys = append(keys, update.ID)
+		nfts = append(nfts, &types.Eth1NFTMetadata{Token: update.Token, TokenId: update.TokenId, Standard: update.Standard, FirstBlock: update.BlockNumber})
+	}
+	return keys, nfts, nil
+}
+
+// SaveNFTMetadata stores the metadata of the token ids and removes the passed keys from the nft metadata update queue
+func (mongodb *Mongo) SaveNFTMetadata(metadata []*types.Eth1NFTMetadata, deleteKeys []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(metadata))
+	for _, nft := range metadata {
+		doc, err := utils.ToDoc(&entity.NFTMetadata{
+			ChainId:   mongodb.ChainId,
+			Type:      NFT_METADATA_FAMILY,
+			Token:     nft.Token,
+			TokenId:   nft.TokenId,
+			Standard:  nft.Standard,
+			URI:       nft.URI,
+			Metadata:  nft.Metadata,
+			UpdatedAt: nft.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		models = append(models, upsertModel(mongodb.documentID(NFT_METADATA_FAMILY, nft.Token, nft.TokenId), doc))
+	}
+	if len(models) > 0 {
+		_, err := mongodb.Db.Collection(METADATA).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("error saving nft metadata: %w", err)
+		}
+	}
+
+	if len(deleteKeys) > 0 {
+		filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: deleteKeys}}}, {Key: "type", Value: NFT_METADATA_UPDATE}}
+		_, err := mongodb.Db.Collection(METADATA_UPDATES).DeleteMany(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("error deleting nft metadata updates: %w", err)
+		}
+	}
+	return nil
+}
+
+func nftMetadataFromEntity(nft *entity.NFTMetadata) *types.Eth1NFTMetadata {
+	return &types.Eth1NFTMetadata{
+		Token:     nft.Token,
+		TokenId:   nft.TokenId,
+		Standard:  nft.Standard,
+		URI:       nft.URI,
+		Metadata:  nft.Metadata,
+		UpdatedAt: nft.UpdatedAt,
+	}
+}
+
+// GetNFTMetadata returns the metadata of a token id, mongo.ErrNoDocuments if it has not been retrieved yet
+func (mongodb *Mongo) GetNFTMetadata(token []byte, tokenId []byte) (*types.Eth1NFTMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	nft := &entity.NFTMetadata{}
+	err := mongodb.Db.Collection(METADATA).FindOne(ctx, bson.D{{Key: "_id", Value: mongodb.documentID(NFT_METADATA_FAMILY, token, tokenId)}}).Decode(nft)
+	if err != nil {
+		return nil, err
+	}
+	return nftMetadataFromEntity(nft), nil
+}
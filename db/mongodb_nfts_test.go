@@ -0,0 +1,270 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/erc1155"
+	"github.com/Prajjawalk/zond-indexer/erc721"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func word(n int64) []byte {
+	return common.LeftPadBytes(big.NewInt(n).Bytes(), 32)
+}
+
+func erc721Transfer(token, from, to common.Address, id int64) *types.Eth1Log {
+	return &types.Eth1Log{
+		Address: token.Bytes(),
+		Topics:  [][]byte{erc721.TransferTopic, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32), word(id)},
+	}
+}
+
+func erc1155TransferSingle(token, from, to common.Address, id, value int64) *types.Eth1Log {
+	return &types.Eth1Log{
+		Address: token.Bytes(),
+		Data:    append(word(id), word(value)...),
+		Topics:  [][]byte{erc1155.TransferSingleTopic, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)},
+	}
+}
+
+func erc1155TransferBatch(token, from, to common.Address, ids, values []int64) *types.Eth1Log {
+	data := append(word(64), word(int64(64+32*(len(ids)+1)))...)
+	data = append(data, word(int64(len(ids)))...)
+	for _, id := range ids {
+		data = append(data, word(id)...)
+	}
+	data = append(data, word(int64(len(values)))...)
+	for _, value := range values {
+		data = append(data, word(value)...)
+	}
+	return &types.Eth1Log{
+		Address: token.Bytes(),
+		Data:    data,
+		Topics:  [][]byte{erc1155.TransferBulkTopic, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)},
+	}
+}
+
+func TestBlockNFTDeltas(t *testing.T) {
+	zero := common.Address{}
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	kitties := common.HexToAddress("0xe7")
+	items := common.HexToAddress("0xe8")
+
+	block := &types.Eth1Block{
+		Number: 9,
+		Transactions: []*types.Eth1Transaction{{
+			Logs: []*types.Eth1Log{
+				// kitty 1 is minted to alice and passed on to bob within the block, kitty 2 is minted to alice
+				erc721Transfer(kitties, zero, alice, 1),
+				erc721Transfer(kitties, alice, bob, 1),
+				erc721Transfer(kitties, zero, alice, 2),
+				// alice mints 10 of item 5 and 3 of item 6 and sends 4 of item 5 to bob, bob burns 1 of them
+				erc1155TransferBatch(items, zero, alice, []int64{5, 6}, []int64{10, 3}),
+				erc1155TransferSingle(items, alice, bob, 5, 4),
+				erc1155TransferSingle(items, bob, zero, 5, 1),
+			},
+		}},
+	}
+
+	want := []struct {
+		token   common.Address
+		id      int64
+		address common.Address
+		delta   int64
+	}{
+		{token: kitties, id: 1, address: bob, delta: 1},
+		{token: kitties, id: 2, address: alice, delta: 1},
+		{token: items, id: 5, address: alice, delta: 6},
+		{token: items, id: 5, address: bob, delta: 3},
+		{token: items, id: 6, address: alice, delta: 3},
+	}
+
+	got := blockNFTDeltas(block)
+	if len(got) != len(want) {
+		for _, delta := range got {
+			t.Logf("%x / %x / %x: %v", delta.Token, delta.TokenId, delta.Address, delta.Delta)
+		}
+		t.Fatalf("got %v nft deltas, want %v", len(got), len(want))
+	}
+	for i, delta := range got {
+		if !bytes.Equal(delta.Token, want[i].token.Bytes()) || !bytes.Equal(delta.TokenId, big.NewInt(want[i].id).Bytes()) || !bytes.Equal(delta.Address, want[i].address.Bytes()) {
+			t.Errorf("delta %v is for %x / %x / %x, want %x / %v / %x", i, delta.Token, delta.TokenId, delta.Address, want[i].token, want[i].id, want[i].address)
+			continue
+		}
+		if delta.Delta.Cmp(big.NewInt(want[i].delta)) != 0 {
+			t.Errorf("delta of %x / %x / %x = %v, want %v", delta.Token, delta.TokenId, delta.Address, delta.Delta, want[i].delta)
+		}
+	}
+}
+
+func TestEmbeddedNFTHoldings(t *testing.T) {
+	testNFTHoldings(t, testEmbedded(t))
+}
+
+func TestMongoNFTHoldings(t *testing.T) {
+	testNFTHoldings(t, testMongo(t))
+}
+
+// testNFTHoldings writes nft transfers and checks the holders, the holdings and the metadata queue of storage
+func testNFTHoldings(t *testing.T, storage interfaces.Database) {
+	zero := common.Address{}
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	kitties := common.HexToAddress("0xe7")
+	items := common.HexToAddress("0xe8")
+	kitty := big.NewInt(42).Bytes()
+
+	// kitty 42 and 2 of item 5 are minted to alice in block 1 and moved to bob in block 2
+	blocks := []*types.Eth1Block{
+		{Number: 1, Transactions: []*types.Eth1Transaction{{Logs: []*types.Eth1Log{erc721Transfer(kitties, zero, alice, 42), erc1155TransferSingle(items, zero, alice, 5, 2)}}}},
+		{Number: 2, Transactions: []*types.Eth1Transaction{{Logs: []*types.Eth1Log{erc721Transfer(kitties, alice, bob, 42), erc1155TransferSingle(items, alice, bob, 5, 1)}}}},
+	}
+	if err := testutil.WriteFixtureBlocks(storage, blocks, storage.TransformNFTs); err != nil {
+		t.Fatal(err)
+	}
+	// writing a block again replaces its deltas without counting them twice
+	if err := testutil.WriteFixtureBlocks(storage, blocks[1:], storage.TransformNFTs); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOwners := func(when string, want ...common.Address) {
+		t.Helper()
+		owners, _, err := storage.GetNFTOwners(kitties.Bytes(), kitty, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(owners) != len(want) {
+			t.Fatalf("%s: got %v owners of kitty 42, want %v", when, len(owners), len(want))
+		}
+		for i, owner := range owners {
+			if !bytes.Equal(owner.Address, want[i].Bytes()) || owner.Balance.Cmp(big.NewInt(1)) != 0 {
+				t.Errorf("%s: owner %v of kitty 42 is %x with %v, want %x with 1", when, i, owner.Address, owner.Balance, want[i])
+			}
+		}
+	}
+	assertOwners("after block 2", bob)
+
+	holdings, _, err := storage.GetNFTHoldings(alice.Bytes(), nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holdings) != 1 || !bytes.Equal(holdings[0].Token, items.Bytes()) || holdings[0].Balance.Cmp(big.NewInt(1)) != 0 || holdings[0].Standard != TOKEN_STANDARD_ERC1155 {
+		t.Errorf("got %v holdings of alice, want 1 of item 5", len(holdings))
+	}
+	holdings, _, err = storage.GetNFTHoldings(bob.Bytes(), kitties.Bytes(), "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holdings) != 1 || !bytes.Equal(holdings[0].TokenId, kitty) || holdings[0].Standard != TOKEN_STANDARD_ERC721 {
+		t.Errorf("got %v kitties of bob, want kitty 42", len(holdings))
+	}
+
+	// removing block 2 in a reorg returns the kitty to alice
+	if err := storage.DeleteBlock(2, blocks[1].Hash); err != nil {
+		t.Fatal(err)
+	}
+	assertOwners("after removing block 2", alice)
+	holdings, _, err = storage.GetNFTHoldings(bob.Bytes(), nil, "", 10)
+	if err != nil || len(holdings) != 0 {
+		t.Errorf("got %v holdings of bob and error %v after removing block 2, want none", len(holdings), err)
+	}
+
+	// both token ids are queued once for the retrieval of their metadata, apart from the token updates
+	keys, nfts, err := storage.GetNFTMetadataUpdates(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %v queued token ids, want 2", len(keys))
+	}
+	tokenKeys, _, err := storage.GetTokenUpdates(10)
+	if err != nil || len(tokenKeys) != 0 {
+		t.Errorf("got %v token updates and error %v, want none", len(tokenKeys), err)
+	}
+
+	for _, nft := range nfts {
+		nft.URI = "data:application/json,{}"
+		nft.Metadata = []byte("{}")
+	}
+	if err := storage.SaveNFTMetadata(nfts, keys); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := storage.GetNFTMetadata(kitties.Bytes(), kitty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Standard != TOKEN_STANDARD_ERC721 || string(metadata.Metadata) != "{}" {
+		t.Errorf("got metadata %q of a %v token, want {} of an erc721 token", metadata.Metadata, metadata.Standard)
+	}
+	keys, _, err = storage.GetNFTMetadataUpdates(10)
+	if err != nil || len(keys) != 0 {
+		t.Errorf("got %v queued token ids and error %v after saving their metadata, want none", len(keys), err)
+	}
+
+	// kitties 0, 7 and 300 are minted to alice and 1 of item 5 to bob and carol, token ids sort by value
+	carol := common.HexToAddress("0xc0")
+	mints := []*types.Eth1Block{{Number: 3, Transactions: []*types.Eth1Transaction{{Logs: []*types.Eth1Log{
+		erc721Transfer(kitties, zero, alice, 300),
+		erc721Transfer(kitties, zero, alice, 7),
+		erc721Transfer(kitties, zero, alice, 0),
+		erc1155TransferSingle(items, zero, carol, 5, 1),
+		erc1155TransferSingle(items, zero, bob, 5, 1),
+	}}}}}
+	if err := testutil.WriteFixtureBlocks(storage, mints, storage.TransformNFTs); err != nil {
+		t.Fatal(err)
+	}
+
+	type holding struct {
+		token   common.Address
+		id      int64
+		address common.Address
+		balance int64
+	}
+	assertPages := func(what string, get func(pageToken string) ([]*types.Eth1NFTHolding, string, error), want ...[]holding) {
+		t.Helper()
+		pageToken := ""
+		for i, page := range want {
+			holdings, nextPageToken, err := get(pageToken)
+			if err != nil {
+				t.Fatalf("%s page %v: %v", what, i, err)
+			}
+			if len(holdings) != len(page) {
+				t.Fatalf("%s page %v: got %v holdings, want %v", what, i, len(holdings), len(page))
+			}
+			for j, h := range holdings {
+				w := page[j]
+				if !bytes.Equal(h.Token, w.token.Bytes()) || !bytes.Equal(h.TokenId, big.NewInt(w.id).Bytes()) || !bytes.Equal(h.Address, w.address.Bytes()) || h.Balance.Cmp(big.NewInt(w.balance)) != 0 {
+					t.Errorf("%s page %v holding %v is %x / %x / %x with %v, want %x / %v / %x with %v", what, i, j, h.Token, h.TokenId, h.Address, h.Balance, w.token, w.id, w.address, w.balance)
+				}
+			}
+			if last := i == len(want)-1; last != (nextPageToken == "") {
+				t.Fatalf("%s page %v: got page token %q, want one only before the last page", what, i, nextPageToken)
+			}
+			pageToken = nextPageToken
+		}
+	}
+	assertPages("holdings of alice", func(pageToken string) ([]*types.Eth1NFTHolding, string, error) {
+		return storage.GetNFTHoldings(alice.Bytes(), nil, pageToken, 2)
+	},
+		[]holding{{kitties, 0, alice, 1}, {kitties, 7, alice, 1}},
+		[]holding{{kitties, 42, alice, 1}, {kitties, 300, alice, 1}},
+		[]holding{{items, 5, alice, 2}},
+	)
+	assertPages("owners of item 5", func(pageToken string) ([]*types.Eth1NFTHolding, string, error) {
+		return storage.GetNFTOwners(items.Bytes(), big.NewInt(5).Bytes(), pageToken, 2)
+	},
+		[]holding{{items, 5, alice, 2}, {items, 5, bob, 1}},
+		[]holding{{items, 5, carol, 1}},
+	)
+
+	if _, _, err := storage.GetNFTOwners(items.Bytes(), big.NewInt(5).Bytes(), "not a page token", 2); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("got error %v for an invalid page token, want ErrInvalidPageToken", err)
+	}
+}
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const BLOCK_JOURNAL = "block_journal"
+const REORGS = "reorgs"
+
+// withTransaction runs fn inside a multi document transaction if the deployment supports them. Standalone
+// servers don't, there fn runs without a transaction and a failure can leave partial writes behind.
+func (mongodb *Mongo) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !mongodb.transactions {
+		return fn(ctx)
+	}
+
+	session, err := mongodb.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("error starting mongodb session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// supportsTransactions reports whether the deployment is a replica set or a sharded cluster
+func (mongodb *Mongo) supportsTransactions(ctx context.Context) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := mongodb.Db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		logger.Warnf("error checking mongodb transaction support: %v", err)
+		return false
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		logger.Warnf("mongodb deployment does not support transactions, block writes and rollbacks are not atomic")
+		return false
+	}
+	return true
+}
+
+// WriteBlockMutations writes the documents produced by the transforms of a block and records their ids in the write
+// journal of the block, so DeleteBlock can remove them again if the block gets reorged out of the chain. The nft deltas
+// of the block are added to the nft holdings in the same transaction.
+func (mongodb *Mongo) WriteBlockMutations(block *types.Eth1Block, bulkData *types.BulkMutations, metadataUpdates []mongo.WriteModel) error {
+	writes := map[string][]mongo.WriteModel{
+		DATA:             bulkData.Model,
+		METADATA_UPDATES: metadataUpdates,
+	}
+	for collection, models := range bulkData.Collections {
+		writes[collection] = append(writes[collection], models...)
+	}
+
+	journal := bson.D{}
+	for collection, models := range writes {
+		ids := make(bson.A, 0, len(models))
+		for _, model := range models {
+			id, err := writeModelID(model)
+			if err != nil {
+				return fmt.Errorf("error journaling write to %v of block %v: %w", collection, block.Number, err)
+			}
+			ids = append(ids, id)
+		}
+		journal = append(journal, bson.E{Key: "writes." + collection, Value: bson.D{{Key: "$each", Value: ids}}})
+	}
+
+	nftDeltaIDs, nftDeltas, err := nftDeltasOf(bulkData.Model)
+	if err != nil {
+		return fmt.Errorf("error reading nft deltas of block %v: %w", block.Number, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+	defer cancel()
+
+	return mongodb.withTransaction(ctx, func(ctx context.Context) error {
+		// rewriting a block replaces its stored nft deltas, their changes are taken back from the holdings
+		replaced, err := mongodb.findNFTDeltas(ctx, nftDeltaIDs)
+		if err != nil {
+			return err
+		}
+
+		for collection, models := range writes {
+			if len(models) == 0 {
+				continue
+			}
+			_, err := mongodb.Db.Collection(collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			if err != nil {
+				return fmt.Errorf("error writing to mongodb %v collection: %w", collection, err)
+			}
+		}
+
+		if err := mongodb.updateNFTHoldings(ctx, mongodb.nftHoldingChanges(nftDeltas, replaced)); err != nil {
+			return fmt.Errorf("error updating nft holdings of block %v: %w", block.Number, err)
+		}
+
+		filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "blocknumber", Value: block.Number}, {Key: "blockhash", Value: block.Hash}}
+		update := bson.D{
+			{Key: "$addToSet", Value: journal},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		}
+		_, err = mongodb.Db.Collection(BLOCK_JOURNAL).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("error saving write journal of block %v: %w", block.Number, err)
+		}
+		return nil
+	})
+}
+
+// writeModelID returns the _id of the document written by a model, insert models without an _id are assigned one
+func writeModelID(model mongo.WriteModel) (interface{}, error) {
+	var filter interface{}
+	switch m := model.(type) {
+	case *mongo.InsertOneModel:
+		doc, ok := m.Document.(*bson.D)
+		if !ok {
+			return nil, fmt.Errorf("unsupported insert document type %T", m.Document)
+		}
+		for _, e := range *doc {
+			if e.Key == "_id" {
+				return e.Value, nil
+			}
+		}
+		id := primitive.NewObjectID()
+		*doc = append(bson.D{{Key: "_id", Value: id}}, *doc...)
+		return id, nil
+	case *mongo.ReplaceOneModel:
+		filter = m.Filter
+	case *mongo.UpdateOneModel:
+		filter = m.Filter
+	default:
+		return nil, fmt.Errorf("unsupported write model %T", model)
+	}
+
+	if d, ok := filter.(bson.D); ok {
+		for _, e := range d {
+			if e.Key == "_id" {
+				return e.Value, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("write model filter does not select an _id")
+}
+
+// DeleteBlock removes a block and every document written while indexing it in a single transaction. Documents are
+// looked up in the write journal of the block and the legacy block keys, blocks indexed before the journal existed
+// are cleaned up by block number if the stored block has the passed hash. Balances, tokens and token ids touched by
+// the block are marked for a refresh against the canonical chain, tokens first seen in the block are removed from the
+// token registry and its nft deltas are subtracted from the nft holdings.
+func (mongodb *Mongo) DeleteBlock(blockNumber uint64, blockHash []byte) error {
+	legacyKeys, err := mongodb.GetBlockKeys(blockNumber, blockHash)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+	defer cancel()
+
+	return mongodb.withTransaction(ctx, func(ctx context.Context) error {
+		journalFilter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "blocknumber", Value: blockNumber}, {Key: "blockhash", Value: blockHash}}
+		journal := &entity.BlockJournal{}
+		err := mongodb.Db.Collection(BLOCK_JOURNAL).FindOne(ctx, journalFilter).Decode(journal)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("error retrieving write journal of block %v: %w", blockNumber, err)
+		}
+		journaled := err == nil
+
+		refreshes, err := mongodb.balanceRefreshesFor(ctx, journal.Writes[METADATA_UPDATES])
+		if err != nil {
+			return err
+		}
+		tokens, markers, err := mongodb.tokenRefreshesFor(ctx, journal.Writes[DATA])
+		if err != nil {
+			return err
+		}
+		nftDeltas, err := mongodb.findNFTDeltas(ctx, journal.Writes[DATA])
+		if err != nil {
+			return err
+		}
+
+		for collection, ids := range journal.Writes {
+			if len(ids) == 0 {
+				continue
+			}
+			_, err = mongodb.Db.Collection(collection).DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+			if err != nil {
+				return fmt.Errorf("error deleting journaled %v documents of block %v: %w", collection, blockNumber, err)
+			}
+		}
+		if err := mongodb.updateNFTHoldings(ctx, mongodb.nftHoldingChanges(nil, nftDeltas)); err != nil {
+			return fmt.Errorf("error reverting nft holdings of block %v: %w", blockNumber, err)
+		}
+
+		if len(legacyKeys) > 0 {
+			idxFilter := bson.D{{Key: "type", Value: bson.D{{Key: "$in", Value: bson.A{"index", ADDRESS_INDEX}}}}, {Key: "key", Value: bson.D{{Key: "$in", Value: legacyKeys}}}}
+			_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, idxFilter)
+			if err != nil {
+				return err
+			}
+		}
+
+		blockFilter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "eth1block.number", Value: blockNumber}, {Key: "eth1block.hash", Value: blockHash}}
+		if !journaled {
+			// the data documents don't record the hash of their block, they are only removed by number if the stored
+			// block is the one being deleted and not a block of another branch at the same height
+			stored, err := mongodb.Db.Collection(BLOCKS).CountDocuments(ctx, blockFilter)
+			if err != nil {
+				return fmt.Errorf("error retrieving block %v: %w", blockNumber, err)
+			}
+			if stored > 0 {
+				dataFilter := bson.D{
+					{Key: "chainid", Value: mongodb.ChainId},
+					{Key: "blockhash", Value: bson.D{{Key: "$in", Value: bson.A{blockHash, nil}}}},
+					{Key: "$or", Value: bson.A{
+						bson.D{{Key: "blocknumber", Value: blockNumber}},
+						bson.D{{Key: "type", Value: "blockindex"}, {Key: "number", Value: blockNumber}},
+					}},
+				}
+				_, err = mongodb.Db.Collection(DATA).DeleteMany(ctx, dataFilter)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = mongodb.Db.Collection(BLOCKS).DeleteOne(ctx, blockFilter)
+		if err != nil {
+			return err
+		}
+
+		keysFilter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: "metadata_block_keys"}, {Key: "blocknumber", Value: blockNumber}, {Key: "blockhash", Value: hex.EncodeToString(blockHash)}}
+		_, err = mongodb.Db.Collection(METADATA_UPDATES).DeleteOne(ctx, keysFilter)
+		if err != nil {
+			return err
+		}
+
+		_, err = mongodb.Db.Collection(BLOCK_JOURNAL).DeleteOne(ctx, journalFilter)
+		if err != nil {
+			return err
+		}
+
+		if len(tokens) > 0 {
+			registryFilter := bson.D{
+				{Key: "chainid", Value: mongodb.ChainId},
+				{Key: "address", Value: bson.D{{Key: "$in", Value: tokens}}},
+				{Key: "firstblock", Value: bson.D{{Key: "$gte", Value: blockNumber}}},
+			}
+			_, err = mongodb.Db.Collection(TOKENS).DeleteMany(ctx, registryFilter)
+			if err != nil {
+				return fmt.Errorf("error removing tokens first seen in block %v: %w", blockNumber, err)
+			}
+		}
+		markers = append(refreshes, markers...)
+		if len(markers) > 0 {
+			models := make([]mongo.WriteModel, 0, len(markers))
+			for _, marker := range markers {
+				update := bson.D{{Key: "$setOnInsert", Value: marker.doc}}
+				models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.D{{Key: "_id", Value: marker.id}}).SetUpdate(update).SetUpsert(true))
+			}
+			_, err = mongodb.Db.Collection(METADATA_UPDATES).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			if err != nil {
+				return fmt.Errorf("error marking balances and tokens of block %v for refresh: %w", blockNumber, err)
+			}
+		}
+		return nil
+	})
+}
+
+// tokenRefreshesFor returns the tokens and the token and nft metadata update markers of the transfer and nft delta
+// documents with the passed ids
+func (mongodb *Mongo) tokenRefreshesFor(ctx context.Context, ids []interface{}) ([][]byte, []metadataMarker, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}},
+		{Key: "type", Value: bson.D{{Key: "$in", Value: bson.A{"erc20index", "erc721index", "erc1155index", NFT_DELTA}}}},
+	}
+	cursor, err := mongodb.Db.Collection(DATA).Find(ctx, filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving token transfers: %w", err)
+	}
+	var docs []bson.Raw
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, nil, fmt.Errorf("error while parsing token transfers: %w", err)
+	}
+	return mongodb.tokenMarkersFor(docs)
+}
+
+// balanceRefreshesFor returns new balance update markers for the address and token pairs of the passed markers.
+// The markers of a removed block are deleted with it, but the balances they touched still have to be refreshed.
+func (mongodb *Mongo) balanceRefreshesFor(ctx context.Context, ids []interface{}) ([]metadataMarker, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}, {Key: "type", Value: "metadata_balances_updates"}}
+	cursor, err := mongodb.Db.Collection(METADATA_UPDATES).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving balance updates: %w", err)
+	}
+	var markers []*entity.BalanceUpdates
+	if err = cursor.All(ctx, &markers); err != nil {
+		return nil, fmt.Errorf("error while parsing balance updates: %w", err)
+	}
+
+	seen := make(map[string]bool, len(markers))
+	refreshes := make([]metadataMarker, 0, len(markers))
+	for _, marker := range markers {
+		refresh := mongodb.balanceUpdateMarker(marker.Key, marker.Address, marker.Token)
+		if seen[refresh.id] {
+			continue
+		}
+		seen[refresh.id] = true
+		refreshes = append(refreshes, refresh)
+	}
+	return refreshes, nil
+}
+
+// SaveReorg records a chain reorganization handled by the indexer
+func (mongodb *Mongo) SaveReorg(reorg *entity.Reorg) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	reorg.ChainId = mongodb.ChainId
+	doc, err := utils.ToDoc(reorg)
+	if err != nil {
+		return err
+	}
+
+	_, err = mongodb.Db.Collection(REORGS).InsertOne(ctx, doc)
+	return err
+}
+
+// GetReorgs returns the most recent chain reorganizations, newest first
+func (mongodb *Mongo) GetReorgs(limit int64) ([]*entity.Reorg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	cursor, err := mongodb.Db.Collection(REORGS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "detectedat", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving reorgs: %w", err)
+	}
+
+	reorgs := []*entity.Reorg{}
+	if err = cursor.All(ctx, &reorgs); err != nil {
+		return nil, fmt.Errorf("error while parsing reorgs: %w", err)
+	}
+	return reorgs, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SIGNATURES is the collection of the selector signature database, it is shared by all chains
+const SIGNATURES = "signatures"
+
+// SaveSignatures adds the signatures to the signature database, signatures which are already known are skipped
+func (mongodb *Mongo) SaveSignatures(signatures []*types.Eth1Signature) error {
+	if len(signatures) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(signatures))
+	for _, signature := range signatures {
+		doc, err := utils.ToDoc(&entity.Signature{Selector: signature.Selector, Signature: signature.Signature, Event: signature.Event})
+		if err != nil {
+			return err
+		}
+		models = append(models, upsertModel(fmt.Sprintf("%x:%s", signature.Selector, signature.Signature), doc))
+	}
+
+	_, err := mongodb.Db.Collection(SIGNATURES).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("error saving %v signatures: %w", len(signatures), err)
+	}
+	return nil
+}
+
+// GetSignatures returns the signatures of the selector, the signatures of the embedded list come first
+func (mongodb *Mongo) GetSignatures(selector []byte) ([]*types.Eth1Signature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cursor, err := mongodb.Db.Collection(SIGNATURES).Find(ctx, bson.D{{Key: "selector", Value: selector}})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving signatures of selector 0x%x: %w", selector, err)
+	}
+	stored := []*entity.Signature{}
+	if err = cursor.All(ctx, &stored); err != nil {
+		return nil, fmt.Errorf("error decoding signatures of selector 0x%x: %w", selector, err)
+	}
+	return mergeSignatures(selector, stored), nil
+}
+
+// mergeSignatures returns the signatures of the embedded list for the selector followed by the stored ones which aren't
+// part of it, sorted by signature
+func mergeSignatures(selector []byte, stored []*entity.Signature) []*types.Eth1Signature {
+	embedded := utils.EmbeddedSignatures(selector)
+	signatures := make([]*types.Eth1Signature, 0, len(embedded)+len(stored))
+	known := make(map[string]bool, len(embedded)+len(stored))
+	for _, signature := range embedded {
+		known[signature.Signature] = true
+		signatures = append(signatures, signature)
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Signature < stored[j].Signature
+	})
+	for _, signature := range stored {
+		if known[signature.Signature] {
+			continue
+		}
+		known[signature.Signature] = true
+		signatures = append(signatures, &types.Eth1Signature{Selector: signature.Selector, Signature: signature.Signature, Event: signature.Event})
+	}
+	return signatures
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestEmbeddedSignatures(t *testing.T) {
+	testSignatures(t, testEmbedded(t))
+}
+
+func TestMongoSignatures(t *testing.T) {
+	testSignatures(t, testMongo(t))
+}
+
+// testSignatures saves a contract abi and imported signatures and checks the signatures storage returns for them
+func testSignatures(t *testing.T, storage interfaces.Database) {
+	abiJson := `[
+		{"inputs":[],"name":"count","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+		{"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[],"stateMutability":"nonpayable","type":"function"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"value","type":"uint256"}],"name":"Counted","type":"event"}
+	]`
+	contractAbi, err := abi.JSON(strings.NewReader(abiJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := contractAbi.Methods["count"].ID
+
+	signatures, err := storage.GetSignatures(count)
+	if err != nil || len(signatures) != 0 {
+		t.Fatalf("got signatures %+v and error %v before saving the abi, want none", signatures, err)
+	}
+	err = storage.SaveContractMetadata(common.HexToAddress("0xc1").Bytes(), &types.ContractMetadata{Name: "Counter", ABIJson: []byte(abiJson), ABI: &contractAbi})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the logs endpoint only decodes with stored abis
+	if stored, err := storage.GetStoredContractMetadata(common.HexToAddress("0xc1").Bytes()); err != nil || stored == nil || stored.ABI.Methods["count"].ID == nil {
+		t.Errorf("got stored metadata %+v and error %v, want the saved abi", stored, err)
+	}
+	if stored, err := storage.GetStoredContractMetadata(common.HexToAddress("0xc2").Bytes()); err != nil || stored != nil {
+		t.Errorf("got stored metadata %+v and error %v for an unknown contract, want none", stored, err)
+	}
+
+	signatures, err = storage.GetSignatures(count)
+	if err != nil || len(signatures) != 1 || signatures[0].Signature != "count()" || signatures[0].Event {
+		t.Errorf("got signatures %+v and error %v, want count() of the saved abi", signatures, err)
+	}
+	signatures, err = storage.GetSignatures(contractAbi.Events["Counted"].ID.Bytes())
+	if err != nil || len(signatures) != 1 || signatures[0].Signature != "Counted(uint256)" || !signatures[0].Event {
+		t.Errorf("got signatures %+v and error %v, want the Counted event of the saved abi", signatures, err)
+	}
+	// signatures of the embedded list aren't duplicated by saved ones
+	signatures, err = storage.GetSignatures(contractAbi.Methods["transfer"].ID)
+	if err != nil || len(signatures) != 1 || signatures[0].Signature != "transfer(address,uint256)" {
+		t.Errorf("got signatures %+v and error %v, want transfer(address,uint256) once", signatures, err)
+	}
+
+	// imported signatures colliding with the embedded list follow it
+	collision, err := utils.NewSignature("collate_propagate_storage(bytes16)", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveSignatures([]*types.Eth1Signature{collision}); err != nil {
+		t.Fatal(err)
+	}
+	signatures, err = storage.GetSignatures(collision.Selector)
+	if err != nil || len(signatures) != 2 || signatures[0].Signature != "burn(uint256)" || signatures[1].Signature != collision.Signature {
+		t.Errorf("got signatures %+v and error %v, want burn(uint256) followed by the imported collision", signatures, err)
+	}
+}
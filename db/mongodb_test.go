@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/cache"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testMongo connects to the deployment in MONGODB_TEST_URL and returns a backend on a database of its own that is
+// dropped when the test ends, the test is skipped if the variable is not set. With MONGODB_TEST_TRANSACTIONS set the
+// test fails if the deployment does not support transactions.
+func testMongo(t *testing.T) *Mongo {
+	t.Helper()
+
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mongodb := &Mongo{
+		Client:  client,
+		Db:      client.Database(fmt.Sprintf("test_%d", time.Now().UnixNano())),
+		ChainId: "1",
+	}
+	mongodb.transactions = mongodb.supportsTransactions(ctx)
+	if os.Getenv("MONGODB_TEST_TRANSACTIONS") != "" && !mongodb.transactions {
+		t.Fatal("MONGODB_TEST_TRANSACTIONS is set but the deployment in MONGODB_TEST_URL does not support transactions")
+	}
+	cache.MustInitTieredCacheMongodb(client, mongodb.ChainId)
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := mongodb.Db.Drop(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := client.Disconnect(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	return mongodb
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/entity"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/coocood/freecache"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TOKENS is the collection of the token registry
+const TOKENS = "tokens"
+
+// TOKEN_UPDATE is the type of the metadata update markers of token contracts whose metadata has to be retrieved
+const TOKEN_UPDATE = "metadata_token_updates"
+
+// Standards of the tokens in the token registry
+const (
+	TOKEN_STANDARD_ERC20   = "erc20"
+	TOKEN_STANDARD_ERC721  = "erc721"
+	TOKEN_STANDARD_ERC1155 = "erc1155"
+)
+
+// markTokenUpdate queues the token for the retrieval of its metadata the first time it is seen by the transforms
+func (mongodb *Mongo) markTokenUpdate(token []byte, standard string, blockNumber uint64, mutations *[]mongo.WriteModel, cache *freecache.Cache) {
+	tokenUpdateCacheKey := []byte(fmt.Sprintf("%s:T:%x", mongodb.ChainId, token))
+	if _, err := cache.Get(tokenUpdateCacheKey); err == nil {
+		return
+	}
+
+	marker := mongodb.tokenUpdateMarker(token, standard, blockNumber)
+	*mutations = append(*mutations, upsertModel(marker.id, marker.doc))
+	cache.Set(tokenUpdateCacheKey, []byte{0x1}, int((time.Hour * 48).Seconds()))
+}
+
+// metadataMarker is a balance, token or nft metadata update marker and the id it is stored with
+type metadataMarker struct {
+	id  string
+	doc *bson.D
+}
+
+// tokenUpdateMarker returns the metadata update marker of a token first seen in the passed block
+func (mongodb *Mongo) tokenUpdateMarker(token []byte, standard string, blockNumber uint64) metadataMarker {
+	doc, _ := utils.ToDoc(entity.TokenUpdate{
+		ChainId:     mongodb.ChainId,
+		Type:        TOKEN_UPDATE,
+		Token:       token,
+		Standard:    standard,
+		BlockNumber: blockNumber,
+	})
+	return metadataMarker{id: mongodb.documentID(TOKEN_UPDATE, token), doc: doc}
+}
+
+// tokenMarkersFor returns the metadata update markers of the tokens and token ids referenced by the transfer and nft
+// delta documents of a removed block, together with the tokens. Markers consumed since the block was indexed are
+// queued again, so the metadata of the tokens it touched is retrieved against the canonical chain.
+func (mongodb *Mongo) tokenMarkersFor(docs []bson.Raw) ([][]byte, []metadataMarker, error) {
+	standards := map[string]string{
+		"erc20index":   TOKEN_STANDARD_ERC20,
+		"erc721index":  TOKEN_STANDARD_ERC721,
+		"erc1155index": TOKEN_STANDARD_ERC1155,
+	}
+
+	tokens := [][]byte{}
+	markers := []metadataMarker{}
+	seen := make(map[string]bool)
+	for _, raw := range docs {
+		docType, _ := raw.Lookup("type").StringValueOK()
+		if docType == NFT_DELTA {
+			delta := &entity.NFTDelta{}
+			if err := bson.Unmarshal(raw, delta); err != nil {
+				return nil, nil, fmt.Errorf("error decoding nft delta: %w", err)
+			}
+			marker := mongodb.nftMetadataUpdateMarker(&types.Eth1NFTDelta{Token: delta.Token, TokenId: delta.TokenId, Standard: delta.Standard, BlockNumber: delta.BlockNumber})
+			if !seen[marker.id] {
+				seen[marker.id] = true
+				markers = append(markers, marker)
+			}
+			continue
+		}
+
+		standard, ok := standards[docType]
+		if !ok {
+			continue
+		}
+		transfer := &entity.ERC20Index{}
+		if err := bson.Unmarshal(raw, transfer); err != nil {
+			return nil, nil, fmt.Errorf("error decoding %v document: %w", docType, err)
+		}
+		marker := mongodb.tokenUpdateMarker(transfer.TokenAddress, standard, transfer.BlockNumber)
+		if !seen[marker.id] {
+			seen[marker.id] = true
+			markers = append(markers, marker)
+			tokens = append(tokens, transfer.TokenAddress)
+		}
+	}
+	return tokens, markers, nil
+}
+
+func tokenFromEntity(token *entity.Token) *types.Eth1Token {
+	return &types.Eth1Token{
+		Address:     token.Address,
+		Standard:    token.Standard,
+		Name:        token.Name,
+		Symbol:      token.Symbol,
+		Decimals:    token.Decimals,
+		TotalSupply: token.TotalSupply,
+		Interfaces:  token.Interfaces,
+		FirstBlock:  token.FirstBlock,
+		UpdatedAt:   token.UpdatedAt,
+	}
+}
+
+// GetTokenUpdates returns up to limit queued tokens whose metadata has to be retrieved, the keys are passed to
+// SaveTokens to remove them from the queue
+func (mongodb *Mongo) GetTokenUpdates(limit int) ([]string, []*types.Eth1Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "type", Value: TOKEN_UPDATE}}
+	cursor, err := mongodb.Db.Collection(METADATA_UPDATES).Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving token updates: %w", err)
+	}
+
+	var updates []*entity.TokenUpdate
+	if err := cursor.All(ctx, &updates); err != nil {
+		return nil, nil, fmt.Errorf("error decoding token updates: %w", err)
+	}
+
+	keys := make([]string, 0, len(updates))
+	tokens := make([]*types.Eth1Token, 0, len(updates))
+	for _, update := range updates {
+		keys = append(keys, update.ID)
+		tokens = append(tokens, &types.Eth1Token{Address: update.Token, Standard: update.Standard, FirstBlock: update.BlockNumber})
+	}
+	return keys, tokens, nil
+}
+
+// SaveTokens stores the tokens in the token registry and removes the passed keys from the token update queue. The
+// first block of a token already in the registry is only lowered.
+func (mongodb *Mongo) SaveTokens(tokens []*types.Eth1Token, deleteKeys []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(tokens))
+	for _, token := range tokens {
+		filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "address", Value: token.Address}}
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "standard", Value: token.Standard},
+				{Key: "name", Value: token.Name},
+				{Key: "symbol", Value: token.Symbol},
+				{Key: "decimals", Value: token.Decimals},
+				{Key: "totalsupply", Value: token.TotalSupply},
+				{Key: "interfaces", Value: token.Interfaces},
+				{Key: "updatedat", Value: token.UpdatedAt},
+			}},
+			{Key: "$min", Value: bson.D{{Key: "firstblock", Value: token.FirstBlock}}},
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+	if len(models) > 0 {
+		_, err := mongodb.Db.Collection(TOKENS).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("error saving tokens: %w", err)
+		}
+	}
+
+	if len(deleteKeys) > 0 {
+		filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: deleteKeys}}}, {Key: "type", Value: TOKEN_UPDATE}}
+		_, err := mongodb.Db.Collection(METADATA_UPDATES).DeleteMany(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("error deleting token updates: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetToken returns the token registry entry of a token contract, mongo.ErrNoDocuments if it is unknown
+func (mongodb *Mongo) GetToken(address []byte) (*types.Eth1Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	token := &entity.Token{}
+	err := mongodb.Db.Collection(TOKENS).FindOne(ctx, bson.D{{Key: "chainid", Value: mongodb.ChainId}, {Key: "address", Value: address}}).Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	return tokenFromEntity(token), nil
+}
+
+// GetTokens returns up to limit tokens of the registry ordered by address, starting after startAddress. An empty standard
+// returns the tokens of all standards.
+func (mongodb *Mongo) GetTokens(standard string, startAddress []byte, limit int64) ([]*types.Eth1Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := bson.D{{Key: "chainid", Value: mongodb.ChainId}}
+	if standard != "" {
+		filter = append(filter, bson.E{Key: "standard", Value: standard})
+	}
+	if len(startAddress) > 0 {
+		filter = append(filter, bson.E{Key: "address", Value: bson.D{{Key: "$gt", Value: startAddress}}})
+	}
+
+	cursor, err := mongodb.Db.Collection(TOKENS).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "address", Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving tokens: %w", err)
+	}
+	var results []*entity.Token
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding tokens: %w", err)
+	}
+
+	tokens := make([]*types.Eth1Token, 0, len(results))
+	for _, result := range results {
+		tokens = append(tokens, tokenFromEntity(result))
+	}
+	return tokens, nil
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/erc721"
+	"github.com/Prajjawalk/zond-indexer/interfaces"
+	"github.com/Prajjawalk/zond-indexer/internal/testutil"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestEmbeddedTokenRegistry(t *testing.T) {
+	testTokenRegistry(t, testEmbedded(t))
+}
+
+func TestMongoTokenRegistry(t *testing.T) {
+	testTokenRegistry(t, testMongo(t))
+}
+
+// testTokenRegistry writes token transfers and checks the token update queue and the token registry of storage
+func testTokenRegistry(t *testing.T, storage interfaces.Database) {
+	alice := common.HexToAddress("0xa1")
+	bob := common.HexToAddress("0xb0")
+	coin := common.HexToAddress("0xe2")
+	nft := common.HexToAddress("0xe7")
+
+	transfers := func(number uint64) *types.Eth1Block {
+		return &types.Eth1Block{
+			Number: number,
+			Transactions: []*types.Eth1Transaction{{
+				Hash: common.BigToHash(new(big.Int).SetUint64(number + 1000)).Bytes(),
+				From: alice.Bytes(),
+				Logs: []*types.Eth1Log{
+					{
+						Address: coin.Bytes(),
+						Data:    common.LeftPadBytes(big.NewInt(1000).Bytes(), 32),
+						Topics:  [][]byte{erc20.TransferTopic, common.LeftPadBytes(alice.Bytes(), 32), common.LeftPadBytes(bob.Bytes(), 32)},
+					},
+					{
+						Address: nft.Bytes(),
+						Topics:  [][]byte{erc721.TransferTopic, common.LeftPadBytes(alice.Bytes(), 32), common.LeftPadBytes(bob.Bytes(), 32), common.LeftPadBytes(big.NewInt(7).Bytes(), 32)},
+					},
+				},
+			}},
+		}
+	}
+
+	// both blocks queue both tokens, the markers written for the earlier block 3 replace the ones of block 5
+	blocks := []*types.Eth1Block{transfers(5), transfers(3)}
+	if err := testutil.WriteFixtureBlocks(storage, blocks, storage.TransformERC20, storage.TransformERC721); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, queued, err := storage.GetTokenUpdates(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || len(queued) != 2 {
+		t.Fatalf("got %v queued tokens, want 2", len(queued))
+	}
+	standards := map[common.Address]string{coin: TOKEN_STANDARD_ERC20, nft: TOKEN_STANDARD_ERC721}
+	for _, token := range queued {
+		if want := standards[common.BytesToAddress(token.Address)]; token.Standard != want {
+			t.Errorf("queued token %x has standard %q, want %q", token.Address, token.Standard, want)
+		}
+		if token.FirstBlock != 3 {
+			t.Errorf("queued token %x was first seen at block %v, want 3", token.Address, token.FirstBlock)
+		}
+	}
+
+	// the token markers are not taken for balance update markers
+	_, pairs, err := storage.GetMetadataUpdates("1:B:", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range pairs {
+		if !bytes.Equal(pair.Token, coin.Bytes()) {
+			t.Errorf("got balance update of %x for token %x, want only balance updates for %x", pair.Address, pair.Token, coin)
+		}
+	}
+
+	for _, token := range queued {
+		token.Name = "Token " + token.Standard
+		token.UpdatedAt = time.Unix(1700000000, 0)
+	}
+	if err := storage.SaveTokens(queued, keys); err != nil {
+		t.Fatal(err)
+	}
+	keys, _, err = storage.GetTokenUpdates(10)
+	if err != nil || len(keys) != 0 {
+		t.Errorf("got %v queued tokens and error %v after saving the tokens, want none", len(keys), err)
+	}
+
+	// a later first sighting does not move the first block of a registered token
+	if err := storage.SaveTokens([]*types.Eth1Token{{Address: coin.Bytes(), Standard: TOKEN_STANDARD_ERC20, Name: "Coin", FirstBlock: 9}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	token, err := storage.GetToken(coin.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Name != "Coin" || token.FirstBlock != 3 {
+		t.Errorf("got token %q first seen at block %v, want %q first seen at block 3", token.Name, token.FirstBlock, "Coin")
+	}
+	if _, err := storage.GetToken(alice.Bytes()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("got error %v for an unknown token, want %v", err, mongo.ErrNoDocuments)
+	}
+
+	for _, tc := range []struct {
+		standard string
+		start    []byte
+		want     []common.Address
+	}{
+		{want: []common.Address{coin, nft}},
+		{standard: TOKEN_STANDARD_ERC721, want: []common.Address{nft}},
+		{start: coin.Bytes(), want: []common.Address{nft}},
+		{standard: TOKEN_STANDARD_ERC1155},
+	} {
+		tokens, err := storage.GetTokens(tc.standard, tc.start, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != len(tc.want) {
+			t.Errorf("got %v tokens of standard %q after %x, want %v", len(tokens), tc.standard, tc.start, len(tc.want))
+			continue
+		}
+		for i, token := range tokens {
+			if !bytes.Equal(token.Address, tc.want[i].Bytes()) {
+				t.Errorf("token %v of standard %q after %x is %x, want %x", i, tc.standard, tc.start, token.Address, tc.want[i])
+			}
+		}
+	}
+
+	// removing a block queues the tokens it touched again, tokens first seen in it are removed from the registry
+	if err := storage.DeleteBlock(5, blocks[0].Hash); err != nil {
+		t.Fatal(err)
+	}
+	keys, _, err = storage.GetTokenUpdates(10)
+	if err != nil || len(keys) != 2 {
+		t.Errorf("got %v queued tokens and error %v after removing block 5, want 2", len(keys), err)
+	}
+	if _, err := storage.GetToken(coin.Bytes()); err != nil {
+		t.Errorf("got error %v for a token first seen before the removed block, want it registered", err)
+	}
+	if err := storage.DeleteBlock(3, blocks[1].Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetToken(coin.Bytes()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("got error %v for a token first seen in the removed block, want %v", err, mongo.ErrNoDocuments)
+	}
+}
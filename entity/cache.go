@@ -5,6 +5,6 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 type Cache struct {
 	Key        []byte
 	Value      []byte
-	Expiration primitive.DateTime
+	Expiration primitive.DateTime // point in time after which the ttl index removes the entry
 	Type       string
 }
@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Checkpoint stores the highest block up to which all blocks have been processed by a named indexing job
+type Checkpoint struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId   string
+	Name      string
+	Block     uint64
+	UpdatedAt time.Time
+}
+
+// TransformCursor stores the highest block up to which all blocks have been processed by a version of a data transform
+type TransformCursor struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId   string
+	Name      string
+	Version   uint64
+	Block     uint64
+	UpdatedAt time.Time
+}
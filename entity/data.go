@@ -1,6 +1,9 @@
 package entity
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type BlockIndex struct {
 	ChainId                  string
@@ -122,3 +125,100 @@ type Indexes struct {
 	Key   string
 	Value string
 }
+
+// AddressIndex is a structured index document referencing an entity (transaction, internal transaction, token transfer, mined block
+// or withdrawal) from the perspective of one participating address. Display fields are denormalized so lists can be rendered
+// without joining the referenced entity.
+type AddressIndex struct {
+	ChainId          string
+	Type             string
+	Key              string
+	Kind             string
+	Address          []byte
+	Direction        string
+	Counterparty     []byte
+	Token            []byte
+	Method           []byte
+	BlockNumber      uint64
+	TxIndex          uint64
+	Position         uint64
+	Time             primitive.Timestamp
+	Hash             []byte
+	Value            []byte
+	TokenId          []byte
+	CallType         string
+	Failed           bool
+	ContractCreation bool
+}
+
+// BalanceDelta is the net change of the balance of an address for a token (0x00 for the native token) caused by the
+// transfers, fees and withdrawals of a block. Value is the absolute amount of the change.
+type BalanceDelta struct {
+	ChainId     string
+	Type        string
+	Address     []byte
+	Token       []byte
+	BlockNumber uint64
+	Time        primitive.Timestamp
+	Value       []byte
+	Negative    bool
+}
+
+// NFTDelta is the net change of the number of units of an ERC-721 or ERC-1155 token id held by an address caused by
+// the transfers of a block. Value is the absolute amount of the change.
+type NFTDelta struct {
+	ChainId     string
+	Type        string
+	Address     []byte
+	Token       []byte
+	TokenId     []byte
+	Standard    string
+	BlockNumber uint64
+	Value       []byte
+	Negative    bool
+}
+
+// NFTHolding is the number of units of an ERC-721 or ERC-1155 token id held by an address, the sum of its nft deltas.
+// Balance is a decimal so block writes and rollbacks can add to it in place.
+type NFTHolding struct {
+	ChainId  string
+	Type     string
+	Address  []byte
+	Token    []byte
+	TokenId  []byte
+	Standard string
+	Balance  primitive.Decimal128
+}
+
+// ContractEvent is a decoded log of a contract event index, Params holds the event parameters in abi order
+type ContractEvent struct {
+	ChainId     string
+	Type        string
+	Contract    []byte
+	Event       string
+	BlockNumber uint64
+	Time        primitive.Timestamp
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+	Params      bson.D
+}
+
+// Log is a receipt log of the generic log index. The topics are stored by position to query them with an index,
+// LogIndex is the position of the log in its block.
+type Log struct {
+	ChainId     string
+	Type        string
+	Address     []byte
+	Topic0      []byte
+	Topic1      []byte
+	Topic2      []byte
+	Topic3      []byte
+	TopicCount  uint64
+	Data        []byte
+	BlockNumber uint64
+	BlockHash   []byte
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+}
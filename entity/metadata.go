@@ -1,7 +1,7 @@
 package entity
 
 import (
-	"math/big"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -11,17 +11,31 @@ type AccountMetadataFamily struct {
 	ChainId string
 	Type    string
 	Name    string
-	Balance big.Int
-	Token   string
-	Address string
+	Balance []byte
+	// BlockNumber is the block the balance has been retrieved at
+	BlockNumber uint64
+	Token       string
+	Address     string
 }
 
 type ContractMetadataFamily struct {
 	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	ChainId string
+	Type    string
 	Name    string
 	Abi     []byte
 	Address string
+	// CodeHash is the keccak256 hash of the runtime bytecode of verified code, Address is empty for verified code
+	CodeHash         string           `bson:",omitempty"`
+	Compiler         string           `bson:",omitempty"`
+	CompilerMetadata []byte           `bson:",omitempty"`
+	Sources          []ContractSource `bson:",omitempty"`
+}
+
+// ContractSource is a source file of a verified contract, the paths are not used as keys as they contain dots
+type ContractSource struct {
+	Path    string
+	Content string
 }
 
 type ERC20MetadataFamily struct {
@@ -76,7 +90,8 @@ type Series struct {
 }
 
 type BalanceUpdates struct {
-	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	// ID is the deterministic id of the address and token pair, markers written before had random object ids
+	ID       interface{} `json:"_id,omitempty" bson:"_id,omitempty"`
 	ChainId  string
 	Type     string
 	Key      string
@@ -94,3 +109,58 @@ type BlockMetadataUpdates struct {
 	Type        string
 	Keys        string
 }
+
+// TokenUpdate marks a token contract seen in the transfers of a block whose metadata has to be retrieved
+type TokenUpdate struct {
+	ID          string `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId     string
+	Type        string
+	Token       []byte
+	Standard    string
+	BlockNumber uint64
+}
+
+// NFTMetadataUpdate marks a token id seen in the transfers of a block whose metadata has to be retrieved
+type NFTMetadataUpdate struct {
+	ID          string `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId     string
+	Type        string
+	Token       []byte
+	TokenId     []byte
+	Standard    string
+	BlockNumber uint64
+}
+
+// NFTMetadata is the metadata uri of an ERC-721 or ERC-1155 token id and the JSON metadata it resolves to, if the uri
+// embeds it
+type NFTMetadata struct {
+	ChainId   string
+	Type      string
+	Token     []byte
+	TokenId   []byte
+	Standard  string
+	URI       string
+	Metadata  []byte
+	UpdatedAt time.Time
+}
+
+// Token is an entry of the token registry listing every token contract seen in the indexed transfers
+type Token struct {
+	ChainId     string
+	Address     []byte
+	Standard    string
+	Name        string
+	Symbol      string
+	Decimals    []byte
+	TotalSupply []byte
+	Interfaces  []string
+	FirstBlock  uint64
+	UpdatedAt   time.Time
+}
+
+// Signature is a text signature of the selector signature database, signatures aren't specific to a chain
+type Signature struct {
+	Selector  []byte
+	Signature string
+	Event     bool
+}
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BlockJournal records the ids of every document written while indexing a block, keyed by collection name,
+// so all of them can be removed if the block is reorged out of the chain
+type BlockJournal struct {
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId     string
+	BlockNumber uint64
+	BlockHash   []byte
+	Writes      map[string][]interface{}
+	UpdatedAt   time.Time
+}
+
+// Reorg describes a chain reorganization detected by the indexer
+type Reorg struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ChainId    string
+	DetectedAt time.Time
+	// ForkBlock is the first block that differed between the indexed and the canonical chain
+	ForkBlock uint64
+	Depth     uint64
+	Blocks    []ReorgBlock
+}
+
+// ReorgBlock is a height replaced by a reorg with the hash of the removed and of the canonical block
+type ReorgBlock struct {
+	Number  uint64
+	OldHash []byte
+	NewHash []byte
+}
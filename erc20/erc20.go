@@ -34,15 +34,22 @@ func InitTokenList(path string) {
 	}
 
 	for _, token := range TokenList.Tokens {
-		address := strings.Replace(token.Address, "0x", "", -1)
-		address = strings.ToLower(address)
-		tokenMap[address] = token
+		address, err := utils.ParseAddress(token.Address)
+		if err != nil {
+			logger.Warnf("skipping token %v with invalid address: %v", token.Symbol, err)
+			continue
+		}
+		tokenMap[address.Hex()] = token
 		// logger.Info(address)
 	}
 
 }
 
+// GetTokenDetail returns the token list entry for a Z or 0x prefixed (or unprefixed) address
 func GetTokenDetail(address string) *ERC20TokenDetail {
+	if a, err := utils.ParseAddress(address); err == nil {
+		return tokenMap[a.Hex()]
+	}
 	return tokenMap[address]
 }
 
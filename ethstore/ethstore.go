@@ -2,19 +2,22 @@ package ethstore
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
+	nethttp "net/http"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/Prajjawalk/zond-indexer/utils"
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/http"
-	"github.com/attestantio/go-eth2-client/spec"
-	"github.com/attestantio/go-eth2-client/spec/bellatrix"
-	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -55,7 +58,7 @@ type Day struct {
 
 type Validator struct {
 	Index                phase0.ValidatorIndex
-	Pubkey               phase0.BLSPubKey
+	Pubkey               []byte // raw public key, the length depends on the signature scheme of the chain
 	EffectiveBalanceGwei phase0.Gwei
 	StartBalanceGwei     phase0.Gwei
 	EndBalanceGwei       phase0.Gwei
@@ -156,7 +159,21 @@ func GetHeadDay(ctx context.Context, address string) (uint64, error) {
 	return day, nil
 }
 
-func GetValidators(ctx context.Context, client *http.Service, stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+// BeaconValidator is a validator as returned by the validators endpoint of the Zond beacon api. The public key is kept
+// as returned by the node, dilithium keys do not fit the fixed size bls key of the eth2 client types.
+type BeaconValidator struct {
+	Index     phase0.ValidatorIndex `json:"index,string"`
+	Balance   phase0.Gwei           `json:"balance,string"`
+	Status    v1.ValidatorState     `json:"status"`
+	Validator struct {
+		Pubkey           hexutil.Bytes `json:"pubkey"`
+		EffectiveBalance phase0.Gwei   `json:"effective_balance,string"`
+		ExitEpoch        phase0.Epoch  `json:"exit_epoch,string"`
+	} `json:"validator"`
+}
+
+// GetValidators returns the validators of a state keyed by their index
+func GetValidators(ctx context.Context, address string, stateID string) (map[phase0.ValidatorIndex]*BeaconValidator, error) {
 	validatorsCacheMu.Lock()
 	defer validatorsCacheMu.Unlock()
 
@@ -168,62 +185,196 @@ func GetValidators(ctx context.Context, client *http.Service, stateID string) (m
 		validatorsCache = c
 	}
 
-	key := fmt.Sprintf("%s:%s", client.Address(), stateID)
+	key := fmt.Sprintf("%s:%s", address, stateID)
 	val, found := validatorsCache.Get(key)
 	if found {
-		return val.(map[phase0.ValidatorIndex]*v1.Validator), nil
+		return val.(map[phase0.ValidatorIndex]*BeaconValidator), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, GetConsTimeout())
+	defer cancel()
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, fmt.Sprintf("%s/eth/v1/beacon/states/%s/validators", address, stateID), nil)
+	if err != nil {
+		return nil, err
 	}
-	vals, err := client.Validators(ctx, stateID, nil)
+	resp, err := nethttp.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error getting validators for slot %v: %w", stateID, err)
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error getting validators for slot %v: http %v: %s", stateID, resp.StatusCode, body)
+	}
+
+	parsed := struct {
+		Data []*BeaconValidator `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("error parsing validators for slot %v: %w", stateID, err)
+	}
+	vals := make(map[phase0.ValidatorIndex]*BeaconValidator, len(parsed.Data))
+	for _, v := range parsed.Data {
+		vals[v.Index] = v
+	}
 	validatorsCache.Add(key, vals)
 	return vals, nil
 }
 
+// BlockDeposit is a deposit of a beacon block. The public key and signature are kept as returned by the node, their
+// length depends on the signature scheme of the chain.
+type BlockDeposit struct {
+	Data struct {
+		Pubkey                hexutil.Bytes `json:"pubkey"`
+		WithdrawalCredentials hexutil.Bytes `json:"withdrawal_credentials"`
+		Amount                phase0.Gwei   `json:"amount,string"`
+		Signature             hexutil.Bytes `json:"signature"`
+	} `json:"data"`
+}
+
+// BlockWithdrawal is a withdrawal of the execution payload of a beacon block
+type BlockWithdrawal struct {
+	ValidatorIndex phase0.ValidatorIndex `json:"validator_index,string"`
+	Amount         phase0.Gwei           `json:"amount,string"`
+}
+
 type BlockData struct {
 	ProposerIndex phase0.ValidatorIndex
-	Transactions  []bellatrix.Transaction
-	BaseFeePerGas [32]byte
-	Deposits      []*phase0.Deposit
+	Transactions  []hexutil.Bytes
+	BaseFeePerGas *big.Int
+	Deposits      []*BlockDeposit
 	GasUsed       uint64
 	GasLimit      uint64
-	Withdrawals   []*capella.Withdrawal
+	Withdrawals   []*BlockWithdrawal
 	BlockNumber   uint64
 }
 
-func GetBlockData(block *spec.VersionedSignedBeaconBlock) (*BlockData, error) {
-	d := &BlockData{}
-	switch block.Version {
-	case spec.DataVersionPhase0:
-		d.Deposits = block.Phase0.Message.Body.Deposits
-		d.ProposerIndex = block.Phase0.Message.ProposerIndex
-	case spec.DataVersionAltair:
-		d.Deposits = block.Altair.Message.Body.Deposits
-		d.ProposerIndex = block.Altair.Message.ProposerIndex
-	case spec.DataVersionBellatrix:
-		d.Deposits = block.Bellatrix.Message.Body.Deposits
-		d.ProposerIndex = block.Bellatrix.Message.ProposerIndex
-		d.GasUsed = block.Bellatrix.Message.Body.ExecutionPayload.GasUsed
-		d.GasLimit = block.Bellatrix.Message.Body.ExecutionPayload.GasLimit
-		d.BaseFeePerGas = block.Bellatrix.Message.Body.ExecutionPayload.BaseFeePerGas
-		d.BlockNumber = block.Bellatrix.Message.Body.ExecutionPayload.BlockNumber
-		d.Transactions = block.Bellatrix.Message.Body.ExecutionPayload.Transactions
-	case spec.DataVersionCapella:
-		d.Deposits = block.Capella.Message.Body.Deposits
-		d.ProposerIndex = block.Capella.Message.ProposerIndex
-		d.GasUsed = block.Capella.Message.Body.ExecutionPayload.GasUsed
-		d.GasLimit = block.Capella.Message.Body.ExecutionPayload.GasLimit
-		d.BaseFeePerGas = block.Capella.Message.Body.ExecutionPayload.BaseFeePerGas
-		d.Withdrawals = block.Capella.Message.Body.ExecutionPayload.Withdrawals
-		d.BlockNumber = block.Capella.Message.Body.ExecutionPayload.BlockNumber
-		d.Transactions = block.Capella.Message.Body.ExecutionPayload.Transactions
-	default:
-		return nil, fmt.Errorf("unknown block version: %v", block.Version)
+// GetBlockData returns the data of the block at a slot from the blocks endpoint of the beacon api, or nil if the slot
+// is empty. The block is decoded independently of its fork version since the eth2 client types only hold bls keys.
+func GetBlockData(ctx context.Context, address string, slot uint64) (*BlockData, error) {
+	ctx, cancel := context.WithTimeout(ctx, GetConsTimeout())
+	defer cancel()
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, fmt.Sprintf("%s/eth/v2/beacon/blocks/%d", address, slot), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := nethttp.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting block for slot %v: %w", slot, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == nethttp.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error getting block for slot %v: http %v: %s", slot, resp.StatusCode, body)
+	}
+
+	parsed := struct {
+		Data struct {
+			Message struct {
+				ProposerIndex phase0.ValidatorIndex `json:"proposer_index,string"`
+				Body          struct {
+					Deposits         []*BlockDeposit `json:"deposits"`
+					ExecutionPayload *struct {
+						BlockNumber   uint64             `json:"block_number,string"`
+						GasLimit      uint64             `json:"gas_limit,string"`
+						GasUsed       uint64             `json:"gas_used,string"`
+						BaseFeePerGas string             `json:"base_fee_per_gas"`
+						Transactions  []hexutil.Bytes    `json:"transactions"`
+						Withdrawals   []*BlockWithdrawal `json:"withdrawals"`
+					} `json:"execution_payload"`
+				} `json:"body"`
+			} `json:"message"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("error parsing block for slot %v: %w", slot, err)
+	}
+
+	message := parsed.Data.Message
+	d := &BlockData{
+		ProposerIndex: message.ProposerIndex,
+		Deposits:      message.Body.Deposits,
+		BaseFeePerGas: new(big.Int),
+	}
+	if payload := message.Body.ExecutionPayload; payload != nil {
+		// the base fee is rendered as a decimal number by the beacon api
+		if _, ok := d.BaseFeePerGas.SetString(payload.BaseFeePerGas, 10); !ok {
+			return nil, fmt.Errorf("invalid base_fee_per_gas %q of block at slot %v", payload.BaseFeePerGas, slot)
+		}
+		d.BlockNumber = payload.BlockNumber
+		d.GasLimit = payload.GasLimit
+		d.GasUsed = payload.GasUsed
+		d.Transactions = payload.Transactions
+		d.Withdrawals = payload.Withdrawals
 	}
 	return d, nil
 }
 
+// verifyDepositSignature checks the signature of a deposit with the signature scheme of the chain
+func verifyDepositSignature(d *BlockDeposit, domain []byte) error {
+	if utils.SignatureScheme() != utils.SignatureSchemeDilithium {
+		return deposit.VerifyDepositSignature(&ethpb.Deposit_Data{
+			PublicKey:             d.Data.Pubkey,
+			WithdrawalCredentials: d.Data.WithdrawalCredentials,
+			Amount:                uint64(d.Data.Amount),
+			Signature:             d.Data.Signature,
+		}, domain)
+	}
+
+	if len(d.Data.Pubkey) != utils.DilithiumPubkeyLength {
+		return fmt.Errorf("invalid deposit pubkey length %d", len(d.Data.Pubkey))
+	}
+	if len(d.Data.WithdrawalCredentials) != 32 {
+		return fmt.Errorf("invalid deposit withdrawal credentials length %d", len(d.Data.WithdrawalCredentials))
+	}
+	// signing root of the deposit message (pubkey, withdrawal_credentials, amount) as defined by the consensus specs
+	amount := make([]byte, 32)
+	binary.LittleEndian.PutUint64(amount, uint64(d.Data.Amount))
+	messageRoot := merkleize([][]byte{merkleize(chunkify(d.Data.Pubkey)), d.Data.WithdrawalCredentials, amount})
+	signingRoot := merkleize([][]byte{messageRoot, domain})
+	if !utils.VerifyDilithiumSignature(d.Data.Pubkey, signingRoot, d.Data.Signature) {
+		return fmt.Errorf("invalid dilithium deposit signature")
+	}
+	return nil
+}
+
+// chunkify splits b into zero padded 32 byte chunks
+func chunkify(b []byte) [][]byte {
+	chunks := make([][]byte, 0, (len(b)+31)/32)
+	for i := 0; i < len(b); i += 32 {
+		chunk := make([]byte, 32)
+		copy(chunk, b[i:])
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// merkleize returns the ssz merkle root of 32 byte chunks, padded with zero chunks to the next power of two
+func merkleize(chunks [][]byte) []byte {
+	if len(chunks) == 0 {
+		return make([]byte, 32)
+	}
+	layer := chunks
+	zero := make([]byte, 32) // root of an all zero subtree of the current depth
+	for len(layer) > 1 {
+		if len(layer)%2 == 1 {
+			layer = append(layer, zero)
+		}
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			h := sha256.Sum256(append(append([]byte{}, layer[2*i]...), layer[2*i+1]...))
+			next[i] = h[:]
+		}
+		h := sha256.Sum256(append(append([]byte{}, zero...), zero...))
+		zero = h[:]
+		layer = next
+	}
+	return layer[0]
+}
+
 func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurrency int) (*Day, map[uint64]*Day, error) {
 	gethRpcClient, err := gethRPC.Dial(elAddress)
 	if err != nil {
@@ -333,9 +484,9 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 	}
 
 	validatorsByIndex := map[phase0.ValidatorIndex]*Validator{}
-	validatorsByPubkey := map[phase0.BLSPubKey]*Validator{}
+	validatorsByPubkey := map[string]*Validator{} // keyed by the raw pubkey bytes
 
-	startValidators, err := GetValidators(ctx, client, fmt.Sprintf("%d", firstSlot))
+	startValidators, err := GetValidators(ctx, bnAddress, fmt.Sprintf("%d", firstSlot))
 	if err != nil {
 		return nil, nil, fmt.Errorf("error getting startValidators for firstSlot %d: %w", firstSlot, err)
 	}
@@ -346,16 +497,16 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 		}
 		vv := &Validator{
 			Index:                val.Index,
-			Pubkey:               val.Validator.PublicKey,
+			Pubkey:               val.Validator.Pubkey,
 			EffectiveBalanceGwei: val.Validator.EffectiveBalance,
 			StartBalanceGwei:     val.Balance,
 			TxFeesSumWei:         new(big.Int),
 		}
 		validatorsByIndex[val.Index] = vv
-		validatorsByPubkey[val.Validator.PublicKey] = vv
+		validatorsByPubkey[string(val.Validator.Pubkey)] = vv
 	}
 
-	endValidators, err := GetValidators(ctx, client, fmt.Sprintf("%d", endSlot))
+	endValidators, err := GetValidators(ctx, bnAddress, fmt.Sprintf("%d", endSlot))
 	if err != nil {
 		return nil, nil, fmt.Errorf("error getting endValidators for endSlot %d: %w", endSlot, err)
 	}
@@ -368,7 +519,7 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 		if uint64(val.Validator.ExitEpoch) < endEpoch {
 			// do not account validators that have not been active until the end of the day
 			delete(validatorsByIndex, val.Index)
-			delete(validatorsByPubkey, val.Validator.PublicKey)
+			delete(validatorsByPubkey, string(val.Validator.Pubkey))
 			continue
 		}
 		// set endBalance of validator to the balance of the first epoch of the next day
@@ -389,10 +540,10 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 			log.Printf("DEBUG eth.store: checking blocks for deposits and txs: %.0f%% (%v of %v-%v)\n", 100*float64(i-firstSlot)/float64(endSlot-firstSlot), i, firstSlot, endSlot)
 		}
 		g.Go(func() error {
-			var block *spec.VersionedSignedBeaconBlock
+			var blockData *BlockData
 			var err error
 			for j := 0; j < 10; j++ { // retry up to 10 times on failure
-				block, err = client.SignedBeaconBlock(ctx, fmt.Sprintf("%d", i))
+				blockData, err = GetBlockData(ctx, bnAddress, i)
 
 				if err == nil {
 					break
@@ -404,13 +555,9 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 			if err != nil {
 				return fmt.Errorf("error getting block %v: %w", i, err)
 			}
-			if block == nil {
+			if blockData == nil {
 				return nil
 			}
-			blockData, err := GetBlockData(block)
-			if err != nil {
-				return fmt.Errorf("error getting blockData for block at slot %v: %w", i, err)
-			}
 
 			v, exists := validatorsByIndex[blockData.ProposerIndex]
 			// only calculate for validators that have been active the whole day
@@ -451,13 +598,7 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 					totalTxFee.Add(totalTxFee, txFee)
 				}
 
-				// base fee per gas is stored little-endian but we need it
-				// big-endian for big.Int.
-				var baseFeePerGasBEBytes [32]byte
-				for i := 0; i < 32; i++ {
-					baseFeePerGasBEBytes[i] = blockData.BaseFeePerGas[32-1-i]
-				}
-				baseFeePerGas := new(big.Int).SetBytes(baseFeePerGasBEBytes[:])
+				baseFeePerGas := blockData.BaseFeePerGas
 				burntFee := new(big.Int).Mul(baseFeePerGas, new(big.Int).SetUint64(blockData.GasUsed))
 
 				totalTxFee.Sub(totalTxFee, burntFee)
@@ -474,18 +615,12 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 			validatorsMu.Lock()
 			defer validatorsMu.Unlock()
 			for _, d := range blockData.Deposits {
-				v, exists := validatorsByPubkey[d.Data.PublicKey]
+				v, exists := validatorsByPubkey[string(d.Data.Pubkey)]
 				if !exists {
 					// only calculate for validators that have been active the whole day
 					continue
 				}
-				msg := &ethpb.Deposit_Data{
-					PublicKey:             d.Data.PublicKey[:],
-					WithdrawalCredentials: d.Data.WithdrawalCredentials,
-					Amount:                uint64(d.Data.Amount),
-					Signature:             d.Data.Signature[:],
-				}
-				err := deposit.VerifyDepositSignature(msg, depositDomainComputed)
+				err := verifyDepositSignature(d, depositDomainComputed)
 				if err != nil {
 					if GetDebugLevel() > 0 {
 						log.Printf("DEBUG eth.store: invalid deposit signature in block %d: %v", i, err)
@@ -493,7 +628,7 @@ func Calculate(ctx context.Context, bnAddress, elAddress, dayStr string, concurr
 					continue
 				}
 				if GetDebugLevel() > 0 {
-					log.Printf("DEBUG eth.store: extra deposit at block %d from %v: %#x: %v\n", i, v.Index, d.Data.PublicKey, d.Data.Amount)
+					log.Printf("DEBUG eth.store: extra deposit at block %d from %v: %#x: %v\n", i, v.Index, d.Data.Pubkey, d.Data.Amount)
 				}
 				v.DepositsSumGwei += d.Data.Amount
 			}
@@ -0,0 +1,104 @@
+package ethstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/cloudflare/circl/sign/dilithium/mode5"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+func TestMerkleizeDepositMessage(t *testing.T) {
+	msg := &ethpb.DepositMessage{
+		PublicKey:             bytes.Repeat([]byte{0xab}, utils.BLSPubkeyLength),
+		WithdrawalCredentials: bytes.Repeat([]byte{0x01}, 32),
+		Amount:                32000000000,
+	}
+	want, err := msg.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amount := make([]byte, 32)
+	binary.LittleEndian.PutUint64(amount, msg.Amount)
+	got := merkleize([][]byte{merkleize(chunkify(msg.PublicKey)), msg.WithdrawalCredentials, amount})
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("merkleize() = %x, want %x", got, want)
+	}
+}
+
+func TestVerifyDilithiumDepositSignature(t *testing.T) {
+	utils.Config = &types.Config{}
+	utils.Config.Chain.SignatureScheme = utils.SignatureSchemeDilithium
+	defer func() { utils.Config = nil }()
+
+	pk, sk, err := mode5.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := bytes.Repeat([]byte{0x03}, 32)
+
+	d := &BlockDeposit{}
+	d.Data.Pubkey = pk.Bytes()
+	d.Data.WithdrawalCredentials = bytes.Repeat([]byte{0x01}, 32)
+	d.Data.Amount = 40000000000
+
+	amount := make([]byte, 32)
+	binary.LittleEndian.PutUint64(amount, uint64(d.Data.Amount))
+	messageRoot := merkleize([][]byte{merkleize(chunkify(d.Data.Pubkey)), d.Data.WithdrawalCredentials, amount})
+	signature := make([]byte, mode5.SignatureSize)
+	mode5.SignTo(sk, merkleize([][]byte{messageRoot, domain}), signature)
+	d.Data.Signature = signature
+
+	if err := verifyDepositSignature(d, domain); err != nil {
+		t.Errorf("verifyDepositSignature() error = %v, want nil", err)
+	}
+
+	d.Data.Amount++
+	if err := verifyDepositSignature(d, domain); err == nil {
+		t.Errorf("verifyDepositSignature() of a modified deposit returned no error")
+	}
+}
+
+func TestGetBlockData(t *testing.T) {
+	pubkey := bytes.Repeat([]byte{0xcd}, utils.DilithiumPubkeyLength)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eth/v2/beacon/blocks/7" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"version":"capella","data":{"message":{"slot":"7","proposer_index":"12","body":{
+			"deposits":[{"proof":[],"data":{"pubkey":"0x%x","withdrawal_credentials":"0x%x","amount":"32000000000","signature":"0x00"}}],
+			"execution_payload":{"block_number":"5","gas_limit":"30000000","gas_used":"21000","base_fee_per_gas":"1000000007","transactions":["0x02"],
+			"withdrawals":[{"index":"1","validator_index":"3","address":"0x0000000000000000000000000000000000000001","amount":"12"}]}}}}}`,
+			pubkey, bytes.Repeat([]byte{0x01}, 32))
+	}))
+	defer server.Close()
+
+	d, err := GetBlockData(context.Background(), server.URL, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ProposerIndex != 12 || d.BlockNumber != 5 || d.GasUsed != 21000 || d.BaseFeePerGas.String() != "1000000007" {
+		t.Errorf("unexpected block data %+v", d)
+	}
+	if len(d.Deposits) != 1 || !bytes.Equal(d.Deposits[0].Data.Pubkey, pubkey) || d.Deposits[0].Data.Amount != 32000000000 {
+		t.Errorf("unexpected deposits %+v", d.Deposits)
+	}
+	if len(d.Withdrawals) != 1 || d.Withdrawals[0].ValidatorIndex != 3 || d.Withdrawals[0].Amount != 12 {
+		t.Errorf("unexpected withdrawals %+v", d.Withdrawals)
+	}
+
+	d, err = GetBlockData(context.Background(), server.URL, 8)
+	if err != nil || d != nil {
+		t.Errorf("GetBlockData() of an empty slot = %v, %v, want nil, nil", d, err)
+	}
+}
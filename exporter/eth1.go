@@ -39,7 +39,11 @@ var gethRequestEntityTooLargeRE = regexp.MustCompile("413 Request Entity Too Lar
 // If a reorg of the eth1-chain happened within these 100 blocks it will delete
 // removed deposits.
 func eth1DepositsExporter() {
-	eth1DepositContractAddress = common.HexToAddress(utils.Config.Chain.Config.DepositContractAddress)
+	depositContractAddress, err := utils.ParseAddress(utils.Config.Chain.Config.DepositContractAddress)
+	if err != nil {
+		utils.LogFatal(err, "invalid deposit contract address", 0)
+	}
+	eth1DepositContractAddress = common.BytesToAddress(depositContractAddress.Bytes())
 	eth1DepositContractFirstBlock = utils.Config.Indexer.Eth1DepositContractFirstBlock
 
 	rpcClient, err := gethRPC.Dial(utils.Config.Eth1GethEndpoint)
@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/db"
+	"github.com/Prajjawalk/zond-indexer/rpc"
+	"github.com/Prajjawalk/zond-indexer/services"
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestExportEpochReplay exports epoch 1 of the hand-written zond fixtures in rpc/testdata/zond-devnet-fake. It writes to the
+// mongodb deployment in MONGODB_TEST_URL and to the postgres database in POSTGRES_TEST_URL, which must be a database
+// for tests only as the migrations are applied to it, and is skipped if either is not set.
+func TestExportEpochReplay(t *testing.T) {
+	mongoURL, postgresURL := os.Getenv("MONGODB_TEST_URL"), os.Getenv("POSTGRES_TEST_URL")
+	if mongoURL == "" || postgresURL == "" {
+		t.Skip("MONGODB_TEST_URL or POSTGRES_TEST_URL is not set")
+	}
+
+	fixtures := "../rpc/testdata/zond-devnet-fake"
+	cfg := &types.Config{}
+	cfg.Chain.ConfigPath = filepath.Join(fixtures, "chain.yml")
+	if err := utils.ReadConfig(cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.Config
+	utils.Config = cfg
+	defer func() { utils.Config = previous }()
+
+	postgres, err := sqlx.Open("pgx", postgresURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.Close()
+	db.WriterDb, db.ReaderDb = postgres, postgres
+	if err := db.ApplyEmbeddedDbSchema(-2); err != nil {
+		t.Fatal(err)
+	}
+
+	mongodb, err := db.InitMongodb(mongoURL, fmt.Sprintf("test_%d", time.Now().UnixNano()), "1337")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := mongodb.Db.Drop(context.Background()); err != nil {
+			t.Error(err)
+		}
+		mongodb.Close()
+	}()
+
+	if err := services.InitLastAttestationCache(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	rpc.LighthouseLatestHeadEpoch = 0
+	client, err := rpc.NewReplayingLighthouseClient(filepath.Join(fixtures, "beacon"), big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportEpoch(1, client); err != nil {
+		t.Fatal(err)
+	}
+
+	balances, err := mongodb.Db.Collection(db.BEACON_CHAIN).CountDocuments(context.Background(), bson.M{"type": db.VALIDATOR_BALANCES_FAMILY, "epoch": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances != 4 {
+		t.Errorf("got %v validator balances of epoch 1 in mongodb, want 4", balances)
+	}
+
+	var slots []uint64
+	if err := postgres.Select(&slots, "SELECT slot FROM blocks WHERE epoch = 1 ORDER BY slot"); err != nil {
+		t.Fatal(err)
+	}
+	// the missed block of slot 6 is stored as well
+	if fmt.Sprint(slots) != "[4 5 6 7]" {
+		t.Errorf("got blocks of slots %v in epoch 1, want [4 5 6 7]", slots)
+	}
+	var epoch struct {
+		Validators   uint64 `db:"validatorscount"`
+		Attestations uint64 `db:"attestationscount"`
+	}
+	if err := postgres.Get(&epoch, "SELECT validatorscount, attestationscount FROM epochs WHERE epoch = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if epoch.Validators != 4 || epoch.Attestations != 2 {
+		t.Errorf("got %v validators and %v attestations in epoch 1, want 4 and 2", epoch.Validators, epoch.Attestations)
+	}
+}
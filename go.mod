@@ -6,6 +6,7 @@ require (
 	cloud.google.com/go/secretmanager v1.10.1
 	github.com/alexedwards/scs/redisstore v0.0.0-20230327161757-10d4299e3b24
 	github.com/alexedwards/scs/v2 v2.5.1
+	github.com/cloudflare/circl v1.3.3
 	github.com/davecgh/go-spew v1.1.1
 	github.com/donovanhide/eventsource v0.0.0-20210830082556-c59027999da0
 	github.com/gomodule/redigo v1.8.9
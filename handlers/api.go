@@ -641,7 +641,7 @@ func ApiValidatorLeaderboard(w http.ResponseWriter, r *http.Request) {
 // @Summary Get all eth1 deposits for up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorDepositsResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/deposits [get]
@@ -675,7 +675,7 @@ func ApiValidatorDeposits(w http.ResponseWriter, r *http.Request) {
 // @Summary Get all attestations during the last 10 epochs for up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{[]types.ApiValidatorAttestationsResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/attestations [get]
@@ -743,7 +743,7 @@ func ApiValidatorAttestations(w http.ResponseWriter, r *http.Request) {
 // @Summary Get all proposed blocks during the last 100 epochs for up to 100 validators. Optionally set the epoch query parameter to look back further.
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Param  epoch query string false "Page the result by epoch"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorProposalsResponse}
 // @Failure 400 {object} types.ApiResponse
@@ -832,7 +832,7 @@ func ApiValidatorProposals(w http.ResponseWriter, r *http.Request) {
 // @Summary Get the income detail history (last 100 epochs) of up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorIncomeHistoryResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/incomedetailhistory [get]
@@ -910,7 +910,7 @@ func ApiValidatorIncomeDetailsHistory(w http.ResponseWriter, r *http.Request) {
 // @Summary Get the withdrawal history of up to 100 validators for the last 100 epochs. To receive older withdrawals modify the epoch paraum
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Param  epoch query int false "the start epoch for the withdrawal history (default: latest epoch)"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorWithdrawalResponse}
 // @Failure 400 {object} types.ApiResponse
@@ -980,7 +980,7 @@ func ApiValidatorWithdrawals(w http.ResponseWriter, r *http.Request) {
 // @Summary Get the balance history of up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Param  latest_epoch query int false "The latest epoch to consider in the query"
 // @Param  offset query int false "Number of items to skip"
 // @Param  limit query int false "Maximum number of items to return, up to 100"
@@ -1092,7 +1092,7 @@ func getBalanceHistoryQueryParameters(q url.Values) (uint64, uint64, error) {
 // @Summary Get the current consensus reward performance of up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorPerformanceResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/performance [get]
@@ -1168,7 +1168,7 @@ func ApiValidatorPerformance(w http.ResponseWriter, r *http.Request) {
 // @Summary DEPRECIATED - USE /attestationefficiency (Get the current performance of up to 100 validators)
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/attestationeffectiveness [get]
@@ -1210,7 +1210,7 @@ func ApiValidatorAttestationEffectiveness(w http.ResponseWriter, r *http.Request
 // @Summary Get the current performance of up to 100 validators
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/attestationefficiency [get]
@@ -1264,7 +1264,7 @@ func validatorEffectiveness(epoch uint64, indices []uint64) ([]*types.ValidatorE
 // @Summary Get the current execution reward performance of up to 100 validators. If block was produced via mev relayer, this endpoint will use the relayer data as block reward instead of the normal block reward.
 // @Tags Validator
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorExecutionPerformanceResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey}/execution/performance [get]
@@ -1296,7 +1296,7 @@ func ApiValidatorExecutionPerformance(w http.ResponseWriter, r *http.Request) {
 // @Tags Validator
 // @Description Searching for too many validators based on their pubkeys will lead to an "URI too long" error
 // @Produce  json
-// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys, comma separated"
+// @Param  indexOrPubkey path string true "Up to 100 validator indicesOrPubkeys (full key or its sha256 short form), comma separated"
 // @Success 200 {object} types.ApiResponse{data=[]types.APIValidatorResponse}
 // @Failure 400 {object} types.ApiResponse
 // @Router /api/v1/validator/{indexOrPubkey} [get]
@@ -1523,7 +1523,7 @@ func ApiValidatorDailyStats(w http.ResponseWriter, r *http.Request) {
 // @Summary Get all validators that belong to an eth1 address
 // @Tags Validator
 // @Produce  json
-// @Param  eth1address path string true "Eth1 address from which the validator deposits were sent"
+// @Param  eth1address path string true "Eth1 address from which the validator deposits were sent, Z or 0x prefixed"
 // @Param limit query string false "Limit the number of results (default: 2000)"
 // @Param offset query string false "Offset the results (default: 0)"
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiValidatorEth1Response}
@@ -1556,13 +1556,13 @@ func ApiValidatorByEth1Address(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 
-	eth1Address, err := hex.DecodeString(strings.Replace(vars["address"], "0x", "", -1))
+	eth1Address, err := utils.ParseAddress(vars["address"])
 	if err != nil {
 		sendErrorResponse(w, r.URL.String(), "invalid eth1 address provided")
 		return
 	}
 
-	rows, err := db.ReaderDb.Query("SELECT publickey, validatorindex, valid_signature FROM eth1_deposits LEFT JOIN validators ON eth1_deposits.publickey = validators.pubkey WHERE from_address = $1 GROUP BY publickey, validatorindex, valid_signature ORDER BY validatorindex OFFSET $2 LIMIT $3;", eth1Address, offset, limit)
+	rows, err := db.ReaderDb.Query("SELECT publickey, validatorindex, valid_signature FROM eth1_deposits LEFT JOIN validators ON eth1_deposits.publickey = validators.pubkey WHERE from_address = $1 GROUP BY publickey, validatorindex, valid_signature ORDER BY validatorindex OFFSET $2 LIMIT $3;", eth1Address.Bytes(), offset, limit)
 	if err != nil {
 		sendErrorResponse(w, r.URL.String(), "could not retrieve db results")
 		return
@@ -1606,7 +1606,7 @@ func getUserPremium(r *http.Request) PremiumUser {
 // @Tags Validator
 // @Description Returns the validator indexes and pubkeys of a withdrawal credential or eth1 address
 // @Produce json
-// @Param withdrawalCredentialsOrEth1address path string true "Provide a withdrawal credential or an eth1 address with an optional 0x prefix"
+// @Param withdrawalCredentialsOrEth1address path string true "Provide a withdrawal credential with an optional 0x prefix or an eth1 address with an optional Z or 0x prefix"
 // @Param  limit query int false "Limit the number of results, maximum: 200" default(10)
 // @Param offset query int false "Offset the number of results" default(0)
 // @Success 200 {object} types.ApiResponse{data=[]types.ApiWithdrawalCredentialsResponse}
@@ -1621,20 +1621,26 @@ func ApiWithdrawalCredentialsValidators(w http.ResponseWriter, r *http.Request)
 	credentialsOrAddressString := vars["withdrawalCredentialsOrEth1address"]
 	credentialsOrAddressString = strings.ToLower(credentialsOrAddressString)
 
-	if !utils.IsValidEth1Address(credentialsOrAddressString) &&
-		!utils.IsValidWithdrawalCredentials(credentialsOrAddressString) {
+	var credentials []byte
+	if utils.IsValidEth1Address(credentialsOrAddressString) {
+		address, err := utils.ParseAddress(credentialsOrAddressString)
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid eth1 address provided")
+			return
+		}
+		credentials, err = utils.AddressToWithdrawalCredentials(address.Bytes())
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid eth1 address provided")
+			return
+		}
+	} else if utils.IsValidWithdrawalCredentials(credentialsOrAddressString) {
+		// Input is not an address so it must already be withdrawal credentials
+		credentials = common.FromHex(credentialsOrAddressString)
+	} else {
 		sendErrorResponse(w, r.URL.String(), "invalid withdrawal credentials or eth1 address provided")
 		return
 	}
 
-	credentialsOrAddress := common.FromHex(credentialsOrAddressString)
-
-	credentials, err := utils.AddressToWithdrawalCredentials(credentialsOrAddress)
-	if err != nil {
-		// Input is not an address so it must already be withdrawal credentials
-		credentials = credentialsOrAddress
-	}
-
 	limitQuery := q.Get("limit")
 	offsetQuery := q.Get("offset")
 
@@ -1650,7 +1656,7 @@ func ApiWithdrawalCredentialsValidators(w http.ResponseWriter, r *http.Request)
 		Pubkey []byte `db:"pubkey"`
 	}{}
 
-	err = db.ReaderDb.Select(&result, `
+	err := db.ReaderDb.Select(&result, `
 	SELECT
 		validatorindex,
 		pubkey
@@ -1727,26 +1733,22 @@ func parseApiValidatorParamToIndices(origParam string, limit int) (indices []uin
 		return nil, fmt.Errorf("only a maximum of %d query parameters are allowed", limit)
 	}
 	for _, param := range params {
-		if strings.Contains(param, "0x") || len(param) == 96 {
-			pubkey, err := hex.DecodeString(strings.Replace(param, "0x", "", -1))
-			if err != nil {
-				return nil, fmt.Errorf("invalid validator-parameter")
-			}
-			pubkeys = append(pubkeys, pubkey)
-		} else {
-			index, err := strconv.ParseUint(param, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid validator-parameter: %v", param)
-			}
+		if index, err := strconv.ParseUint(param, 10, 64); err == nil {
 			indices = append(indices, index)
+			continue
+		}
+		pubkey, _, err := utils.ParseValidatorPubkey(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator-parameter: %v", err)
 		}
+		pubkeys = append(pubkeys, pubkey)
 	}
 
 	var queryIndicesDeduped []uint64
 	queryIndicesDeduped = append(queryIndicesDeduped, indices...)
 	if len(pubkeys) != 0 {
 		indicesFromPubkeys := []uint64{}
-		err = db.ReaderDb.Select(&indicesFromPubkeys, "SELECT validatorindex FROM validators WHERE pubkey = ANY($1)", pubkeys)
+		err = db.ReaderDb.Select(&indicesFromPubkeys, "SELECT validatorindex FROM validators WHERE pubkey = ANY($1) OR pubkeyhash = ANY($1)", pubkeys)
 
 		if err != nil {
 			return nil, err
@@ -1772,31 +1774,32 @@ func parseApiValidatorParamToIndices(origParam string, limit int) (indices []uin
 
 func parseApiValidatorParamToPubkeys(origParam string, limit int) (pubkeys pq.ByteaArray, err error) {
 	var indices pq.Int64Array
+	var pubkeyHashes pq.ByteaArray
 	params := strings.Split(origParam, ",")
 	if len(params) > limit {
 		return nil, fmt.Errorf("only a maximum of 100 query parameters are allowed")
 	}
 	for _, param := range params {
-		if strings.Contains(param, "0x") || len(param) == 96 {
-			pubkey, err := hex.DecodeString(strings.Replace(param, "0x", "", -1))
-			if err != nil {
-				return nil, fmt.Errorf("invalid validator-parameter")
-			}
-			pubkeys = append(pubkeys, pubkey)
-		} else {
-			index, err := strconv.ParseUint(param, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid validator-parameter: %v", param)
-			}
+		if index, err := strconv.ParseUint(param, 10, 64); err == nil {
 			indices = append(indices, int64(index))
+			continue
+		}
+		pubkey, isHash, err := utils.ParseValidatorPubkey(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator-parameter: %v", err)
+		}
+		if isHash {
+			pubkeyHashes = append(pubkeyHashes, pubkey)
+		} else {
+			pubkeys = append(pubkeys, pubkey)
 		}
 	}
 
 	var queryIndicesDeduped pq.ByteaArray
 	queryIndicesDeduped = append(queryIndicesDeduped, pubkeys...)
-	if len(indices) != 0 {
+	if len(indices) != 0 || len(pubkeyHashes) != 0 {
 		var pubkeysFromIndices pq.ByteaArray
-		err = db.ReaderDb.Select(&pubkeysFromIndices, "SELECT pubkey FROM validators WHERE validatorindex = ANY($1)", indices)
+		err = db.ReaderDb.Select(&pubkeysFromIndices, "SELECT pubkey FROM validators WHERE validatorindex = ANY($1) OR pubkeyhash = ANY($2)", indices, pubkeyHashes)
 
 		if err != nil {
 			return nil, err
@@ -1,19 +1,31 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Prajjawalk/zond-indexer/db"
+	"github.com/Prajjawalk/zond-indexer/rpc"
 	"github.com/Prajjawalk/zond-indexer/services"
 	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/exp/maps"
 )
 
@@ -77,7 +89,7 @@ func getValidatorExecutionPerformance(queryIndices []uint64) ([]types.ExecutionP
 
 	blockList, blockToProposerMap := getBlockNumbersAndMapProposer(execBlocks)
 
-	blocks, err := db.MongodbClient.GetBlocksIndexedMultiple(blockList, 10000)
+	blocks, err := db.Storage.GetBlocksIndexedMultiple(blockList, 10000)
 	if err != nil {
 		return nil, fmt.Errorf("error cannot get blocks from bigtable using GetBlocksIndexedMultiple: %w", err)
 	}
@@ -144,3 +156,1483 @@ func getBlockNumbersAndMapProposer(data []types.ExecBlockProposer) ([]uint64, ma
 	}
 	return blockList, blockToProposerMap
 }
+
+// ApiEth1Address godoc
+// @Summary Get the balances of an execution layer address
+// @Tags Execution
+// @Description Returns the ether balance and the token balances of an execution layer address
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1AddressResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address} [get]
+func ApiEth1Address(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, err := utils.ParseAddress(mux.Vars(r)["address"])
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid address provided")
+		return
+	}
+
+	metadata, err := db.Storage.GetMetadataForAddress(address.Bytes())
+	if err != nil {
+		logger.Errorf("error retrieving metadata for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve address metadata")
+		return
+	}
+
+	response := types.ApiEth1AddressResponse{
+		Address: address.String(),
+		Ether:   decimal.NewFromBigInt(new(big.Int).SetBytes(metadata.EthBalance.Balance), -18).String(),
+		Tokens:  make([]types.ApiEth1AddressTokenResponse, 0, len(metadata.Balances)),
+	}
+
+	for _, balance := range metadata.Balances {
+		decimals := new(big.Int).SetBytes(balance.Metadata.Decimals).Int64()
+		token := types.ApiEth1AddressTokenResponse{
+			Address:  utils.FormatAddressString(balance.Token),
+			Balance:  decimal.NewFromBigInt(new(big.Int).SetBytes(balance.Balance), -int32(decimals)).String(),
+			Symbol:   balance.Metadata.Symbol,
+			Decimals: fmt.Sprintf("%d", decimals),
+		}
+		if len(balance.Metadata.Price) > 0 {
+			price, err := strconv.ParseFloat(string(balance.Metadata.Price), 64)
+			if err == nil {
+				token.Price = price
+				token.Currency = "USD"
+			}
+		}
+		response.Tokens = append(response.Tokens, token)
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressTx godoc
+// @Summary Get the transactions of an execution layer address
+// @Tags Execution
+// @Description Returns the transactions of an execution layer address, most recent first. The page of the response continues the list.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of transactions per page, at most 100 (default: 25)"
+// @Param  search query string false "Whitespace separated filter terms, e.g. TO:<address>, FROM:<address>, METHOD:<selector>, ERROR, CONTRACT, BLOCK:<from>-<to> or TIME:<from>-<to>"
+// @Success 200 {object} types.ApiResponse{data=types.APIEth1AddressTxResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/transactions [get]
+func ApiEth1AddressTx(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, pageToken, limit, filter, err := parseEth1AddressListRequest(r)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+
+	transactions, nextPageToken, err := db.Storage.GetEth1TxForAddress(address.Bytes(), filter, pageToken, limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving transactions for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve transactions")
+		return
+	}
+
+	response := types.APIEth1AddressTxResponse{
+		Transactions: make([]types.Eth1TransactionParsed, 0, len(transactions)),
+		Page:         nextPageToken,
+	}
+	decoder := newEth1Decoder(true)
+	for _, tx := range transactions {
+		methodName, methodAmbiguous := decoder.methodName(tx.MethodId)
+		response.Transactions = append(response.Transactions, types.Eth1TransactionParsed{
+			Hash:               fmt.Sprintf("0x%x", tx.Hash),
+			BlockNumber:        tx.BlockNumber,
+			Time:               tx.Time.AsTime(),
+			MethodId:           formatMethodId(tx.MethodId),
+			MethodName:         methodName,
+			MethodAmbiguous:    methodAmbiguous,
+			From:               utils.FormatAddressString(tx.From),
+			To:                 formatOptionalAddress(tx.To),
+			Value:              new(big.Int).SetBytes(tx.Value).String(),
+			TxFee:              new(big.Int).SetBytes(tx.TxFee).String(),
+			GasPrice:           new(big.Int).SetBytes(tx.GasPrice).String(),
+			IsContractCreation: tx.IsContractCreation,
+			InvokesContract:    tx.InvokesContract,
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressItx godoc
+// @Summary Get the internal transactions of an execution layer address
+// @Tags Execution
+// @Description Returns the internal transactions of an execution layer address, most recent first. The page of the response continues the list.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of internal transactions per page, at most 100 (default: 25)"
+// @Param  search query string false "Whitespace separated filter terms, e.g. TO:<address>, FROM:<address>, BLOCK:<from>-<to> or TIME:<from>-<to>"
+// @Success 200 {object} types.ApiResponse{data=types.APIEth1AddressItxResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/internalTx [get]
+func ApiEth1AddressItx(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, pageToken, limit, filter, err := parseEth1AddressListRequest(r)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+
+	internalTransactions, nextPageToken, err := db.Storage.GetEth1ItxForAddress(address.Bytes(), filter, pageToken, limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving internal transactions for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve internal transactions")
+		return
+	}
+
+	response := types.APIEth1AddressItxResponse{
+		InternalTransactions: make([]types.Eth1InternalTransactionParsed, 0, len(internalTransactions)),
+		Page:                 nextPageToken,
+	}
+	for _, itx := range internalTransactions {
+		response.InternalTransactions = append(response.InternalTransactions, types.Eth1InternalTransactionParsed{
+			ParentHash:  fmt.Sprintf("0x%x", itx.ParentHash),
+			BlockNumber: itx.BlockNumber,
+			Type:        itx.Type,
+			Time:        itx.Time.AsTime(),
+			From:        utils.FormatAddressString(itx.From),
+			To:          formatOptionalAddress(itx.To),
+			Value:       new(big.Int).SetBytes(itx.Value).String(),
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressBlocks godoc
+// @Summary Get the blocks produced by an execution layer address
+// @Tags Execution
+// @Description Returns the blocks with the address as fee recipient, most recent first. The page of the response continues the list.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of blocks per page, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=types.APIEth1AddressBlockResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/blocks [get]
+func ApiEth1AddressBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, pageToken, limit, _, err := parseEth1AddressListRequest(r)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+
+	blocks, nextPageToken, err := db.Storage.GetEth1BlocksForAddress(address.Bytes(), pageToken, limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving blocks for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve blocks")
+		return
+	}
+
+	response := types.APIEth1AddressBlockResponse{
+		ProducedBlocks: make([]types.Eth1BlockParsed, 0, len(blocks)),
+		Page:           nextPageToken,
+	}
+	for _, block := range blocks {
+		response.ProducedBlocks = append(response.ProducedBlocks, parseEth1Block(block))
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressTokens godoc
+// @Summary Get the token transfers of an execution layer address
+// @Tags Execution
+// @Description Returns the token transfers of an execution layer address, most recent first. The page of the response continues the list.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  type query string false "Token standard, one of erc20, erc721 or erc1155 (default: erc20)"
+// @Param  tokenAddress query string false "Only return transfers of this erc20 token"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of transfers per page, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=types.APIEth1TokenResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/tokens [get]
+func ApiEth1AddressTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, pageToken, limit, _, err := parseEth1AddressListRequest(r)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	var filter *db.AddressIndexFilter
+	if q.Get("tokenAddress") != "" {
+		tokenAddress, err := utils.ParseAddress(q.Get("tokenAddress"))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid token address provided")
+			return
+		}
+		filter = &db.AddressIndexFilter{Token: tokenAddress.Bytes()}
+	}
+
+	response := types.APIEth1TokenResponse{TokenTxs: []*types.Eth1TokenTxParsed{}}
+
+	switch strings.ToLower(q.Get("type")) {
+	case "", "erc20":
+		var transfers []*types.Eth1ERC20Indexed
+		transfers, response.Page, err = db.Storage.GetEth1ERC20ForAddress(address.Bytes(), filter, pageToken, limit)
+		for _, transfer := range transfers {
+			response.TokenTxs = append(response.TokenTxs, &types.Eth1TokenTxParsed{
+				ParentHash:   fmt.Sprintf("0x%x", transfer.ParentHash),
+				BlockNumber:  transfer.BlockNumber,
+				TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+				Time:         transfer.Time.AsTime(),
+				From:         utils.FormatAddressString(transfer.From),
+				To:           utils.FormatAddressString(transfer.To),
+				Value:        new(big.Int).SetBytes(transfer.Value).String(),
+			})
+		}
+	case "erc721":
+		if filter != nil {
+			sendErrorResponse(w, r.URL.String(), "tokenAddress is only supported for erc20 transfers")
+			return
+		}
+		var transfers []*types.Eth1ERC721Indexed
+		transfers, response.Page, err = db.Storage.GetEth1ERC721ForAddress(address.Bytes(), pageToken, limit)
+		for _, transfer := range transfers {
+			response.TokenTxs = append(response.TokenTxs, &types.Eth1TokenTxParsed{
+				ParentHash:   fmt.Sprintf("0x%x", transfer.ParentHash),
+				BlockNumber:  transfer.BlockNumber,
+				TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+				Time:         transfer.Time.AsTime(),
+				From:         utils.FormatAddressString(transfer.From),
+				To:           utils.FormatAddressString(transfer.To),
+				TokenId:      new(big.Int).SetBytes(transfer.TokenId).String(),
+			})
+		}
+	case "erc1155":
+		if filter != nil {
+			sendErrorResponse(w, r.URL.String(), "tokenAddress is only supported for erc20 transfers")
+			return
+		}
+		var transfers []*types.ETh1ERC1155Indexed
+		transfers, response.Page, err = db.Storage.GetEth1ERC1155ForAddress(address.Bytes(), pageToken, limit)
+		for _, transfer := range transfers {
+			response.TokenTxs = append(response.TokenTxs, &types.Eth1TokenTxParsed{
+				ParentHash:   fmt.Sprintf("0x%x", transfer.ParentHash),
+				BlockNumber:  transfer.BlockNumber,
+				TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+				Time:         transfer.Time.AsTime(),
+				From:         utils.FormatAddressString(transfer.From),
+				To:           utils.FormatAddressString(transfer.To),
+				Value:        new(big.Int).SetBytes(transfer.Value).String(),
+				TokenId:      new(big.Int).SetBytes(transfer.TokenId).String(),
+				Operator:     utils.FormatAddressString(transfer.Operator),
+			})
+		}
+	default:
+		sendErrorResponse(w, r.URL.String(), "invalid token type provided, expected erc20, erc721 or erc1155")
+		return
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving token transfers for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve token transfers")
+		return
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressBalanceHistory godoc
+// @Summary Get the balance history of an execution layer address
+// @Tags Execution
+// @Description Returns the balance of an execution layer address at a block and its balances at the end of the last days (UTC), derived from the indexed balance changes. Balances are in the smallest unit of the token.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  tokenAddress query string false "Return the balances of this erc20 token instead of ether"
+// @Param  block query int false "Block of the balance (default: the last indexed block)"
+// @Param  days query int false "Number of days of the daily balances including today, at most 365 (default: 30)"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1BalanceHistoryResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/balanceHistory [get]
+func ApiEth1AddressBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, err := utils.ParseAddress(mux.Vars(r)["address"])
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid address provided")
+		return
+	}
+
+	q := r.URL.Query()
+	response := types.ApiEth1BalanceHistoryResponse{Address: address.String()}
+
+	token := []byte{0x0}
+	if q.Get("tokenAddress") != "" {
+		tokenAddress, err := utils.ParseAddress(q.Get("tokenAddress"))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid token address provided")
+			return
+		}
+		token = tokenAddress.Bytes()
+		response.Token = tokenAddress.String()
+	}
+
+	days := int64(30)
+	if q.Get("days") != "" {
+		days, err = strconv.ParseInt(q.Get("days"), 10, 64)
+		if err != nil || days < 1 || days > 365 {
+			sendErrorResponse(w, r.URL.String(), "invalid days provided, expected a value between 1 and 365")
+			return
+		}
+	}
+
+	if q.Get("block") != "" {
+		response.BlockNumber, err = strconv.ParseUint(q.Get("block"), 10, 64)
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid block provided")
+			return
+		}
+	} else {
+		lastBlock, err := db.Storage.GetLastBlockInBlocksTable()
+		if err != nil {
+			logger.Errorf("error retrieving last indexed block route: %v, err: %v", r.URL.String(), err)
+			sendServerErrorResponse(w, r.URL.String(), "could not retrieve balance history")
+			return
+		}
+		response.BlockNumber = uint64(lastBlock)
+	}
+
+	balance, err := db.GetBalanceAtBlock(db.Storage, address.Bytes(), token, response.BlockNumber)
+	if err != nil {
+		logger.Errorf("error retrieving balance at block %v for address %v route: %v, err: %v", response.BlockNumber, address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve balance history")
+		return
+	}
+	response.Balance = balance.String()
+
+	today := time.Now().UTC()
+	daily, err := db.GetDailyBalances(db.Storage, address.Bytes(), token, today.AddDate(0, 0, -int(days-1)), today)
+	if err != nil {
+		logger.Errorf("error retrieving daily balances for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve balance history")
+		return
+	}
+	response.Daily = make([]types.ApiEth1DailyBalanceResponse, 0, len(daily))
+	for _, day := range daily {
+		response.Daily = append(response.Daily, types.ApiEth1DailyBalanceResponse{
+			Day:     day.Day.Format("2006-01-02"),
+			Balance: day.Balance.String(),
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1Tokens godoc
+// @Summary Get the known token contracts
+// @Tags Execution
+// @Description Returns the token contracts seen in the indexed transfers ordered by address, with the metadata retrieved from the contracts
+// @Produce  json
+// @Param  type query string false "Return only tokens of this standard: erc20, erc721 or erc1155"
+// @Param  start query string false "Return the tokens after this address, use next of the previous page"
+// @Param  limit query int false "Number of tokens per page, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1TokensResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/tokens [get]
+func ApiEth1Tokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+
+	standard := q.Get("type")
+	switch standard {
+	case "", db.TOKEN_STANDARD_ERC20, db.TOKEN_STANDARD_ERC721, db.TOKEN_STANDARD_ERC1155:
+	default:
+		sendErrorResponse(w, r.URL.String(), "invalid type provided, expected erc20, erc721 or erc1155")
+		return
+	}
+
+	var start []byte
+	if q.Get("start") != "" {
+		startAddress, err := utils.ParseAddress(q.Get("start"))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid start address provided")
+			return
+		}
+		start = startAddress.Bytes()
+	}
+
+	limit := int64(25)
+	if q.Get("limit") != "" {
+		var err error
+		limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 100")
+			return
+		}
+	}
+
+	tokens, err := db.Storage.GetTokens(standard, start, limit)
+	if err != nil {
+		logger.Errorf("error retrieving tokens route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve tokens")
+		return
+	}
+
+	response := types.ApiEth1TokensResponse{Tokens: make([]types.ApiEth1TokenResponse, 0, len(tokens))}
+	for _, token := range tokens {
+		entry := types.ApiEth1TokenResponse{
+			Address:    utils.FormatAddressString(token.Address),
+			Standard:   token.Standard,
+			Name:       token.Name,
+			Symbol:     token.Symbol,
+			Interfaces: token.Interfaces,
+			FirstBlock: token.FirstBlock,
+		}
+		if entry.Interfaces == nil {
+			entry.Interfaces = []string{}
+		}
+		if len(token.Decimals) > 0 {
+			entry.Decimals = new(big.Int).SetBytes(token.Decimals).String()
+		}
+		if len(token.TotalSupply) > 0 {
+			entry.TotalSupply = new(big.Int).SetBytes(token.TotalSupply).String()
+		}
+		response.Tokens = append(response.Tokens, entry)
+	}
+	if int64(len(tokens)) == limit {
+		response.Next = response.Tokens[len(response.Tokens)-1].Address
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1AddressNFTs godoc
+// @Summary Get the nfts held by an execution layer address
+// @Tags Execution
+// @Description Returns the ERC-721 and ERC-1155 token ids currently held by an execution layer address ordered by token and token id, derived from the indexed transfers. The page of the response continues the list.
+// @Produce  json
+// @Param  address path string true "Execution layer address, Z or 0x prefixed"
+// @Param  tokenAddress query string false "Return only the token ids of this token"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of token ids per page, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1NFTsResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/address/{address}/nfts [get]
+func ApiEth1AddressNFTs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address, err := utils.ParseAddress(mux.Vars(r)["address"])
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid address provided")
+		return
+	}
+
+	q := r.URL.Query()
+
+	var token []byte
+	if q.Get("tokenAddress") != "" {
+		tokenAddress, err := utils.ParseAddress(q.Get("tokenAddress"))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid token address provided")
+			return
+		}
+		token = tokenAddress.Bytes()
+	}
+
+	limit := int64(25)
+	if q.Get("limit") != "" {
+		limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 100")
+			return
+		}
+	}
+
+	holdings, nextPageToken, err := db.Storage.GetNFTHoldings(address.Bytes(), token, q.Get("token"), limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving nfts for address %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve nfts")
+		return
+	}
+
+	response := types.ApiEth1NFTsResponse{Address: address.String(), NFTs: make([]types.ApiEth1NFTHoldingResponse, 0, len(holdings)), Page: nextPageToken}
+	for _, holding := range holdings {
+		response.NFTs = append(response.NFTs, types.ApiEth1NFTHoldingResponse{
+			Token:    utils.FormatAddressString(holding.Token),
+			TokenId:  new(big.Int).SetBytes(holding.TokenId).String(),
+			Standard: holding.Standard,
+			Balance:  holding.Balance.String(),
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1NFT godoc
+// @Summary Get the owners and the metadata of an nft
+// @Tags Execution
+// @Description Returns the current owners of an ERC-721 or ERC-1155 token id ordered by address and its metadata uri. The JSON metadata is included if the uri embeds it. The page of the response continues the owners.
+// @Produce  json
+// @Param  token path string true "Token contract address, Z or 0x prefixed"
+// @Param  id path string true "Token id, decimal or 0x prefixed hex"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of owners per page, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1NFTResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/token/{token}/{id} [get]
+func ApiEth1NFT(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	token, err := utils.ParseAddress(vars["token"])
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid token address provided")
+		return
+	}
+
+	base := 10
+	idString := vars["id"]
+	if strings.HasPrefix(idString, "0x") || strings.HasPrefix(idString, "0X") {
+		base, idString = 16, idString[2:]
+	}
+	id, ok := new(big.Int).SetString(idString, base)
+	if !ok || id.Sign() < 0 || id.BitLen() > 256 {
+		sendErrorResponse(w, r.URL.String(), "invalid token id provided")
+		return
+	}
+
+	q := r.URL.Query()
+	limit := int64(25)
+	if q.Get("limit") != "" {
+		limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 100")
+			return
+		}
+	}
+
+	owners, nextPageToken, err := db.Storage.GetNFTOwners(token.Bytes(), id.Bytes(), q.Get("token"), limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving owners of token %v id %v route: %v, err: %v", token, id, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve nft")
+		return
+	}
+	metadata, err := db.Storage.GetNFTMetadata(token.Bytes(), id.Bytes())
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Errorf("error retrieving metadata of token %v id %v route: %v, err: %v", token, id, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve nft")
+		return
+	}
+	if len(owners) == 0 && metadata == nil && q.Get("token") == "" {
+		sendErrorResponse(w, r.URL.String(), "no transfers of the token id have been indexed")
+		return
+	}
+
+	response := types.ApiEth1NFTResponse{
+		Token:   token.String(),
+		TokenId: id.String(),
+		Owners:  make([]types.ApiEth1NFTOwnerResponse, 0, len(owners)),
+		Page:    nextPageToken,
+	}
+	for _, owner := range owners {
+		response.Standard = owner.Standard
+		response.Owners = append(response.Owners, types.ApiEth1NFTOwnerResponse{
+			Address: utils.FormatAddressString(owner.Address),
+			Balance: owner.Balance.String(),
+		})
+	}
+	if metadata != nil {
+		response.Standard = metadata.Standard
+		response.URI = metadata.URI
+		response.Metadata = metadata.Metadata
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ContractEventIndexes holds the configured contract event indexes by name
+var ContractEventIndexes = map[string]*types.ContractEventIndex{}
+
+// ApiEth1ContractEvents godoc
+// @Summary Get the decoded events of a configured contract event index
+// @Tags Execution
+// @Description Returns the decoded events of the contracts of a contract event index of the indexer config in chain order, a page at a time. Events can be filtered by the value of their top level parameters with param.<name>=<value>, which requires the event to be set.
+// @Produce  json
+// @Param  name path string true "Name of the contract event index"
+// @Param  contract query string false "Return only events of this contract"
+// @Param  event query string false "Return only events with this name"
+// @Param  fromBlock query int false "Return only events from this block on"
+// @Param  toBlock query int false "Return only events up to this block"
+// @Param  limit query int false "Number of events, at most 100 (default: 25)"
+// @Param  token query string false "Page token returned by the previous request"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1ContractEventsResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/events/{name} [get]
+func ApiEth1ContractEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	index, ok := ContractEventIndexes[vars["name"]]
+	if !ok {
+		sendErrorResponse(w, r.URL.String(), "unknown contract event index")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := &types.ContractEventFilter{Event: q.Get("event"), Params: map[string]interface{}{}}
+	if q.Get("contract") != "" {
+		contract, err := utils.ParseAddress(q.Get("contract"))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid contract address provided")
+			return
+		}
+		filter.Contract = contract.Bytes()
+	}
+	var err error
+	if q.Get("fromBlock") != "" {
+		filter.FromBlock, err = strconv.ParseUint(q.Get("fromBlock"), 10, 64)
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid fromBlock provided")
+			return
+		}
+	}
+	if q.Get("toBlock") != "" {
+		filter.ToBlock, err = strconv.ParseUint(q.Get("toBlock"), 10, 64)
+		if err != nil || filter.ToBlock < filter.FromBlock {
+			sendErrorResponse(w, r.URL.String(), "invalid toBlock provided")
+			return
+		}
+	}
+	for key := range q {
+		if !strings.HasPrefix(key, "param.") {
+			continue
+		}
+		if filter.Event == "" {
+			sendErrorResponse(w, r.URL.String(), "filtering by parameters requires an event")
+			return
+		}
+		name := strings.TrimPrefix(key, "param.")
+		filter.Params[name], err = db.ContractEventParamFilter(index, filter.Event, name, q.Get(key))
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), fmt.Sprintf("invalid filter of parameter %v: %v", name, err))
+			return
+		}
+	}
+
+	limit := int64(25)
+	if q.Get("limit") != "" {
+		limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 100")
+			return
+		}
+	}
+
+	events, nextPageToken, err := db.Storage.GetContractEvents(index, filter, q.Get("token"), limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving %v events route: %v, err: %v", index.Name, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve events")
+		return
+	}
+
+	response := types.ApiEth1ContractEventsResponse{Name: index.Name, Events: make([]types.ApiEth1ContractEventResponse, 0, len(events)), Page: nextPageToken}
+	for _, event := range events {
+		response.Events = append(response.Events, types.ApiEth1ContractEventResponse{
+			Contract:    utils.FormatAddressString(event.Contract),
+			Event:       event.Event,
+			BlockNumber: event.BlockNumber,
+			Time:        event.Time,
+			TxHash:      fmt.Sprintf("0x%x", event.TxHash),
+			TxIndex:     event.TxIndex,
+			LogIndex:    event.LogIndex,
+			Params:      event.Params,
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1VerifyContract godoc
+// @Summary Verify the source of a deployed contract
+// @Tags Execution
+// @Description Verifies compiler metadata and sources against the metadata hash embedded in the deployed bytecode of a contract, without running the compiler. Pass the compiler metadata JSON or a standard-JSON output, the sources are taken from the metadata or from a standard-JSON input. The verified name, abi and sources are stored for the contract and for all contracts deployed with identical bytecode.
+// @Accept  json
+// @Produce  json
+// @Param  address path string true "Contract address, Z or 0x prefixed"
+// @Param  request body types.ApiEth1VerifyContractRequest true "Compiler output"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1VerifyContractResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/contract/{address}/verify [post]
+func ApiEth1VerifyContract(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	address, err := utils.ParseAddress(vars["address"])
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid address provided")
+		return
+	}
+
+	request := &types.ApiEth1VerifyContractRequest{}
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024*1024)).Decode(request)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid request body")
+		return
+	}
+
+	if rpc.CurrentErigonClient == nil {
+		sendServerErrorResponse(w, r.URL.String(), "contract verification is not available")
+		return
+	}
+	code, err := rpc.CurrentErigonClient.GetCode(address.Bytes())
+	if err != nil {
+		logger.Errorf("error retrieving code of %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve contract code")
+		return
+	}
+	if len(code) == 0 {
+		sendErrorResponse(w, r.URL.String(), "no contract is deployed at the address")
+		return
+	}
+
+	metadata, err := utils.VerifyContract(code, request)
+	if errors.Is(err, utils.ErrContractVerification) {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+	if err != nil {
+		logger.Errorf("error verifying contract %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not verify contract")
+		return
+	}
+
+	err = db.Storage.SaveVerifiedCode(crypto.Keccak256(code), metadata)
+	if err != nil {
+		logger.Errorf("error saving verified code of %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not save contract")
+		return
+	}
+	err = db.Storage.SaveContractMetadata(address.Bytes(), metadata)
+	if err != nil {
+		logger.Errorf("error saving contract metadata of %v route: %v, err: %v", address, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not save contract")
+		return
+	}
+
+	response := types.ApiEth1VerifyContractResponse{
+		Address:  address.String(),
+		Name:     metadata.Name,
+		Compiler: metadata.Compiler,
+		Sources:  maps.Keys(metadata.Sources),
+	}
+	sort.Strings(response.Sources)
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1Logs godoc
+// @Summary Get logs matching an eth_getLogs filter
+// @Tags Execution
+// @Description Returns the logs matching the standard eth_getLogs filter object from the log index, in chain order. Unlike the node the index serves arbitrarily large block ranges, the page of the response continues the list. Logs are decoded with the stored abi of the contract or the signature database on a best effort basis, abis of unknown contracts are not retrieved.
+// @Accept  json
+// @Produce  json
+// @Param  filter body types.ApiEth1LogFilterRequest true "eth_getLogs filter object"
+// @Param  token query string false "Page token returned by the previous request"
+// @Param  limit query int false "Number of logs per page, at most 1000 (default: 100)"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1LogsResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/logs [post]
+func ApiEth1Logs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request := &types.ApiEth1LogFilterRequest{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(request)
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), "invalid filter provided")
+		return
+	}
+
+	limit := int64(100)
+	if q := r.URL.Query().Get("limit"); q != "" {
+		limit, err = strconv.ParseInt(q, 10, 64)
+		if err != nil || limit < 1 || limit > 1000 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 1000")
+			return
+		}
+	}
+
+	latest := 0
+	if request.BlockHash == "" {
+		latest, err = db.Storage.GetLastBlockInBlocksTable()
+		if err != nil {
+			logger.Errorf("error retrieving last block of the blocks table route: %v, err: %v", r.URL.String(), err)
+			sendServerErrorResponse(w, r.URL.String(), "could not retrieve logs")
+			return
+		}
+	}
+
+	filter, err := parseLogFilter(request, uint64(latest))
+	if err != nil {
+		sendErrorResponse(w, r.URL.String(), err.Error())
+		return
+	}
+
+	logs, nextPageToken, err := db.Storage.GetLogs(filter, r.URL.Query().Get("token"), limit)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			sendErrorResponse(w, r.URL.String(), "invalid page token provided")
+			return
+		}
+		logger.Errorf("error retrieving logs route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve logs")
+		return
+	}
+
+	response := types.ApiEth1LogsResponse{
+		Logs: make([]types.ApiEth1LogResponse, 0, len(logs)),
+		Page: nextPageToken,
+	}
+	decoder := newEth1Decoder(false)
+	for _, l := range logs {
+		topics := make([]hexutil.Bytes, 0, len(l.Topics))
+		for _, topic := range l.Topics {
+			topics = append(topics, topic)
+		}
+		decoded, err := decoder.decodeLog(l.Address, l.Topics, l.Data)
+		if decoded == nil && err != nil {
+			logger.Warnf("error decoding log %v of block %v: %v", l.LogIndex, l.BlockNumber, err)
+		}
+		response.Logs = append(response.Logs, types.ApiEth1LogResponse{
+			Address:          utils.FormatAddressString(l.Address),
+			Topics:           topics,
+			Data:             l.Data,
+			BlockNumber:      hexutil.Uint64(l.BlockNumber),
+			BlockHash:        l.BlockHash,
+			TransactionHash:  l.TxHash,
+			TransactionIndex: hexutil.Uint64(l.TxIndex),
+			LogIndex:         hexutil.Uint64(l.LogIndex),
+			Decoded:          decoded,
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// parseLogFilter converts an eth_getLogs filter object into a log filter, the block tags resolve relative to the passed
+// latest block
+func parseLogFilter(request *types.ApiEth1LogFilterRequest, latest uint64) (*types.LogFilter, error) {
+	filter := &types.LogFilter{}
+
+	if request.BlockHash != "" {
+		if request.FromBlock != "" || request.ToBlock != "" {
+			return nil, fmt.Errorf("invalid filter provided, blockHash can not be combined with fromBlock or toBlock")
+		}
+		hash, err := hexutil.Decode(request.BlockHash)
+		if err != nil || len(hash) != common.HashLength {
+			return nil, fmt.Errorf("invalid blockHash provided")
+		}
+		filter.BlockHash = hash
+	} else {
+		var err error
+		filter.FromBlock, err = parseLogFilterBlock(request.FromBlock, latest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fromBlock provided")
+		}
+		filter.ToBlock, err = parseLogFilterBlock(request.ToBlock, latest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid toBlock provided")
+		}
+		if filter.FromBlock > filter.ToBlock {
+			return nil, fmt.Errorf("invalid block range provided, fromBlock is above toBlock")
+		}
+	}
+
+	for _, a := range request.Address {
+		address, err := utils.ParseAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %v provided", a)
+		}
+		filter.Addresses = append(filter.Addresses, address.Bytes())
+	}
+
+	if len(request.Topics) > db.MaxLogTopics {
+		return nil, fmt.Errorf("invalid topics provided, logs have at most %v topics", db.MaxLogTopics)
+	}
+	for _, alternatives := range request.Topics {
+		set := make([][]byte, 0, len(alternatives))
+		for _, t := range alternatives {
+			topic, err := hexutil.Decode(t)
+			if err != nil || len(topic) != common.HashLength {
+				return nil, fmt.Errorf("invalid topic %v provided", t)
+			}
+			set = append(set, topic)
+		}
+		filter.Topics = append(filter.Topics, set)
+	}
+	return filter, nil
+}
+
+// parseLogFilterBlock parses a hex encoded block number or a block tag, the tags other than earliest resolve to the
+// latest block
+func parseLogFilterBlock(block string, latest uint64) (uint64, error) {
+	switch block {
+	case "", "latest", "safe", "finalized", "pending":
+		return latest, nil
+	case "earliest":
+		return 0, nil
+	}
+	return hexutil.DecodeUint64(block)
+}
+
+// ApiETH1ExecBlocks godoc
+// @Summary Get execution blocks
+// @Tags Execution
+// @Description Returns up to 100 execution blocks by their numbers
+// @Produce  json
+// @Param  blockNumber path string true "Block number, or up to 100 comma separated block numbers"
+// @Success 200 {object} types.ApiResponse{data=[]types.Eth1BlockParsed}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/block/{blockNumber} [get]
+func ApiETH1ExecBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	numbers := strings.Split(mux.Vars(r)["blockNumber"], ",")
+	if len(numbers) > 100 {
+		sendErrorResponse(w, r.URL.String(), "only a maximum of 100 blocks per query are allowed")
+		return
+	}
+
+	blockNumbers := make([]uint64, 0, len(numbers))
+	for _, number := range numbers {
+		blockNumber, err := strconv.ParseUint(strings.TrimSpace(number), 10, 64)
+		if err != nil {
+			sendErrorResponse(w, r.URL.String(), "invalid block number provided")
+			return
+		}
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+
+	blocks, err := db.Storage.GetBlocksIndexedMultiple(blockNumbers, uint64(len(blockNumbers)))
+	if err != nil {
+		logger.Errorf("error retrieving blocks %v route: %v, err: %v", blockNumbers, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve blocks")
+		return
+	}
+
+	data := make([]types.Eth1BlockParsed, 0, len(blocks))
+	for _, block := range blocks {
+		data = append(data, parseEth1Block(block))
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{data})
+}
+
+// ApiEth1GasNowData godoc
+// @Summary Get the gas price history
+// @Tags Execution
+// @Description Returns the recorded gas price suggestions (in wei) of the requested time frame, oldest first
+// @Produce  json
+// @Param  hours query int false "Number of hours of history to return, at most 168 (default: 1)"
+// @Success 200 {object} types.ApiResponse{data=[]types.GasNowHistory}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/gasnow [get]
+func ApiEth1GasNowData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hours := uint64(1)
+	if q := r.URL.Query().Get("hours"); q != "" {
+		var err error
+		hours, err = strconv.ParseUint(q, 10, 64)
+		if err != nil || hours == 0 || hours > 168 {
+			sendErrorResponse(w, r.URL.String(), "invalid hours provided, expected a value between 1 and 168")
+			return
+		}
+	}
+
+	now := time.Now()
+	history, err := db.Storage.GetGasNowHistory(now, now.Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		logger.Errorf("error retrieving gas now history route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve gas now history")
+		return
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{history})
+}
+
+// parseEth1AddressListRequest parses the address path parameter and the paging and search query parameters shared by
+// the execution address list endpoints
+func parseEth1AddressListRequest(r *http.Request) (utils.Address, string, int64, *db.AddressIndexFilter, error) {
+	address, err := utils.ParseAddress(mux.Vars(r)["address"])
+	if err != nil {
+		return address, "", 0, nil, fmt.Errorf("invalid address provided")
+	}
+
+	q := r.URL.Query()
+
+	limit := int64(25)
+	if q.Get("limit") != "" {
+		limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			return address, "", 0, nil, fmt.Errorf("invalid limit provided, expected a value between 1 and 100")
+		}
+	}
+
+	filter, err := db.ParseAddressIndexFilter(q.Get("search"))
+	if err != nil {
+		return address, "", 0, nil, err
+	}
+
+	return address, q.Get("token"), limit, filter, nil
+}
+
+func parseEth1Block(block *types.Eth1BlockIndexed) types.Eth1BlockParsed {
+	return types.Eth1BlockParsed{
+		Hash:                     fmt.Sprintf("0x%x", block.Hash),
+		ParentHash:               fmt.Sprintf("0x%x", block.ParentHash),
+		UncleHash:                fmt.Sprintf("0x%x", block.UncleHash),
+		Coinbase:                 utils.FormatAddressString(block.Coinbase),
+		TxReward:                 new(big.Int).SetBytes(block.TxReward).String(),
+		Difficulty:               new(big.Int).SetBytes(block.Difficulty).String(),
+		Number:                   block.Number,
+		GasLimit:                 block.GasLimit,
+		GasUsed:                  block.GasUsed,
+		Time:                     block.Time.AsTime(),
+		BaseFee:                  new(big.Int).SetBytes(block.BaseFee).String(),
+		UncleCount:               block.UncleCount,
+		TransactionCount:         block.TransactionCount,
+		InternalTransactionCount: block.InternalTransactionCount,
+		Mev:                      new(big.Int).SetBytes(block.Mev).String(),
+		LowestGasPrice:           new(big.Int).SetBytes(block.LowestGasPrice).String(),
+		HighestGasPrice:          new(big.Int).SetBytes(block.HighestGasPrice).String(),
+		UncleReward:              new(big.Int).SetBytes(block.UncleReward).String(),
+	}
+}
+
+// formatOptionalAddress formats an address which is empty for contract creations
+func formatOptionalAddress(address []byte) string {
+	if len(address) == 0 {
+		return ""
+	}
+	return utils.FormatAddressString(address)
+}
+
+func formatMethodId(methodId []byte) string {
+	if len(methodId) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%x", methodId)
+}
+
+// eth1Decoder decodes call data and logs with the abi of the contract if it is known and falls back to the signature
+// database otherwise, e.g. for contracts behind a proxy. Abis and signatures are only looked up once per request. If
+// fetch is not set only stored abis are used, unknown contracts aren't looked up with the node or etherscan.
+type eth1Decoder struct {
+	fetch      bool
+	abis       map[string]*abi.ABI
+	signatures map[string][]*types.Eth1Signature
+}
+
+func newEth1Decoder(fetch bool) *eth1Decoder {
+	return &eth1Decoder{fetch: fetch, abis: make(map[string]*abi.ABI), signatures: make(map[string][]*types.Eth1Signature)}
+}
+
+// storedOnly returns a decoder sharing the abis and signatures already looked up by d which doesn't fetch unknown
+// contracts
+func (d *eth1Decoder) storedOnly() *eth1Decoder {
+	return &eth1Decoder{abis: d.abis, signatures: d.signatures}
+}
+
+func (d *eth1Decoder) contractAbi(address []byte) *abi.ABI {
+	if contract, ok := d.abis[string(address)]; ok {
+		return contract
+	}
+	var contract *abi.ABI
+	getContractMetadata := db.Storage.GetStoredContractMetadata
+	if d.fetch {
+		getContractMetadata = db.Storage.GetContractMetadata
+	}
+	metadata, err := getContractMetadata(address)
+	if err != nil {
+		logger.Warnf("error retrieving contract metadata of 0x%x: %v", address, err)
+	} else if metadata != nil {
+		contract = metadata.ABI
+	}
+	d.abis[string(address)] = contract
+	return contract
+}
+
+func (d *eth1Decoder) lookupSignatures(selector []byte) []*types.Eth1Signature {
+	if signatures, ok := d.signatures[string(selector)]; ok {
+		return signatures
+	}
+	signatures, err := db.Storage.GetSignatures(selector)
+	if err != nil {
+		logger.Warnf("error retrieving signatures of selector 0x%x: %v", selector, err)
+	}
+	d.signatures[string(selector)] = signatures
+	return signatures
+}
+
+// methodName returns the best effort name of the function with the selector, ambiguous is set if it is one of several
+func (d *eth1Decoder) methodName(selector []byte) (string, bool) {
+	if len(selector) != 4 {
+		return "", false
+	}
+	return utils.SignatureName(d.lookupSignatures(selector))
+}
+
+// decodeCall decodes the call data of a call to the contract, the returned error is the one of decoding with the abi
+// and only relevant if the call couldn't be decoded with the signature database either
+func (d *eth1Decoder) decodeCall(to []byte, data []byte) (*types.Eth1DecodedCall, error) {
+	var err error
+	if contract := d.contractAbi(to); contract != nil {
+		var decoded *types.Eth1DecodedCall
+		decoded, err = utils.DecodeCallData(contract, data)
+		if err == nil {
+			return decoded, nil
+		}
+	}
+	return utils.DecodeCallDataWithSignatures(d.lookupSignatures(data[:4]), data), err
+}
+
+// decodeLog decodes a log of the contract, the returned error is the one of decoding with the abi and only relevant if
+// the log couldn't be decoded with the signature database either
+func (d *eth1Decoder) decodeLog(address []byte, topics [][]byte, data []byte) (*types.Eth1DecodedEvent, error) {
+	var err error
+	if contract := d.contractAbi(address); contract != nil {
+		var decoded *types.Eth1DecodedEvent
+		decoded, err = utils.DecodeLog(contract, topics, data)
+		if err == nil {
+			return decoded, nil
+		}
+	}
+	if len(topics) == 0 {
+		return nil, err
+	}
+	return utils.DecodeLogWithSignatures(d.lookupSignatures(topics[0]), topics, data), err
+}
+
+// ApiEth1Tx godoc
+// @Summary Get an execution layer transaction
+// @Tags Execution
+// @Description Returns a transaction with its receipt, logs, internal call tree and token transfers. Input and logs are decoded with the abi of the contract if it is known, otherwise with the signature database on a best effort basis. Only the abi of the called contract is retrieved if it is unknown, logs of other contracts are decoded with stored abis. Guessed decodings are flagged, ambiguous ones list the other matching signatures.
+// @Produce  json
+// @Param  hash path string true "Transaction hash"
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1TransactionResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/tx/{hash} [get]
+func ApiEth1Tx(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	txHash, err := hex.DecodeString(strings.TrimPrefix(mux.Vars(r)["hash"], "0x"))
+	if err != nil || len(txHash) != 32 {
+		sendErrorResponse(w, r.URL.String(), "invalid transaction hash provided")
+		return
+	}
+
+	tx, block, txIndex, err := db.Storage.GetEth1TransactionWithBlock(txHash)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			sendErrorResponse(w, r.URL.String(), "transaction not found")
+			return
+		}
+		logger.Errorf("error retrieving tx 0x%x route: %v, err: %v", txHash, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve transaction")
+		return
+	}
+
+	erc20, erc721, erc1155, err := db.Storage.GetTokenTransfersForTransaction(txHash)
+	if err != nil {
+		logger.Errorf("error retrieving token transfers of tx 0x%x route: %v, err: %v", txHash, r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve token transfers")
+		return
+	}
+
+	baseFee := new(big.Int).SetBytes(block.BaseFee)
+	response := types.ApiEth1TransactionResponse{
+		Hash:              fmt.Sprintf("0x%x", tx.Hash),
+		BlockNumber:       block.Number,
+		BlockHash:         fmt.Sprintf("0x%x", block.Hash),
+		TransactionIndex:  txIndex,
+		Time:              block.Time.AsTime(),
+		Type:              tx.Type,
+		Nonce:             tx.Nonce,
+		From:              utils.FormatAddressString(tx.From),
+		To:                formatOptionalAddress(tx.To),
+		Value:             new(big.Int).SetBytes(tx.Value).String(),
+		Input:             fmt.Sprintf("0x%x", tx.Data),
+		Status:            tx.Status,
+		ErrorMsg:          tx.ErrorMsg,
+		GasLimit:          tx.Gas,
+		GasUsed:           tx.GasUsed,
+		CumulativeGasUsed: tx.CommulativeGasUsed,
+		GasPrice:          new(big.Int).SetBytes(tx.GasPrice).String(),
+		EffectiveGasPrice: db.CalculateEffectiveGasPrice(tx, baseFee).String(),
+		TxFee:             db.CalculateTxFeeFromTransaction(tx, baseFee).String(),
+		SignatureVerified: tx.SignatureVerified,
+		Logs:              make([]*types.Eth1LogParsed, 0, len(tx.Logs)),
+		InternalCalls:     utils.BuildInternalCallTree(tx.Itx),
+		ERC20Transfers:    make([]*types.Eth1TokenTxParsed, 0, len(erc20)),
+		ERC721Transfers:   make([]*types.Eth1TokenTxParsed, 0, len(erc721)),
+		ERC1155Transfers:  make([]*types.Eth1TokenTxParsed, 0, len(erc1155)),
+	}
+	if len(tx.ContractAddress) > 0 && !bytes.Equal(tx.ContractAddress, db.ZERO_ADDRESS) {
+		response.ContractAddress = utils.FormatAddressString(tx.ContractAddress)
+	}
+	if len(tx.PublicKeyHash) > 0 {
+		response.PublicKeyHash = fmt.Sprintf("0x%x", tx.PublicKeyHash)
+		response.SignatureHash = fmt.Sprintf("0x%x", tx.SignatureHash)
+	}
+	if tx.Type == 2 {
+		response.MaxFeePerGas = new(big.Int).SetBytes(tx.MaxFeePerGas).String()
+		response.MaxPriorityFeePerGas = new(big.Int).SetBytes(tx.MaxPriorityFeePerGas).String()
+	}
+	if len(tx.Data) >= 4 {
+		response.MethodId = formatMethodId(tx.Data[:4])
+	}
+
+	decoder := newEth1Decoder(true)
+	if len(tx.Data) >= 4 && len(tx.To) > 0 {
+		response.DecodedInput, err = decoder.decodeCall(tx.To, tx.Data)
+		if response.DecodedInput == nil && err != nil {
+			logger.Warnf("error decoding input of tx 0x%x: %v", txHash, err)
+		}
+	}
+
+	// the logs can be emitted by any contract of the call tree, only the called contract is fetched if it is unknown
+	logDecoder := decoder.storedOnly()
+	for i, log := range tx.Logs {
+		parsed := &types.Eth1LogParsed{
+			Index:   i,
+			Address: utils.FormatAddressString(log.Address),
+			Topics:  make([]string, 0, len(log.Topics)),
+			Data:    fmt.Sprintf("0x%x", log.Data),
+			Removed: log.Removed,
+		}
+		for _, topic := range log.Topics {
+			parsed.Topics = append(parsed.Topics, fmt.Sprintf("0x%x", topic))
+		}
+		parsed.Decoded, err = logDecoder.decodeLog(log.Address, log.Topics, log.Data)
+		if parsed.Decoded == nil && err != nil {
+			logger.Warnf("error decoding log %v of tx 0x%x: %v", i, txHash, err)
+		}
+		response.Logs = append(response.Logs, parsed)
+	}
+
+	for _, transfer := range erc20 {
+		response.ERC20Transfers = append(response.ERC20Transfers, &types.Eth1TokenTxParsed{
+			TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+			From:         utils.FormatAddressString(transfer.From),
+			To:           utils.FormatAddressString(transfer.To),
+			Value:        new(big.Int).SetBytes(transfer.Value).String(),
+		})
+	}
+	for _, transfer := range erc721 {
+		response.ERC721Transfers = append(response.ERC721Transfers, &types.Eth1TokenTxParsed{
+			TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+			From:         utils.FormatAddressString(transfer.From),
+			To:           utils.FormatAddressString(transfer.To),
+			TokenId:      new(big.Int).SetBytes(transfer.TokenId).String(),
+		})
+	}
+	for _, transfer := range erc1155 {
+		response.ERC1155Transfers = append(response.ERC1155Transfers, &types.Eth1TokenTxParsed{
+			TokenAddress: utils.FormatAddressString(transfer.TokenAddress),
+			From:         utils.FormatAddressString(transfer.From),
+			To:           utils.FormatAddressString(transfer.To),
+			Value:        new(big.Int).SetBytes(transfer.Value).String(),
+			TokenId:      new(big.Int).SetBytes(transfer.TokenId).String(),
+			Operator:     utils.FormatAddressString(transfer.Operator),
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1Reorgs godoc
+// @Summary Get the most recent chain reorgs
+// @Tags Execution
+// @Description Returns the chain reorgs handled by the indexer, most recent first, with the orphaned and canonical hashes of every affected block.
+// @Produce  json
+// @Param  limit query int false "Number of reorgs to return, at most 100 (default: 25)"
+// @Success 200 {object} types.ApiResponse{data=[]types.ApiEth1ReorgResponse}
+// @Failure 400 {object} types.ApiResponse
+// @Router /api/v1/execution/reorgs [get]
+func ApiEth1Reorgs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := int64(25)
+	if q := r.URL.Query().Get("limit"); q != "" {
+		var err error
+		limit, err = strconv.ParseInt(q, 10, 64)
+		if err != nil || limit < 1 || limit > 100 {
+			sendErrorResponse(w, r.URL.String(), "invalid limit provided, expected a value between 1 and 100")
+			return
+		}
+	}
+
+	reorgs, err := db.Storage.GetReorgs(limit)
+	if err != nil {
+		logger.Errorf("error retrieving reorgs route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve reorgs")
+		return
+	}
+
+	response := make([]types.ApiEth1ReorgResponse, 0, len(reorgs))
+	for _, reorg := range reorgs {
+		blocks := make([]types.ApiEth1ReorgBlockResponse, 0, len(reorg.Blocks))
+		for _, block := range reorg.Blocks {
+			b := types.ApiEth1ReorgBlockResponse{
+				Number:  block.Number,
+				OldHash: fmt.Sprintf("0x%x", block.OldHash),
+			}
+			if len(block.NewHash) > 0 {
+				b.NewHash = fmt.Sprintf("0x%x", block.NewHash)
+			}
+			blocks = append(blocks, b)
+		}
+		response = append(response, types.ApiEth1ReorgResponse{
+			ForkBlock:  reorg.ForkBlock,
+			Depth:      reorg.Depth,
+			DetectedAt: reorg.DetectedAt,
+			Blocks:     blocks,
+		})
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// ApiEth1Status godoc
+// @Summary Get the indexing progress of the execution layer transforms
+// @Tags Execution
+// @Description Returns the last block of the blocks table and the cursor of every data transform, the highest block up to which the transform has processed all blocks, with its lag behind the blocks table. Transforms which have not been run yet have no cursor.
+// @Produce  json
+// @Success 200 {object} types.ApiResponse{data=types.ApiEth1StatusResponse}
+// @Failure 500 {object} types.ApiResponse
+// @Router /api/v1/execution/status [get]
+func ApiEth1Status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	lastBlock, err := db.Storage.GetLastBlockInBlocksTable()
+	if err != nil {
+		logger.Errorf("error retrieving last block of the blocks table route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve status")
+		return
+	}
+	cursors, err := db.Storage.GetTransformCursors()
+	if err != nil {
+		logger.Errorf("error retrieving transform cursors route: %v, err: %v", r.URL.String(), err)
+		sendServerErrorResponse(w, r.URL.String(), "could not retrieve status")
+		return
+	}
+
+	transforms := db.Storage.DataTransforms()
+	names := make([]string, 0, len(ContractEventIndexes))
+	for name := range ContractEventIndexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		index := ContractEventIndexes[name]
+		transforms = append(transforms, &types.Eth1Transform{Name: index.Collection, Version: index.Version})
+	}
+
+	response := types.ApiEth1StatusResponse{
+		LastBlock:  uint64(lastBlock),
+		Transforms: make([]types.ApiEth1TransformStatusResponse, 0, len(transforms)),
+	}
+	for _, transform := range transforms {
+		status := types.ApiEth1TransformStatusResponse{
+			Name:    transform.Name,
+			Version: transform.Version,
+			Lag:     uint64(lastBlock) + 1,
+		}
+		for _, cursor := range cursors {
+			if cursor.Name != transform.Name || cursor.Version != transform.Version {
+				continue
+			}
+			block, updatedAt := cursor.Block, cursor.UpdatedAt
+			status.Block = &block
+			status.UpdatedAt = &updatedAt
+			status.Lag = 0
+			if uint64(lastBlock) > block {
+				status.Lag = uint64(lastBlock) - block
+			}
+		}
+		response.Transforms = append(response.Transforms, status)
+	}
+
+	sendOKResponse(json.NewEncoder(w), r.URL.String(), []interface{}{response})
+}
+
+// RegisterExecutionRoutes registers the execution layer api endpoints, they are served by both storage backends
+// and by the indexer process of the embedded backend
+func RegisterExecutionRoutes(apiV1Router *mux.Router) {
+	apiV1Router.HandleFunc("/execution/gasnow", ApiEth1GasNowData).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/block/{blockNumber}", ApiETH1ExecBlocks).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/tx/{hash}", ApiEth1Tx).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/reorgs", ApiEth1Reorgs).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/status", ApiEth1Status).Methods("GET", "OPTIONS")
+	// 	apiV1Router.HandleFunc("/execution/{addressIndexOrPubkey}/produced", ApiETH1AccountProducedBlocks).Methods("GET", "OPTIONS")
+
+	// query params: token (page token), limit, search
+	apiV1Router.HandleFunc("/execution/address/{address}", ApiEth1Address).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/address/{address}/transactions", ApiEth1AddressTx).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/address/{address}/internalTx", ApiEth1AddressItx).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/address/{address}/blocks", ApiEth1AddressBlocks).Methods("GET", "OPTIONS")
+	// 	apiV1Router.HandleFunc("/execution/address/{address}/uncles", ApiEth1AddressUncles).Methods("GET", "OPTIONS")
+	// query params: type={erc20,erc721,erc1155}, tokenAddress, token (page token), limit
+	apiV1Router.HandleFunc("/execution/address/{address}/tokens", ApiEth1AddressTokens).Methods("GET", "OPTIONS")
+	// query params: tokenAddress, block, days
+	apiV1Router.HandleFunc("/execution/address/{address}/balanceHistory", ApiEth1AddressBalanceHistory).Methods("GET", "OPTIONS")
+	// query params: tokenAddress
+	apiV1Router.HandleFunc("/execution/address/{address}/nfts", ApiEth1AddressNFTs).Methods("GET", "OPTIONS")
+	apiV1Router.HandleFunc("/execution/token/{token}/{id}", ApiEth1NFT).Methods("GET", "OPTIONS")
+	// query params: type={erc20,erc721,erc1155}, start, limit
+	apiV1Router.HandleFunc("/execution/tokens", ApiEth1Tokens).Methods("GET", "OPTIONS")
+	// query params: contract, event, fromBlock, toBlock, limit, param.<name>
+	apiV1Router.HandleFunc("/execution/events/{name}", ApiEth1ContractEvents).Methods("GET", "OPTIONS")
+	// body: types.ApiEth1LogFilterRequest, query params: token (page token), limit
+	apiV1Router.HandleFunc("/execution/logs", ApiEth1Logs).Methods("POST", "OPTIONS")
+	// body: types.ApiEth1VerifyContractRequest
+	apiV1Router.HandleFunc("/execution/contract/{address}/verify", ApiEth1VerifyContract).Methods("POST", "OPTIONS")
+}
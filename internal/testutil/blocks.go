@@ -0,0 +1,69 @@
+// Package testutil holds helpers shared by the tests of several packages
+package testutil
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fixtureGenesisTime is the timestamp of block 0 of the fixture blocks, the blocks are 12 seconds apart
+const fixtureGenesisTime = 1700000000
+
+// FixtureHash returns the hash of the fixture block at number of a branch, blocks of different branches at the same
+// height have different hashes
+func FixtureHash(number uint64, branch string) []byte {
+	return common.BytesToHash([]byte(fmt.Sprintf("%s%d", branch, number))).Bytes()
+}
+
+// FixtureBlock returns an empty block at number of a branch, its parent is the block at number-1 of the same branch
+func FixtureBlock(number uint64, branch string) *types.Eth1Block {
+	block := &types.Eth1Block{
+		Number: number,
+		Hash:   FixtureHash(number, branch),
+		Time:   timestamppb.New(time.Unix(fixtureGenesisTime+int64(number)*12, 0)),
+	}
+	if number > 0 {
+		block.ParentHash = FixtureHash(number-1, branch)
+	}
+	return block
+}
+
+// WriteFixtureBlocks runs the transforms on the blocks and writes their mutations to the database, like the indexer
+// does for the blocks of the blocks table. Every block is transformed with an empty cache, so the markers of already
+// seen tokens and balances are written for all of them. Blocks without a hash or a timestamp get the ones of
+// FixtureBlock.
+func WriteFixtureBlocks(database interface {
+	WriteBlockMutations(block *types.Eth1Block, bulkData *types.BulkMutations, metadataUpdates []mongo.WriteModel) error
+}, blocks []*types.Eth1Block, transforms ...func(blk *types.Eth1Block, cache *freecache.Cache) (*types.BulkMutations, []mongo.WriteModel, error)) error {
+	for _, block := range blocks {
+		cache := freecache.NewCache(1024 * 1024)
+		fixture := FixtureBlock(block.Number, "")
+		if block.Hash == nil {
+			block.Hash = fixture.Hash
+		}
+		if block.Time == nil {
+			block.Time = fixture.Time
+		}
+
+		mutations := &types.BulkMutations{}
+		var updates []mongo.WriteModel
+		for _, transform := range transforms {
+			transformMutations, transformUpdates, err := transform(block, cache)
+			if err != nil {
+				return fmt.Errorf("error transforming block %v: %w", block.Number, err)
+			}
+			mutations.Append(transformMutations)
+			updates = append(updates, transformUpdates...)
+		}
+		if err := database.WriteBlockMutations(block, mutations, updates); err != nil {
+			return fmt.Errorf("error writing mutations of block %v: %w", block.Number, err)
+		}
+	}
+	return nil
+}
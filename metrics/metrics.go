@@ -66,6 +66,48 @@ var (
 		Name: "notifications_sent",
 		Help: "Counter of notifications sent with the channel and notification type in the label",
 	}, []string{"channel", "status"})
+	ChainReorgs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chain_reorgs",
+		Help: "Counter of execution layer chain reorgs handled by the indexer",
+	})
+	ChainReorgDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "chain_reorg_depth",
+		Help:    "Number of blocks removed by execution layer chain reorgs",
+		Buckets: []float64{1, 2, 3, 5, 10, 20, 50, 100},
+	})
+	RpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_requests",
+		Help: "Counter of requests sent to the execution layer rpc endpoints by endpoint, method and status",
+	}, []string{"endpoint", "method", "status"})
+	RpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rpc_request_duration",
+		Help:    "Duration of requests sent to the execution layer rpc endpoints in seconds",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"endpoint", "method"})
+	RpcRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_retries",
+		Help: "Counter of requests retried after a failure of the endpoint",
+	}, []string{"endpoint"})
+	RpcRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_rate_limited",
+		Help: "Counter of requests delayed by the rate limit of the endpoint",
+	}, []string{"endpoint"})
+	RpcEndpointHead = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_endpoint_head",
+		Help: "Latest block reported by the endpoint during its last health check",
+	}, []string{"endpoint"})
+	RpcEndpointSelectable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_endpoint_selectable",
+		Help: "Whether the endpoint is healthy, in sync and its circuit breaker is closed",
+	}, []string{"endpoint"})
+	RpcCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_endpoint_circuit_open",
+		Help: "Whether the circuit breaker of the endpoint is open",
+	}, []string{"endpoint"})
+	BalanceChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "balance_checks",
+		Help: "Counter of balances retrieved from the node compared with the indexed balance history by result (match or mismatch)",
+	}, []string{"result"})
 )
 
 var logger = logrus.New().WithField("module", "metrics")
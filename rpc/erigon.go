@@ -2,18 +2,24 @@ package rpc
 
 import (
 	"context"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/Prajjawalk/zond-indexer/erc1155"
 	"github.com/Prajjawalk/zond-indexer/erc20"
+	"github.com/Prajjawalk/zond-indexer/erc721"
 	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -31,40 +37,118 @@ type ErigonClient struct {
 	rpcClient *geth_rpc.Client
 	ethClient *ethclient.Client
 
-	multiChecker *Balance
+	// multiChecker is the balance checker contract at multiCheckerAddress, it is nil if no contract has been set
+	multiChecker        *Balance
+	multiCheckerAddress common.Address
+
+	verifySignatures bool
+
+	// pool spreads the requests over several endpoints, it is nil for clients of a single endpoint
+	pool *Pool
+	// traceBlockUnsupported is set once the endpoint of a client without a pool answered trace_block with "method not
+	// found", a pool keeps track of the methods of its endpoints itself
+	traceBlockUnsupported int32
 }
 
 var CurrentErigonClient *ErigonClient
 
 func NewErigonClient(endpoint string) (*ErigonClient, error) {
 	logger.Infof("initializing erigon client at %v", endpoint)
-	client := &ErigonClient{
-		endpoint: endpoint,
-	}
 
-	rpcClient, err := geth_rpc.Dial(client.endpoint)
+	rpcClient, err := geth_rpc.Dial(endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("error dialing rpc node: %v", err)
 	}
-	client.rpcClient = rpcClient
+	return newErigonClient(endpoint, rpcClient)
+}
+
+// NewErigonClientWithTransport creates an erigon client for a http endpoint which sends all requests through the
+// passed transport, e.g. a FixtureTransport recording or replaying the responses of the node
+func NewErigonClientWithTransport(endpoint string, transport http.RoundTripper) (*ErigonClient, error) {
+	logger.Infof("initializing erigon client at %v with a custom transport", endpoint)
 
-	ethClient, err := ethclient.Dial(client.endpoint)
+	rpcClient, err := geth_rpc.DialHTTPWithClient(endpoint, &http.Client{Transport: transport})
 	if err != nil {
 		return nil, fmt.Errorf("error dialing rpc node: %v", err)
 	}
-	client.ethClient = ethClient
+	return newErigonClient(endpoint, rpcClient)
+}
+
+// NewErigonPoolClient creates an erigon client which spreads its requests over a pool of http endpoints, see Pool. If
+// next is not nil the pool sends its requests through it, e.g. to record them.
+func NewErigonPoolClient(endpoints []PoolEndpoint, config PoolConfig, next http.RoundTripper) (*ErigonClient, error) {
+	names := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.Name)
+	}
+	logger.Infof("initializing erigon client with a pool of %v endpoints: %v", len(endpoints), strings.Join(names, ", "))
 
-	client.multiChecker, err = NewBalance(common.HexToAddress("0xb1F8e55c7f64D203C1400B9D8555d050F94aDF39"), client.ethClient)
+	pool, err := NewPool(endpoints, config, next)
 	if err != nil {
-		return nil, fmt.Errorf("error initiation balance checker contract: %v", err)
+		return nil, err
+	}
+	rpcClient, err := geth_rpc.DialHTTPWithClient("http://pool", &http.Client{Transport: pool})
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("error dialing rpc node: %v", err)
+	}
+	client, err := newErigonClient("pool", rpcClient)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	client.pool = pool
+	return client, nil
+}
+
+func newErigonClient(endpoint string, rpcClient *geth_rpc.Client) (*ErigonClient, error) {
+	client := &ErigonClient{
+		endpoint:  endpoint,
+		rpcClient: rpcClient,
+		ethClient: ethclient.NewClient(rpcClient),
 	}
 
 	return client, nil
 }
 
+// SetBalanceChecker retrieves the balances through the balance checker contract deployed at address, a Z or 0x prefixed
+// address. The contract returns the balances of many tokens of an address with a single call.
+func (client *ErigonClient) SetBalanceChecker(address string) error {
+	parsed, err := utils.ParseAddress(address)
+	if err != nil {
+		return fmt.Errorf("invalid balance checker contract address: %w", err)
+	}
+	checkerAddress := common.Address(parsed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	code, err := client.ethClient.CodeAt(ctx, checkerAddress, nil)
+	if err != nil {
+		return fmt.Errorf("error retrieving code of balance checker contract %v: %w", checkerAddress, err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no balance checker contract deployed at %v", checkerAddress)
+	}
+
+	checker, err := NewBalance(checkerAddress, client.ethClient)
+	if err != nil {
+		return fmt.Errorf("error initiation balance checker contract: %v", err)
+	}
+	client.multiChecker, client.multiCheckerAddress = checker, checkerAddress
+	return nil
+}
+
+// SetVerifySignatures enables the verification of the dilithium signatures of all retrieved transactions
+func (client *ErigonClient) SetVerifySignatures(verify bool) {
+	client.verifySignatures = verify
+}
+
 func (client *ErigonClient) Close() {
 	client.rpcClient.Close()
 	client.ethClient.Close()
+	if client.pool != nil {
+		client.pool.Close()
+	}
 }
 
 func (client *ErigonClient) GetNativeClient() *ethclient.Client {
@@ -82,7 +166,7 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 	start := time.Now()
 	timings := &types.GetBlockTimings{}
 
-	block, err := client.ethClient.BlockByNumber(ctx, big.NewInt(int64(number)))
+	block, rpcTxs, err := client.getBlock(ctx, number)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -155,16 +239,16 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 
 	txs := block.Transactions()
 
-	for _, tx := range txs {
-
-		var from []byte
-		msg, err := tx.AsMessage(geth_types.NewLondonSigner(tx.ChainId()), big.NewInt(1))
+	for i, tx := range txs {
+		from, verified, err := transactionSender(rpcTxs[i], client.verifySignatures)
 		if err != nil {
-			from, _ = hex.DecodeString("abababababababababababababababababababab")
+			return nil, nil, fmt.Errorf("error retrieving sender of tx %v in block %v: %w", i, block.Number(), err)
+		}
 
-			logrus.Errorf("error converting tx %v to msg: %v", tx.Hash(), err)
-		} else {
-			from = msg.From().Bytes()
+		// the hash computed by go-ethereum does not cover the dilithium signature fields, the hash of the node is used
+		hash := rpcTxs[i].Hash
+		if hash == (common.Hash{}) {
+			hash = tx.Hash()
 		}
 
 		pbTx := &types.Eth1Transaction{
@@ -176,11 +260,17 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 			Gas:                  tx.Gas(),
 			Value:                tx.Value().Bytes(),
 			Data:                 tx.Data(),
-			From:                 from,
+			From:                 from.Bytes(),
 			ChainId:              tx.ChainId().Bytes(),
 			AccessList:           []*types.AccessList{},
-			Hash:                 tx.Hash().Bytes(),
+			Hash:                 hash.Bytes(),
 			Itx:                  []*types.Eth1InternalTransaction{},
+			SignatureVerified:    verified,
+		}
+
+		if len(rpcTxs[i].PublicKey) > 0 {
+			pbTx.PublicKeyHash = utils.PubkeyHash(rpcTxs[i].PublicKey)
+			pbTx.SignatureHash = utils.PubkeyHash(rpcTxs[i].Signature)
 		}
 
 		if tx.To() != nil {
@@ -193,11 +283,24 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 	g := new(errgroup.Group)
 
 	g.Go(func() error {
-		traces, err := client.TraceParity(block.NumberU64())
-
-		if err != nil {
-			logger.Errorf("error tracing block via parity style traces (%v), %v: %v", block.Number(), block.Hash(), err)
+		var traces []*ParityTraceResult
+		var err error
+		traceBlock := client.supportsTraceBlock()
+		if traceBlock {
+			traces, err = client.TraceParity(block.NumberU64())
+			if isMethodNotFound(err) {
+				// the pool only answers "method not found" if none of its endpoints supports the method, the fallback is
+				// sent to the endpoints with the debug api
+				logger.Infof("trace_block is not supported, tracing blocks via debug_traceBlockByHash: %v", err)
+				if client.pool == nil {
+					atomic.StoreInt32(&client.traceBlockUnsupported, 1)
+				}
+			} else if err != nil {
+				logger.Errorf("error tracing block via parity style traces (%v), %v: %v", block.Number(), block.Hash(), err)
+			}
+		}
 
+		if !traceBlock || err != nil {
 			gethTraceData, err := client.TraceGeth(block.Hash())
 
 			if err != nil {
@@ -220,8 +323,9 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 				}
 
 				tracePb := &types.Eth1InternalTransaction{
-					Type: strings.ToLower(trace.Type),
-					Path: "0",
+					Type:     strings.ToLower(trace.Type),
+					Path:     "0",
+					ErrorMsg: trace.Error,
 				}
 
 				tracePb.From = trace.From.Bytes()
@@ -244,6 +348,8 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 		timings.Traces = time.Since(start)
 
 		// logrus.Infof("retrieved %v traces for %v txs", len(traces), len(c.Transactions))
+		// errors of failed calls by tx position and trace address, the calls made by a failed call are reverted with it
+		failedCalls := make(map[string]string)
 		for _, trace := range traces {
 			if trace.Type == "reward" {
 				continue
@@ -261,8 +367,15 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 			}
 
 			tracePb := &types.Eth1InternalTransaction{
-				Type: trace.Type,
-				Path: fmt.Sprint(trace.TraceAddress),
+				Type:     trace.Type,
+				Path:     fmt.Sprint(trace.TraceAddress),
+				ErrorMsg: trace.Error,
+			}
+			if n := len(trace.TraceAddress); tracePb.ErrorMsg == "" && n > 0 {
+				tracePb.ErrorMsg = failedCalls[fmt.Sprint(trace.TransactionPosition, trace.TraceAddress[:n-1])]
+			}
+			if tracePb.ErrorMsg != "" {
+				failedCalls[fmt.Sprint(trace.TransactionPosition, trace.TraceAddress)] = tracePb.ErrorMsg
 			}
 
 			if trace.Type == "create" {
@@ -274,6 +387,10 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 				tracePb.To = common.FromHex(trace.Action.RefundAddress)
 				tracePb.Value = common.FromHex(trace.Action.Balance)
 			} else if trace.Type == "call" || trace.Type == "delegatecall" {
+				if trace.Action.CallType != "" {
+					// like geth style traces, delegate and static calls are stored with their call type
+					tracePb.Type = trace.Action.CallType
+				}
 				tracePb.From = common.FromHex(trace.Action.From)
 				tracePb.To = common.FromHex(trace.Action.To)
 				tracePb.Value = common.FromHex(trace.Action.Value)
@@ -290,7 +407,7 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 	for i := range reqs {
 		reqs[i] = geth_rpc.BatchElem{
 			Method: "eth_getTransactionReceipt",
-			Args:   []interface{}{txs[i].Hash().String()},
+			Args:   []interface{}{fmt.Sprintf("0x%x", c.Transactions[i].Hash)},
 			Result: &receipts[i],
 		}
 	}
@@ -337,6 +454,11 @@ func (client *ErigonClient) GetBlock(number int64) (*types.Eth1Block, *types.Get
 		return nil, nil, fmt.Errorf("error retrieving traces for block %v: %v", block.Number(), err)
 	}
 
+	// the traces only report the errors of single calls, a tx whose failed call was handled by its caller succeeded
+	for i := range receipts {
+		c.Transactions[i].Status = receipts[i].Status
+	}
+
 	return c, timings, nil
 }
 
@@ -351,6 +473,18 @@ func (client *ErigonClient) GetBlockNumberByHash(hash string) (uint64, error) {
 	return block.NumberU64(), nil
 }
 
+// GetBlockHash returns the hash of the canonical block at the passed height
+func (client *ErigonClient) GetBlockHash(number uint64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	header, err := client.ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, fmt.Errorf("error getting header of block %v: %w", number, err)
+	}
+	return header.Hash().Bytes(), nil
+}
+
 func (client *ErigonClient) GetLatestEth1BlockNumber() (uint64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
@@ -402,12 +536,21 @@ func extractCalls(r *GethTraceCallResult, d *[]*GethTraceCallResult) {
 	}
 	for _, c := range r.Calls {
 		c.TransactionPosition = r.TransactionPosition
+		if c.Error == "" {
+			// the calls made by a failed call are reverted with it
+			c.Error = r.Error
+		}
 		extractCalls(c, d)
 	}
 }
 
 func (client *ErigonClient) TraceGeth(blockHash common.Hash) ([]*GethTraceCallResult, error) {
-	var res []*GethTraceCallResult
+	// the call of every transaction is wrapped in the trace result of the transaction
+	var res []struct {
+		TxHash common.Hash          `json:"txHash"`
+		Result *GethTraceCallResult `json:"result"`
+		Error  string               `json:"error"`
+	}
 
 	err := client.rpcClient.Call(&res, "debug_traceBlockByHash", blockHash, gethTracerArg)
 	if err != nil {
@@ -416,8 +559,11 @@ func (client *ErigonClient) TraceGeth(blockHash common.Hash) ([]*GethTraceCallRe
 
 	data := make([]*GethTraceCallResult, 0, 20)
 	for i, r := range res {
-		r.TransactionPosition = i
-		extractCalls(r, &data)
+		if r.Error != "" || r.Result == nil {
+			return nil, fmt.Errorf("error tracing tx %v of block %v: %v", i, blockHash, r.Error)
+		}
+		r.Result.TransactionPosition = i
+		extractCalls(r.Result, &data)
 	}
 
 	return data, nil
@@ -460,7 +606,7 @@ func (client *ErigonClient) TraceParity(blockNumber uint64) ([]*ParityTraceResul
 
 	hexString := strconv.FormatUint(blockNumber, 16)
 
-	err := client.rpcClient.Call(&res, "debug_traceBlockByNumber", "0x"+hexString)
+	err := client.rpcClient.Call(&res, "trace_block", "0x"+hexString)
 	if err != nil {
 		return nil, err
 	}
@@ -468,6 +614,21 @@ func (client *ErigonClient) TraceParity(blockNumber uint64) ([]*ParityTraceResul
 	return res, nil
 }
 
+// supportsTraceBlock reports whether trace_block might be answered by the endpoints of the client, it is false once all
+// of them answered it with "method not found" and the traces are retrieved via debug_traceBlockByHash only
+func (client *ErigonClient) supportsTraceBlock() bool {
+	if client.pool != nil {
+		return client.pool.Supports("trace_block")
+	}
+	return atomic.LoadInt32(&client.traceBlockUnsupported) == 0
+}
+
+// isMethodNotFound reports whether a request failed because the endpoint does not support its method
+func isMethodNotFound(err error) bool {
+	var rpcErr geth_rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == jsonrpcMethodNotFound
+}
+
 func (client *ErigonClient) TraceParityTx(txHash string) ([]*ParityTraceResult, error) {
 	var res []*ParityTraceResult
 
@@ -479,68 +640,198 @@ func (client *ErigonClient) TraceParityTx(txHash string) ([]*ParityTraceResult,
 	return res, nil
 }
 
-func (client *ErigonClient) GetBalances(pairs []*types.Eth1AddressBalance, addressIndex, tokenIndex int) ([]*types.Eth1AddressBalance, error) {
-	batchElements := make([]geth_rpc.BatchElem, 0, len(pairs))
-
+// GetBalances retrieves the native and token balances of the pairs as of block, a pair with a token shorter than an
+// address is a native balance. The balances are retrieved through the balance checker contract if one has been set,
+// otherwise and for the addresses the contract fails for they are retrieved with eth_getBalance and balanceOf calls.
+// All calls are sent as a single batch request pinned to block, so the balances are consistent with each other.
+func (client *ErigonClient) GetBalances(pairs []*types.Eth1AddressBalance, block uint64) ([]*types.Eth1AddressBalance, error) {
 	ret := make([]*types.Eth1AddressBalance, len(pairs))
-
 	for i, pair := range pairs {
+		ret[i] = &types.Eth1AddressBalance{
+			Address:     pair.Address,
+			Token:       pair.Token,
+			BlockNumber: block,
+		}
+	}
 
-		// if s[1] != "B" {
-		// 	logrus.Fatalf("%v has invalid balance update prefix", pair)
-		// }
+	remaining := ret
+	if client.multiChecker != nil {
+		var err error
+		remaining, err = client.getCheckerBalances(ret, block)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		result := ""
+	err := client.getNodeBalances(remaining, block)
+	if err != nil {
+		return nil, err
+	}
 
-		ret[i] = &types.Eth1AddressBalance{
-			Address: pair.Address,
-			Token:   pair.Token,
+	return ret, nil
+}
+
+// getCheckerBalances retrieves the balances with one balance checker contract call per address and returns the
+// balances of the addresses the contract call failed for
+func (client *ErigonClient) getCheckerBalances(balances []*types.Eth1AddressBalance, block uint64) ([]*types.Eth1AddressBalance, error) {
+	checkerAbi, err := BalanceMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([][]*types.Eth1AddressBalance, 0, len(balances))
+	groupIndex := make(map[string]int)
+	for _, balance := range balances {
+		i, ok := groupIndex[string(balance.Address)]
+		if !ok {
+			i = len(groups)
+			groupIndex[string(balance.Address)] = i
+			groups = append(groups, nil)
 		}
+		groups[i] = append(groups[i], balance)
+	}
 
-		// logger.Infof("retrieving balance for %x / %x", ret[i].Address, ret[i].Token)
+	batchElements := make([]geth_rpc.BatchElem, 0, len(groups))
+	results := make([]hexutil.Bytes, len(groups))
+	for i, group := range groups {
+		tokens := make([]common.Address, 0, len(group))
+		for _, balance := range group {
+			// the balance checker contract returns the native balance for the zero address
+			if len(balance.Token) < 20 {
+				tokens = append(tokens, common.Address{})
+			} else {
+				tokens = append(tokens, common.BytesToAddress(balance.Token))
+			}
+		}
+		data, err := checkerAbi.Pack("balances", []common.Address{common.BytesToAddress(group[0].Address)}, tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		batchElements = append(batchElements, geth_rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{toCallArg(ethereum.CallMsg{To: &client.multiCheckerAddress, Data: data}), hexutil.EncodeUint64(block)},
+			Result: &results[i],
+		})
+	}
+
+	err = client.rpcClient.BatchCall(batchElements)
+	if err != nil {
+		return nil, fmt.Errorf("error during batch request: %v", err)
+	}
+
+	remaining := make([]*types.Eth1AddressBalance, 0)
+	for i, el := range batchElements {
+		group := groups[i]
+		if el.Error != nil {
+			logrus.Warnf("error retrieving balances of address %x from balance checker contract, falling back to eth_getBalance and balanceOf calls: %v", group[0].Address, el.Error)
+			remaining = append(remaining, group...)
+			continue
+		}
+
+		values, err := checkerAbi.Unpack("balances", results[i])
+		if err == nil && len(values) != 1 {
+			err = fmt.Errorf("unexpected number of return values %v", len(values))
+		}
+		var amounts []*big.Int
+		if err == nil {
+			amounts = *abi.ConvertType(values[0], new([]*big.Int)).(*[]*big.Int)
+			if len(amounts) != len(group) {
+				err = fmt.Errorf("got %v balances for %v tokens", len(amounts), len(group))
+			}
+		}
+		if err != nil {
+			logrus.Warnf("error decoding balances of address %x from balance checker contract, falling back to eth_getBalance and balanceOf calls: %v", group[0].Address, err)
+			remaining = append(remaining, group...)
+			continue
+		}
+
+		for j, balance := range group {
+			balance.Balance = amounts[j].Bytes()
+		}
+	}
+
+	return remaining, nil
+}
 
-		if len(pair.Token) < 20 {
+// getNodeBalances retrieves the balances with eth_getBalance and balanceOf calls. A failing balanceOf call is a broken
+// or non erc20 token contract and results in a zero balance, every other error is returned.
+func (client *ErigonClient) getNodeBalances(balances []*types.Eth1AddressBalance, block uint64) error {
+	if len(balances) == 0 {
+		return nil
+	}
+
+	batchElements := make([]geth_rpc.BatchElem, 0, len(balances))
+	results := make([]string, len(balances))
+	for i, balance := range balances {
+		if len(balance.Token) < 20 {
 			batchElements = append(batchElements, geth_rpc.BatchElem{
 				Method: "eth_getBalance",
-				Args:   []interface{}{common.BytesToAddress(pair.Address), "latest"},
-				Result: &result,
+				Args:   []interface{}{common.BytesToAddress(balance.Address), hexutil.EncodeUint64(block)},
+				Result: &results[i],
 			})
 		} else {
-			to := common.BytesToAddress(pair.Token)
+			to := common.BytesToAddress(balance.Token)
 			msg := ethereum.CallMsg{
 				To:   &to,
 				Gas:  1000000,
-				Data: common.Hex2Bytes(fmt.Sprintf("70a08231000000000000000000000000%x", pair.Address)),
+				Data: common.Hex2Bytes(fmt.Sprintf("70a08231000000000000000000000000%x", common.BytesToAddress(balance.Address))),
 			}
 
 			batchElements = append(batchElements, geth_rpc.BatchElem{
 				Method: "eth_call",
-				Args:   []interface{}{toCallArg(msg), "latest"},
-				Result: &result,
+				Args:   []interface{}{toCallArg(msg), hexutil.EncodeUint64(block)},
+				Result: &results[i],
 			})
 		}
 	}
 
 	err := client.rpcClient.BatchCall(batchElements)
 	if err != nil {
-		return nil, fmt.Errorf("error during batch request: %v", err)
+		return fmt.Errorf("error during batch request: %v", err)
 	}
 
 	for i, el := range batchElements {
+		balance := balances[i]
 		if el.Error != nil {
-			logrus.Warnf("error in batch call: %v", el.Error) // PPR: are smart contracts that pretend to implement the erc20 standard but are somehow buggy
+			var rpcErr geth_rpc.Error
+			if el.Method == "eth_call" && errors.As(el.Error, &rpcErr) {
+				// PPR: are smart contracts that pretend to implement the erc20 standard but are somehow buggy
+				logrus.Warnf("error retrieving balance of address %x for token %x: %v", balance.Address, balance.Token, el.Error)
+				balance.Balance = []byte{}
+				continue
+			}
+			return fmt.Errorf("error retrieving balance of address %x for token %x at block %v: %w", balance.Address, balance.Token, block, el.Error)
 		}
 
-		res := strings.TrimPrefix(*el.Result.(*string), "0x")
-		ret[i].Balance = new(big.Int).SetBytes(common.FromHex(res)).Bytes()
-
-		// logger.Infof("retrieved balance %x / %x: %x (%v)", ret[i].Address, ret[i].Token, ret[i].Balance, *el.Result.(*string))
+		value := common.FromHex(results[i])
+		if len(value) > 32 {
+			// only the first word of the return data is the balance
+			value = value[:32]
+		}
+		balance.Balance = new(big.Int).SetBytes(value).Bytes()
 	}
 
-	return ret, nil
+	return nil
+}
+
+// GetCode retrieves the runtime bytecode deployed at address at the latest block, empty if there is no contract
+func (client *ErigonClient) GetCode(address []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	code, err := client.ethClient.CodeAt(ctx, common.BytesToAddress(address), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving code of %x: %w", address, err)
+	}
+	return code, nil
 }
 
 func (client *ErigonClient) GetBalancesForAddresse(address string, tokenStr []string) ([]*types.Eth1AddressBalance, error) {
+	if client.multiChecker == nil {
+		return nil, fmt.Errorf("no balance checker contract set")
+	}
+
 	opts := &bind.CallOpts{
 		BlockNumber: nil,
 	}
@@ -560,7 +851,7 @@ func (client *ErigonClient) GetBalancesForAddresse(address string, tokenStr []st
 
 		res[tokenIdx] = &types.Eth1AddressBalance{
 			Address: common.FromHex(address),
-			Token:   common.FromHex(string(tokens[tokenIdx].Bytes())),
+			Token:   tokens[tokenIdx].Bytes(),
 			Balance: balancesInt[tokenIdx].Bytes(),
 		}
 	}
@@ -597,6 +888,8 @@ func (client *ErigonClient) GetERC20TokenBalance(address string, token string) (
 	return balance, nil
 }
 
+// GetERC20TokenMetadata retrieves the name, symbol, decimals and total supply of a token contract. Fields whose method is
+// not implemented by the contract are left empty, an unknown symbol is reported as UNKNOWN.
 func (client *ErigonClient) GetERC20TokenMetadata(token []byte) (*types.ERC20Metadata, error) {
 
 	logger.Infof("retrieving metadata for token %x", token)
@@ -613,7 +906,7 @@ func (client *ErigonClient) GetERC20TokenMetadata(token []byte) (*types.ERC20Met
 	g.Go(func() error {
 		symbol, err := contract.Symbol(nil)
 		if err != nil {
-			if strings.Contains(err.Error(), "abi") {
+			if isMissingMethod(err) {
 				ret.Symbol = "UNKNOWN"
 				return nil
 			}
@@ -625,9 +918,24 @@ func (client *ErigonClient) GetERC20TokenMetadata(token []byte) (*types.ERC20Met
 		return nil
 	})
 
+	g.Go(func() error {
+		name, err := contract.Name(nil)
+		if err != nil {
+			if isMissingMethod(err) {
+				return nil
+			}
+			return fmt.Errorf("error retrieving name: %v", err)
+		}
+		ret.Name = name
+		return nil
+	})
+
 	g.Go(func() error {
 		totalSupply, err := contract.TotalSupply(nil)
 		if err != nil {
+			if isMissingMethod(err) {
+				return nil
+			}
 			return fmt.Errorf("error retrieving total supply: %v", err)
 		}
 		ret.TotalSupply = totalSupply.Bytes()
@@ -637,6 +945,9 @@ func (client *ErigonClient) GetERC20TokenMetadata(token []byte) (*types.ERC20Met
 	g.Go(func() error {
 		decimals, err := contract.Decimals(nil)
 		if err != nil {
+			if isMissingMethod(err) {
+				return nil
+			}
 			return fmt.Errorf("error retrieving decimals: %v", err)
 		}
 		ret.Decimals = big.NewInt(int64(decimals)).Bytes()
@@ -647,6 +958,112 @@ func (client *ErigonClient) GetERC20TokenMetadata(token []byte) (*types.ERC20Met
 	return ret, err
 }
 
+// jsonrpcExecutionReverted is the error code of a call which reverted with a reason
+const jsonrpcExecutionReverted = 3
+
+// isMissingMethod reports whether a contract call failed because the contract does not implement the method: the call
+// reverted, the address has no code or the returned data can't be decoded. Other errors of the node, e.g. a timeout or
+// a missing block, are not.
+func isMissingMethod(err error) bool {
+	var rpcErr geth_rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == jsonrpcExecutionReverted {
+		return true
+	}
+	return errors.Is(err, bind.ErrNoCode) || strings.Contains(err.Error(), "execution reverted") || strings.HasPrefix(err.Error(), "abi: ")
+}
+
+// ERC-165 interfaces of the token standards reported by GetTokenInterfaces
+const (
+	InterfaceERC721           = "erc721"
+	InterfaceERC721Metadata   = "erc721metadata"
+	InterfaceERC721Enumerable = "erc721enumerable"
+	InterfaceERC1155          = "erc1155"
+	InterfaceERC1155Metadata  = "erc1155metadata"
+)
+
+var tokenInterfaceIds = []struct {
+	name string
+	id   [4]byte
+}{
+	{name: InterfaceERC721, id: [4]byte{0x80, 0xac, 0x58, 0xcd}},
+	{name: InterfaceERC721Metadata, id: [4]byte{0x5b, 0x5e, 0x13, 0x9f}},
+	{name: InterfaceERC721Enumerable, id: [4]byte{0x78, 0x0e, 0x9d, 0x63}},
+	{name: InterfaceERC1155, id: [4]byte{0xd9, 0xb6, 0x7a, 0x26}},
+	{name: InterfaceERC1155Metadata, id: [4]byte{0x0e, 0x89, 0x34, 0x1c}},
+}
+
+// GetTokenInterfaces returns the token interfaces a contract reports via ERC-165 supportsInterface, nil if the contract
+// does not implement ERC-165
+func (client *ErigonClient) GetTokenInterfaces(token []byte) ([]string, error) {
+	contract, err := erc721.NewErc721Caller(common.BytesToAddress(token), client.ethClient)
+	if err != nil {
+		return nil, err
+	}
+
+	supports := func(id [4]byte) (bool, error) {
+		supported, err := contract.SupportsInterface(nil, id)
+		if err != nil && isMissingMethod(err) {
+			return false, nil
+		}
+		return supported, err
+	}
+
+	// a contract implementing ERC-165 supports its own interface id but not 0xffffffff
+	erc165, err := supports([4]byte{0x01, 0xff, 0xc9, 0xa7})
+	if err != nil || !erc165 {
+		return nil, err
+	}
+	invalid, err := supports([4]byte{0xff, 0xff, 0xff, 0xff})
+	if err != nil || invalid {
+		return nil, err
+	}
+
+	interfaces := []string{}
+	for _, i := range tokenInterfaceIds {
+		supported, err := supports(i.id)
+		if err != nil {
+			return nil, fmt.Errorf("error checking interface %v of token %x: %w", i.name, token, err)
+		}
+		if supported {
+			interfaces = append(interfaces, i.name)
+		}
+	}
+	return interfaces, nil
+}
+
+// GetNFTTokenURI retrieves the metadata uri of a token id via tokenURI for ERC-721 and uri for ERC-1155 tokens. The
+// {id} placeholder of ERC-1155 uris is replaced by the token id, an empty uri is returned if the contract does not
+// implement the method or reverts, e.g. for a burned token.
+func (client *ErigonClient) GetNFTTokenURI(token []byte, tokenId []byte, erc1155Token bool) (string, error) {
+	id := new(big.Int).SetBytes(tokenId)
+
+	var uri string
+	var err error
+	if erc1155Token {
+		var contract *erc1155.Erc1155Caller
+		contract, err = erc1155.NewErc1155Caller(common.BytesToAddress(token), client.ethClient)
+		if err != nil {
+			return "", err
+		}
+		uri, err = contract.Uri(nil, id)
+		uri = strings.ReplaceAll(uri, "{id}", fmt.Sprintf("%064x", id))
+	} else {
+		var contract *erc721.Erc721Caller
+		contract, err = erc721.NewErc721Caller(common.BytesToAddress(token), client.ethClient)
+		if err != nil {
+			return "", err
+		}
+		uri, err = contract.TokenURI(nil, id)
+	}
+	if err != nil {
+		if isMissingMethod(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error retrieving uri of token %x id %v: %w", token, id, err)
+	}
+	return uri, nil
+}
+
 func toCallArg(msg ethereum.CallMsg) interface{} {
 	arg := map[string]interface{}{
 		"from": msg.From,
@@ -0,0 +1,249 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	balanceTestChecker = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	balanceTestToken   = common.HexToAddress("0x00000000000000000000000000000000000000e2")
+	// balanceTestBroken is a token contract whose balanceOf reverts
+	balanceTestBroken = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+)
+
+// balanceTestNode answers eth_getBalance, balanceOf calls of balanceTestToken and balances calls of the balance checker
+// contract at balanceTestChecker with balances depending on the block. The balance checker contract reverts for tokens
+// without a balanceOf method, like the deployed contract does. It records the block parameters of all requests.
+type balanceTestNode struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	blocks map[string]int
+	calls  map[string]int
+}
+
+// balanceTestValue returns the balance of address for token at block, the native token is the zero address
+func balanceTestValue(address, token common.Address, block uint64) *big.Int {
+	value := new(big.Int).SetBytes(address.Bytes())
+	if token != (common.Address{}) {
+		value.Mul(value, big.NewInt(1000))
+	}
+	return value.Add(value, new(big.Int).SetUint64(block))
+}
+
+func newBalanceTestNode(t *testing.T) *balanceTestNode {
+	checkerAbi, err := BalanceMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &balanceTestNode{blocks: make(map[string]int), calls: make(map[string]int)}
+
+	type callArg struct {
+		To   common.Address `json:"to"`
+		Data hexutil.Bytes  `json:"data"`
+	}
+	type message struct {
+		Version string            `json:"jsonrpc"`
+		ID      json.RawMessage   `json:"id"`
+		Method  string            `json:"method,omitempty"`
+		Params  []json.RawMessage `json:"params,omitempty"`
+		Result  interface{}       `json:"result,omitempty"`
+		Error   interface{}       `json:"error,omitempty"`
+	}
+	revert := map[string]interface{}{"code": 3, "message": "execution reverted"}
+
+	handle := func(req message) message {
+		resp := message{Version: "2.0", ID: req.ID}
+		if req.Method == "eth_getCode" {
+			resp.Result = hexutil.Bytes{0x60, 0x80}
+			return resp
+		}
+
+		var tag string
+		if err := json.Unmarshal(req.Params[len(req.Params)-1], &tag); err != nil {
+			t.Error(err)
+			return resp
+		}
+		block, err := hexutil.DecodeUint64(tag)
+		if err != nil {
+			t.Errorf("%v request is not pinned to a block: %v", req.Method, tag)
+			return resp
+		}
+
+		node.mu.Lock()
+		node.blocks[tag]++
+		node.calls[req.Method]++
+		node.mu.Unlock()
+
+		switch req.Method {
+		case "eth_getBalance":
+			var address common.Address
+			if err := json.Unmarshal(req.Params[0], &address); err != nil {
+				t.Error(err)
+			}
+			resp.Result = (*hexutil.Big)(balanceTestValue(address, common.Address{}, block))
+		case "eth_call":
+			var arg callArg
+			if err := json.Unmarshal(req.Params[0], &arg); err != nil {
+				t.Error(err)
+			}
+
+			switch arg.To {
+			case balanceTestToken:
+				address := common.BytesToAddress(arg.Data[4:])
+				resp.Result = hexutil.Bytes(common.LeftPadBytes(balanceTestValue(address, balanceTestToken, block).Bytes(), 32))
+			case balanceTestChecker:
+				node.mu.Lock()
+				node.calls["balances"]++
+				node.mu.Unlock()
+
+				args, err := checkerAbi.Methods["balances"].Inputs.Unpack(arg.Data[4:])
+				if err != nil {
+					t.Error(err)
+					return resp
+				}
+				users, tokens := args[0].([]common.Address), args[1].([]common.Address)
+				values := make([]*big.Int, 0, len(users)*len(tokens))
+				for _, user := range users {
+					for _, token := range tokens {
+						if token != (common.Address{}) && token != balanceTestToken {
+							resp.Error = revert
+							return resp
+						}
+						values = append(values, balanceTestValue(user, token, block))
+					}
+				}
+				data, err := checkerAbi.Methods["balances"].Outputs.Pack(values)
+				if err != nil {
+					t.Error(err)
+				}
+				resp.Result = hexutil.Bytes(data)
+			default:
+				resp.Error = revert
+			}
+		default:
+			t.Errorf("unexpected method %v", req.Method)
+		}
+		return resp
+	}
+
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		if bytes.HasPrefix(body, []byte("[")) {
+			var reqs []message
+			if err := json.Unmarshal(body, &reqs); err != nil {
+				t.Error(err)
+				return
+			}
+			resps := make([]message, 0, len(reqs))
+			for _, req := range reqs {
+				resps = append(resps, handle(req))
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+		var req message
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+			return
+		}
+		json.NewEncoder(w).Encode(handle(req))
+	}))
+	return node
+}
+
+func TestGetBalances(t *testing.T) {
+	alice := common.HexToAddress("0x0000000000000000000000000000000000000a11")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	native := []byte{0x0}
+
+	pairs := []*types.Eth1AddressBalance{
+		{Address: alice.Bytes(), Token: native},
+		{Address: alice.Bytes(), Token: balanceTestToken.Bytes()},
+		{Address: bob.Bytes(), Token: balanceTestToken.Bytes()},
+		{Address: bob.Bytes(), Token: balanceTestBroken.Bytes()},
+		{Address: bob.Bytes(), Token: native},
+	}
+	const block = 100
+	want := []*big.Int{
+		balanceTestValue(alice, common.Address{}, block),
+		balanceTestValue(alice, balanceTestToken, block),
+		balanceTestValue(bob, balanceTestToken, block),
+		big.NewInt(0),
+		balanceTestValue(bob, common.Address{}, block),
+	}
+
+	tests := []struct {
+		name             string
+		checker          bool
+		wantCheckerCalls int
+		wantNodeCalls    int
+	}{
+		// without a checker contract every balance is retrieved with its own call
+		{name: "node calls", wantNodeCalls: 5},
+		// the checker contract fails for bob because of the broken token, so the balances of bob are retrieved with node calls
+		{name: "balance checker", checker: true, wantCheckerCalls: 2, wantNodeCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newBalanceTestNode(t)
+			defer node.Close()
+			client, err := NewErigonClient(node.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			if tt.checker {
+				// Zond addresses are Z prefixed
+				if err := client.SetBalanceChecker("Z" + balanceTestChecker.Hex()[2:]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := client.GetBalances(pairs, block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(pairs) {
+				t.Fatalf("got %v balances, want %v", len(got), len(pairs))
+			}
+			for i, balance := range got {
+				if !bytes.Equal(balance.Address, pairs[i].Address) || !bytes.Equal(balance.Token, pairs[i].Token) {
+					t.Errorf("balance %v is for %x / %x, want %x / %x", i, balance.Address, balance.Token, pairs[i].Address, pairs[i].Token)
+				}
+				if value := new(big.Int).SetBytes(balance.Balance); value.Cmp(want[i]) != 0 {
+					t.Errorf("balance %v = %v, want %v", i, value, want[i])
+				}
+				if balance.BlockNumber != block {
+					t.Errorf("balance %v is at block %v, want %v", i, balance.BlockNumber, block)
+				}
+			}
+
+			if len(node.blocks) != 1 || node.blocks[hexutil.EncodeUint64(block)] == 0 {
+				t.Errorf("requests were sent for blocks %v, want only block %v", node.blocks, block)
+			}
+			if node.calls["balances"] != tt.wantCheckerCalls {
+				t.Errorf("balance checker contract received %v calls, want %v", node.calls["balances"], tt.wantCheckerCalls)
+			}
+			if calls := node.calls["eth_getBalance"] + node.calls["eth_call"] - node.calls["balances"]; calls != tt.wantNodeCalls {
+				t.Errorf("node received %v eth_getBalance and balanceOf calls, want %v", calls, tt.wantNodeCalls)
+			}
+		})
+	}
+}
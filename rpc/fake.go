@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+
+	"github.com/ethereum/go-ethereum"
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeEth1Client is an in-memory Eth1Client serving a fixed set of blocks, it is used to run the indexer offline.
+// The canonical chain can be changed with SetBlocks and RemoveBlocks to simulate reorgs.
+type FakeEth1Client struct {
+	mux    sync.Mutex
+	blocks map[uint64]*types.Eth1Block
+}
+
+var _ Eth1Client = (*FakeEth1Client)(nil)
+
+func NewFakeEth1Client(blocks ...*types.Eth1Block) *FakeEth1Client {
+	client := &FakeEth1Client{blocks: make(map[uint64]*types.Eth1Block)}
+	client.SetBlocks(blocks...)
+	return client
+}
+
+// SetBlocks adds the passed blocks to the canonical chain, replacing the blocks at the same heights
+func (client *FakeEth1Client) SetBlocks(blocks ...*types.Eth1Block) {
+	client.mux.Lock()
+	defer client.mux.Unlock()
+
+	for _, block := range blocks {
+		client.blocks[block.Number] = proto.Clone(block).(*types.Eth1Block)
+	}
+}
+
+// RemoveBlocks removes all blocks from the passed height on from the canonical chain
+func (client *FakeEth1Client) RemoveBlocks(from uint64) {
+	client.mux.Lock()
+	defer client.mux.Unlock()
+
+	for number := range client.blocks {
+		if number >= from {
+			delete(client.blocks, number)
+		}
+	}
+}
+
+func (client *FakeEth1Client) GetBlock(number int64) (*types.Eth1Block, *types.GetBlockTimings, error) {
+	client.mux.Lock()
+	defer client.mux.Unlock()
+
+	block, ok := client.blocks[uint64(number)]
+	if !ok || number < 0 {
+		return nil, nil, fmt.Errorf("error retrieving block %v: %w", number, ethereum.NotFound)
+	}
+	return proto.Clone(block).(*types.Eth1Block), &types.GetBlockTimings{}, nil
+}
+
+func (client *FakeEth1Client) GetBlockHash(number uint64) ([]byte, error) {
+	block, _, err := client.GetBlock(int64(number))
+	if err != nil {
+		return nil, err
+	}
+	return block.Hash, nil
+}
+
+func (client *FakeEth1Client) GetLatestEth1BlockNumber() (uint64, error) {
+	client.mux.Lock()
+	defer client.mux.Unlock()
+
+	if len(client.blocks) == 0 {
+		return 0, fmt.Errorf("error getting latest block: %w", ethereum.NotFound)
+	}
+	numbers := make([]uint64, 0, len(client.blocks))
+	for number := range client.blocks {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers[len(numbers)-1], nil
+}
+
+func (client *FakeEth1Client) Close() {}
+
+// FakeClient is an in-memory consensus layer Client returning the data stored in its fields, requests for data which
+// has not been set fail
+type FakeClient struct {
+	ChainHead           *types.ChainHead
+	ValidatorQueue      *types.ValidatorQueue
+	Epochs              map[uint64]*types.EpochData
+	Assignments         map[uint64]*types.EpochAssignments
+	Blocks              map[uint64][]*types.Block
+	BlockStatus         map[uint64][]*types.CanonBlock
+	Participation       map[uint64]*types.ValidatorParticipation
+	FinalityCheckpoints map[uint64]*types.FinalityCheckpoints
+	SyncCommittees      map[uint64]*StandardSyncCommittee
+	Balances            map[int64]map[uint64]uint64
+	NewBlocks           chan *types.Block
+}
+
+var _ Client = (*FakeClient)(nil)
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		Epochs:              make(map[uint64]*types.EpochData),
+		Assignments:         make(map[uint64]*types.EpochAssignments),
+		Blocks:              make(map[uint64][]*types.Block),
+		BlockStatus:         make(map[uint64][]*types.CanonBlock),
+		Participation:       make(map[uint64]*types.ValidatorParticipation),
+		FinalityCheckpoints: make(map[uint64]*types.FinalityCheckpoints),
+		SyncCommittees:      make(map[uint64]*StandardSyncCommittee),
+		Balances:            make(map[int64]map[uint64]uint64),
+		NewBlocks:           make(chan *types.Block, 10),
+	}
+}
+
+func (fc *FakeClient) GetChainHead() (*types.ChainHead, error) {
+	if fc.ChainHead == nil {
+		return nil, fmt.Errorf("no chain head set")
+	}
+	return fc.ChainHead, nil
+}
+
+func (fc *FakeClient) GetEpochData(epoch uint64, skipHistoricBalances bool) (*types.EpochData, error) {
+	data, ok := fc.Epochs[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no data for epoch %v", epoch)
+	}
+	return data, nil
+}
+
+func (fc *FakeClient) GetValidatorQueue() (*types.ValidatorQueue, error) {
+	if fc.ValidatorQueue == nil {
+		return nil, fmt.Errorf("no validator queue set")
+	}
+	return fc.ValidatorQueue, nil
+}
+
+func (fc *FakeClient) GetEpochAssignments(epoch uint64) (*types.EpochAssignments, error) {
+	assignments, ok := fc.Assignments[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no assignments for epoch %v", epoch)
+	}
+	return assignments, nil
+}
+
+// GetBlocksBySlot returns the blocks of a slot, a slot without blocks is empty
+func (fc *FakeClient) GetBlocksBySlot(slot uint64) ([]*types.Block, error) {
+	return fc.Blocks[slot], nil
+}
+
+func (fc *FakeClient) GetValidatorParticipation(epoch uint64) (*types.ValidatorParticipation, error) {
+	participation, ok := fc.Participation[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no participation for epoch %v", epoch)
+	}
+	return participation, nil
+}
+
+func (fc *FakeClient) GetNewBlockChan() chan *types.Block {
+	return fc.NewBlocks
+}
+
+func (fc *FakeClient) GetBlockStatusByEpoch(epoch uint64) ([]*types.CanonBlock, error) {
+	return fc.BlockStatus[epoch], nil
+}
+
+func (fc *FakeClient) GetFinalityCheckpoints(epoch uint64) (*types.FinalityCheckpoints, error) {
+	checkpoints, ok := fc.FinalityCheckpoints[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no finality checkpoints for epoch %v", epoch)
+	}
+	return checkpoints, nil
+}
+
+func (fc *FakeClient) GetSyncCommittee(stateID string, epoch uint64) (*StandardSyncCommittee, error) {
+	committee, ok := fc.SyncCommittees[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no sync committee for epoch %v (state: %v)", epoch, stateID)
+	}
+	return committee, nil
+}
+
+func (fc *FakeClient) GetBalancesForEpoch(epoch int64) (map[uint64]uint64, error) {
+	balances, ok := fc.Balances[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no balances for epoch %v", epoch)
+	}
+	return balances, nil
+}
@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FixtureTransport is a http.RoundTripper which records the responses of a node to fixture files or replays them from
+// there without network access. It is used for the json-rpc requests of the ErigonClient as well as for the beacon api
+// requests of the LighthouseClient.
+//
+// A fixture is identified by the method, the path and query of the url and the body of the request, the host of the
+// endpoint is not part of it. The ids of json-rpc requests are replaced by their position in the request, so replayed
+// responses match the ids of the replaying client regardless of how many requests it sent before.
+type FixtureTransport struct {
+	dir    string
+	record bool
+	next   http.RoundTripper
+	mux    sync.Mutex
+}
+
+// fixtureEndpoint is the endpoint of replaying clients, requests never leave the process
+const fixtureEndpoint = "http://fixtures"
+
+// Fixture is a recorded request and its response
+type Fixture struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Text holds a response body which is not json
+	Text string `json:"text,omitempty"`
+}
+
+// NewRecordingTransport returns a transport which sends all requests using next and saves the responses to dir.
+// If next is nil http.DefaultTransport is used.
+func NewRecordingTransport(dir string, next http.RoundTripper) (*FixtureTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating fixture directory %v: %w", dir, err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &FixtureTransport{dir: dir, record: true, next: next}, nil
+}
+
+// NewReplayingTransport returns a transport which answers all requests with the fixtures in dir. A request without a
+// fixture fails.
+func NewReplayingTransport(dir string) (*FixtureTransport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening fixture directory %v: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("fixture path %v is not a directory", dir)
+	}
+	return &FixtureTransport{dir: dir}, nil
+}
+
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	normalized, ids := normalizeRequestIds(body)
+	fixture := &Fixture{
+		Method:  req.Method,
+		URL:     req.URL.RequestURI(),
+		Request: normalized,
+	}
+	path := filepath.Join(t.dir, fixture.key()+".json")
+
+	if t.record {
+		forward := req.Clone(req.Context())
+		forward.Body = ioutil.NopCloser(bytes.NewReader(body))
+		forward.ContentLength = int64(len(body))
+		resp, err := t.next.RoundTrip(forward)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		fixture.Status = resp.StatusCode
+		if json.Valid(data) {
+			fixture.Response = responseWithIds(data, ids, true)
+		} else {
+			fixture.Text = string(data)
+		}
+		if err := t.save(path, fixture); err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		return resp, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture for %v %v in %v", req.Method, fixture.URL, t.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("error decoding fixture %v: %w", path, err)
+	}
+
+	response := []byte(fixture.Text)
+	if len(fixture.Response) > 0 {
+		// json responses are indented in the fixture files
+		compacted := new(bytes.Buffer)
+		if err := json.Compact(compacted, fixture.Response); err != nil {
+			return nil, fmt.Errorf("error decoding fixture %v: %w", path, err)
+		}
+		response = responseWithIds(compacted.Bytes(), ids, false)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(response)),
+		ContentLength: int64(len(response)),
+		Request:       req,
+	}, nil
+}
+
+func (t *FixtureTransport) save(path string, fixture *Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// key returns the file name of the fixture
+func (f *Fixture) key() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", f.Method, f.URL)
+	h.Write(f.Request)
+
+	name := strings.Trim(strings.NewReplacer("/", "_", "?", "_", "&", "_", "=", "_").Replace(f.URL), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		name = strings.ToLower(f.Method)
+	}
+	return fmt.Sprintf("%s-%x", name, h.Sum(nil)[:8])
+}
+
+type jsonrpcMessage map[string]json.RawMessage
+
+// normalizeRequestIds replaces the ids of a json-rpc request or batch by their position and returns the original ids.
+// Other bodies are returned unchanged.
+func normalizeRequestIds(body []byte) (json.RawMessage, []json.RawMessage) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	msgs, batch := decodeJsonrpcMessages(body)
+	if msgs == nil {
+		return body, nil
+	}
+	ids := make([]json.RawMessage, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg["id"]
+		msg["id"] = json.RawMessage(fmt.Sprintf("%d", i))
+	}
+	return encodeJsonrpcMessages(msgs, batch, body), ids
+}
+
+// responseWithIds maps the ids of a json-rpc response between the ids of the request and their positions. If record is
+// set the request ids are replaced by positions, otherwise the positions are replaced by the request ids.
+func responseWithIds(body []byte, ids []json.RawMessage, record bool) json.RawMessage {
+	msgs, batch := decodeJsonrpcMessages(body)
+	if msgs == nil || len(ids) == 0 {
+		return body
+	}
+	for _, msg := range msgs {
+		for i, id := range ids {
+			if record && bytes.Equal(msg["id"], id) {
+				msg["id"] = json.RawMessage(fmt.Sprintf("%d", i))
+				break
+			}
+			if !record && string(msg["id"]) == fmt.Sprintf("%d", i) {
+				msg["id"] = id
+				break
+			}
+		}
+	}
+	return encodeJsonrpcMessages(msgs, batch, body)
+}
+
+func decodeJsonrpcMessages(body []byte) ([]jsonrpcMessage, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, false
+	}
+	if trimmed[0] == '[' {
+		var msgs []jsonrpcMessage
+		if err := json.Unmarshal(trimmed, &msgs); err != nil {
+			return nil, false
+		}
+		for _, msg := range msgs {
+			if _, ok := msg["jsonrpc"]; !ok {
+				return nil, false
+			}
+		}
+		return msgs, true
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(trimmed, &msg); err != nil {
+		return nil, false
+	}
+	if _, ok := msg["jsonrpc"]; !ok {
+		return nil, false
+	}
+	return []jsonrpcMessage{msg}, false
+}
+
+func encodeJsonrpcMessages(msgs []jsonrpcMessage, batch bool, fallback []byte) json.RawMessage {
+	var data []byte
+	var err error
+	if batch {
+		data, err = json.Marshal(msgs)
+	} else {
+		data, err = json.Marshal(msgs[0])
+	}
+	if err != nil {
+		return fallback
+	}
+	return data
+}
+
+// NewReplayingErigonClient creates an erigon client which answers all requests with the fixtures in dir
+func NewReplayingErigonClient(dir string) (*ErigonClient, error) {
+	transport, err := NewReplayingTransport(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewErigonClientWithTransport(fixtureEndpoint, transport)
+}
+
+// NewReplayingLighthouseClient creates a Lighthouse client which answers all requests with the fixtures in dir. The
+// event stream of new blocks is not part of the fixtures.
+func NewReplayingLighthouseClient(dir string, chainID *big.Int) (*LighthouseClient, error) {
+	transport, err := NewReplayingTransport(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewLighthouseClientWithTransport(fixtureEndpoint, chainID, transport)
+}
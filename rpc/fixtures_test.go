@@ -0,0 +1,386 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/Prajjawalk/zond-indexer/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	geth_types "github.com/ethereum/go-ethereum/core/types"
+	geth_rpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// testNode serves eth_getBlockByNumber for the blocks 0 to 9 and a beacon api version endpoint
+func testNode(t *testing.T, requests *int32) *httptest.Server {
+	header := func(number uint64) *geth_types.Header {
+		return &geth_types.Header{
+			Number:     new(big.Int).SetUint64(number),
+			Difficulty: big.NewInt(0),
+			UncleHash:  geth_types.EmptyUncleHash,
+			TxHash:     geth_types.EmptyRootHash,
+			Extra:      []byte{},
+		}
+	}
+	block := func(params []json.RawMessage) (interface{}, error) {
+		var tag string
+		if err := json.Unmarshal(params[0], &tag); err != nil {
+			return nil, err
+		}
+		number := uint64(9)
+		if tag != "latest" {
+			n, err := hexutil.DecodeUint64(tag)
+			if err != nil {
+				return nil, err
+			}
+			number = n
+		}
+		if number > 9 {
+			return nil, nil
+		}
+		h := header(number)
+		data, err := json.Marshal(h)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["hash"] = h.Hash()
+		fields["transactions"] = []interface{}{}
+		fields["uncles"] = []interface{}{}
+		return fields, nil
+	}
+
+	type message struct {
+		Version string            `json:"jsonrpc"`
+		ID      json.RawMessage   `json:"id"`
+		Method  string            `json:"method,omitempty"`
+		Params  []json.RawMessage `json:"params,omitempty"`
+		Result  interface{}       `json:"result"`
+	}
+	handle := func(req message) message {
+		resp := message{Version: "2.0", ID: req.ID}
+		if req.Method != "eth_getBlockByNumber" {
+			t.Errorf("unexpected method %v", req.Method)
+			return resp
+		}
+		result, err := block(req.Params)
+		if err != nil {
+			t.Error(err)
+		}
+		resp.Result = result
+		return resp
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		switch r.URL.Path {
+		case "/eth/v1/node/version":
+			w.Write([]byte(`{"data":{"version":"Lighthouse/v4.0.0"}}`))
+			return
+		case "/eth/v1/node/missing":
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		if bytes.HasPrefix(body, []byte("[")) {
+			var reqs []message
+			if err := json.Unmarshal(body, &reqs); err != nil {
+				t.Error(err)
+				return
+			}
+			// answer in reverse order, clients match responses by id
+			resps := make([]message, len(reqs))
+			for i, req := range reqs {
+				resps[len(reqs)-1-i] = handle(req)
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+		var req message
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+			return
+		}
+		json.NewEncoder(w).Encode(handle(req))
+	}))
+}
+
+func TestFixtureTransportErigon(t *testing.T) {
+	var requests int32
+	node := testNode(t, &requests)
+	dir := t.TempDir()
+
+	type result struct {
+		latest uint64
+		hashes []string
+		batch  []string
+	}
+	run := func(client *ErigonClient) result {
+		var r result
+		var err error
+		r.latest, err = client.GetLatestEth1BlockNumber()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, number := range []uint64{1, 2, 1} {
+			hash, err := client.GetBlockHash(number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.hashes = append(r.hashes, fmt.Sprintf("%x", hash))
+		}
+
+		headers := make([]*geth_types.Header, 3)
+		reqs := make([]geth_rpc.BatchElem, len(headers))
+		for i := range reqs {
+			reqs[i] = geth_rpc.BatchElem{
+				Method: "eth_getBlockByNumber",
+				Args:   []interface{}{hexutil.EncodeUint64(uint64(i + 3)), false},
+				Result: &headers[i],
+			}
+		}
+		if err := client.GetRPCClient().BatchCall(reqs); err != nil {
+			t.Fatal(err)
+		}
+		for i := range reqs {
+			if reqs[i].Error != nil {
+				t.Fatal(reqs[i].Error)
+			}
+			r.batch = append(r.batch, headers[i].Number.String())
+		}
+		return r
+	}
+
+	transport, err := NewRecordingTransport(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, err := NewErigonClientWithTransport(node.URL, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := run(recording)
+	recording.Close()
+	node.Close()
+	sent := atomic.LoadInt32(&requests)
+
+	if recorded.latest != 9 {
+		t.Errorf("latest block = %v, want 9", recorded.latest)
+	}
+	if fmt.Sprint(recorded.batch) != "[3 4 5]" {
+		t.Errorf("batch results = %v, want [3 4 5]", recorded.batch)
+	}
+
+	replaying, err := NewReplayingErigonClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replaying.Close()
+	replayed := run(replaying)
+
+	if fmt.Sprint(replayed) != fmt.Sprint(recorded) {
+		t.Errorf("replayed results %v differ from recorded results %v", replayed, recorded)
+	}
+	if got := atomic.LoadInt32(&requests); got != sent {
+		t.Errorf("replaying client sent %v requests to the node", got-sent)
+	}
+
+	if _, err := replaying.GetBlockHash(7); err == nil {
+		t.Errorf("request without a fixture did not fail")
+	}
+}
+
+func TestFixtureTransportLighthouse(t *testing.T) {
+	var requests int32
+	node := testNode(t, &requests)
+	dir := t.TempDir()
+
+	transport, err := NewRecordingTransport(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, err := NewLighthouseClientWithTransport(node.URL, big.NewInt(1), transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying, err := NewReplayingLighthouseClient(dir, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "ok", path: "/eth/v1/node/version", want: `{"data":{"version":"Lighthouse/v4.0.0"}}`},
+		{name: "not found", path: "/eth/v1/node/missing", wantErr: errNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, lc := range []*LighthouseClient{recording, replaying} {
+				data, err := lc.get(lc.endpoint + tt.path)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("get(%v) error = %v, want %v", lc.endpoint+tt.path, err, tt.wantErr)
+				}
+				if string(bytes.TrimSpace(data)) != tt.want {
+					t.Errorf("get(%v) = %s, want %s", lc.endpoint+tt.path, data, tt.want)
+				}
+			}
+		})
+	}
+	node.Close()
+
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Errorf("recording client sent no requests to the node")
+	}
+}
+
+// zondFixtures holds hand-written fake responses of a zond execution and beacon node, see its README
+const zondFixtures = "testdata/zond-devnet-fake"
+
+// useZondFixtureConfig sets the chain config of the zond fixtures for the duration of the test
+func useZondFixtureConfig(t *testing.T) {
+	t.Helper()
+
+	cfg := &types.Config{}
+	cfg.Chain.ConfigPath = filepath.Join(zondFixtures, "chain.yml")
+	if err := utils.ReadConfig(cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.Config
+	utils.Config = cfg
+	t.Cleanup(func() { utils.Config = previous })
+}
+
+func TestReplayZondBlocks(t *testing.T) {
+	useZondFixtureConfig(t)
+
+	client, err := NewReplayingErigonClient(filepath.Join(zondFixtures, "execution"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetVerifySignatures(true)
+
+	blocks := make([]*types.Eth1Block, 4)
+	for i := range blocks {
+		blocks[i], _, err = client.GetBlock(int64(i))
+		if err != nil {
+			t.Fatalf("error replaying block %v: %v", i, err)
+		}
+		if i > 0 && !bytes.Equal(blocks[i].ParentHash, blocks[i-1].Hash) {
+			t.Errorf("parent hash of block %v is %x, want %x", i, blocks[i].ParentHash, blocks[i-1].Hash)
+		}
+	}
+
+	txs := []int{}
+	for _, b := range blocks {
+		txs = append(txs, len(b.Transactions))
+		for _, tx := range b.Transactions {
+			if !tx.SignatureVerified || tx.Status != 1 {
+				t.Errorf("tx %x of block %v has verified signature %v and status %v, want a verified successful tx", tx.Hash, b.Number, tx.SignatureVerified, tx.Status)
+			}
+			if len(tx.PublicKeyHash) == 0 || len(tx.SignatureHash) == 0 {
+				t.Errorf("tx %x of block %v has no dilithium public key or signature hash", tx.Hash, b.Number)
+			}
+		}
+	}
+	if fmt.Sprint(txs) != "[0 2 1 1]" {
+		t.Fatalf("got %v txs per block, want [0 2 1 1]", txs)
+	}
+
+	sender := blocks[1].Transactions[0].From
+	if !bytes.Equal(blocks[2].Transactions[0].From, sender) || !bytes.Equal(blocks[3].Transactions[0].To, sender) {
+		t.Errorf("the txs of the sender %x in blocks 1 to 3 have different senders", sender)
+	}
+	token := blocks[1].Transactions[1].ContractAddress
+	if bytes.Equal(token, make([]byte, 20)) {
+		t.Errorf("the contract creation in block 1 has no contract address")
+	}
+	// the node has no trace api, the calls are retrieved via debug_traceBlockByHash
+	itx := blocks[1].Transactions[1].Itx
+	if len(itx) != 1 || itx[0].Type != "create" || !bytes.Equal(itx[0].To, token) {
+		t.Errorf("got internal txs %v of the contract creation in block 1, want the creation of %x", itx, token)
+	}
+	logs := blocks[2].Transactions[0].Logs
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	if len(logs) != 1 || !bytes.Equal(logs[0].Address, token) || !bytes.Equal(logs[0].Topics[0], transfer.Bytes()) {
+		t.Errorf("got logs %v in block 2, want a transfer of token %x", logs, token)
+	}
+	if len(blocks[3].Withdrawals) != 2 {
+		t.Errorf("got %v withdrawals in block 3, want 2", len(blocks[3].Withdrawals))
+	}
+}
+
+func TestReplayZondEpoch(t *testing.T) {
+	useZondFixtureConfig(t)
+	previousHead := LighthouseLatestHeadEpoch
+	LighthouseLatestHeadEpoch = 0
+	defer func() { LighthouseLatestHeadEpoch = previousHead }()
+
+	client, err := NewReplayingLighthouseClient(filepath.Join(zondFixtures, "beacon"), big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.GetEpochData(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Validators) != 4 {
+		t.Fatalf("got %v validators, want 4", len(data.Validators))
+	}
+	for _, validator := range data.Validators {
+		if len(validator.PublicKey) != utils.DilithiumPubkeyLength {
+			t.Errorf("validator %v has a public key of %v bytes, want a dilithium key", validator.Index, len(validator.PublicKey))
+		}
+	}
+
+	assignments := data.ValidatorAssignmentes
+	if len(assignments.ProposerAssignments) != 4 || len(assignments.AttestorAssignments) != 4 || len(assignments.SyncAssignments) != 16 {
+		t.Errorf("got %v proposer, %v attester and %v sync assignments, want 4, 4 and 16", len(assignments.ProposerAssignments), len(assignments.AttestorAssignments), len(assignments.SyncAssignments))
+	}
+
+	statuses := map[uint64]uint64{}
+	for slot, blocks := range data.Blocks {
+		for _, block := range blocks {
+			statuses[slot] = block.Status
+		}
+	}
+	// the block of slot 6 was missed
+	if fmt.Sprint(statuses) != "map[4:1 5:1 6:2 7:1]" {
+		t.Errorf("got block statuses %v, want map[4:1 5:1 6:2 7:1]", statuses)
+	}
+	for _, block := range data.Blocks[5] {
+		if len(block.Attestations) != 1 || fmt.Sprint(block.Attestations[0].Attesters) != "[2]" {
+			t.Errorf("got attestations %v in slot 5, want the attestation of validator 2 for slot 4", block.Attestations)
+		}
+		if block.SyncAggregate == nil || block.ExecutionPayload == nil {
+			t.Errorf("block of slot 5 has no sync aggregate or execution payload")
+		}
+	}
+
+	if data.EpochParticipationStats.EligibleEther != 640000000000000 {
+		t.Errorf("got eligible balance %v, want 640000000000000", data.EpochParticipationStats.EligibleEther)
+	}
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	geth_rpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// Head is a new head of the execution layer chain announced by a node
+type Head struct {
+	Number     uint64
+	Hash       []byte
+	ParentHash []byte
+}
+
+// HeadSubscription is a newHeads subscription of a websocket endpoint
+type HeadSubscription struct {
+	client *geth_rpc.Client
+	sub    *geth_rpc.ClientSubscription
+	err    chan error
+	once   sync.Once
+}
+
+// SubscribeNewHeads subscribes to the new heads announced by the websocket endpoint and sends them to heads until the
+// subscription is closed with Unsubscribe or fails with an error on Err
+func SubscribeNewHeads(ctx context.Context, endpoint string, heads chan<- *Head) (*HeadSubscription, error) {
+	client, err := geth_rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing websocket endpoint: %v", err)
+	}
+
+	// the hashes are taken from the node, the header fields of a zond node differ from the ones the hash of a
+	// go-ethereum header is computed of
+	headers := make(chan *struct {
+		Number     hexutil.Uint64 `json:"number"`
+		Hash       common.Hash    `json:"hash"`
+		ParentHash common.Hash    `json:"parentHash"`
+	}, cap(heads))
+	sub, err := client.EthSubscribe(ctx, headers, "newHeads")
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error subscribing to new heads: %w", err)
+	}
+
+	s := &HeadSubscription{client: client, sub: sub, err: make(chan error, 1)}
+	go func() {
+		defer close(s.err)
+		for {
+			select {
+			case header := <-headers:
+				head := &Head{
+					Number:     uint64(header.Number),
+					Hash:       header.Hash.Bytes(),
+					ParentHash: header.ParentHash.Bytes(),
+				}
+				select {
+				case heads <- head:
+				case err := <-sub.Err():
+					s.err <- err
+					return
+				}
+			case err := <-sub.Err():
+				s.err <- err
+				return
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// Err returns the channel receiving the error which ended the subscription, the error is nil after Unsubscribe
+func (s *HeadSubscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *HeadSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.sub.Unsubscribe()
+		s.client.Close()
+	})
+}
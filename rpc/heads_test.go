@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	geth_rpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// headsTestService announces its heads to every newHeads subscription
+type headsTestService struct {
+	heads []map[string]interface{}
+}
+
+func (s *headsTestService) NewHeads(ctx context.Context) (*geth_rpc.Subscription, error) {
+	notifier, ok := geth_rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, geth_rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		for _, head := range s.heads {
+			if err := notifier.Notify(sub.ID, head); err != nil {
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	hash := common.HexToHash("0x66b214dad041dac6f78edb08000dc7a70160fb00e286f63d1bafb8b3018a983b")
+	parentHash := common.HexToHash("0x2bfe2aace5b1d213219c4e5e146dc392db2cfc9ad7f8bc5331907e98b61a9afb")
+	// the header only has the fields of a zond header the subscription uses, the hash is the one reported by the node
+	service := &headsTestService{heads: []map[string]interface{}{
+		{"number": hexutil.Uint64(3), "hash": hash, "parentHash": parentHash, "timestamp": hexutil.Uint64(1700000036)},
+	}}
+
+	server := geth_rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpServer.Close()
+
+	heads := make(chan *Head, 1)
+	sub, err := SubscribeNewHeads(context.Background(), "ws"+strings.TrimPrefix(httpServer.URL, "http"), heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case head := <-heads:
+		if head.Number != 3 || !bytes.Equal(head.Hash, hash.Bytes()) || !bytes.Equal(head.ParentHash, parentHash.Bytes()) {
+			t.Errorf("got head %v 0x%x with parent 0x%x, want 3 %v with parent %v", head.Number, head.Hash, head.ParentHash, hash, parentHash)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription ended: %v", err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("no head received")
+	}
+}
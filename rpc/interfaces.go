@@ -21,10 +21,15 @@ type Client interface {
 	GetBalancesForEpoch(epoch int64) (map[uint64]uint64, error)
 }
 
+// Eth1Client provides an interface for execution layer RPC clients
 type Eth1Client interface {
-	GetBlock(number uint64) (*types.Eth1Block, *types.GetBlockTimings, error)
+	GetBlock(number int64) (*types.Eth1Block, *types.GetBlockTimings, error)
+	GetBlockHash(number uint64) ([]byte, error)
 	GetLatestEth1BlockNumber() (uint64, error)
 	Close()
 }
 
+var _ Client = (*LighthouseClient)(nil)
+var _ Eth1Client = (*ErigonClient)(nil)
+
 var logger = logrus.New().WithField("module", "rpc")
@@ -35,6 +35,7 @@ type LighthouseClient struct {
 	assignmentsCache    *lru.Cache
 	assignmentsCacheMux *sync.Mutex
 	signer              gtypes.Signer
+	httpClient          *http.Client
 }
 
 // NewLighthouseClient is used to create a new Lighthouse client
@@ -44,12 +45,24 @@ func NewLighthouseClient(endpoint string, chainID *big.Int) (*LighthouseClient,
 		endpoint:            endpoint,
 		assignmentsCacheMux: &sync.Mutex{},
 		signer:              signer,
+		httpClient:          &http.Client{Timeout: time.Second * 500},
 	}
 	client.assignmentsCache, _ = lru.New(10)
 
 	return client, nil
 }
 
+// NewLighthouseClientWithTransport creates a Lighthouse client which sends all beacon api requests through the passed
+// transport, e.g. a FixtureTransport recording or replaying the responses of the node
+func NewLighthouseClientWithTransport(endpoint string, chainID *big.Int, transport http.RoundTripper) (*LighthouseClient, error) {
+	client, err := NewLighthouseClient(endpoint, chainID)
+	if err != nil {
+		return nil, err
+	}
+	client.httpClient.Transport = transport
+	return client, nil
+}
+
 func (lc *LighthouseClient) GetNewBlockChan() chan *types.Block {
 	blkCh := make(chan *types.Block, 10)
 	go func() {
@@ -890,7 +903,7 @@ func (lc *LighthouseClient) GetValidatorParticipation(epoch uint64) (*types.Vali
 			continue
 		}
 
-		votedBalance, _ := strconv.ParseUint(parsedValidatorsResp.Data[0].Validator.EffectiveBalance, 10, 32)
+		votedBalance, _ := strconv.ParseUint(parsedValidatorsResp.Data[0].Validator.EffectiveBalance, 10, 64)
 		totalVotedBalance += totalVotes * (votedBalance)
 
 		for _, attestation := range parsedResp.Data {
@@ -913,7 +926,7 @@ func (lc *LighthouseClient) GetValidatorParticipation(epoch uint64) (*types.Vali
 			if validators.Validator.EffectiveBalance == "" {
 				continue
 			}
-			effectiveBalance, err := strconv.ParseUint(validators.Validator.EffectiveBalance, 10, 32)
+			effectiveBalance, err := strconv.ParseUint(validators.Validator.EffectiveBalance, 10, 64)
 			if err != nil {
 				logger.Errorf("Error parsing effective balance of validator %v", validators.Index)
 			}
@@ -967,8 +980,7 @@ var errNotFound = errors.New("not found 404")
 func (lc *LighthouseClient) get(url string) ([]byte, error) {
 	// t0 := time.Now()
 	// defer func() { fmt.Println(url, time.Since(t0)) }()
-	client := &http.Client{Timeout: time.Second * 500}
-	resp, err := client.Get(url)
+	resp, err := lc.httpClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -1128,45 +1140,45 @@ type LighthouseValidatorParticipationResponse struct {
 }
 
 type BlockAttestationResponse struct {
-	ExecutionOptimistic bool
-	Finalized           bool
+	ExecutionOptimistic bool `json:"execution_optimistic"`
+	Finalized           bool `json:"finalized"`
 	Data                []struct {
-		AggregationBits string
-		Signature       string
+		AggregationBits string `json:"aggregation_bits"`
+		Signature       string `json:"signature"`
 		Data            struct {
-			Slot            string
-			Index           string
-			BeaconBlockRoot string
-			source          struct {
-				Epoch string
-				Root  string
-			}
+			Slot            string `json:"slot"`
+			Index           string `json:"index"`
+			BeaconBlockRoot string `json:"beacon_block_root"`
+			Source          struct {
+				Epoch string `json:"epoch"`
+				Root  string `json:"root"`
+			} `json:"source"`
 			Target struct {
-				Epoch string
-				Root  string
-			}
-		}
-	}
+				Epoch string `json:"epoch"`
+				Root  string `json:"root"`
+			} `json:"target"`
+		} `json:"data"`
+	} `json:"data"`
 }
 
 type StateValidatorsResponse struct {
-	ExecutionOptimistic bool
-	Finalized           bool
+	ExecutionOptimistic bool `json:"execution_optimistic"`
+	Finalized           bool `json:"finalized"`
 	Data                []struct {
-		Index     string
-		Balance   string
-		Status    string
+		Index     string `json:"index"`
+		Balance   string `json:"balance"`
+		Status    string `json:"status"`
 		Validator struct {
-			Pubkey                     string
-			WithdrawalCredentials      string
-			EffectiveBalance           string
-			Slashed                    bool
-			ActivationEligibilityEpoch string
-			ActivationEpoch            string
-			ExitEpoch                  string
-			WithdrawableEpoch          string
-		}
-	}
+			Pubkey                     string `json:"pubkey"`
+			WithdrawalCredentials      string `json:"withdrawal_credentials"`
+			EffectiveBalance           string `json:"effective_balance"`
+			Slashed                    bool   `json:"slashed"`
+			ActivationEligibilityEpoch string `json:"activation_eligibility_epoch"`
+			ActivationEpoch            string `json:"activation_epoch"`
+			ExitEpoch                  string `json:"exit_epoch"`
+			WithdrawableEpoch          string `json:"withdrawable_epoch"`
+		} `json:"validator"`
+	} `json:"data"`
 }
 
 type ProposerSlashing struct {
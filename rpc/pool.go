@@ -0,0 +1,708 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Prajjawalk/zond-indexer/metrics"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// jsonrpcMethodNotFound is the error code of a json-rpc method that is not supported by a node, e.g. trace_block on a
+// node without the trace api
+const jsonrpcMethodNotFound = -32601
+
+// jsonrpcLimitExceeded is the error code of a json-rpc request rejected by a node because of a rate or resource limit
+const jsonrpcLimitExceeded = -32005
+
+// retryableErrors are parts of the messages of json-rpc errors which a node answers with status 200 but which another
+// node or a later attempt may not fail with, e.g. a node which has not imported the requested block yet
+var retryableErrors = []string{
+	"header not found",
+	"block not found",
+	"unknown block",
+	"missing trie node",
+	"timeout",
+	"timed out",
+	"too many requests",
+	"rate limit",
+	"busy",
+}
+
+// PoolEndpoint is an execution layer http endpoint of a Pool
+type PoolEndpoint struct {
+	URL string
+	// Name identifies the endpoint in logs and metrics, it defaults to the host of the url
+	Name string
+	// Weight is the relative share of requests sent to the endpoint, it defaults to 1
+	Weight int
+	// RateLimit is the maximum number of requests per second sent to the endpoint, 0 disables the limit
+	RateLimit float64
+}
+
+// ParsePoolEndpoints parses a comma separated list of endpoints. Options are appended to an endpoint separated by '|',
+// e.g. "http://node-a:8545|weight=3|rps=50,http://node-b:8545|name=backup"
+func ParsePoolEndpoints(spec string) ([]PoolEndpoint, error) {
+	endpoints := []PoolEndpoint{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		options := strings.Split(part, "|")
+		endpoint := PoolEndpoint{URL: options[0], Weight: 1}
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %v: %w", endpoint.URL, err)
+		}
+		endpoint.Name = u.Host
+
+		for _, option := range options[1:] {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid option %v of endpoint %v", option, endpoint.URL)
+			}
+			switch kv[0] {
+			case "name":
+				endpoint.Name = kv[1]
+			case "weight":
+				endpoint.Weight, err = strconv.Atoi(kv[1])
+				if err == nil && endpoint.Weight < 1 {
+					err = fmt.Errorf("weight must be positive")
+				}
+			case "rps":
+				endpoint.RateLimit, err = strconv.ParseFloat(kv[1], 64)
+				if err == nil && endpoint.RateLimit < 0 {
+					err = fmt.Errorf("rate limit must not be negative")
+				}
+			default:
+				err = fmt.Errorf("unknown option")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid option %v of endpoint %v: %w", option, endpoint.URL, err)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoint in %q", spec)
+	}
+	return endpoints, nil
+}
+
+// PoolConfig configures the retries, the circuit breakers and the health checks of a Pool
+type PoolConfig struct {
+	// MaxRetries is the number of times a failed request is retried, on another endpoint if there is one
+	MaxRetries int
+	// BackoffBase is the delay before the first retry, it doubles with every further retry up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// FailureThreshold consecutive failures open the circuit breaker of an endpoint for BreakerCooldown, afterwards a
+	// single request is let through to probe it
+	FailureThreshold int
+	BreakerCooldown  time.Duration
+	// HealthInterval is the interval of the eth_blockNumber health checks of all endpoints
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	// MaxHeadLag is the number of blocks an endpoint may lag behind the highest head of the pool before it is avoided
+	MaxHeadLag uint64
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxRetries:       4,
+		BackoffBase:      time.Millisecond * 200,
+		BackoffMax:       time.Second * 10,
+		FailureThreshold: 5,
+		BreakerCooldown:  time.Second * 30,
+		HealthInterval:   time.Second * 10,
+		HealthTimeout:    time.Second * 5,
+		MaxHeadLag:       5,
+	}
+}
+
+// Pool is a http.RoundTripper spreading json-rpc requests over several execution layer endpoints. It selects an
+// endpoint by weight among the healthy endpoints which are in sync, whose circuit breaker is closed and which support
+// the requested methods. Failed requests are retried with exponential backoff, preferably on another endpoint, this
+// includes requests answered with a retryable json-rpc error like "header not found" of a lagging endpoint. Methods an
+// endpoint answers with "method not found" are not sent to it again.
+type Pool struct {
+	config PoolConfig
+	nodes  []*poolNode
+	next   http.RoundTripper
+
+	headMux  sync.RWMutex
+	bestHead uint64
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type poolNode struct {
+	endpoint PoolEndpoint
+	url      *url.URL
+	limiter  *tokenBucket
+
+	mux         sync.Mutex
+	head        uint64
+	healthy     bool
+	failures    int
+	openUntil   time.Time
+	probing     bool
+	unsupported map[string]bool
+}
+
+// NewPool creates a pool of the passed endpoints which sends its requests using next, if next is nil
+// http.DefaultTransport is used. The endpoints are checked once before NewPool returns and then every
+// config.HealthInterval until the pool is closed.
+func NewPool(endpoints []PoolEndpoint, config PoolConfig, next http.RoundTripper) (*Pool, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints provided")
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	pool := &Pool{
+		config: config,
+		next:   next,
+		stop:   make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %v: %w", endpoint.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("endpoint %v is not a http endpoint", endpoint.Name)
+		}
+		if endpoint.Weight < 1 {
+			endpoint.Weight = 1
+		}
+		if endpoint.Name == "" {
+			endpoint.Name = u.Host
+		}
+		pool.nodes = append(pool.nodes, &poolNode{
+			endpoint:    endpoint,
+			url:         u,
+			limiter:     newTokenBucket(endpoint.RateLimit),
+			healthy:     true,
+			unsupported: make(map[string]bool),
+		})
+	}
+
+	pool.checkHealth()
+	if config.HealthInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(config.HealthInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					pool.checkHealth()
+				case <-pool.stop:
+					return
+				}
+			}
+		}()
+	}
+	return pool, nil
+}
+
+// Close stops the health checks of the pool
+func (pool *Pool) Close() {
+	pool.closeOnce.Do(func() { close(pool.stop) })
+}
+
+func (pool *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	methods := requestMethods(body)
+	label := "batch"
+	if len(methods) == 1 {
+		for _, method := range methods {
+			label = method
+		}
+	}
+
+	ctx := req.Context()
+	tried := make(map[*poolNode]bool)
+	var lastResp *http.Response
+	var lastErr error
+	failures := 0
+	for attempt := 0; attempt <= pool.config.MaxRetries; attempt++ {
+		node := pool.pick(methods, tried)
+		if node == nil {
+			break
+		}
+		if lastErr != nil {
+			metrics.RpcRetries.WithLabelValues(node.endpoint.Name).Inc()
+			if err := sleepContext(ctx, pool.backoff(failures)); err != nil {
+				return nil, err
+			}
+		}
+		tried[node] = true
+
+		if err := node.limiter.wait(ctx, node.endpoint.Name); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, data, err := pool.send(req, node, body)
+		metrics.RpcRequestDuration.WithLabelValues(node.endpoint.Name, label).Observe(time.Since(start).Seconds())
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			// the response of a failed request is returned if there is no other endpoint left
+			lastResp = nil
+			if err == nil {
+				err = fmt.Errorf("endpoint %v responded with status %v", node.endpoint.Name, resp.Status)
+				lastResp = resp
+			}
+			logger.Warnf("error sending %v request to %v (attempt %v): %v", label, node.endpoint.Name, attempt+1, err)
+			metrics.RpcRequests.WithLabelValues(node.endpoint.Name, label, "error").Inc()
+			node.failure(pool.config)
+			lastErr = err
+			failures++
+			continue
+		}
+		node.success()
+
+		if unsupported := unsupportedMethods(body, data); len(unsupported) > 0 {
+			node.mux.Lock()
+			for _, method := range unsupported {
+				if !node.unsupported[method] {
+					logger.Infof("endpoint %v does not support %v", node.endpoint.Name, method)
+				}
+				node.unsupported[method] = true
+			}
+			node.mux.Unlock()
+			metrics.RpcRequests.WithLabelValues(node.endpoint.Name, label, "unsupported").Inc()
+			// try the endpoints which might support the methods, if there is none the response is returned as is
+			lastResp, lastErr = resp, nil
+			continue
+		}
+
+		if message := retryableError(data); message != "" {
+			// the endpoint is responsive, so its circuit breaker is left closed
+			logger.Warnf("error response to %v request from %v (attempt %v): %v", label, node.endpoint.Name, attempt+1, message)
+			metrics.RpcRequests.WithLabelValues(node.endpoint.Name, label, "retryable").Inc()
+			// the error response is returned if all retries fail
+			lastResp, lastErr = resp, fmt.Errorf("endpoint %v responded with error %v", node.endpoint.Name, message)
+			failures++
+			continue
+		}
+
+		metrics.RpcRequests.WithLabelValues(node.endpoint.Name, label, "ok").Inc()
+		return resp, nil
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no rpc endpoint available for %v", label)
+}
+
+// Supports reports whether any endpoint of the pool might support method, i.e. not all of them answered it with
+// "method not found"
+func (pool *Pool) Supports(method string) bool {
+	for _, node := range pool.nodes {
+		node.mux.Lock()
+		unsupported := node.unsupported[method]
+		node.mux.Unlock()
+		if !unsupported {
+			return true
+		}
+	}
+	return false
+}
+
+// send posts the body to the endpoint of the node and returns the response with its body read into data
+func (pool *Pool) send(req *http.Request, node *poolNode, body []byte) (*http.Response, []byte, error) {
+	out := req.Clone(req.Context())
+	u := *node.url
+	if u.User != nil {
+		password, _ := u.User.Password()
+		out.SetBasicAuth(u.User.Username(), password)
+		u.User = nil
+	}
+	out.URL = &u
+	out.Host = ""
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+
+	resp, err := pool.next.RoundTrip(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, data, nil
+}
+
+// pick selects the endpoint for a request by weight. Endpoints which have not been tried for the request yet are
+// preferred, lagging or unhealthy endpoints are only used if no other endpoint is available.
+func (pool *Pool) pick(methods map[string]string, tried map[*poolNode]bool) *poolNode {
+	pool.headMux.RLock()
+	bestHead := pool.bestHead
+	pool.headMux.RUnlock()
+
+	now := time.Now()
+	var preferred, fallback []*poolNode
+	for _, node := range pool.nodes {
+		node.mux.Lock()
+		available := node.available(now) && node.supports(methods)
+		inSync := node.healthy && node.head+pool.config.MaxHeadLag >= bestHead
+		node.mux.Unlock()
+		if !available {
+			continue
+		}
+		if inSync {
+			preferred = append(preferred, node)
+		} else {
+			fallback = append(fallback, node)
+		}
+	}
+
+	for _, candidates := range [][]*poolNode{untried(preferred, tried), untried(fallback, tried), preferred, fallback} {
+		if node := pickWeighted(candidates); node != nil {
+			node.mux.Lock()
+			defer node.mux.Unlock()
+			if !node.openUntil.IsZero() {
+				// the cooldown of the breaker has passed, the request probes the endpoint
+				node.probing = true
+			}
+			return node
+		}
+	}
+	return nil
+}
+
+func untried(nodes []*poolNode, tried map[*poolNode]bool) []*poolNode {
+	result := make([]*poolNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !tried[node] {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func pickWeighted(nodes []*poolNode) *poolNode {
+	total := 0
+	for _, node := range nodes {
+		total += node.endpoint.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	n := rand.Intn(total)
+	for _, node := range nodes {
+		n -= node.endpoint.Weight
+		if n < 0 {
+			return node
+		}
+	}
+	return nil
+}
+
+func (pool *Pool) backoff(attempt int) time.Duration {
+	delay := pool.config.BackoffBase << uint(attempt-1)
+	if delay > pool.config.BackoffMax || delay <= 0 {
+		delay = pool.config.BackoffMax
+	}
+	// add up to 20% jitter to spread the retries of concurrent requests
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	}
+	return delay
+}
+
+// checkHealth requests the latest block of every endpoint and updates the highest head of the pool
+func (pool *Pool) checkHealth() {
+	wg := &sync.WaitGroup{}
+	for _, node := range pool.nodes {
+		wg.Add(1)
+		go func(node *poolNode) {
+			defer wg.Done()
+			head, err := pool.blockNumber(node)
+
+			node.mux.Lock()
+			node.healthy = err == nil
+			if err == nil {
+				node.head = head
+			}
+			node.mux.Unlock()
+
+			if err != nil {
+				logger.Warnf("health check of endpoint %v failed: %v", node.endpoint.Name, err)
+				node.failure(pool.config)
+				return
+			}
+			node.success()
+			metrics.RpcEndpointHead.WithLabelValues(node.endpoint.Name).Set(float64(head))
+		}(node)
+	}
+	wg.Wait()
+
+	bestHead := uint64(0)
+	for _, node := range pool.nodes {
+		node.mux.Lock()
+		if node.healthy && node.head > bestHead {
+			bestHead = node.head
+		}
+		node.mux.Unlock()
+	}
+	pool.headMux.Lock()
+	pool.bestHead = bestHead
+	pool.headMux.Unlock()
+
+	now := time.Now()
+	for _, node := range pool.nodes {
+		node.mux.Lock()
+		selectable := node.healthy && node.head+pool.config.MaxHeadLag >= bestHead && node.available(now)
+		open := !node.openUntil.IsZero()
+		node.mux.Unlock()
+		metrics.RpcEndpointSelectable.WithLabelValues(node.endpoint.Name).Set(boolToFloat(selectable))
+		metrics.RpcCircuitOpen.WithLabelValues(node.endpoint.Name).Set(boolToFloat(open))
+	}
+}
+
+func (pool *Pool) blockNumber(node *poolNode) (uint64, error) {
+	timeout := pool.config.HealthTimeout
+	if timeout <= 0 {
+		timeout = time.Second * 5
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, node.url.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, data, err := pool.send(req, node, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %v", resp.Status)
+	}
+
+	var result struct {
+		Result hexutil.Uint64 `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil {
+		return 0, errors.New(result.Error.Message)
+	}
+	return uint64(result.Result), nil
+}
+
+// available returns whether the circuit breaker of the node lets a request through, the mutex must be held
+func (node *poolNode) available(now time.Time) bool {
+	if node.openUntil.IsZero() {
+		return true
+	}
+	return !node.probing && now.After(node.openUntil)
+}
+
+// supports returns whether the node has not rejected any of the methods, the mutex must be held
+func (node *poolNode) supports(methods map[string]string) bool {
+	for _, method := range methods {
+		if node.unsupported[method] {
+			return false
+		}
+	}
+	return true
+}
+
+func (node *poolNode) success() {
+	node.mux.Lock()
+	defer node.mux.Unlock()
+
+	if !node.openUntil.IsZero() {
+		logger.Infof("closing circuit breaker of endpoint %v", node.endpoint.Name)
+		metrics.RpcCircuitOpen.WithLabelValues(node.endpoint.Name).Set(0)
+	}
+	node.failures = 0
+	node.openUntil = time.Time{}
+	node.probing = false
+}
+
+func (node *poolNode) failure(config PoolConfig) {
+	node.mux.Lock()
+	defer node.mux.Unlock()
+
+	node.failures++
+	if node.probing || (config.FailureThreshold > 0 && node.failures >= config.FailureThreshold) {
+		if node.openUntil.IsZero() {
+			logger.Warnf("opening circuit breaker of endpoint %v after %v consecutive failures", node.endpoint.Name, node.failures)
+		}
+		node.openUntil = time.Now().Add(config.BreakerCooldown)
+		node.probing = false
+		metrics.RpcCircuitOpen.WithLabelValues(node.endpoint.Name).Set(1)
+	}
+}
+
+// requestMethods returns the methods of a json-rpc request or batch by request id
+func requestMethods(body []byte) map[string]string {
+	methods := make(map[string]string)
+	msgs, _ := decodeJsonrpcMessages(body)
+	for _, msg := range msgs {
+		var method string
+		if err := json.Unmarshal(msg["method"], &method); err == nil {
+			methods[string(msg["id"])] = method
+		}
+	}
+	return methods
+}
+
+// unsupportedMethods returns the methods of the request which have been answered with "method not found"
+func unsupportedMethods(request, response []byte) []string {
+	methods := requestMethods(request)
+	msgs, _ := decodeJsonrpcMessages(response)
+
+	unsupported := []string{}
+	for _, msg := range msgs {
+		var rpcErr struct {
+			Code int `json:"code"`
+		}
+		if len(msg["error"]) == 0 || json.Unmarshal(msg["error"], &rpcErr) != nil || rpcErr.Code != jsonrpcMethodNotFound {
+			continue
+		}
+		if method, ok := methods[string(msg["id"])]; ok {
+			unsupported = append(unsupported, method)
+		}
+	}
+	return unsupported
+}
+
+// retryableError returns the message of the first retryable json-rpc error of the response, an empty string if there
+// is none
+func retryableError(response []byte) string {
+	msgs, _ := decodeJsonrpcMessages(response)
+	for _, msg := range msgs {
+		var rpcErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if len(msg["error"]) == 0 || json.Unmarshal(msg["error"], &rpcErr) != nil {
+			continue
+		}
+		if rpcErr.Code == jsonrpcLimitExceeded {
+			return rpcErr.Message
+		}
+		message := strings.ToLower(rpcErr.Message)
+		if strings.HasPrefix(message, "execution reverted") {
+			// the revert reason of a call is chosen by the contract
+			continue
+		}
+		for _, retryable := range retryableErrors {
+			if strings.Contains(message, retryable) {
+				return rpcErr.Message
+			}
+		}
+	}
+	return ""
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// tokenBucket limits the rate of requests sent to an endpoint
+type tokenBucket struct {
+	mux    sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a limiter of rate requests per second, a rate of 0 disables the limit
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until the request may be sent or the context is done
+func (b *tokenBucket) wait(ctx context.Context, name string) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	limited := false
+	for {
+		b.mux.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mux.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mux.Unlock()
+
+		if !limited {
+			metrics.RpcRateLimited.WithLabelValues(name).Inc()
+			limited = true
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	geth_rpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// poolTestNode is a json-rpc node reporting head as its latest block. It fails with status if status is not 200 and
+// answers the methods in unsupported with "method not found". All other requests are answered with a json-rpc error of
+// the message stored in fail if it is not empty.
+type poolTestNode struct {
+	*httptest.Server
+	head        uint64
+	status      int32
+	fail        atomic.Value
+	unsupported map[string]bool
+	requests    map[string]*int32
+}
+
+func newPoolTestNode(t *testing.T, head uint64, unsupported ...string) *poolTestNode {
+	node := &poolTestNode{
+		head:        head,
+		status:      http.StatusOK,
+		unsupported: make(map[string]bool),
+		requests:    map[string]*int32{"eth_blockNumber": new(int32), "eth_chainId": new(int32), "trace_block": new(int32)},
+	}
+	for _, method := range unsupported {
+		node.unsupported[method] = true
+	}
+	node.fail.Store("")
+
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		atomic.AddInt32(node.requests[req.Method], 1)
+
+		if status := atomic.LoadInt32(&node.status); status != http.StatusOK {
+			w.WriteHeader(int(status))
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case node.unsupported[req.Method]:
+			resp["error"] = map[string]interface{}{"code": jsonrpcMethodNotFound, "message": "the method " + req.Method + " does not exist/is not available"}
+		case req.Method != "eth_blockNumber" && node.fail.Load().(string) != "":
+			resp["error"] = map[string]interface{}{"code": -32000, "message": node.fail.Load().(string)}
+		case req.Method == "eth_blockNumber":
+			resp["result"] = hexutil.Uint64(node.head)
+		default:
+			resp["result"] = hexutil.Uint64(1)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return node
+}
+
+func (node *poolTestNode) count(method string) int32 {
+	return atomic.LoadInt32(node.requests[method])
+}
+
+func testPoolConfig() PoolConfig {
+	config := DefaultPoolConfig()
+	config.BackoffBase = time.Millisecond
+	config.BackoffMax = time.Millisecond * 5
+	config.FailureThreshold = 2
+	config.BreakerCooldown = time.Hour
+	config.HealthInterval = 0
+	return config
+}
+
+func newTestPoolClient(t *testing.T, nodes ...*poolTestNode) (*geth_rpc.Client, *Pool) {
+	endpoints := make([]PoolEndpoint, 0, len(nodes))
+	for _, node := range nodes {
+		endpoints = append(endpoints, PoolEndpoint{URL: node.URL})
+	}
+	pool, err := NewPool(endpoints, testPoolConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := geth_rpc.DialHTTPWithClient("http://pool", &http.Client{Transport: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		pool.Close()
+	})
+	return client, pool
+}
+
+func call(client *geth_rpc.Client, method string) error {
+	var result hexutil.Uint64
+	return client.Call(&result, method)
+}
+
+func TestPoolFailover(t *testing.T) {
+	failing := newPoolTestNode(t, 10)
+	defer failing.Close()
+	healthy := newPoolTestNode(t, 10)
+	defer healthy.Close()
+	client, pool := newTestPoolClient(t, failing, healthy)
+
+	atomic.StoreInt32(&failing.status, http.StatusBadGateway)
+	for i := 0; i < 20; i++ {
+		if err := call(client, "eth_chainId"); err != nil {
+			t.Fatalf("request %v failed: %v", i, err)
+		}
+	}
+	if got := healthy.count("eth_chainId"); got != 20 {
+		t.Errorf("healthy endpoint answered %v requests, want 20", got)
+	}
+	// the breaker of the failing endpoint opens after FailureThreshold failures
+	if got := failing.count("eth_chainId"); got > int32(testPoolConfig().FailureThreshold) {
+		t.Errorf("failing endpoint received %v requests, want at most %v", got, testPoolConfig().FailureThreshold)
+	}
+	if pool.nodes[0].openUntil.IsZero() {
+		t.Errorf("circuit breaker of the failing endpoint is closed")
+	}
+
+	atomic.StoreInt32(&healthy.status, http.StatusServiceUnavailable)
+	if err := call(client, "eth_chainId"); err == nil {
+		t.Errorf("request without a healthy endpoint did not fail")
+	}
+}
+
+func TestPoolUnsupportedMethods(t *testing.T) {
+	plain := newPoolTestNode(t, 10, "trace_block")
+	defer plain.Close()
+	tracing := newPoolTestNode(t, 10)
+	defer tracing.Close()
+	client, pool := newTestPoolClient(t, plain, tracing)
+
+	for i := 0; i < 20; i++ {
+		if err := call(client, "trace_block"); err != nil {
+			t.Fatalf("request %v failed: %v", i, err)
+		}
+	}
+	if got := plain.count("trace_block"); got > 1 {
+		t.Errorf("endpoint without the trace api received %v trace_block requests, want at most 1", got)
+	}
+	if got := tracing.count("trace_block"); got != 20 {
+		t.Errorf("endpoint with the trace api answered %v trace_block requests, want 20", got)
+	}
+
+	if !pool.Supports("trace_block") {
+		t.Errorf("pool with an endpoint with the trace api does not support trace_block")
+	}
+
+	// a method no endpoint supports returns the error of the node, so the client can fall back to another method
+	only, onlyPool := newTestPoolClient(t, plain)
+	err := call(only, "trace_block")
+	if rpcErr, ok := err.(geth_rpc.Error); !ok || rpcErr.ErrorCode() != jsonrpcMethodNotFound {
+		t.Errorf("got error %v, want a method not found error", err)
+	}
+	if onlyPool.Supports("trace_block") {
+		t.Errorf("pool without an endpoint with the trace api supports trace_block")
+	}
+}
+
+func TestPoolRetryableErrors(t *testing.T) {
+	lagging := newPoolTestNode(t, 10)
+	defer lagging.Close()
+	synced := newPoolTestNode(t, 10)
+	defer synced.Close()
+	client, pool := newTestPoolClient(t, lagging, synced)
+
+	lagging.fail.Store("header not found")
+	for i := 0; i < 20; i++ {
+		if err := call(client, "eth_chainId"); err != nil {
+			t.Fatalf("request %v failed: %v", i, err)
+		}
+	}
+	if got := synced.count("eth_chainId"); got != 20 {
+		t.Errorf("synced endpoint answered %v requests, want 20", got)
+	}
+	// an endpoint answering with an error is responsive, its circuit breaker stays closed
+	if !pool.nodes[0].openUntil.IsZero() {
+		t.Errorf("circuit breaker of the lagging endpoint is open")
+	}
+
+	// the error is returned once all retries failed
+	synced.fail.Store("header not found")
+	if err := call(client, "eth_chainId"); err == nil || err.Error() != "header not found" {
+		t.Errorf("got error %v, want header not found", err)
+	}
+
+	// other errors are not retried
+	lagging.fail.Store("execution reverted")
+	synced.fail.Store("execution reverted")
+	before := lagging.count("eth_chainId") + synced.count("eth_chainId")
+	if err := call(client, "eth_chainId"); err == nil {
+		t.Errorf("reverted request did not fail")
+	}
+	if got := lagging.count("eth_chainId") + synced.count("eth_chainId") - before; got != 1 {
+		t.Errorf("reverted request was sent %v times, want 1", got)
+	}
+}
+
+func TestPoolHeadLag(t *testing.T) {
+	synced := newPoolTestNode(t, 100)
+	defer synced.Close()
+	lagging := newPoolTestNode(t, 50)
+	defer lagging.Close()
+	client, _ := newTestPoolClient(t, synced, lagging)
+
+	for i := 0; i < 20; i++ {
+		if err := call(client, "eth_chainId"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := lagging.count("eth_chainId"); got != 0 {
+		t.Errorf("lagging endpoint received %v requests, want 0", got)
+	}
+}
+
+func TestParsePoolEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []PoolEndpoint
+		wantErr bool
+	}{
+		{
+			name: "single",
+			spec: "http://localhost:8545",
+			want: []PoolEndpoint{{URL: "http://localhost:8545", Name: "localhost:8545", Weight: 1}},
+		},
+		{
+			name: "options",
+			spec: "http://node-a:8545|weight=3|rps=50, http://node-b:8545|name=backup",
+			want: []PoolEndpoint{
+				{URL: "http://node-a:8545", Name: "node-a:8545", Weight: 3, RateLimit: 50},
+				{URL: "http://node-b:8545", Name: "backup", Weight: 1},
+			},
+		},
+		{name: "unknown option", spec: "http://node-a:8545|foo=1", wantErr: true},
+		{name: "invalid weight", spec: "http://node-a:8545|weight=0", wantErr: true},
+		{name: "empty", spec: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePoolEndpoints(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePoolEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePoolEndpoints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolBackoff(t *testing.T) {
+	pool := &Pool{config: PoolConfig{BackoffBase: time.Millisecond * 100, BackoffMax: time.Second}}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: time.Millisecond * 100, max: time.Millisecond * 120},
+		{attempt: 2, min: time.Millisecond * 200, max: time.Millisecond * 240},
+		{attempt: 4, min: time.Millisecond * 800, max: time.Millisecond * 960},
+		{attempt: 10, min: time.Second, max: time.Millisecond * 1200},
+		{attempt: 100, min: time.Second, max: time.Millisecond * 1200},
+	}
+	for _, tt := range tests {
+		if got := pool.backoff(tt.attempt); got < tt.min || got > tt.max {
+			t.Errorf("backoff(%v) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Prajjawalk/zond-indexer/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	geth_rpc "github.com/ethereum/go-ethereum/rpc"
+
+	geth_types "github.com/ethereum/go-ethereum/core/types"
+)
+
+// rpcBlock holds the block fields which are not part of the header
+type rpcBlock struct {
+	Hash         common.Hash              `json:"hash"`
+	Transactions []*rpcTransaction        `json:"transactions"`
+	UncleHashes  []common.Hash            `json:"uncles"`
+	Withdrawals  []*geth_types.Withdrawal `json:"withdrawals,omitempty"`
+}
+
+// rpcTransaction is a transaction together with the fields the node reports alongside it. Zond transactions are
+// signed with dilithium and carry the public key of their sender instead of an ecdsa signature.
+type rpcTransaction struct {
+	tx *geth_types.Transaction
+
+	Hash      common.Hash     `json:"hash"`
+	From      *common.Address `json:"from,omitempty"`
+	PublicKey hexutil.Bytes   `json:"publicKey,omitempty"`
+	Signature hexutil.Bytes   `json:"signature,omitempty"`
+}
+
+func (tx *rpcTransaction) UnmarshalJSON(msg []byte) error {
+	type extraInfo rpcTransaction
+	var extra extraInfo
+	if err := json.Unmarshal(msg, &extra); err != nil {
+		return err
+	}
+
+	// the go-ethereum decoder requires an ecdsa signature, zond transactions are decoded with an empty one
+	if len(extra.PublicKey) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(msg, &fields); err != nil {
+			return err
+		}
+		for _, field := range []string{"v", "r", "s"} {
+			if _, ok := fields[field]; !ok {
+				fields[field] = json.RawMessage(`"0x0"`)
+			}
+		}
+		var err error
+		msg, err = json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	*tx = rpcTransaction(extra)
+	return json.Unmarshal(msg, &tx.tx)
+}
+
+// getBlock retrieves a block including the zond signature fields of its transactions, which are dropped by the
+// go-ethereum client
+func (client *ErigonClient) getBlock(ctx context.Context, number int64) (*geth_types.Block, []*rpcTransaction, error) {
+	var raw json.RawMessage
+	err := client.rpcClient.CallContext(ctx, &raw, "eth_getBlockByNumber", hexutil.EncodeUint64(uint64(number)), true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var head *geth_types.Header
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, nil, err
+	}
+	// the node returns null if the block does not exist
+	if head == nil {
+		return nil, nil, ethereum.NotFound
+	}
+	var body rpcBlock
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, nil, err
+	}
+
+	// uncles are not included in the block response
+	uncles := make([]*geth_types.Header, len(body.UncleHashes))
+	if len(body.UncleHashes) > 0 {
+		reqs := make([]geth_rpc.BatchElem, len(body.UncleHashes))
+		for i := range reqs {
+			reqs[i] = geth_rpc.BatchElem{
+				Method: "eth_getUncleByBlockHashAndIndex",
+				Args:   []interface{}{body.Hash, hexutil.EncodeUint64(uint64(i))},
+				Result: &uncles[i],
+			}
+		}
+		if err := client.rpcClient.BatchCallContext(ctx, reqs); err != nil {
+			return nil, nil, err
+		}
+		for i := range reqs {
+			if reqs[i].Error != nil {
+				return nil, nil, reqs[i].Error
+			}
+			if uncles[i] == nil {
+				return nil, nil, fmt.Errorf("got null header for uncle %d of block %x", i, body.Hash)
+			}
+		}
+	}
+
+	txs := make([]*geth_types.Transaction, len(body.Transactions))
+	for i, tx := range body.Transactions {
+		txs[i] = tx.tx
+	}
+
+	return geth_types.NewBlockWithHeader(head).WithBody(txs, uncles).WithWithdrawals(body.Withdrawals), body.Transactions, nil
+}
+
+// transactionSender returns the sender of a transaction and whether its signature has been verified. The sender of a
+// zond transaction is derived from its dilithium public key, its signature is only checked if verify is set. The
+// sender of an ecdsa signed transaction is recovered from its signature. A sender reported by the node which differs
+// from the derived one is an error, the sender is never guessed.
+func transactionSender(tx *rpcTransaction, verify bool) (common.Address, bool, error) {
+	var from common.Address
+	verified := false
+
+	if len(tx.PublicKey) > 0 {
+		if len(tx.PublicKey) != utils.DilithiumPubkeyLength {
+			return from, false, fmt.Errorf("invalid public key length %v of tx %v, expected %v bytes", len(tx.PublicKey), tx.Hash, utils.DilithiumPubkeyLength)
+		}
+		from = common.Address(utils.DilithiumPubkeyToAddress(tx.PublicKey))
+
+		if verify {
+			hash := geth_types.LatestSignerForChainID(tx.tx.ChainId()).Hash(tx.tx)
+			if !utils.VerifyDilithiumSignature(tx.PublicKey, hash.Bytes(), tx.Signature) {
+				return from, false, fmt.Errorf("invalid dilithium signature of tx %v", tx.Hash)
+			}
+			verified = true
+		}
+	} else {
+		var err error
+		from, err = geth_types.Sender(geth_types.LatestSignerForChainID(tx.tx.ChainId()), tx.tx)
+		if err != nil {
+			return from, false, fmt.Errorf("error recovering sender of tx %v: %w", tx.Hash, err)
+		}
+		verified = true
+	}
+
+	if tx.From != nil && *tx.From != from {
+		return from, false, fmt.Errorf("sender %v of tx %v reported by the node does not match its signer %v", tx.From, tx.Hash, from)
+	}
+	return from, verified, nil
+}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/pkg/errors"
 )
 
@@ -210,16 +211,18 @@ func (a DiscordReq) Value() (driver.Value, error) {
 }
 
 type ApiEth1AddressResponse struct {
-	Address string `json:"address"`
-	Ether   string `json:"ether"`
-	Tokens  []struct {
-		Address  string  `json:"address"`
-		Balance  string  `json:"balance"`
-		Symbol   string  `json:"symbol"`
-		Decimals string  `json:"decimals,omitempty"`
-		Price    float64 `json:"price,omitempty"`
-		Currency string  `json:"currency,omitempty"`
-	} `json:"tokens"`
+	Address string                        `json:"address"`
+	Ether   string                        `json:"ether"`
+	Tokens  []ApiEth1AddressTokenResponse `json:"tokens"`
+}
+
+type ApiEth1AddressTokenResponse struct {
+	Address  string  `json:"address"`
+	Balance  string  `json:"balance"`
+	Symbol   string  `json:"symbol"`
+	Decimals string  `json:"decimals,omitempty"`
+	Price    float64 `json:"price,omitempty"`
+	Currency string  `json:"currency,omitempty"`
 }
 
 type APIEth1AddressTxResponse struct {
@@ -232,6 +235,8 @@ type Eth1TransactionParsed struct {
 	BlockNumber        uint64    `json:"block,omitempty"`
 	Time               time.Time `json:"time,omitempty"`
 	MethodId           string    `json:"method,omitempty"`
+	MethodName         string    `json:"method_name,omitempty"`
+	MethodAmbiguous    bool      `json:"method_ambiguous,omitempty"`
 	From               string    `json:"from,omitempty"`
 	To                 string    `json:"to,omitempty"`
 	Value              string    `json:"value,omitempty"`
@@ -317,6 +322,117 @@ type Eth1TokenTxParsed struct {
 	TokenId      string    `json:"token_id,omitempty"`
 	Operator     string    `json:"operator,omitempty"`
 }
+type ApiEth1TransactionResponse struct {
+	Hash                 string                    `json:"hash"`
+	BlockNumber          uint64                    `json:"block"`
+	BlockHash            string                    `json:"block_hash"`
+	TransactionIndex     int                       `json:"transaction_index"`
+	Time                 time.Time                 `json:"time"`
+	Type                 uint32                    `json:"type"`
+	Nonce                uint64                    `json:"nonce"`
+	From                 string                    `json:"from"`
+	To                   string                    `json:"to,omitempty"`
+	ContractAddress      string                    `json:"contract_address,omitempty"`
+	Value                string                    `json:"value"`
+	Input                string                    `json:"input"`
+	MethodId             string                    `json:"method,omitempty"`
+	DecodedInput         *Eth1DecodedCall          `json:"decoded_input,omitempty"`
+	Status               uint64                    `json:"status"`
+	ErrorMsg             string                    `json:"error,omitempty"`
+	GasLimit             uint64                    `json:"gas_limit"`
+	GasUsed              uint64                    `json:"gas_used"`
+	CumulativeGasUsed    uint64                    `json:"cumulative_gas_used"`
+	GasPrice             string                    `json:"gas_price"`
+	EffectiveGasPrice    string                    `json:"effective_gas_price"`
+	MaxFeePerGas         string                    `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string                    `json:"max_priority_fee_per_gas,omitempty"`
+	TxFee                string                    `json:"fee"`
+	PublicKeyHash        string                    `json:"public_key_hash,omitempty"`
+	SignatureHash        string                    `json:"signature_hash,omitempty"`
+	SignatureVerified    bool                      `json:"signature_verified"`
+	Logs                 []*Eth1LogParsed          `json:"logs"`
+	InternalCalls        []*Eth1InternalCallParsed `json:"internal_calls"`
+	ERC20Transfers       []*Eth1TokenTxParsed      `json:"erc20_transfers"`
+	ERC721Transfers      []*Eth1TokenTxParsed      `json:"erc721_transfers"`
+	ERC1155Transfers     []*Eth1TokenTxParsed      `json:"erc1155_transfers"`
+}
+
+type ApiEth1ReorgResponse struct {
+	ForkBlock  uint64                      `json:"fork_block"`
+	Depth      uint64                      `json:"depth"`
+	DetectedAt time.Time                   `json:"detected_at"`
+	Blocks     []ApiEth1ReorgBlockResponse `json:"blocks"`
+}
+
+type ApiEth1ReorgBlockResponse struct {
+	Number  uint64 `json:"number"`
+	OldHash string `json:"old_hash"`
+	NewHash string `json:"new_hash,omitempty"`
+}
+
+type ApiEth1StatusResponse struct {
+	LastBlock  uint64                           `json:"last_block"`
+	Transforms []ApiEth1TransformStatusResponse `json:"transforms"`
+}
+
+// ApiEth1TransformStatusResponse is the progress of a transform, Block is nil if it hasn't stored a cursor yet
+type ApiEth1TransformStatusResponse struct {
+	Name      string     `json:"name"`
+	Version   uint64     `json:"version"`
+	Block     *uint64    `json:"block"`
+	Lag       uint64     `json:"lag"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type Eth1LogParsed struct {
+	Index   int               `json:"index"`
+	Address string            `json:"address"`
+	Topics  []string          `json:"topics"`
+	Data    string            `json:"data"`
+	Removed bool              `json:"removed,omitempty"`
+	Decoded *Eth1DecodedEvent `json:"decoded,omitempty"`
+}
+
+// Eth1InternalCallParsed is a node of the internal call tree of a transaction
+type Eth1InternalCallParsed struct {
+	Path     string                    `json:"path"`
+	Type     string                    `json:"type"`
+	From     string                    `json:"from"`
+	To       string                    `json:"to"`
+	Value    string                    `json:"value"`
+	ErrorMsg string                    `json:"error,omitempty"`
+	Calls    []*Eth1InternalCallParsed `json:"calls,omitempty"`
+}
+
+type Eth1DecodedCall struct {
+	Name      string              `json:"name"`
+	Signature string              `json:"signature"`
+	Params    []*Eth1DecodedParam `json:"params"`
+	// Guessed is set if the signature was taken from the signature database instead of the contract abi, Ambiguous if
+	// several signatures of the database match and Alternatives holds the other matching signatures
+	Guessed      bool     `json:"guessed,omitempty"`
+	Ambiguous    bool     `json:"ambiguous,omitempty"`
+	Alternatives []string `json:"alternatives,omitempty"`
+}
+
+type Eth1DecodedEvent struct {
+	Name      string              `json:"name"`
+	Signature string              `json:"signature"`
+	Params    []*Eth1DecodedParam `json:"params"`
+	// Guessed is set if the signature was taken from the signature database instead of the contract abi, Ambiguous if
+	// several signatures of the database match and Alternatives holds the other matching signatures
+	Guessed      bool     `json:"guessed,omitempty"`
+	Ambiguous    bool     `json:"ambiguous,omitempty"`
+	Alternatives []string `json:"alternatives,omitempty"`
+}
+
+type Eth1DecodedParam struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Value   interface{} `json:"value"`
+	Indexed bool        `json:"indexed,omitempty"`
+}
+
 type ApiWithdrawalCredentialsResponse struct {
 	Publickey      string `json:"publickey"`
 	ValidatorIndex uint64 `json:"validatorindex"`
@@ -712,3 +828,157 @@ func (income *ValidatorEpochIncome) TotalClRewards() int64 {
 		income.SlashingPenalty
 	return int64(rewards) - int64(penalties)
 }
+
+type ApiEth1BalanceHistoryResponse struct {
+	Address     string                        `json:"address"`
+	Token       string                        `json:"token,omitempty"`
+	BlockNumber uint64                        `json:"block_number"`
+	Balance     string                        `json:"balance"`
+	Daily       []ApiEth1DailyBalanceResponse `json:"daily"`
+}
+
+type ApiEth1DailyBalanceResponse struct {
+	Day     string `json:"day"`
+	Balance string `json:"balance"`
+}
+
+type ApiEth1TokensResponse struct {
+	Tokens []ApiEth1TokenResponse `json:"tokens"`
+	// Next is the start address of the next page, empty on the last page
+	Next string `json:"next"`
+}
+
+type ApiEth1TokenResponse struct {
+	Address     string   `json:"address"`
+	Standard    string   `json:"standard"`
+	Name        string   `json:"name"`
+	Symbol      string   `json:"symbol"`
+	Decimals    string   `json:"decimals,omitempty"`
+	TotalSupply string   `json:"total_supply,omitempty"`
+	Interfaces  []string `json:"interfaces"`
+	FirstBlock  uint64   `json:"first_block"`
+}
+
+type ApiEth1NFTsResponse struct {
+	Address string                      `json:"address"`
+	NFTs    []ApiEth1NFTHoldingResponse `json:"nfts"`
+	Page    string                      `json:"page"`
+}
+
+type ApiEth1NFTHoldingResponse struct {
+	Token    string `json:"token"`
+	TokenId  string `json:"token_id"`
+	Standard string `json:"standard"`
+	Balance  string `json:"balance"`
+}
+
+type ApiEth1NFTResponse struct {
+	Token    string                    `json:"token"`
+	TokenId  string                    `json:"token_id"`
+	Standard string                    `json:"standard"`
+	Owners   []ApiEth1NFTOwnerResponse `json:"owners"`
+	URI      string                    `json:"uri,omitempty"`
+	Metadata json.RawMessage           `json:"metadata,omitempty"`
+	Page     string                    `json:"page"`
+}
+
+type ApiEth1NFTOwnerResponse struct {
+	Address string `json:"address"`
+	Balance string `json:"balance"`
+}
+
+// ApiEth1VerifyContractRequest holds the compiler output a deployed contract is verified against. The metadata is
+// either passed as is or taken from the contracts of a standard-JSON output, the sources are taken from the metadata
+// or from a standard-JSON input.
+type ApiEth1VerifyContractRequest struct {
+	// Metadata is the compiler metadata JSON exactly as produced by the compiler
+	Metadata string `json:"metadata"`
+	// Input is a standard-JSON compiler input providing the sources
+	Input json.RawMessage `json:"input"`
+	// Output is a standard-JSON compiler output, the metadata of each of its contracts is tried
+	Output json.RawMessage `json:"output"`
+	// ABI is compared with the ABI of the metadata if set
+	ABI json.RawMessage `json:"abi"`
+}
+
+type ApiEth1VerifyContractResponse struct {
+	Address  string   `json:"address"`
+	Name     string   `json:"name"`
+	Compiler string   `json:"compiler"`
+	Sources  []string `json:"sources"`
+}
+
+type ApiEth1ContractEventsResponse struct {
+	Name   string                         `json:"name"`
+	Events []ApiEth1ContractEventResponse `json:"events"`
+	Page   string                         `json:"page"`
+}
+
+type ApiEth1ContractEventResponse struct {
+	Contract    string                 `json:"contract"`
+	Event       string                 `json:"event"`
+	BlockNumber uint64                 `json:"block_number"`
+	Time        time.Time              `json:"time"`
+	TxHash      string                 `json:"tx_hash"`
+	TxIndex     uint64                 `json:"tx_index"`
+	LogIndex    uint64                 `json:"log_index"`
+	Params      map[string]interface{} `json:"params"`
+}
+
+// ApiEth1LogFilterRequest is the filter object of eth_getLogs
+type ApiEth1LogFilterRequest struct {
+	// FromBlock and ToBlock are hex encoded block numbers or one of the tags earliest and latest, both default to latest
+	FromBlock string `json:"fromBlock"`
+	ToBlock   string `json:"toBlock"`
+	// BlockHash selects the logs of a single block and excludes FromBlock and ToBlock
+	BlockHash string       `json:"blockHash"`
+	Address   StringOrList `json:"address"`
+	// Topics holds a topic, a list of alternative topics or null for any topic per position
+	Topics []StringOrList `json:"topics"`
+}
+
+// StringOrList is a json string or a list of strings, null or a list containing null is an empty list
+type StringOrList []string
+
+func (s *StringOrList) UnmarshalJSON(data []byte) error {
+	var values []*string
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		values = []*string{&value}
+	} else if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*s = make(StringOrList, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			*s = nil
+			return nil
+		}
+		*s = append(*s, *value)
+	}
+	return nil
+}
+
+type ApiEth1LogsResponse struct {
+	Logs []ApiEth1LogResponse `json:"logs"`
+	Page string               `json:"page"`
+}
+
+// ApiEth1LogResponse is a log in the format of eth_getLogs
+type ApiEth1LogResponse struct {
+	Address          string          `json:"address"`
+	Topics           []hexutil.Bytes `json:"topics"`
+	Data             hexutil.Bytes   `json:"data"`
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	BlockHash        hexutil.Bytes   `json:"blockHash"`
+	TransactionHash  hexutil.Bytes   `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+	LogIndex         hexutil.Uint64  `json:"logIndex"`
+	Removed          bool            `json:"removed"`
+	// Decoded is the best effort decoding of the log with the abi of the contract or the signature database
+	Decoded *Eth1DecodedEvent `json:"decoded,omitempty"`
+}
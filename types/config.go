@@ -33,6 +33,7 @@ type Config struct {
 		DomainBLSToExecutionChange string `yaml:"domainBLSToExecutionChange" envconfig:"CHAIN_DOMAIN_BLS_TO_EXECUTION_CHANGE"`
 		DomainVoluntaryExit        string `yaml:"domainVoluntaryExit" envconfig:"CHAIN_DOMAIN_VOLUNTARY_EXIT"`
 		ConfigPath                 string `yaml:"configPath" envconfig:"CHAIN_CONFIG_PATH"`
+		SignatureScheme            string `yaml:"signatureScheme" envconfig:"CHAIN_SIGNATURE_SCHEME"`
 		Config                     ChainConfig
 	} `yaml:"chain"`
 	Eth1ErigonEndpoint  string `yaml:"eth1ErigonEndpoint" envconfig:"ETH1_ERIGON_ENDPOINT"`
@@ -66,6 +67,8 @@ type Config struct {
 		PubKeyTagsExporter struct {
 			Enabled bool `yaml:"enabled" envconfig:"PUBKEY_TAGS_EXPORTER_ENABLED"`
 		} `yaml:"pubkeyTagsExporter"`
+		// ContractEvents lists the events of custom contracts the execution layer indexer decodes into their own collections
+		ContractEvents []ContractEventsConfig `yaml:"contractEvents"`
 	} `yaml:"indexer"`
 	Frontend struct {
 		Debug                          bool   `yaml:"debug" envconfig:"FRONTEND_DEBUG"`
@@ -191,3 +194,18 @@ type DatabaseConfig struct {
 	Host     string
 	Port     string
 }
+
+// ContractEventsConfig declares the events of a set of contracts sharing an abi which are indexed into the collection
+// events_<name> and served by the /execution/events/<name> endpoint
+type ContractEventsConfig struct {
+	// Name may only contain lower case letters, digits and underscores
+	Name      string   `yaml:"name"`
+	Addresses []string `yaml:"addresses"`
+	// ABIFile is the path of a json abi, or of a compiler artifact with an abi field
+	ABIFile string `yaml:"abiFile"`
+	// Events are the names of the events to index, all events of the abi are indexed if empty
+	Events []string `yaml:"events"`
+	// Version has to be increased after changing the addresses or the events, the index is then tracked as a new
+	// transform which has to be backfilled. Defaults to 1.
+	Version uint64 `yaml:"version"`
+}
@@ -1,8 +1,12 @@
 package types
 
 import (
+	"math/big"
 	"time"
 
+	"github.com/coocood/freecache"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -15,4 +19,178 @@ type GetBlockTimings struct {
 type BulkMutations struct {
 	Keys  []string
 	Model []mongo.WriteModel
+	// Collections holds the writes to other collections than the data collection, e.g. of the contract event transforms
+	Collections map[string][]mongo.WriteModel
+}
+
+// Append adds the writes of other to the mutations
+func (m *BulkMutations) Append(other *BulkMutations) {
+	m.Keys = append(m.Keys, other.Keys...)
+	m.Model = append(m.Model, other.Model...)
+	for collection, models := range other.Collections {
+		if m.Collections == nil {
+			m.Collections = make(map[string][]mongo.WriteModel)
+		}
+		m.Collections[collection] = append(m.Collections[collection], models...)
+	}
+}
+
+// Eth1Transform is a named transform of the blocks into the data table. The version of a transform is bumped whenever
+// the documents it writes change, the progress of every version is tracked in a cursor of its own.
+type Eth1Transform struct {
+	Name      string
+	Version   uint64
+	Transform func(blk *Eth1Block, cache *freecache.Cache) (*BulkMutations, []mongo.WriteModel, error)
+}
+
+// ContractEventIndex is a parsed ContractEventsConfig
+type ContractEventIndex struct {
+	Name       string
+	Collection string
+	Version    uint64
+	// Addresses is nil if the events of all contracts are indexed
+	Addresses map[common.Address]bool
+	// Events holds the indexed events by their topic
+	Events map[common.Hash]abi.Event
+}
+
+// ContractEventFilter narrows down the events of a query, all set conditions have to match
+type ContractEventFilter struct {
+	Contract []byte
+	Event    string
+	// Params holds the values of top level event parameters by name, converted to their stored representation
+	Params map[string]interface{}
+	// FromBlock and ToBlock are inclusive, a zero ToBlock means no upper bound
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+// Eth1ContractEvent is a decoded event of a contract event index
+type Eth1ContractEvent struct {
+	Contract    []byte
+	Event       string
+	BlockNumber uint64
+	Time        time.Time
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+	// Params holds the json representation of the event parameters by name
+	Params map[string]interface{}
+}
+
+// Eth1Signature is a text signature of the signature database. The selector of a function is the first 4 bytes of
+// the hash of its signature, the selector of an event the whole hash.
+type Eth1Signature struct {
+	Selector  []byte
+	Signature string
+	Event     bool
+}
+
+// LogFilter selects logs like the filter object of eth_getLogs. A log matches if it was emitted by one of the addresses
+// and has one of the topics of the respective set at every position, an empty set matches any topic.
+type LogFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	// BlockHash selects the logs of a single block instead of the block range
+	BlockHash []byte
+	Addresses [][]byte
+	Topics    [][][]byte
+}
+
+// Eth1LogIndexed is a log of the generic log index, LogIndex is the position of the log in its block
+type Eth1LogIndexed struct {
+	Address     []byte
+	Topics      [][]byte
+	Data        []byte
+	BlockNumber uint64
+	BlockHash   []byte
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+}
+
+// AddressIndexFilter narrows down the address index entries of a query, all set conditions have to match
+type AddressIndexFilter struct {
+	// Direction is "in" or "out", transfers to self match both directions
+	Direction            string
+	Counterparty         []byte
+	Token                []byte
+	Method               []byte
+	FailedOnly           bool
+	ContractCreationOnly bool
+	// FromBlock and ToBlock are inclusive, a zero ToBlock means no upper bound
+	FromBlock uint64
+	ToBlock   uint64
+	// FromTime and ToTime are inclusive, zero values mean no bound
+	FromTime time.Time
+	ToTime   time.Time
+}
+
+// BalanceDeltaFilter narrows down the balance deltas of an address and token. FromBlock and ToBlock are inclusive, a
+// zero ToBlock means no upper bound. FromTime is inclusive, a zero value means no bound.
+type BalanceDeltaFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	FromTime  time.Time
+}
+
+// Eth1BalanceDelta is the net change of the balance of an address for a token caused by a block, the native token
+// is 0x00
+type Eth1BalanceDelta struct {
+	Address     []byte
+	Token       []byte
+	BlockNumber uint64
+	Time        time.Time
+	Delta       *big.Int
+}
+
+// Eth1DailyBalance is the balance of an address for a token at the end of a day (UTC)
+type Eth1DailyBalance struct {
+	Day     time.Time
+	Balance *big.Int
+}
+
+// Eth1Token is a token contract of the token registry. Standard is erc20, erc721 or erc1155, Interfaces lists the
+// token interfaces the contract reports via ERC-165 and FirstBlock is the earliest block the token has been seen in.
+type Eth1Token struct {
+	Address     []byte
+	Standard    string
+	Name        string
+	Symbol      string
+	Decimals    []byte
+	TotalSupply []byte
+	Interfaces  []string
+	FirstBlock  uint64
+	UpdatedAt   time.Time
+}
+
+// Eth1NFTDelta is the net change of the number of units of a token id held by an address caused by a block
+type Eth1NFTDelta struct {
+	Address     []byte
+	Token       []byte
+	TokenId     []byte
+	Standard    string
+	BlockNumber uint64
+	Delta       *big.Int
+}
+
+// Eth1NFTHolding is the number of units of an ERC-721 or ERC-1155 token id held by an address, always 1 for ERC-721
+// tokens
+type Eth1NFTHolding struct {
+	Address  []byte
+	Token    []byte
+	TokenId  []byte
+	Standard string
+	Balance  *big.Int
+}
+
+// Eth1NFTMetadata is the metadata uri of a token id, Metadata holds the JSON metadata if the uri embeds it
+type Eth1NFTMetadata struct {
+	Token      []byte
+	TokenId    []byte
+	Standard   string
+	URI        string
+	Metadata   []byte
+	FirstBlock uint64
+	UpdatedAt  time.Time
 }
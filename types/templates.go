@@ -158,10 +158,12 @@ type ValidatorEffectiveness struct {
 }
 
 type Eth1AddressBalance struct {
-	Address  []byte
-	Token    []byte
-	Balance  []byte
-	Metadata *ERC20Metadata
+	Address []byte
+	Token   []byte
+	Balance []byte
+	// BlockNumber is the block the balance has been retrieved at
+	BlockNumber uint64
+	Metadata    *ERC20Metadata
 }
 
 type Eth1AddressMetadata struct {
@@ -176,6 +178,10 @@ type ContractMetadata struct {
 	Name    string
 	ABI     *abi.ABI `msgpack:"-"`
 	ABIJson []byte
+	// the fields below are only set for contracts verified against their compiler metadata
+	Compiler         string
+	CompilerMetadata []byte
+	Sources          map[string]string
 }
 
 type EtherscanContractMetadata struct {
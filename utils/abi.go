@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DecodeCallData decodes the input of a contract call using the abi of the called contract
+func DecodeCallData(contractAbi *abi.ABI, data []byte) (*types.Eth1DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("call data too short to contain a method selector")
+	}
+
+	method, err := contractAbi.MethodById(data[:4])
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("error unpacking arguments of method %v: %w", method.Name, err)
+	}
+
+	decoded := &types.Eth1DecodedCall{
+		Name:      method.Name,
+		Signature: method.Sig,
+		Params:    make([]*types.Eth1DecodedParam, 0, len(method.Inputs)),
+	}
+	for i, input := range method.Inputs {
+		decoded.Params = append(decoded.Params, &types.Eth1DecodedParam{
+			Name:  input.Name,
+			Type:  input.Type.String(),
+			Value: formatAbiValue(values[i]),
+		})
+	}
+	return decoded, nil
+}
+
+// DecodeLog decodes an event log using the abi of the emitting contract
+func DecodeLog(contractAbi *abi.ABI, topics [][]byte, data []byte) (*types.Eth1DecodedEvent, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("anonymous events can not be decoded")
+	}
+
+	event, err := contractAbi.EventByID(common.BytesToHash(topics[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, values, err := UnpackLog(event, topics, data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &types.Eth1DecodedEvent{
+		Name:      event.Name,
+		Signature: event.Sig,
+		Params:    make([]*types.Eth1DecodedParam, 0, len(inputs)),
+	}
+	for i, input := range inputs {
+		decoded.Params = append(decoded.Params, &types.Eth1DecodedParam{
+			Name:    event.Inputs[i].Name,
+			Type:    input.Type.String(),
+			Value:   formatAbiValue(values[input.Name]),
+			Indexed: input.Indexed,
+		})
+	}
+	return decoded, nil
+}
+
+// UnpackLog unpacks the parameters of a log of the event into a map keyed by the names of the returned arguments,
+// unnamed parameters are named arg<i>. Indexed parameters of dynamic types only hold the topic hash of their value.
+func UnpackLog(event *abi.Event, topics [][]byte, data []byte) (abi.Arguments, map[string]interface{}, error) {
+	// unnamed arguments would overwrite each other in the value map
+	inputs := make(abi.Arguments, len(event.Inputs))
+	indexed := abi.Arguments{}
+	for i, input := range event.Inputs {
+		if input.Name == "" {
+			input.Name = fmt.Sprintf("arg%d", i)
+		}
+		inputs[i] = input
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(topics) != len(indexed)+1 {
+		return nil, nil, fmt.Errorf("event %v has %v indexed parameters, the log has %v topics", event.Name, len(indexed), len(topics))
+	}
+
+	values := make(map[string]interface{}, len(inputs))
+	if err := inputs.UnpackIntoMap(values, data); err != nil {
+		return nil, nil, fmt.Errorf("error unpacking data of event %v: %w", event.Name, err)
+	}
+
+	topicHashes := make([]common.Hash, 0, len(topics)-1)
+	parsed := abi.Arguments{}
+	for i, topic := range topics[1:] {
+		// tuples can't be reconstructed from their topic either
+		if indexed[i].Type.T == abi.TupleTy {
+			values[indexed[i].Name] = common.BytesToHash(topic)
+			continue
+		}
+		parsed = append(parsed, indexed[i])
+		topicHashes = append(topicHashes, common.BytesToHash(topic))
+	}
+	if err := abi.ParseTopicsIntoMap(values, parsed, topicHashes); err != nil {
+		return nil, nil, fmt.Errorf("error parsing topics of event %v: %w", event.Name, err)
+	}
+	return inputs, values, nil
+}
+
+// formatAbiValue converts an unpacked abi value into its json representation. Addresses are rendered with the
+// Z prefix, integers as decimal strings to keep their precision and byte values as 0x prefixed hex strings.
+func formatAbiValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case common.Address:
+		return FormatAddressString(v.Bytes())
+	case *big.Int:
+		return v.String()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return "0x" + hex.EncodeToString(b)
+		}
+		values := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values[i] = formatAbiValue(rv.Index(i).Interface())
+		}
+		return values
+	case reflect.Struct:
+		values := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			values[rv.Type().Field(i).Name] = formatAbiValue(rv.Field(i).Interface())
+		}
+		return values
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value)
+	}
+	return value
+}
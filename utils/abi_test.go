@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const erc20TestAbi = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+func TestDecodeCallData(t *testing.T) {
+	contractAbi, err := abi.JSON(strings.NewReader(erc20TestAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	data, err := contractAbi.Pack("transfer", to, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCallData(&contractAbi, data)
+	if err != nil {
+		t.Fatalf("DecodeCallData() error = %v", err)
+	}
+	if decoded.Name != "transfer" || decoded.Signature != "transfer(address,uint256)" {
+		t.Errorf("DecodeCallData() method = %v %v", decoded.Name, decoded.Signature)
+	}
+	got := []interface{}{decoded.Params[0].Value, decoded.Params[1].Value}
+	want := []interface{}{"Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "1000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeCallData() params = %v, want %v", got, want)
+	}
+
+	if _, err := DecodeCallData(&contractAbi, []byte{0x01, 0x02, 0x03, 0x04}); err == nil {
+		t.Errorf("DecodeCallData() expected an error for an unknown selector")
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	contractAbi, err := abi.JSON(strings.NewReader(erc20TestAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	to := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	data, _ := hex.DecodeString("00000000000000000000000000000000000000000000000000000000000003e8")
+	topics := [][]byte{
+		contractAbi.Events["Transfer"].ID.Bytes(),
+		common.BytesToHash(from.Bytes()).Bytes(),
+		common.BytesToHash(to.Bytes()).Bytes(),
+	}
+
+	decoded, err := DecodeLog(&contractAbi, topics, data)
+	if err != nil {
+		t.Fatalf("DecodeLog() error = %v", err)
+	}
+	if decoded.Name != "Transfer" {
+		t.Errorf("DecodeLog() event = %v", decoded.Name)
+	}
+	got := []interface{}{decoded.Params[0].Value, decoded.Params[1].Value, decoded.Params[2].Value}
+	want := []interface{}{"Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "ZfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "1000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeLog() params = %v, want %v", got, want)
+	}
+	if !decoded.Params[0].Indexed || decoded.Params[2].Indexed {
+		t.Errorf("DecodeLog() indexed flags are wrong")
+	}
+}
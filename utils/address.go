@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressLength is the length of a zond execution layer address in bytes
+const AddressLength = common.AddressLength
+
+// AddressPrefix is the prefix zond uses when rendering execution layer addresses
+const AddressPrefix = "Z"
+
+// legacyAddressPrefix is the ethereum style prefix which is still accepted as input
+const legacyAddressPrefix = "0x"
+
+// Address represents a zond execution layer address. It is stored as raw bytes,
+// rendered with the Z prefix and accepts both Z and legacy 0x prefixed input.
+type Address [AddressLength]byte
+
+// ParseAddress parses a Z or 0x prefixed (or unprefixed) hex string into an Address
+func ParseAddress(s string) (Address, error) {
+	var a Address
+
+	raw := trimAddressPrefix(strings.TrimSpace(s))
+	if len(raw) != AddressLength*2 {
+		return a, fmt.Errorf("invalid address %q: expected %d hex characters, got %d", s, AddressLength*2, len(raw))
+	}
+
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return a, fmt.Errorf("invalid address %q: %w", s, err)
+	}
+	copy(a[:], b)
+
+	return a, nil
+}
+
+// MustParseAddress parses an address and panics if the input is invalid
+func MustParseAddress(s string) Address {
+	a, err := ParseAddress(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// BytesToAddress converts a byte slice into an Address. Longer inputs are
+// cropped from the left, shorter inputs are left padded with zeros.
+func BytesToAddress(b []byte) Address {
+	return Address(common.BytesToAddress(b))
+}
+
+// Bytes returns the raw address bytes
+func (a Address) Bytes() []byte {
+	return a[:]
+}
+
+// Hex returns the lower case hex encoding of the address without any prefix.
+// This is the representation used for database keys.
+func (a Address) Hex() string {
+	return hex.EncodeToString(a[:])
+}
+
+// Checksum returns the mixed case checksummed hex encoding of the address without any prefix
+func (a Address) Checksum() string {
+	return strings.TrimPrefix(common.Address(a).Hex(), legacyAddressPrefix)
+}
+
+// String returns the checksummed, Z prefixed representation of the address
+func (a Address) String() string {
+	return AddressPrefix + a.Checksum()
+}
+
+// IsZero returns true if the address is the zero address
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// IsAddress verifies whether a string represents an address with a Z, 0x or no prefix.
+// In contrast to IsValidAddress, this also returns true for the zero address.
+func IsAddress(s string) bool {
+	_, err := ParseAddress(s)
+	return err == nil
+}
+
+// IsValidAddress verifies whether a string represents a non zero address with a Z, 0x or no prefix
+func IsValidAddress(s string) bool {
+	a, err := ParseAddress(s)
+	return err == nil && !a.IsZero()
+}
+
+// FormatAddressString returns the checksummed, Z prefixed representation of the passed address bytes
+func FormatAddressString(address []byte) string {
+	return BytesToAddress(address).String()
+}
+
+// trimAddressPrefix removes a leading Z or 0x prefix (case insensitive)
+func trimAddressPrefix(s string) string {
+	if len(s) > 0 && (s[0] == 'Z' || s[0] == 'z') {
+		return s[1:]
+	}
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "z prefix",
+			input: "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			want:  "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:  "lower case z prefix",
+			input: "z5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			want:  "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:  "legacy 0x prefix",
+			input: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			want:  "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:  "no prefix",
+			input: "5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			want:  "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:    "too short",
+			input:   "Z5aaeb6053f3e94c9b9a09f33669435e7ef1bea",
+			wantErr: true,
+		},
+		{
+			name:    "invalid hex",
+			input:   "Zzaaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddress(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAddress(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseAddress(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if FixAddressCasing(tt.input) != tt.want {
+				t.Errorf("FixAddressCasing(%q) = %v, want %v", tt.input, FixAddressCasing(tt.input), tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidEth1Address(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "z prefix", input: "Z5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", want: true},
+		{name: "0x prefix", input: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", want: true},
+		{name: "zero address", input: "Z0000000000000000000000000000000000000000", want: false},
+		{name: "garbage", input: "Zhello", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidEth1Address(tt.input); got != tt.want {
+				t.Errorf("IsValidEth1Address(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+)
+
+// BuildInternalCallTree arranges the internal transactions of a transaction into a call tree using their trace
+// address paths. Paths are formatted like "[0 1]", the top level call has the empty path "[]". Calls whose parent
+// is not part of the trace, for example flat geth style traces, are returned at the top level.
+func BuildInternalCallTree(itxs []*types.Eth1InternalTransaction) []*types.Eth1InternalCallParsed {
+	roots := []*types.Eth1InternalCallParsed{}
+	nodes := make(map[string]*types.Eth1InternalCallParsed, len(itxs))
+
+	for _, itx := range itxs {
+		path, ok := parseTracePath(itx.Path)
+		node := &types.Eth1InternalCallParsed{
+			Path:     itx.Path,
+			Type:     itx.Type,
+			From:     FormatAddressString(itx.From),
+			To:       FormatAddressString(itx.To),
+			Value:    new(big.Int).SetBytes(itx.Value).String(),
+			ErrorMsg: itx.ErrorMsg,
+		}
+		if !ok {
+			roots = append(roots, node)
+			continue
+		}
+		nodes[tracePathKey(path)] = node
+
+		if len(path) == 0 {
+			roots = append(roots, node)
+			continue
+		}
+		parent, found := nodes[tracePathKey(path[:len(path)-1])]
+		if !found {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Calls = append(parent.Calls, node)
+	}
+
+	return roots
+}
+
+// parseTracePath parses a trace address path like "[0 1]", ok is false if the path is not in this format
+func parseTracePath(path string) (indices []uint64, ok bool) {
+	if !strings.HasPrefix(path, "[") || !strings.HasSuffix(path, "]") {
+		return nil, false
+	}
+	for _, field := range strings.Fields(path[1 : len(path)-1]) {
+		index, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		indices = append(indices, index)
+	}
+	return indices, true
+}
+
+func tracePathKey(path []uint64) string {
+	parts := make([]string, len(path))
+	for i, index := range path {
+		parts[i] = strconv.FormatUint(index, 10)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+)
+
+func TestBuildInternalCallTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		// want lists the paths of the tree in depth first order, children are indented by a space per level
+		want []string
+	}{
+		{
+			name:  "nested calls",
+			paths: []string{"[]", "[0]", "[0 0]", "[0 1]", "[1]"},
+			want:  []string{"[]", " [0]", "  [0 0]", "  [0 1]", " [1]"},
+		},
+		{
+			name:  "missing top level call",
+			paths: []string{"[0]", "[0 0]", "[1]"},
+			want:  []string{"[0]", " [0 0]", "[1]"},
+		},
+		{
+			name:  "flat geth traces",
+			paths: []string{"0", "0", "0"},
+			want:  []string{"0", "0", "0"},
+		},
+		{
+			name:  "no internal transactions",
+			paths: nil,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			itxs := make([]*types.Eth1InternalTransaction, 0, len(tt.paths))
+			for _, path := range tt.paths {
+				itxs = append(itxs, &types.Eth1InternalTransaction{Type: "call", Path: path})
+			}
+
+			var got []string
+			var walk func(calls []*types.Eth1InternalCallParsed, indent string)
+			walk = func(calls []*types.Eth1InternalCallParsed, indent string) {
+				for _, call := range calls {
+					got = append(got, indent+call.Path)
+					walk(call.Calls, indent+" ")
+				}
+			}
+			walk(BuildInternalCallTree(itxs), "")
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("BuildInternalCallTree() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("BuildInternalCallTree() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+)
+
+func TestReadConfigDepositContract(t *testing.T) {
+	tests := []struct {
+		chain string
+		valid bool
+	}{
+		{chain: "zond-devnet", valid: true},
+		// the public networks have no presets, their configs are passed via chain.configPath
+		{chain: "zond-testnet", valid: false},
+		{chain: "zond-mainnet", valid: false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		if err := os.WriteFile(path, []byte("chain:\n  name: "+tt.chain+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &types.Config{}
+		err := ReadConfig(cfg, path)
+		if tt.valid != (err == nil) {
+			t.Errorf("%v: got error %v, want valid %v", tt.chain, err, tt.valid)
+		}
+		if tt.valid && (cfg.Chain.Config.DepositChainID == 0 || cfg.Chain.Config.DepositContractAddress == "") {
+			t.Errorf("%v: got deposit chain id %v and contract %q", tt.chain, cfg.Chain.Config.DepositChainID, cfg.Chain.Config.DepositContractAddress)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrContractVerification is returned if a contract does not match the passed compiler output
+var ErrContractVerification = errors.New("contract verification failed")
+
+// CompilerMetadata is the part of the solidity compiler metadata JSON used to verify a contract
+type CompilerMetadata struct {
+	Compiler struct {
+		Version string `json:"version"`
+	} `json:"compiler"`
+	Language string `json:"language"`
+	Output   struct {
+		ABI json.RawMessage `json:"abi"`
+	} `json:"output"`
+	Settings struct {
+		CompilationTarget map[string]string `json:"compilationTarget"`
+	} `json:"settings"`
+	Sources map[string]struct {
+		Keccak256 string `json:"keccak256"`
+		Content   string `json:"content"`
+	} `json:"sources"`
+}
+
+// ContractMetadataHash returns the hash of the compiler metadata the solidity compiler appends CBOR encoded to the
+// runtime bytecode, kind is the hash function: ipfs, bzzr0 or bzzr1
+func ContractMetadataHash(code []byte) (kind string, hash []byte, err error) {
+	if len(code) < 2 {
+		return "", nil, fmt.Errorf("%w: no contract code", ErrContractVerification)
+	}
+	length := int(binary.BigEndian.Uint16(code[len(code)-2:]))
+	if length > len(code)-2 {
+		return "", nil, fmt.Errorf("%w: the contract code does not end with compiler metadata", ErrContractVerification)
+	}
+
+	entries, err := decodeCBORMap(code[len(code)-2-length : len(code)-2])
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: the contract code does not end with compiler metadata: %v", ErrContractVerification, err)
+	}
+	for _, kind := range []string{"ipfs", "bzzr1", "bzzr0"} {
+		if hash, ok := entries[kind]; ok {
+			return kind, hash, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: the compiler metadata of the contract code holds no metadata hash", ErrContractVerification)
+}
+
+// decodeCBORMap decodes the CBOR map appended to the runtime bytecode, a map with text keys and byte string, text or
+// boolean values. Boolean values are returned as a single byte.
+func decodeCBORMap(data []byte) (map[string][]byte, error) {
+	pos := 0
+	header := func() (major byte, value uint64, err error) {
+		if pos >= len(data) {
+			return 0, 0, fmt.Errorf("unexpected end of data")
+		}
+		major, info := data[pos]>>5, data[pos]&0x1f
+		pos++
+		size := 0
+		switch {
+		case info < 24:
+			return major, uint64(info), nil
+		case info == 24:
+			size = 1
+		case info == 25:
+			size = 2
+		case info == 26:
+			size = 4
+		default:
+			return 0, 0, fmt.Errorf("unsupported additional information %v", info)
+		}
+		if pos+size > len(data) {
+			return 0, 0, fmt.Errorf("unexpected end of data")
+		}
+		for _, b := range data[pos : pos+size] {
+			value = value<<8 | uint64(b)
+		}
+		pos += size
+		return major, value, nil
+	}
+	content := func(length uint64) ([]byte, error) {
+		if uint64(len(data)-pos) < length {
+			return nil, fmt.Errorf("unexpected end of data")
+		}
+		pos += int(length)
+		return data[pos-int(length) : pos], nil
+	}
+
+	major, count, err := header()
+	if err != nil {
+		return nil, err
+	}
+	if major != 5 {
+		return nil, fmt.Errorf("expected a map, got major type %v", major)
+	}
+
+	entries := make(map[string][]byte, count)
+	for i := uint64(0); i < count; i++ {
+		major, length, err := header()
+		if err != nil {
+			return nil, err
+		}
+		if major != 3 {
+			return nil, fmt.Errorf("expected a text key, got major type %v", major)
+		}
+		key, err := content(length)
+		if err != nil {
+			return nil, err
+		}
+
+		major, length, err = header()
+		if err != nil {
+			return nil, err
+		}
+		var value []byte
+		switch {
+		case major == 2 || major == 3:
+			value, err = content(length)
+			if err != nil {
+				return nil, err
+			}
+		case major == 7 && (length == 20 || length == 21):
+			value = []byte{byte(length - 20)}
+		default:
+			return nil, fmt.Errorf("unsupported value of major type %v for key %s", major, key)
+		}
+		entries[string(key)] = value
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("unexpected data after the map")
+	}
+	return entries, nil
+}
+
+func varint(n uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, n)]
+}
+
+// IpfsHash returns the sha2-256 multihash of the IPFS UnixFS file holding data, which is the hash of the metadata the
+// solidity compiler embeds by default. Files are only supported up to the IPFS chunk size of 256 KiB.
+func IpfsHash(data []byte) ([]byte, error) {
+	if len(data) > 256*1024 {
+		return nil, fmt.Errorf("ipfs hashes of files larger than 256 KiB are not supported")
+	}
+
+	// unixfs file node: type file, data and file size
+	unixfs := []byte{0x08, 0x02}
+	if len(data) > 0 {
+		unixfs = append(unixfs, 0x12)
+		unixfs = append(unixfs, varint(uint64(len(data)))...)
+		unixfs = append(unixfs, data...)
+	}
+	unixfs = append(unixfs, 0x18)
+	unixfs = append(unixfs, varint(uint64(len(data)))...)
+
+	// dag-pb node holding the unixfs node without links
+	node := append([]byte{0x0a}, varint(uint64(len(unixfs)))...)
+	node = append(node, unixfs...)
+
+	digest := sha256.Sum256(node)
+	return append([]byte{0x12, 0x20}, digest[:]...), nil
+}
+
+// Bzzr0Hash returns the swarm hash version 0 of data, the metadata hash of solidity compilers before version 0.5.12
+func Bzzr0Hash(data []byte) []byte {
+	const chunkSize = 0x1000
+	var span [8]byte
+	binary.LittleEndian.PutUint64(span[:], uint64(len(data)))
+	if len(data) <= chunkSize {
+		return crypto.Keccak256(span[:], data)
+	}
+
+	// the largest subtree size below the root, each intermediate chunk holds 128 hashes
+	subtree := chunkSize
+	for subtree*(chunkSize/32) < len(data) {
+		subtree *= chunkSize / 32
+	}
+	children := []byte{}
+	for i := 0; i < len(data); i += subtree {
+		end := i + subtree
+		if end > len(data) {
+			end = len(data)
+		}
+		children = append(children, Bzzr0Hash(data[i:end])...)
+	}
+	return crypto.Keccak256(span[:], children)
+}
+
+// Bzzr1Hash returns the swarm hash version 1 of data, the metadata hash of solidity compilers from version 0.5.12 to
+// 0.5.17. Chunks are zero padded to the chunk size and hashed as binary merkle trees, as solc does.
+func Bzzr1Hash(data []byte) []byte {
+	return bzzr1ChunkHash(data, false)
+}
+
+// bzzr1ChunkHash mirrors chunkHash of solc, which hashes a last subtree of exactly one chunk as an intermediate chunk
+// if the subtrees of its level span more than one chunk
+func bzzr1ChunkHash(data []byte, forceHigherLevel bool) []byte {
+	const chunkSize = 0x1000
+	var chunk []byte
+	if len(data) < chunkSize || (len(data) == chunkSize && !forceHigherLevel) {
+		chunk = append(chunk, data...)
+	} else {
+		subtree := chunkSize
+		for subtree*(chunkSize/32) < len(data) {
+			subtree *= chunkSize / 32
+		}
+		for i := 0; i < len(data); i += subtree {
+			end := i + subtree
+			if end > len(data) {
+				end = len(data)
+			}
+			chunk = append(chunk, bzzr1ChunkHash(data[i:end], subtree > chunkSize)...)
+		}
+	}
+	chunk = append(chunk, make([]byte, chunkSize-len(chunk))...)
+
+	var span [8]byte
+	binary.LittleEndian.PutUint64(span[:], uint64(len(data)))
+	return crypto.Keccak256(span[:], bmtHash(chunk))
+}
+
+// bmtHash returns the root of the binary merkle tree of keccak256 hashes over the 32 byte segments of data
+func bmtHash(data []byte) []byte {
+	if len(data) <= 64 {
+		return crypto.Keccak256(data)
+	}
+	return crypto.Keccak256(bmtHash(data[:len(data)/2]), bmtHash(data[len(data)/2:]))
+}
+
+// VerifyContract verifies the deployed runtime bytecode of a contract against compiler output without running the
+// compiler: the compiler metadata has to hash to the metadata hash embedded in the bytecode and the sources have to
+// hash to the source hashes listed in the metadata. The metadata is the passed one or the first one of the contracts of
+// the passed standard-JSON output that matches. It returns the name, ABI, compiler, metadata and sources of the contract.
+func VerifyContract(code []byte, request *types.ApiEth1VerifyContractRequest) (*types.ContractMetadata, error) {
+	kind, hash, err := ContractMetadataHash(code)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []string{}
+	if request.Metadata != "" {
+		candidates = append(candidates, request.Metadata)
+	}
+	if len(request.Output) > 0 {
+		output := struct {
+			Contracts map[string]map[string]struct {
+				Metadata string `json:"metadata"`
+			} `json:"contracts"`
+		}{}
+		if err := json.Unmarshal(request.Output, &output); err != nil {
+			return nil, fmt.Errorf("%w: invalid standard-JSON output: %v", ErrContractVerification, err)
+		}
+		for _, file := range output.Contracts {
+			for _, contract := range file {
+				if contract.Metadata != "" {
+					candidates = append(candidates, contract.Metadata)
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: no compiler metadata provided", ErrContractVerification)
+	}
+
+	var metadata []byte
+	for _, candidate := range candidates {
+		var candidateHash []byte
+		switch kind {
+		case "ipfs":
+			candidateHash, err = IpfsHash([]byte(candidate))
+			if err != nil {
+				continue
+			}
+		case "bzzr1":
+			candidateHash = Bzzr1Hash([]byte(candidate))
+		default:
+			candidateHash = Bzzr0Hash([]byte(candidate))
+		}
+		if bytes.Equal(candidateHash, hash) {
+			metadata = []byte(candidate)
+			break
+		}
+	}
+	if metadata == nil {
+		return nil, fmt.Errorf("%w: no compiler metadata matches the %v metadata hash 0x%x of the deployed code", ErrContractVerification, kind, hash)
+	}
+
+	parsed := &CompilerMetadata{}
+	if err := json.Unmarshal(metadata, parsed); err != nil {
+		return nil, fmt.Errorf("%w: invalid compiler metadata: %v", ErrContractVerification, err)
+	}
+
+	sources, err := verifiedSources(parsed, request.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAbi, err := abi.JSON(bytes.NewReader(parsed.Output.ABI))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid abi in the compiler metadata: %v", ErrContractVerification, err)
+	}
+	if len(request.ABI) > 0 {
+		var want, got interface{}
+		if json.Unmarshal(request.ABI, &want) != nil || json.Unmarshal(parsed.Output.ABI, &got) != nil || !reflect.DeepEqual(want, got) {
+			return nil, fmt.Errorf("%w: the provided abi differs from the abi of the compiler metadata", ErrContractVerification)
+		}
+	}
+
+	name := ""
+	for _, target := range parsed.Settings.CompilationTarget {
+		name = target
+	}
+
+	return &types.ContractMetadata{
+		Name:             name,
+		ABI:              &contractAbi,
+		ABIJson:          parsed.Output.ABI,
+		Compiler:         parsed.Compiler.Version,
+		CompilerMetadata: metadata,
+		Sources:          sources,
+	}, nil
+}
+
+// verifiedSources returns the sources listed in the compiler metadata, taken from the metadata itself or from the
+// standard-JSON input, after checking their hashes
+func verifiedSources(metadata *CompilerMetadata, input json.RawMessage) (map[string]string, error) {
+	provided := struct {
+		Sources map[string]struct {
+			Content string `json:"content"`
+		} `json:"sources"`
+	}{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &provided); err != nil {
+			return nil, fmt.Errorf("%w: invalid standard-JSON input: %v", ErrContractVerification, err)
+		}
+	}
+
+	sources := make(map[string]string, len(metadata.Sources))
+	missing := []string{}
+	for path, source := range metadata.Sources {
+		content := source.Content
+		if content == "" {
+			content = provided.Sources[path].Content
+		}
+		if content == "" {
+			missing = append(missing, path)
+			continue
+		}
+
+		want, err := hexutil.Decode(source.Keccak256)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid hash of source %v in the compiler metadata", ErrContractVerification, path)
+		}
+		if got := crypto.Keccak256([]byte(content)); !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("%w: source %v hashes to %v, the compiler metadata expects %v", ErrContractVerification, path, common.BytesToHash(got), source.Keccak256)
+		}
+		sources[path] = content
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: missing sources %v", ErrContractVerification, strings.Join(missing, ", "))
+	}
+	return sources, nil
+}
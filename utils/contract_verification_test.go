@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMetadataHashes(t *testing.T) {
+	tests := []struct {
+		name string
+		hash func() []byte
+		want string
+	}{
+		{name: "ipfs of empty file", hash: func() []byte { h, _ := IpfsHash(nil); return h }, want: "1220bfccda787baba32b59c78450ac3d20b633360b43992c77289f9ed46d843561e6"},
+		{name: "ipfs of hello world", hash: func() []byte { h, _ := IpfsHash([]byte("hello world\n")); return h }, want: "122046d44814b9c5af141c3aaab7c05dc5e844ead5f91f12858b021eba45768b4c0e"},
+		{name: "bzzr0 of empty file", hash: func() []byte { return Bzzr0Hash(nil) }, want: "011b4d03dd8c01f1049143cf9c4c817e4b167f1d1b83e5c6f0f10d89ba1e7bce"},
+		{name: "bzzr1 of empty file", hash: func() []byte { return Bzzr1Hash(nil) }, want: "b34ca8c22b9e982354f9c7f50b470d66db428d880c8a904d5fe4ec9713171526"},
+	}
+
+	for _, tt := range tests {
+		if got := hex.EncodeToString(tt.hash()); got != tt.want {
+			t.Errorf("%v = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// contractCode returns runtime bytecode ending with the CBOR encoded ipfs hash of metadata and a solc version
+func contractCode(t *testing.T, metadata string) []byte {
+	hash, err := IpfsHash([]byte(metadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailer := []byte{0xa2, 0x64, 'i', 'p', 'f', 's', 0x58, byte(len(hash))}
+	trailer = append(trailer, hash...)
+	trailer = append(trailer, 0x64, 's', 'o', 'l', 'c', 0x43, 0x00, 0x08, 0x13)
+	code := append([]byte{0x60, 0x80, 0x60, 0x40, 0x52, 0xfe}, trailer...)
+	return append(code, byte(len(trailer)>>8), byte(len(trailer)))
+}
+
+func TestVerifyContract(t *testing.T) {
+	source := "pragma solidity ^0.8.19;\ncontract Counter { uint256 public count; }\n"
+	contractAbi := `[{"inputs":[],"name":"count","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+	metadata := `{"compiler":{"version":"0.8.19+commit.7dd6d404"},"language":"Solidity","output":{"abi":` + contractAbi + `},` +
+		`"settings":{"compilationTarget":{"Counter.sol":"Counter"}},` +
+		`"sources":{"Counter.sol":{"keccak256":"` + "0x" + hex.EncodeToString(crypto.Keccak256([]byte(source))) + `"}},"version":1}`
+	code := contractCode(t, metadata)
+
+	kind, _, err := ContractMetadataHash(code)
+	if err != nil || kind != "ipfs" {
+		t.Fatalf("ContractMetadataHash = %v, %v, want ipfs", kind, err)
+	}
+
+	input, _ := json.Marshal(map[string]interface{}{"sources": map[string]interface{}{"Counter.sol": map[string]string{"content": source}}})
+	output, _ := json.Marshal(map[string]interface{}{"contracts": map[string]interface{}{"Counter.sol": map[string]interface{}{"Counter": map[string]string{"metadata": metadata}}}})
+
+	verified, err := VerifyContract(code, &types.ApiEth1VerifyContractRequest{Input: input, Output: output, ABI: json.RawMessage(contractAbi)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Name != "Counter" || verified.Compiler != "0.8.19+commit.7dd6d404" || verified.Sources["Counter.sol"] != source || verified.ABI.Methods["count"].Name != "count" {
+		t.Errorf("got contract %v compiled with %v, want Counter compiled with 0.8.19+commit.7dd6d404", verified.Name, verified.Compiler)
+	}
+
+	// solc 0.5.12 to 0.5.17 embed a bzzr1 hash of the metadata
+	bzzr1 := Bzzr1Hash([]byte(metadata))
+	trailer := append([]byte{0xa1, 0x65, 'b', 'z', 'z', 'r', '1', 0x58, byte(len(bzzr1))}, bzzr1...)
+	bzzr1Code := append(append([]byte{0x60, 0x80, 0x60, 0x40, 0x52, 0xfe}, trailer...), 0x00, byte(len(trailer)))
+	if verified, err := VerifyContract(bzzr1Code, &types.ApiEth1VerifyContractRequest{Metadata: metadata, Input: input}); err != nil || verified.Name != "Counter" {
+		t.Errorf("got contract %+v and error %v for bzzr1 code, want Counter", verified, err)
+	}
+
+	failures := []struct {
+		name    string
+		code    []byte
+		request *types.ApiEth1VerifyContractRequest
+	}{
+		{name: "missing source", code: code, request: &types.ApiEth1VerifyContractRequest{Metadata: metadata}},
+		{name: "tampered source", code: code, request: &types.ApiEth1VerifyContractRequest{Metadata: metadata, Input: json.RawMessage(`{"sources":{"Counter.sol":{"content":"contract Counter {}"}}}`)}},
+		{name: "other metadata", code: contractCode(t, "{}"), request: &types.ApiEth1VerifyContractRequest{Metadata: metadata, Input: input}},
+		{name: "other abi", code: code, request: &types.ApiEth1VerifyContractRequest{Metadata: metadata, Input: input, ABI: json.RawMessage(`[]`)}},
+		{name: "no metadata", code: code, request: &types.ApiEth1VerifyContractRequest{Input: input}},
+		{name: "code without metadata", code: []byte{0x60, 0x80, 0x60, 0x40, 0x52, 0x00, 0x00}, request: &types.ApiEth1VerifyContractRequest{Metadata: metadata, Input: input}},
+	}
+	for _, tt := range failures {
+		if _, err := VerifyContract(tt.code, tt.request); !errors.Is(err, ErrContractVerification) {
+			t.Errorf("%v: got error %v, want a contract verification error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/sha256"
+
+	"github.com/cloudflare/circl/sign/dilithium/mode5"
+)
+
+// DilithiumSignatureLength is the length of a dilithium signature of a zond transaction in bytes
+const DilithiumSignatureLength = mode5.SignatureSize
+
+// DilithiumPubkeyToAddress derives the execution layer address of a dilithium public key,
+// the address consists of the last AddressLength bytes of the sha256 of the key.
+func DilithiumPubkeyToAddress(pubkey []byte) Address {
+	h := sha256.Sum256(pubkey)
+	return BytesToAddress(h[:])
+}
+
+// VerifyDilithiumSignature returns true if signature is a valid dilithium signature of msg by pubkey
+func VerifyDilithiumSignature(pubkey, msg, signature []byte) bool {
+	if len(pubkey) != DilithiumPubkeyLength || len(signature) != DilithiumSignatureLength {
+		return false
+	}
+
+	var pk mode5.PublicKey
+	if err := pk.UnmarshalBinary(pubkey); err != nil {
+		return false
+	}
+	return mode5.Verify(&pk, msg, signature)
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/dilithium/mode5"
+)
+
+func TestVerifyDilithiumSignature(t *testing.T) {
+	pk, sk, err := mode5.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPk, _, err := mode5.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("zond transaction signing hash")
+	signature := make([]byte, mode5.SignatureSize)
+	mode5.SignTo(sk, msg, signature)
+
+	tests := []struct {
+		name      string
+		pubkey    []byte
+		msg       []byte
+		signature []byte
+		want      bool
+	}{
+		{
+			name:      "valid",
+			pubkey:    pk.Bytes(),
+			msg:       msg,
+			signature: signature,
+			want:      true,
+		},
+		{
+			name:      "other message",
+			pubkey:    pk.Bytes(),
+			msg:       []byte("another message"),
+			signature: signature,
+		},
+		{
+			name:      "other key",
+			pubkey:    otherPk.Bytes(),
+			msg:       msg,
+			signature: signature,
+		},
+		{
+			name:      "truncated signature",
+			pubkey:    pk.Bytes(),
+			msg:       msg,
+			signature: signature[:len(signature)-1],
+		},
+		{
+			name:      "truncated key",
+			pubkey:    pk.Bytes()[:BLSPubkeyLength],
+			msg:       msg,
+			signature: signature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDilithiumSignature(tt.pubkey, tt.msg, tt.signature); got != tt.want {
+				t.Errorf("VerifyDilithiumSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDilithiumPubkeyToAddress(t *testing.T) {
+	pk, _, err := mode5.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPk, _, err := mode5.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := DilithiumPubkeyToAddress(pk.Bytes())
+	if address != DilithiumPubkeyToAddress(pk.Bytes()) {
+		t.Errorf("DilithiumPubkeyToAddress() is not deterministic")
+	}
+	if address == DilithiumPubkeyToAddress(otherPk.Bytes()) {
+		t.Errorf("DilithiumPubkeyToAddress() returned the same address for different keys")
+	}
+	if address == (Address{}) {
+		t.Errorf("DilithiumPubkeyToAddress() returned the zero address")
+	}
+}
@@ -2,6 +2,12 @@ package utils
 
 import "github.com/ethereum/go-ethereum/common"
 
+// FixAddressCasing returns the checksummed, Z prefixed representation of an address.
+// Both Z and legacy 0x prefixed input is accepted.
 func FixAddressCasing(add string) string {
-	return common.HexToAddress(add).Hex()
+	a, err := ParseAddress(add)
+	if err != nil {
+		return BytesToAddress(common.FromHex(trimAddressPrefix(add))).String()
+	}
+	return a.String()
 }
@@ -129,22 +129,24 @@ func FormatAddressAll(address []byte, name string, isContract bool, link string,
 	return formatAddress(address, nil, name, isContract, link, urlFragment, digitsLimit, nameLimit, addCopyToClipboard)
 }
 
-// digitsLimit will limit the address output to that amount of total digits (including 0x & …)
+// digitsLimit will limit the address output to that amount of total digits (including the prefix & …)
 // nameLimit will limit the name, if existing to giving amount of letters, a limit of 0 will display the full name
 func formatAddress(address []byte, token []byte, name string, isContract bool, link string, urlFragment string, digitsLimit int, nameLimit int, addCopyToClipboard bool) template.HTML {
 	name = template.HTMLEscapeString(name)
 
-	// we need at least 5 digits for 0x & …
+	// we need at least 5 digits for the prefix & …
 	if digitsLimit < 5 {
 		digitsLimit = 5
 	}
 
 	// setting tooltip & limit name/address if necessary
 
+	// addresses are rendered with the Z prefix, anything else (e.g. builder pubkeys) stays 0x prefixed
 	addressString := fmt.Sprintf("0x%x", address)
-	if IsEth1Address(addressString) {
-		addressString = FixAddressCasing(addressString)
+	if len(address) == AddressLength {
+		addressString = FormatAddressString(address)
 	}
+	prefixLen := len(addressString) - len(address)*2
 	tooltip := ""
 	if len(name) == 0 { // no name set
 		tooltip = addressString
@@ -153,10 +155,10 @@ func formatAddress(address []byte, token []byte, name string, isContract bool, l
 		if l <= digitsLimit { // len inside digitsLimits, not much to do
 			name = addressString
 		} else { // reduce to digits limit
-			digitsLimit -= 5     // we will need 5 digits for 0x & …
-			name = addressString // get hex bytes as string
-			f := digitsLimit / 2 // as this int devision will always cut, we at an odd limit, we will have more digits at the end
-			name = fmt.Sprintf("%s…%s", name[:(f+2)], name[(l-(digitsLimit-f)+2):])
+			digitsLimit -= 3 + prefixLen // we will need digits for the prefix & …
+			name = addressString         // get hex bytes as string
+			f := digitsLimit / 2         // as this int devision will always cut, we at an odd limit, we will have more digits at the end
+			name = fmt.Sprintf("%s…%s", name[:(f+prefixLen)], name[(l-(digitsLimit-f)+prefixLen):])
 		}
 		name = fmt.Sprintf(`<span class="text-monospace">%s</span>`, name)
 	} else { // name set
@@ -179,9 +181,9 @@ func formatAddress(address []byte, token []byte, name string, isContract bool, l
 	} else {
 		// link & token
 		if token != nil {
-			ret += fmt.Sprintf(`<a href="/`+link+`/0x%x#erc20Txns" target="_parent" data-html="true" data-toggle="tooltip" data-placement="bottom" title="" data-original-title="%s">%s</a>`, address, tooltip, name)
+			ret += fmt.Sprintf(`<a href="/`+link+`/%s#erc20Txns" target="_parent" data-html="true" data-toggle="tooltip" data-placement="bottom" title="" data-original-title="%s">%s</a>`, addressString, tooltip, name)
 		} else { // just link
-			ret += fmt.Sprintf(`<a href="/`+link+`/0x%x`+urlFragment+`" target="_parent" data-html="true" data-toggle="tooltip" data-placement="bottom" title="" data-original-title="%s">%s</a>`, address, tooltip, name)
+			ret += fmt.Sprintf(`<a href="/`+link+`/%s`+urlFragment+`" target="_parent" data-html="true" data-toggle="tooltip" data-placement="bottom" title="" data-original-title="%s">%s</a>`, addressString, tooltip, name)
 		}
 	}
 
@@ -197,7 +199,7 @@ func formatAddress(address []byte, token []byte, name string, isContract bool, l
 func FormatAddressAsLink(address []byte, name string, verified bool, isContract bool) template.HTML {
 	ret := ""
 	name = template.HTMLEscapeString(name)
-	addressString := FixAddressCasing(fmt.Sprintf("%x", address))
+	addressString := FormatAddressString(address)
 
 	if len(name) > 0 {
 		if verified {
@@ -218,16 +220,17 @@ func FormatAddressAsLink(address []byte, name string, verified bool, isContract
 func FormatAddressAsTokenLink(token, address []byte, name string, verified bool, isContract bool) template.HTML {
 	ret := ""
 	name = template.HTMLEscapeString(name)
-	addressString := FixAddressCasing(fmt.Sprintf("%x", address))
+	addressString := FormatAddressString(address)
+	tokenString := FormatAddressString(token)
 
 	if len(name) > 0 {
 		if verified {
-			ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%x?a=%s\">✔ %s (%s…%s)</a> %v", token, addressString, name, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
+			ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%s?a=%s\">✔ %s (%s…%s)</a> %v", tokenString, addressString, name, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
 		} else {
-			ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%x?a=%s\">%s %s…%s</a> %v", token, addressString, name, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
+			ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%s?a=%s\">%s %s…%s</a> %v", tokenString, addressString, name, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
 		}
 	} else {
-		ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%x?a=%s\">%s…%s</a> %v", token, addressString, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
+		ret = fmt.Sprintf("<a class=\"text-monospace\" href=\"/token/%s?a=%s\">%s…%s</a> %v", tokenString, addressString, addressString[:8], addressString[len(addressString)-6:], CopyButton(addressString))
 	}
 
 	if isContract {
@@ -256,7 +259,7 @@ func FormatAddressLong(address string) template.HTML {
 	test := `
 	<span class="text-monospace mw-100"><span class="text-primary">%s</span><span class="text-truncate">%s</span><span class="text-primary">%s</span></span>`
 	if len(address) > 4 {
-		return template.HTML(fmt.Sprintf(test, address[:6], address[6:len(address)-4], address[len(address)-4:]))
+		return template.HTML(fmt.Sprintf(test, address[:5], address[5:len(address)-4], address[len(address)-4:]))
 	}
 
 	return template.HTML(address)
@@ -18,7 +18,6 @@ import (
 	"github.com/prysmaticlabs/go-bitfield"
 	"github.com/shopspring/decimal"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -386,18 +385,17 @@ func FormatEpoch(epoch uint64) template.HTML {
 
 // FormatEth1AddressString will return the eth1-address formated as html string
 func FormatEth1AddressString(addr []byte) template.HTML {
-	eth1Addr := common.BytesToAddress(addr)
-	return template.HTML(eth1Addr.Hex())
+	return template.HTML(FormatAddressString(addr))
 }
 
 // FormatEth1AddressString will return the eth1-address formated as html string
 func FormatEth1AddressStringLowerCase(addr []byte) template.HTML {
-	return template.HTML(fmt.Sprintf("0x%x", addr))
+	return template.HTML(fmt.Sprintf("%s%x", AddressPrefix, addr))
 }
 
 // FormatEth1Address will return the eth1-address formated as html
 func FormatEth1Address(addr []byte) template.HTML {
-	eth1Addr := FixAddressCasing(fmt.Sprintf("%x", addr))
+	eth1Addr := FormatAddressString(addr)
 	copyBtn := CopyButton(eth1Addr)
 	return template.HTML(fmt.Sprintf("<a href=\"/address/%s\" class=\"text-monospace\">%s…</a>%s", eth1Addr, eth1Addr[:8], copyBtn))
 }
@@ -525,7 +523,7 @@ func FormatWithdawalCredentials(hash []byte, addCopyButton bool) template.HTML {
 
 	var text template.HTML
 	if hash[0] == 0x01 {
-		text = template.HTML(fmt.Sprintf("<a href=\"/address/0x%x\">%s</a>", hash[12:], formatWithdrawalHash(hash)))
+		text = template.HTML(fmt.Sprintf("<a href=\"/address/%s\">%s</a>", FormatAddressString(hash[12:]), formatWithdrawalHash(hash)))
 	} else {
 		text = formatWithdrawalHash(hash)
 	}
@@ -566,19 +564,27 @@ func AddCopyButton(element template.HTML, copyContent string) template.HTML {
 
 func CopyButton(clipboardText interface{}) string {
 	value := fmt.Sprintf("%v", clipboardText)
-	if len(value) < 2 || value[0] != '0' || value[1] != 'x' {
+	if !hasClipboardPrefix(value) {
 		value = "0x" + value
 	}
 	return fmt.Sprintf(`<i class="fa fa-copy text-muted text-white ml-2 p-1" style="opacity: .8;" role="button" data-toggle="tooltip" title="Copy to clipboard" data-clipboard-text=%s></i>`, value)
 }
 
+// hasClipboardPrefix returns true if the value already starts with a 0x or Z (address) prefix
+func hasClipboardPrefix(value string) bool {
+	if len(value) == AddressLength*2+len(AddressPrefix) && strings.HasPrefix(value, AddressPrefix) {
+		return true
+	}
+	return len(value) >= 2 && value[0] == '0' && value[1] == 'x'
+}
+
 func CopyButtonText(clipboardText interface{}) string {
 	return fmt.Sprintf(`<i class="fa fa-copy text-muted ml-2 p-1" role="button" data-toggle="tooltip" title="Copy to clipboard" data-clipboard-text=%v></i>`, clipboardText)
 }
 
 func CopyButtonWithTitle(clipboardText interface{}, title string) string {
 	value := fmt.Sprintf("%v", clipboardText)
-	if len(value) < 2 || value[0] != '0' || value[1] != 'x' {
+	if !hasClipboardPrefix(value) {
 		value = "0x" + value
 	}
 	return fmt.Sprintf(`<i class="fa fa-copy text-muted ml-2 p-1" role="button" data-toggle="tooltip" title="%v" data-clipboard-text=%s></i>`, title, value)
@@ -784,7 +790,7 @@ func FormatPercentageWithGPrecision(percentage float64, precision int) string {
 func FormatPublicKey(validator []byte) template.HTML {
 	copyBtn := CopyButton(hex.EncodeToString(validator))
 	// return template.HTML(fmt.Sprintf("<i class=\"fas fa-male\"></i> <a href=\"/validator/0x%x\">%v</a>", validator, FormatHash(validator)))
-	return template.HTML(fmt.Sprintf(`<i class="fas fa-male mr-2"></i><a style="font-family: 'Roboto Mono'" href="/validator/0x%x">0x%v…</a>%v`, validator, hex.EncodeToString(validator)[:6], copyBtn))
+	return template.HTML(fmt.Sprintf(`<i class="fas fa-male mr-2"></i><a style="font-family: 'Roboto Mono'" href="/validator/%s">0x%v…</a>%v`, FormatPubkeyURLParam(validator), hex.EncodeToString(validator)[:6], copyBtn))
 }
 
 func FormatMachineName(machineName string) template.HTML {
@@ -919,9 +925,8 @@ func FormatValidatorWithName(validator interface{}, name string) template.HTML {
 }
 
 func FormatEth1AddressWithName(address []byte, name string) template.HTML {
-	eth1Addr := common.BytesToAddress(address)
 	if name != "" {
-		return template.HTML(fmt.Sprintf("<a href=\"/address/0x%x\" class=\"text-monospace\">%s</a>", eth1Addr, name))
+		return template.HTML(fmt.Sprintf("<a href=\"/address/%s\" class=\"text-monospace\">%s</a>", FormatAddressString(address), name))
 	} else {
 		return FormatEth1Address(address)
 	}
@@ -1207,7 +1212,7 @@ func FormatTokenName(balance *types.Eth1AddressBalance) template.HTML {
 	}
 	symbolTitle := FormatTokenSymbolTitle(balance.Metadata.Symbol)
 	symbol := FormatTokenSymbol(balance.Metadata.Symbol)
-	return template.HTML(fmt.Sprintf(`<a href='/token/0x%x?a=0x%x' title="%s">%s %s</a>`, balance.Token, balance.Address, symbolTitle, logo, symbol))
+	return template.HTML(fmt.Sprintf(`<a href='/token/%s?a=%s' title="%s">%s %s</a>`, FormatAddressString(balance.Token), FormatAddressString(balance.Address), symbolTitle, logo, symbol))
 }
 
 // func ToBase64(input []byte) string {
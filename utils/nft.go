@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// InlineNFTMetadata returns the JSON metadata embedded in a token uri, either as the uri itself or as a data: uri with
+// a base64 or percent encoded payload. ok is false if the uri points to metadata stored elsewhere or does not embed
+// valid JSON.
+func InlineNFTMetadata(uri string) (metadata []byte, ok bool) {
+	uri = strings.TrimSpace(uri)
+
+	if strings.HasPrefix(uri, "{") {
+		metadata = []byte(uri)
+	} else if len(uri) > 5 && strings.EqualFold(uri[:5], "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 {
+			return nil, false
+		}
+		header, payload := uri[5:comma], uri[comma+1:]
+
+		var err error
+		if strings.HasSuffix(strings.ToLower(header), ";base64") {
+			metadata, err = base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				metadata, err = base64.RawStdEncoding.DecodeString(payload)
+			}
+		} else {
+			var unescaped string
+			unescaped, err = url.PathUnescape(payload)
+			metadata = []byte(unescaped)
+		}
+		if err != nil {
+			return nil, false
+		}
+	} else {
+		return nil, false
+	}
+
+	metadata = bytes.TrimSpace(metadata)
+	if !json.Valid(metadata) {
+		return nil, false
+	}
+	return metadata, true
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestInlineNFTMetadata(t *testing.T) {
+	metadata := `{"name":"Token #42","image":"ipfs://image"}`
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: metadata, want: metadata},
+		{uri: "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(metadata)), want: metadata},
+		{uri: "data:application/json;base64," + base64.RawStdEncoding.EncodeToString([]byte(metadata)), want: metadata},
+		{uri: "data:application/json;utf8," + metadata, want: metadata},
+		{uri: "data:application/json,%7B%22name%22%3A%22Token%20%2342%22%7D", want: `{"name":"Token #42"}`},
+		{uri: "ipfs://QmHash/42.json"},
+		{uri: "https://example.com/token/42"},
+		{uri: "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte("<svg></svg>"))},
+		{uri: "data:application/json;base64,not base64"},
+		{uri: "{not json"},
+		{uri: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := InlineNFTMetadata(tt.uri)
+		if ok != (tt.want != "") || string(got) != tt.want {
+			t.Errorf("InlineNFTMetadata(%q) = %q, %v, want %q", tt.uri, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Signature schemes a chain can use for its validator keys
+const (
+	SignatureSchemeBLS       = "bls"
+	SignatureSchemeDilithium = "dilithium"
+)
+
+// Public key lengths (in bytes) of the supported signature schemes
+const (
+	BLSPubkeyLength       = 48
+	DilithiumPubkeyLength = 2592
+)
+
+// PubkeyHashLength is the length of the hashed short form of a validator public key
+const PubkeyHashLength = sha256.Size
+
+// SignatureScheme returns the validator signature scheme of the configured chain
+func SignatureScheme() string {
+	if Config == nil || Config.Chain.SignatureScheme == "" {
+		return SignatureSchemeDilithium
+	}
+	return Config.Chain.SignatureScheme
+}
+
+// PubkeyLength returns the length of a validator public key of the configured chain
+func PubkeyLength() int {
+	return PubkeyLengthForScheme(SignatureScheme())
+}
+
+// PubkeyLengthForScheme returns the validator public key length of a signature scheme
+func PubkeyLengthForScheme(scheme string) int {
+	switch scheme {
+	case SignatureSchemeBLS:
+		return BLSPubkeyLength
+	default:
+		return DilithiumPubkeyLength
+	}
+}
+
+// IsValidSignatureScheme returns true if the scheme is supported
+func IsValidSignatureScheme(scheme string) bool {
+	return scheme == SignatureSchemeBLS || scheme == SignatureSchemeDilithium
+}
+
+// PubkeyHash returns the hashed short form of a validator public key. Dilithium keys are
+// too long to be used in urls, so validators are referenced by the sha256 of their key instead.
+func PubkeyHash(pubkey []byte) []byte {
+	h := sha256.Sum256(pubkey)
+	return h[:]
+}
+
+// FormatPubkeyURLParam returns the representation of a validator public key used in urls,
+// the full key for bls chains and the hashed short form for chains with longer keys.
+func FormatPubkeyURLParam(pubkey []byte) string {
+	if len(pubkey) > BLSPubkeyLength {
+		return fmt.Sprintf("0x%x", PubkeyHash(pubkey))
+	}
+	return fmt.Sprintf("0x%x", pubkey)
+}
+
+// ParseValidatorPubkey parses a 0x prefixed (or unprefixed) validator public key of the configured
+// chain or its hashed short form. isHash reports whether the hashed short form was passed.
+func ParseValidatorPubkey(s string) (key []byte, isHash bool, err error) {
+	raw := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	key, err = hex.DecodeString(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid validator public key %q: %w", s, err)
+	}
+
+	switch len(key) {
+	case PubkeyLength():
+		return key, false, nil
+	case PubkeyHashLength:
+		return key, true, nil
+	default:
+		return nil, false, fmt.Errorf("invalid validator public key length %d, expected %d bytes or a %d byte hash", len(key), PubkeyLength(), PubkeyHashLength)
+	}
+}
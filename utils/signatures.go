@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// signatureList holds the text signatures of common functions and events, in the format read by ParseSignatures
+//
+//go:embed signatures.txt
+var signatureList string
+
+// signatureNameRE matches the identifiers solidity allows as function and event names
+var signatureNameRE = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+var embeddedSignatures struct {
+	once       sync.Once
+	bySelector map[string][]*types.Eth1Signature
+}
+
+// EmbeddedSignatures returns the signatures of the embedded signature list with the passed selector
+func EmbeddedSignatures(selector []byte) []*types.Eth1Signature {
+	embeddedSignatures.once.Do(func() {
+		signatures, err := ParseSignatures(strings.NewReader(signatureList))
+		if err != nil {
+			LogError(err, "error parsing embedded signature list", 0)
+		}
+		embeddedSignatures.bySelector = make(map[string][]*types.Eth1Signature, len(signatures))
+		for _, signature := range signatures {
+			embeddedSignatures.bySelector[string(signature.Selector)] = append(embeddedSignatures.bySelector[string(signature.Selector)], signature)
+		}
+	})
+	return embeddedSignatures.bySelector[string(selector)]
+}
+
+// ParseSignatures reads a list of text signatures, one per line. Event signatures are prefixed with "event ", empty
+// lines and lines starting with # are skipped, e.g.
+//
+//	transfer(address,uint256)
+//	event Transfer(address,address,uint256)
+func ParseSignatures(r io.Reader) ([]*types.Eth1Signature, error) {
+	signatures := []*types.Eth1Signature{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		event := strings.HasPrefix(text, "event ")
+		text = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(text, "event "), "function "))
+
+		signature, err := NewSignature(text, event)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature in line %v: %w", line, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, scanner.Err()
+}
+
+// NewSignature validates a canonical text signature of a function or an event and computes its selector
+func NewSignature(signature string, event bool) (*types.Eth1Signature, error) {
+	if event {
+		e, err := signatureEvent(signature, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Eth1Signature{Selector: e.ID.Bytes(), Signature: signature, Event: true}, nil
+	}
+	method, err := signatureMethod(signature)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Eth1Signature{Selector: method.ID, Signature: signature}, nil
+}
+
+// AbiSignatures returns the signatures of the functions and the events of the abi. Anonymous events have no selector and
+// signatures which aren't valid solidity signatures are skipped.
+func AbiSignatures(contractAbi *abi.ABI) []*types.Eth1Signature {
+	signatures := make([]*types.Eth1Signature, 0, len(contractAbi.Methods)+len(contractAbi.Events))
+	for _, method := range contractAbi.Methods {
+		if signature, err := NewSignature(method.Sig, false); err == nil {
+			signatures = append(signatures, signature)
+		}
+	}
+	for _, event := range contractAbi.Events {
+		if event.Anonymous {
+			continue
+		}
+		if signature, err := NewSignature(event.Sig, true); err == nil {
+			signatures = append(signatures, signature)
+		}
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		return signatures[i].Signature < signatures[j].Signature
+	})
+	return signatures
+}
+
+// SignatureName returns the name of the first signature, ambiguous is set if the signatures have different names
+func SignatureName(signatures []*types.Eth1Signature) (name string, ambiguous bool) {
+	for _, signature := range signatures {
+		n := signature.Signature[:strings.Index(signature.Signature, "(")]
+		if name == "" {
+			name = n
+		} else if n != name {
+			ambiguous = true
+		}
+	}
+	return name, ambiguous
+}
+
+// DecodeCallDataWithSignatures decodes call data with the signatures of its selector in the order passed. Only
+// signatures whose encoding of the decoded arguments reproduces the data are considered, if several of them do the
+// result is flagged as ambiguous. Returns nil if no signature matches.
+func DecodeCallDataWithSignatures(signatures []*types.Eth1Signature, data []byte) *types.Eth1DecodedCall {
+	if len(data) < 4 {
+		return nil
+	}
+
+	var decoded *types.Eth1DecodedCall
+	for _, signature := range signatures {
+		if signature.Event || !bytes.Equal(signature.Selector, data[:4]) {
+			continue
+		}
+		method, err := signatureMethod(signature.Signature)
+		if err != nil {
+			continue
+		}
+		values, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		if packed, err := method.Inputs.Pack(values...); err != nil || !bytes.Equal(packed, data[4:]) {
+			continue
+		}
+
+		if decoded != nil {
+			decoded.Ambiguous = true
+			decoded.Alternatives = append(decoded.Alternatives, signature.Signature)
+			continue
+		}
+		decoded, err = DecodeCallData(&abi.ABI{Methods: map[string]abi.Method{method.Name: *method}}, data)
+		if err != nil {
+			decoded = nil
+			continue
+		}
+		decoded.Guessed = true
+	}
+	return decoded
+}
+
+// DecodeLogWithSignatures decodes an event log with the signatures of its first topic in the order passed. Text
+// signatures don't tell which parameters are indexed, the leading parameters are assumed to be indexed as the log has
+// topics. Only signatures whose encoding reproduces the data are considered, if several of them do the result is
+// flagged as ambiguous. Returns nil if no signature matches.
+func DecodeLogWithSignatures(signatures []*types.Eth1Signature, topics [][]byte, data []byte) *types.Eth1DecodedEvent {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	var decoded *types.Eth1DecodedEvent
+	for _, signature := range signatures {
+		if !signature.Event || !bytes.Equal(signature.Selector, topics[0]) {
+			continue
+		}
+		event, err := signatureEvent(signature.Signature, len(topics)-1)
+		if err != nil {
+			continue
+		}
+		values, err := event.Inputs.NonIndexed().Unpack(data)
+		if err != nil {
+			continue
+		}
+		if packed, err := event.Inputs.NonIndexed().Pack(values...); err != nil || !bytes.Equal(packed, data) {
+			continue
+		}
+
+		if decoded != nil {
+			decoded.Ambiguous = true
+			decoded.Alternatives = append(decoded.Alternatives, signature.Signature)
+			continue
+		}
+		decoded, err = DecodeLog(&abi.ABI{Events: map[string]abi.Event{event.Name: *event}}, topics, data)
+		if err != nil {
+			decoded = nil
+			continue
+		}
+		decoded.Guessed = true
+	}
+	return decoded
+}
+
+// signatureMethod returns the method of a canonical function signature
+func signatureMethod(signature string) (*abi.Method, error) {
+	name, inputs, err := parseSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	method := abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, inputs, nil)
+	if method.Sig != signature {
+		return nil, fmt.Errorf("signature %v is not canonical, expected %v", signature, method.Sig)
+	}
+	return &method, nil
+}
+
+// signatureEvent returns the event of a canonical event signature with the passed number of leading indexed parameters
+func signatureEvent(signature string, indexed int) (*abi.Event, error) {
+	name, inputs, err := parseSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	if indexed > len(inputs) {
+		return nil, fmt.Errorf("event %v has %v parameters, not %v indexed ones", signature, len(inputs), indexed)
+	}
+	for i := 0; i < indexed; i++ {
+		inputs[i].Indexed = true
+	}
+	event := abi.NewEvent(name, name, false, inputs)
+	if event.Sig != signature {
+		return nil, fmt.Errorf("signature %v is not canonical, expected %v", signature, event.Sig)
+	}
+	return &event, nil
+}
+
+// parseSignature splits a text signature into its name and its parameters, the parameters are named arg<i>
+func parseSignature(signature string) (string, abi.Arguments, error) {
+	open := strings.Index(signature, "(")
+	if open < 1 || !strings.HasSuffix(signature, ")") || !signatureNameRE.MatchString(signature[:open]) || strings.ContainsAny(signature, " \t") {
+		return "", nil, fmt.Errorf("invalid signature %q", signature)
+	}
+
+	components, err := parseSignatureTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid signature %q: %w", signature, err)
+	}
+	inputs := make(abi.Arguments, 0, len(components))
+	for _, component := range components {
+		typ, err := abi.NewType(component.Type, "", component.Components)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid signature %q: %w", signature, err)
+		}
+		inputs = append(inputs, abi.Argument{Name: component.Name, Type: typ})
+	}
+	return signature[:open], inputs, nil
+}
+
+// parseSignatureTypes parses a comma separated list of types, tuples are written as parenthesized lists of types
+func parseSignatureTypes(list string) ([]abi.ArgumentMarshaling, error) {
+	components := []abi.ArgumentMarshaling{}
+	if list == "" {
+		return components, nil
+	}
+
+	depth, start := 0, 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("unbalanced parentheses")
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if depth != 0 {
+			return nil, fmt.Errorf("unbalanced parentheses")
+		}
+
+		typ := list[start:i]
+		component := abi.ArgumentMarshaling{Name: fmt.Sprintf("arg%d", len(components)), Type: typ}
+		if strings.HasPrefix(typ, "(") {
+			end := strings.LastIndex(typ, ")")
+			inner, err := parseSignatureTypes(typ[1:end])
+			if err != nil {
+				return nil, err
+			}
+			component.Type = "tuple" + typ[end+1:]
+			component.Components = inner
+		}
+		components = append(components, component)
+		start = i + 1
+	}
+	return components, nil
+}
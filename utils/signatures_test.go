@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Prajjawalk/zond-indexer/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseSignatures(t *testing.T) {
+	signatures, err := ParseSignatures(strings.NewReader(signatureList))
+	if err != nil {
+		t.Fatalf("embedded signature list: %v", err)
+	}
+	if len(signatures) < 50 {
+		t.Errorf("got %v embedded signatures, want at least 50", len(signatures))
+	}
+
+	transfer := EmbeddedSignatures(common.FromHex("0xa9059cbb"))
+	if len(transfer) != 1 || transfer[0].Signature != "transfer(address,uint256)" || transfer[0].Event {
+		t.Errorf("got signatures %+v for 0xa9059cbb, want transfer(address,uint256)", transfer)
+	}
+	event := EmbeddedSignatures(common.FromHex("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"))
+	if len(event) != 1 || event[0].Signature != "Transfer(address,address,uint256)" || !event[0].Event {
+		t.Errorf("got signatures %+v for the Transfer topic, want Transfer(address,address,uint256)", event)
+	}
+
+	signatures, err = ParseSignatures(strings.NewReader("# comment\n\nfunction aggregate((address,bytes)[])\nevent  Sync(uint112,uint112)\n"))
+	if err != nil || len(signatures) != 2 || signatures[0].Signature != "aggregate((address,bytes)[])" || !signatures[1].Event {
+		t.Errorf("got signatures %+v and error %v, want aggregate and the Sync event", signatures, err)
+	}
+
+	for _, invalid := range []string{"transfer", "transfer(address,uint)", "transfer(address, uint256)", "transfer(address,foo)", "aggregate((address,bytes[])", "()", "<b>(uint256)"} {
+		if _, err := ParseSignatures(strings.NewReader(invalid)); err == nil {
+			t.Errorf("got no error for signature %q", invalid)
+		}
+	}
+}
+
+func TestDecodeWithSignatures(t *testing.T) {
+	burn, err := NewSignature("burn(uint256)", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a well known collision with the selector of burn(uint256)
+	collision, err := NewSignature("collate_propagate_storage(bytes16)", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(burn.Selector, collision.Selector) {
+		t.Fatalf("got selectors %x and %x, want a collision", burn.Selector, collision.Selector)
+	}
+	signatures := []*types.Eth1Signature{burn, collision}
+
+	// a small amount isn't a valid bytes16 value, its padding is on the wrong side
+	data := append(append([]byte{}, burn.Selector...), common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)...)
+	decoded := DecodeCallDataWithSignatures(signatures, data)
+	if decoded == nil || decoded.Name != "burn" || !decoded.Guessed || decoded.Ambiguous || decoded.Params[0].Value != "1000" {
+		t.Errorf("got %+v, want an unambiguous burn of 1000", decoded)
+	}
+
+	data = append(append([]byte{}, burn.Selector...), common.RightPadBytes([]byte{0x01}, 32)...)
+	decoded = DecodeCallDataWithSignatures(signatures, data)
+	if decoded == nil || decoded.Name != "burn" || !decoded.Ambiguous || len(decoded.Alternatives) != 1 || decoded.Alternatives[0] != collision.Signature {
+		t.Errorf("got %+v, want an ambiguous burn with the colliding alternative", decoded)
+	}
+	if name, ambiguous := SignatureName(signatures); name != "burn" || !ambiguous {
+		t.Errorf("got name %v ambiguous %v, want an ambiguous burn", name, ambiguous)
+	}
+
+	if decoded := DecodeCallDataWithSignatures(signatures, burn.Selector); decoded != nil {
+		t.Errorf("got %+v for call data without arguments, want nil", decoded)
+	}
+
+	contractAbi, err := abi.JSON(strings.NewReader(erc20TestAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abiSignatures := AbiSignatures(&contractAbi)
+	if len(abiSignatures) != 2 || abiSignatures[0].Signature != "Transfer(address,address,uint256)" || !abiSignatures[0].Event || abiSignatures[1].Signature != "transfer(address,uint256)" {
+		t.Fatalf("got abi signatures %+v, want the Transfer event and transfer", abiSignatures)
+	}
+
+	from := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	to := common.HexToAddress("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359")
+	topics := [][]byte{abiSignatures[0].Selector, common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)}
+	event := DecodeLogWithSignatures(abiSignatures, topics, common.LeftPadBytes(big.NewInt(1000).Bytes(), 32))
+	if event == nil || event.Name != "Transfer" || !event.Guessed || len(event.Params) != 3 || !event.Params[1].Indexed || event.Params[2].Value != "1000" {
+		t.Errorf("got %+v, want the Transfer of 1000 with indexed addresses", event)
+	}
+	// the erc721 Transfer event has the same selector with the token id as third topic
+	if event := DecodeLogWithSignatures(abiSignatures, append(topics, common.LeftPadBytes([]byte{7}, 32)), nil); event == nil || event.Params[2].Value != "7" || !event.Params[2].Indexed {
+		t.Errorf("got %+v, want the Transfer of token 7", event)
+	}
+	if event := DecodeLogWithSignatures(abiSignatures, topics, []byte{0x01}); event != nil {
+		t.Errorf("got %+v for malformed log data, want nil", event)
+	}
+}
@@ -36,7 +36,6 @@ import (
 
 // Config is the globally accessible configuration
 var Config *types.Config
-var eth1AddressRE = regexp.MustCompile("^(0x)?[0-9a-fA-F]{40}$")
 var ErrRateLimit = errors.New("## RATE LIMIT ##")
 
 func readConfigEnv(cfg *types.Config) error {
@@ -68,6 +67,8 @@ func ReadConfig(cfg *types.Config, path string) error {
 			err = yaml.Unmarshal([]byte(config.MainnetChainYml), &cfg.Chain.Config)
 		case "prater":
 			err = yaml.Unmarshal([]byte(config.PraterChainYml), &cfg.Chain.Config)
+		case "zond-devnet":
+			err = yaml.Unmarshal([]byte(config.ZondDevnetChainYml), &cfg.Chain.Config)
 		// case "ropsten":
 		// 	err = yaml.Unmarshal([]byte(config.RopstenChainYml), &cfg.Chain.Config)
 		// case "sepolia":
@@ -103,6 +104,14 @@ func ReadConfig(cfg *types.Config, path string) error {
 	}
 	cfg.Chain.Name = cfg.Chain.Config.ConfigName
 
+	// the deposit chain id also partitions the stored data, a missing or made up value mixes the data of networks
+	if cfg.Chain.Config.DepositChainID == 0 || cfg.Chain.Config.DepositContractAddress == "" {
+		return fmt.Errorf("chain config %v defines no deposit chain id or deposit contract address, provide the config of the network via chain.configPath", cfg.Chain.Config.ConfigName)
+	}
+	if _, err := ParseAddress(cfg.Chain.Config.DepositContractAddress); err != nil {
+		return fmt.Errorf("invalid deposit contract address in chain config %v: %w", cfg.Chain.Config.ConfigName, err)
+	}
+
 	if cfg.Chain.GenesisTimestamp == 0 {
 		switch cfg.Chain.Name {
 		case "mainnet":
@@ -115,6 +124,8 @@ func ReadConfig(cfg *types.Config, path string) error {
 			cfg.Chain.GenesisTimestamp = 1675263600
 		case "gnosis":
 			cfg.Chain.GenesisTimestamp = 1638993340
+		case "zond-devnet":
+			cfg.Chain.GenesisTimestamp = uint64(cfg.Chain.Config.MinGenesisTime) + cfg.Chain.Config.GenesisDelay
 		default:
 			return fmt.Errorf("tried to set known genesis-timestamp, but unknown chain-name")
 		}
@@ -132,6 +143,8 @@ func ReadConfig(cfg *types.Config, path string) error {
 			cfg.Chain.GenesisValidatorsRoot = "0x53a92d8f2bb1d85f62d16a156e6ebcd1bcaba652d0900b2c2f387826f3481f6f"
 		case "gnosis":
 			cfg.Chain.GenesisValidatorsRoot = "0xf5dcb5564e829aab27264b9becd5dfaa017085611224cb3036f573368dbb9d47"
+		case "zond-devnet":
+			// the root depends on the genesis state of the devnet, it can be set via genesisValidatorsRoot if required
 		default:
 			return fmt.Errorf("tried to set known genesis-validators-root, but unknown chain-name")
 		}
@@ -144,6 +157,18 @@ func ReadConfig(cfg *types.Config, path string) error {
 		cfg.Chain.DomainVoluntaryExit = "0x04000000"
 	}
 
+	if cfg.Chain.SignatureScheme == "" {
+		switch cfg.Chain.Name {
+		case "mainnet", "prater", "sepolia", "zhejiang", "gnosis":
+			cfg.Chain.SignatureScheme = SignatureSchemeBLS
+		default:
+			cfg.Chain.SignatureScheme = SignatureSchemeDilithium
+		}
+	}
+	if !IsValidSignatureScheme(cfg.Chain.SignatureScheme) {
+		return fmt.Errorf("unknown signature scheme %v, supported schemes are %v and %v", cfg.Chain.SignatureScheme, SignatureSchemeBLS, SignatureSchemeDilithium)
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"genesisTimestamp":       cfg.Chain.GenesisTimestamp,
 		"genesisValidatorsRoot":  cfg.Chain.GenesisValidatorsRoot,
@@ -151,6 +176,7 @@ func ReadConfig(cfg *types.Config, path string) error {
 		"depositChainID":         cfg.Chain.Config.DepositChainID,
 		"depositNetworkID":       cfg.Chain.Config.DepositNetworkID,
 		"depositContractAddress": cfg.Chain.Config.DepositContractAddress,
+		"signatureScheme":        cfg.Chain.SignatureScheme,
 	}).Infof("did init config")
 
 	return nil
@@ -242,15 +268,25 @@ func logErrorInfo(err error, callerSkip int, additionalInfos ...string) *logrus.
 	return logFields
 }
 
-// IsEth1Address verifies whether a string represents an eth1-address. In contrast to IsValidEth1Address, this also returns true for the 0x0 address
+// IsEth1Address verifies whether a string represents an eth1-address with a Z, 0x or no prefix. In contrast to IsValidEth1Address, this also returns true for the zero address
 func IsEth1Address(s string) bool {
-	return eth1AddressRE.MatchString(s)
+	return IsAddress(s)
 }
 
 func ExchangeRateForCurrency(currency string) float64 {
 	return price.GetEthPrice(currency)
 }
 
+// addressColumns are the BYTEA columns SqlRowsToJSON renders as Z prefixed addresses
+var addressColumns = map[string]bool{
+	"address":            true,
+	"from_address":       true,
+	"to_address":         true,
+	"exec_fee_recipient": true,
+	"fee_recipient":      true,
+	"feerecipient":       true,
+}
+
 func SqlRowsToJSON(rows *sql.Rows) ([]interface{}, error) {
 	columnTypes, err := rows.ColumnTypes()
 
@@ -307,7 +343,9 @@ func SqlRowsToJSON(rows *sql.Rows) ([]interface{}, error) {
 			if z, ok := (scanArgs[i]).(*sql.NullString); ok {
 				if z.Valid {
 					if v.DatabaseTypeName() == "BYTEA" {
-						if len(z.String) > 0 {
+						if addressColumns[v.Name()] && len(z.String) == AddressLength {
+							masterData[v.Name()] = FormatAddressString([]byte(z.String))
+						} else if len(z.String) > 0 {
 							masterData[v.Name()] = "0x" + hex.EncodeToString([]byte(z.String))
 						} else {
 							masterData[v.Name()] = nil
@@ -584,7 +622,7 @@ func GraffitiToSring(graffiti []byte) string {
 
 // AddressToWithdrawalCredentials converts a valid address to withdrawalCredentials
 func AddressToWithdrawalCredentials(address []byte) ([]byte, error) {
-	if IsValidEth1Address(fmt.Sprintf("%#x", address)) {
+	if len(address) == AddressLength && !BytesToAddress(address).IsZero() {
 		credentials := make([]byte, 12, 32)
 		credentials[0] = 0x01
 		credentials = append(credentials, address...)
@@ -614,9 +652,9 @@ var withdrawalCredentialsAddressRE = regexp.MustCompile("^(0x)?01000000000000000
 var eth1TxRE = regexp.MustCompile("^(0x)?[0-9a-fA-F]{64}$")
 var zeroHashRE = regexp.MustCompile("^(0x)?0+$")
 
-// IsValidEth1Address verifies whether a string represents a valid eth1-address.
+// IsValidEth1Address verifies whether a string represents a valid (non zero) eth1-address with a Z, 0x or no prefix.
 func IsValidEth1Address(s string) bool {
-	return !zeroHashRE.MatchString(s) && eth1AddressRE.MatchString(s)
+	return IsValidAddress(s)
 }
 
 // IsValidEth1Tx verifies whether a string represents a valid eth1-tx-hash.